@@ -0,0 +1,36 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+// The account name does not follow the naming policy.
+var ErrInvalidAccountName = errors.New("Invalid account name")
+
+// ValidateEmail is an account name validator accepting a bare email
+// address such as alice@example.com.
+func ValidateEmail(name string) error {
+	addr, err := mail.ParseAddress(name)
+	if err != nil || addr.Address != name || addr.Name != "" {
+		return fmt.Errorf("%w: %q is not an email address", ErrInvalidAccountName, name)
+	}
+	return nil
+}
+
+// ValidateUsername is an account name validator accepting 1 to 64 ASCII
+// letters, digits, dots, underscores and hyphens.
+func ValidateUsername(name string) error {
+	if len(name) == 0 || len(name) > 64 {
+		return fmt.Errorf("%w: %q must be 1 to 64 characters", ErrInvalidAccountName, name)
+	}
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidAccountName, name, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountNameValidators(t *testing.T) {
+	require.NoError(t, ValidateEmail("alice@example.com"))
+	for _, name := range []string{"alice", "Alice <alice@example.com>", "alice@example.com ", ""} {
+		require.True(t, errors.Is(ValidateEmail(name), ErrInvalidAccountName), name)
+	}
+
+	require.NoError(t, ValidateUsername("alice.smith_2-b"))
+	for _, name := range []string{"", "alice smith", "alice@example.com", "ålice", string(make([]byte, 65))} {
+		require.True(t, errors.Is(ValidateUsername(name), ErrInvalidAccountName), name)
+	}
+}
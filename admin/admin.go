@@ -0,0 +1,290 @@
+// Package admin implements the management operations of an OTP
+// deployment: listing the keys a user enrolled, with their secrets
+// redacted, revoking a key, forcing a user to enroll again and suspending
+// OTP for an account for a while. Every change emits an audit Event.
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// OTP is suspended for the account.
+var ErrSuspended = errors.New("OTP suspended for the account")
+
+// The account must enroll a new key before validating codes.
+var ErrReenrollRequired = errors.New("Re-enrollment required")
+
+// Actions of audit Events.
+const (
+	ActionRevoke   = "revoke"
+	ActionReenroll = "force-reenroll"
+	ActionSuspend  = "suspend"
+	ActionResume   = "resume"
+)
+
+// State is the OTP state of an account.
+type State struct {
+	// SuspendedUntil is the end of a suspension, zero when not suspended.
+	SuspendedUntil time.Time
+	// ReenrollRequired is true after ForceReenrollment, until the user
+	// enrolls a new key.
+	ReenrollRequired bool
+}
+
+// Registry is the storage of the keys and states of accounts.
+type Registry interface {
+	// Keys returns the keys of user by key id.
+	Keys(ctx context.Context, user string) (map[string]*otp.Key, error)
+	// DeleteKey removes a key of user. A missing key is reported with an
+	// error matching otp.ErrKeyNotFound.
+	DeleteKey(ctx context.Context, user, keyID string) error
+	// State returns the state of user; the zero State when none was set.
+	State(ctx context.Context, user string) (State, error)
+	// SetState replaces the state of user.
+	SetState(ctx context.Context, user string, state State) error
+}
+
+// Event is an audit record of an administrative change.
+type Event struct {
+	Time time.Time
+	// Actor is the administrator who made the change.
+	Actor string
+	// User whose account changed.
+	User   string
+	Action string
+	// KeyID of a revoked key.
+	KeyID  string
+	Reason string
+}
+
+// KeyInfo describes an enrolled key without its secret.
+type KeyInfo struct {
+	ID          string
+	Type        string
+	Issuer      string
+	AccountName string
+	Algorithm   otp.Algorithm
+	Digits      otp.Digits
+	Period      uint64
+	// Fingerprint identifies the secret without revealing it, see
+	// otp.SecretFingerprint.
+	Fingerprint string
+}
+
+// Service runs administrative operations.
+type Service struct {
+	reg   Registry
+	audit func(ctx context.Context, e Event) error
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+// New creates a Service on reg, calling audit with every change. An audit
+// error is returned by the operation, which has been applied.
+func New(reg Registry, audit func(ctx context.Context, e Event) error) *Service {
+	return &Service{reg: reg, audit: audit}
+}
+
+// ListKeys returns the keys of user, sorted by id, with their secrets
+// redacted.
+func (s *Service) ListKeys(ctx context.Context, user string) ([]KeyInfo, error) {
+	keys, err := s.reg.Keys(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for id, k := range keys {
+		infos = append(infos, KeyInfo{
+			ID:          id,
+			Type:        k.Type(),
+			Issuer:      k.Issuer(),
+			AccountName: k.AccountName(),
+			Algorithm:   k.Algorithm(),
+			Digits:      k.Digits(),
+			Period:      k.Period(),
+			Fingerprint: otp.SecretFingerprint(k.Secret()),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+
+	return infos, nil
+}
+
+// Revoke removes a key of user.
+func (s *Service) Revoke(ctx context.Context, actor, user, keyID, reason string) error {
+	if err := s.reg.DeleteKey(ctx, user, keyID); err != nil {
+		return err
+	}
+	return s.emit(ctx, Event{Actor: actor, User: user, Action: ActionRevoke, KeyID: keyID, Reason: reason})
+}
+
+// ForceReenrollment removes every key of user and requires a new
+// enrollment, eg after a device was lost.
+func (s *Service) ForceReenrollment(ctx context.Context, actor, user, reason string) error {
+	keys, err := s.reg.Keys(ctx, user)
+	if err != nil {
+		return err
+	}
+	for id := range keys {
+		if err := s.reg.DeleteKey(ctx, user, id); err != nil && !errors.Is(err, otp.ErrKeyNotFound) {
+			return err
+		}
+	}
+
+	err = s.update(ctx, user, func(st *State) {
+		st.ReenrollRequired = true
+	})
+	if err != nil {
+		return err
+	}
+	return s.emit(ctx, Event{Actor: actor, User: user, Action: ActionReenroll, Reason: reason})
+}
+
+// Suspend disables OTP for user for d, eg while an incident is
+// investigated.
+func (s *Service) Suspend(ctx context.Context, actor, user string, d time.Duration, reason string) error {
+	until := s.clock().Add(d)
+	err := s.update(ctx, user, func(st *State) {
+		st.SuspendedUntil = until
+	})
+	if err != nil {
+		return err
+	}
+	return s.emit(ctx, Event{Actor: actor, User: user, Action: ActionSuspend, Reason: reason})
+}
+
+// Resume ends a suspension of user early.
+func (s *Service) Resume(ctx context.Context, actor, user, reason string) error {
+	err := s.update(ctx, user, func(st *State) {
+		st.SuspendedUntil = time.Time{}
+	})
+	if err != nil {
+		return err
+	}
+	return s.emit(ctx, Event{Actor: actor, User: user, Action: ActionResume, Reason: reason})
+}
+
+// Enrolled clears the re-enrollment requirement of user, once a new key
+// is enrolled.
+func (s *Service) Enrolled(ctx context.Context, user string) error {
+	return s.update(ctx, user, func(st *State) {
+		st.ReenrollRequired = false
+	})
+}
+
+// CheckAccount reports whether user may validate codes now: it fails with
+// an error matching ErrSuspended or ErrReenrollRequired. Call it before
+// validating a code.
+func (s *Service) CheckAccount(ctx context.Context, user string) error {
+	st, err := s.reg.State(ctx, user)
+	if err != nil {
+		return err
+	}
+	if now := s.clock(); now.Before(st.SuspendedUntil) {
+		return fmt.Errorf("%w until %v", ErrSuspended, st.SuspendedUntil)
+	}
+	if st.ReenrollRequired {
+		return ErrReenrollRequired
+	}
+	return nil
+}
+
+// update changes the state of user with fn.
+func (s *Service) update(ctx context.Context, user string, fn func(st *State)) error {
+	st, err := s.reg.State(ctx, user)
+	if err != nil {
+		return err
+	}
+	fn(&st)
+	return s.reg.SetState(ctx, user, st)
+}
+
+// emit sends an audit Event.
+func (s *Service) emit(ctx context.Context, e Event) error {
+	if s.audit == nil {
+		return nil
+	}
+	e.Time = s.clock()
+	return s.audit(ctx, e)
+}
+
+// clock returns the current time.
+func (s *Service) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// MemoryRegistry is a Registry held in memory, suitable for tests.
+// The zero value is ready to use.
+type MemoryRegistry struct {
+	mu     sync.Mutex
+	keys   map[string]map[string]*otp.Key
+	states map[string]State
+}
+
+// AddKey enrolls key for user under keyID.
+func (r *MemoryRegistry) AddKey(user, keyID string, key *otp.Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys == nil {
+		r.keys = map[string]map[string]*otp.Key{}
+	}
+	if r.keys[user] == nil {
+		r.keys[user] = map[string]*otp.Key{}
+	}
+	r.keys[user][keyID] = key.Clone()
+}
+
+// Keys implements Registry.
+func (r *MemoryRegistry) Keys(ctx context.Context, user string) (map[string]*otp.Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make(map[string]*otp.Key, len(r.keys[user]))
+	for id, k := range r.keys[user] {
+		keys[id] = k.Clone()
+	}
+	return keys, nil
+}
+
+// DeleteKey implements Registry.
+func (r *MemoryRegistry) DeleteKey(ctx context.Context, user, keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[user][keyID]; !ok {
+		return otp.ErrKeyNotFound
+	}
+	delete(r.keys[user], keyID)
+	return nil
+}
+
+// State implements Registry.
+func (r *MemoryRegistry) State(ctx context.Context, user string) (State, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.states[user], nil
+}
+
+// SetState implements Registry.
+func (r *MemoryRegistry) SetState(ctx context.Context, user string, state State) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.states == nil {
+		r.states = map[string]State{}
+	}
+	r.states[user] = state
+	return nil
+}
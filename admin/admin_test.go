@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(t *testing.T) (*Service, *MemoryRegistry, *[]Event, *time.Time) {
+	reg := &MemoryRegistry{}
+	for _, id := range []string{"phone", "laptop"} {
+		k, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+		require.NoError(t, err)
+		reg.AddKey("alice", id, k)
+	}
+
+	var events []Event
+	s := New(reg, func(ctx context.Context, e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	return s, reg, &events, &now
+}
+
+func TestListKeys(t *testing.T) {
+	s, _, _, _ := newService(t)
+
+	infos, err := s.ListKeys(context.Background(), "alice")
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	require.Equal(t, "laptop", infos[0].ID)
+	require.Equal(t, "Example", infos[0].Issuer)
+	require.Len(t, infos[0].Fingerprint, 16)
+	require.False(t, strings.Contains(infos[0].Fingerprint, "JBSW"))
+}
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	s, _, events, now := newService(t)
+
+	require.NoError(t, s.Revoke(ctx, "admin", "alice", "phone", "lost"))
+	infos, err := s.ListKeys(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+
+	require.True(t, errors.Is(s.Revoke(ctx, "admin", "alice", "phone", "lost"), otp.ErrKeyNotFound))
+	require.Equal(t, []Event{{Time: *now, Actor: "admin", User: "alice", Action: ActionRevoke, KeyID: "phone", Reason: "lost"}}, *events)
+}
+
+func TestForceReenrollment(t *testing.T) {
+	ctx := context.Background()
+	s, _, events, _ := newService(t)
+
+	require.NoError(t, s.ForceReenrollment(ctx, "admin", "alice", "device stolen"))
+	infos, err := s.ListKeys(ctx, "alice")
+	require.NoError(t, err)
+	require.Empty(t, infos)
+	require.True(t, errors.Is(s.CheckAccount(ctx, "alice"), ErrReenrollRequired))
+	require.Equal(t, ActionReenroll, (*events)[0].Action)
+
+	require.NoError(t, s.Enrolled(ctx, "alice"))
+	require.NoError(t, s.CheckAccount(ctx, "alice"))
+}
+
+func TestSuspend(t *testing.T) {
+	ctx := context.Background()
+	s, _, events, now := newService(t)
+
+	require.NoError(t, s.Suspend(ctx, "admin", "alice", time.Hour, "investigation"))
+	require.True(t, errors.Is(s.CheckAccount(ctx, "alice"), ErrSuspended))
+
+	*now = now.Add(2 * time.Hour)
+	require.NoError(t, s.CheckAccount(ctx, "alice"), "the suspension expired")
+
+	require.NoError(t, s.Suspend(ctx, "admin", "alice", time.Hour, "investigation"))
+	require.NoError(t, s.Resume(ctx, "admin", "alice", "cleared"))
+	require.NoError(t, s.CheckAccount(ctx, "alice"))
+
+	require.Len(t, *events, 3)
+	require.Equal(t, ActionResume, (*events)[2].Action)
+}
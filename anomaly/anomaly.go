@@ -0,0 +1,261 @@
+// Package anomaly analyzes validation failures for the patterns of brute
+// force campaigns against OTP endpoints, and reports them as structured
+// events.
+package anomaly
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp/events"
+)
+
+// Kind is the pattern of an Anomaly.
+type Kind string
+
+// The detected patterns.
+const (
+	// Velocity is a user failing more often than Config.MaxFailures.
+	Velocity Kind = "velocity"
+	// Distributed is a user failing from more sources than
+	// Config.MaxSources, as in a botnet guessing one account.
+	Distributed Kind = "distributed"
+	// Sequential is a user submitting consecutive codes, eg 123456 then
+	// 123457, as a guessing script does.
+	Sequential Kind = "sequential"
+	// Spray is a source failing against more users than
+	// Config.MaxUsersPerSource.
+	Spray Kind = "spray"
+)
+
+// Attempt is a validation seen by the Detector.
+type Attempt struct {
+	Time time.Time
+	User string
+	// Source of the attempt, eg the client IP.
+	Source string
+	// Passcode submitted, only used to detect sequential guessing. It is
+	// never part of an Anomaly.
+	Passcode string
+	Valid    bool
+}
+
+// Anomaly is a detected pattern.
+type Anomaly struct {
+	Kind Kind
+	Time time.Time
+	// User is empty for Spray anomalies.
+	User string
+	// Source is empty for Velocity, Distributed and Sequential anomalies.
+	Source string
+	// Count of failures, sources, codes or users that triggered the anomaly.
+	Count int
+}
+
+// Event returns the anomaly as an events.Anomaly event.
+func (a Anomaly) Event() events.Event {
+	attrs := map[string]string{"kind": string(a.Kind), "count": strconv.Itoa(a.Count)}
+	if a.Source != "" {
+		attrs["source"] = a.Source
+	}
+	return events.Event{Type: events.Anomaly, Time: a.Time, User: a.User, Attrs: attrs}
+}
+
+// Config are the thresholds of a Detector. Zero fields take the defaults.
+type Config struct {
+	// Window over which failures are counted. Defaults to 10 minutes.
+	Window time.Duration
+	// MaxFailures of a user within Window. Defaults to 10.
+	MaxFailures int
+	// MaxSources a user fails from within Window. Defaults to 5.
+	MaxSources int
+	// SequentialRun is the number of consecutive codes reported as
+	// Sequential. Defaults to 3.
+	SequentialRun int
+	// MaxUsersPerSource a source fails against within Window. Defaults
+	// to 10.
+	MaxUsersPerSource int
+}
+
+func (c *Config) defaults() {
+	if c.Window == 0 {
+		c.Window = 10 * time.Minute
+	}
+	if c.MaxFailures == 0 {
+		c.MaxFailures = 10
+	}
+	if c.MaxSources == 0 {
+		c.MaxSources = 5
+	}
+	if c.SequentialRun == 0 {
+		c.SequentialRun = 3
+	}
+	if c.MaxUsersPerSource == 0 {
+		c.MaxUsersPerSource = 10
+	}
+}
+
+// sweepEvery is the number of attempts between sweeps of idle state.
+const sweepEvery = 1024
+
+// Detector analyzes attempts. An anomaly is reported once per Window for
+// the same kind and user or source, so a campaign does not flood the
+// callback. A Detector is safe for concurrent use.
+type Detector struct {
+	cfg  Config
+	emit func(a Anomaly)
+
+	mu       sync.Mutex
+	users    map[string]*userState
+	sources  map[string]*sourceState
+	reported map[reportKey]time.Time
+	seen     int
+}
+
+type userState struct {
+	failures []failure
+	// last failed code and the length of the run of consecutive codes
+	// ending with it.
+	last int64
+	run  int
+}
+
+type failure struct {
+	time   time.Time
+	source string
+}
+
+type sourceState struct {
+	// last failure against each user.
+	users map[string]time.Time
+}
+
+type reportKey struct {
+	kind Kind
+	name string
+}
+
+// NewDetector creates a Detector calling emit with every anomaly. emit is
+// called with the Detector locked and must not call Observe.
+func NewDetector(cfg Config, emit func(a Anomaly)) *Detector {
+	cfg.defaults()
+	return &Detector{
+		cfg:      cfg,
+		emit:     emit,
+		users:    make(map[string]*userState),
+		sources:  make(map[string]*sourceState),
+		reported: make(map[reportKey]time.Time),
+	}
+}
+
+// Observe analyzes an attempt.
+func (d *Detector) Observe(a Attempt) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seen++
+	if d.seen%sweepEvery == 0 {
+		d.sweep(a.Time)
+	}
+
+	u := d.users[a.User]
+	if a.Valid {
+		if u != nil {
+			u.run = 0
+		}
+		return
+	}
+	if u == nil {
+		u = &userState{}
+		d.users[a.User] = u
+	}
+
+	since := a.Time.Add(-d.cfg.Window)
+	u.failures = append(prune(u.failures, since), failure{a.Time, a.Source})
+
+	if len(u.failures) > d.cfg.MaxFailures {
+		d.report(Anomaly{Kind: Velocity, Time: a.Time, User: a.User, Count: len(u.failures)}, a.User)
+	}
+
+	sources := make(map[string]bool)
+	for _, f := range u.failures {
+		sources[f.source] = true
+	}
+	if len(sources) > d.cfg.MaxSources {
+		d.report(Anomaly{Kind: Distributed, Time: a.Time, User: a.User, Count: len(sources)}, a.User)
+	}
+
+	if code, err := strconv.ParseInt(a.Passcode, 10, 64); err == nil {
+		if u.run > 0 && (code == u.last+1 || code == u.last-1) {
+			u.run++
+		} else {
+			u.run = 1
+		}
+		u.last = code
+		if u.run >= d.cfg.SequentialRun {
+			d.report(Anomaly{Kind: Sequential, Time: a.Time, User: a.User, Count: u.run}, a.User)
+		}
+	}
+
+	if a.Source != "" {
+		s := d.sources[a.Source]
+		if s == nil {
+			s = &sourceState{users: make(map[string]time.Time)}
+			d.sources[a.Source] = s
+		}
+		s.users[a.User] = a.Time
+		for user, t := range s.users {
+			if t.Before(since) {
+				delete(s.users, user)
+			}
+		}
+		if len(s.users) > d.cfg.MaxUsersPerSource {
+			d.report(Anomaly{Kind: Spray, Time: a.Time, Source: a.Source, Count: len(s.users)}, a.Source)
+		}
+	}
+}
+
+// report emits a unless the same anomaly was reported within Window.
+func (d *Detector) report(a Anomaly, name string) {
+	key := reportKey{a.Kind, name}
+	if t, ok := d.reported[key]; ok && a.Time.Sub(t) < d.cfg.Window {
+		return
+	}
+	d.reported[key] = a.Time
+	d.emit(a)
+}
+
+// sweep drops the state of users and sources idle for a Window.
+func (d *Detector) sweep(now time.Time) {
+	since := now.Add(-d.cfg.Window)
+	for name, u := range d.users {
+		if u.failures = prune(u.failures, since); len(u.failures) == 0 {
+			delete(d.users, name)
+		}
+	}
+	for name, s := range d.sources {
+		for user, t := range s.users {
+			if t.Before(since) {
+				delete(s.users, user)
+			}
+		}
+		if len(s.users) == 0 {
+			delete(d.sources, name)
+		}
+	}
+	for key, t := range d.reported {
+		if t.Before(since) {
+			delete(d.reported, key)
+		}
+	}
+}
+
+// prune drops the failures before since.
+func prune(failures []failure, since time.Time) []failure {
+	i := 0
+	for i < len(failures) && failures[i].time.Before(since) {
+		i++
+	}
+	return failures[i:]
+}
@@ -0,0 +1,92 @@
+package anomaly
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/events"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder []Anomaly
+
+func (r *recorder) emit(a Anomaly) {
+	*r = append(*r, a)
+}
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestVelocity(t *testing.T) {
+	var got recorder
+	d := NewDetector(Config{MaxFailures: 3}, got.emit)
+
+	for i := 0; i < 6; i++ {
+		d.Observe(Attempt{Time: t0.Add(time.Duration(i) * time.Second), User: "alice", Source: "10.0.0.1", Passcode: "111111"})
+	}
+	require.Len(t, got, 1)
+	require.Equal(t, Anomaly{Kind: Velocity, Time: t0.Add(3 * time.Second), User: "alice", Count: 4}, got[0])
+
+	// Failures older than the window are forgotten.
+	got = nil
+	d.Observe(Attempt{Time: t0.Add(time.Hour), User: "alice", Passcode: "111111"})
+	require.Empty(t, got)
+}
+
+func TestDistributed(t *testing.T) {
+	var got recorder
+	d := NewDetector(Config{MaxSources: 2}, got.emit)
+
+	for i := 0; i < 3; i++ {
+		d.Observe(Attempt{Time: t0, User: "alice", Source: fmt.Sprintf("10.0.0.%d", i), Passcode: "111111"})
+	}
+	require.Len(t, got, 1)
+	require.Equal(t, Distributed, got[0].Kind)
+	require.Equal(t, 3, got[0].Count)
+}
+
+func TestSequential(t *testing.T) {
+	var got recorder
+	d := NewDetector(Config{}, got.emit)
+
+	d.Observe(Attempt{Time: t0, User: "alice", Passcode: "000099"})
+	d.Observe(Attempt{Time: t0, User: "alice", Passcode: "000100"})
+	// A success interrupts the run.
+	d.Observe(Attempt{Time: t0, User: "alice", Valid: true})
+	d.Observe(Attempt{Time: t0, User: "alice", Passcode: "000101"})
+	d.Observe(Attempt{Time: t0, User: "alice", Passcode: "000102"})
+	require.Empty(t, got)
+
+	d.Observe(Attempt{Time: t0, User: "alice", Passcode: "000103"})
+	require.Len(t, got, 1)
+	require.Equal(t, Sequential, got[0].Kind)
+	require.Equal(t, 3, got[0].Count)
+}
+
+func TestSpray(t *testing.T) {
+	var got recorder
+	d := NewDetector(Config{MaxUsersPerSource: 2}, got.emit)
+
+	for i := 0; i < 3; i++ {
+		d.Observe(Attempt{Time: t0, User: fmt.Sprintf("user%d", i), Source: "10.0.0.1", Passcode: "111111"})
+	}
+	require.Len(t, got, 1)
+	require.Equal(t, Anomaly{Kind: Spray, Time: t0, Source: "10.0.0.1", Count: 3}, got[0])
+
+	e := got[0].Event()
+	require.Equal(t, events.Anomaly, e.Type)
+	require.Equal(t, map[string]string{"kind": "spray", "count": "3", "source": "10.0.0.1"}, e.Attrs)
+}
+
+func TestSweep(t *testing.T) {
+	d := NewDetector(Config{}, func(Anomaly) {})
+	for i := 0; i < sweepEvery; i++ {
+		d.Observe(Attempt{Time: t0, User: fmt.Sprint(i), Source: fmt.Sprint(i), Passcode: "111111"})
+	}
+	d.Observe(Attempt{Time: t0.Add(time.Hour), User: "alice", Valid: true})
+	for i := 0; i < sweepEvery-1; i++ {
+		d.Observe(Attempt{Time: t0.Add(time.Hour), User: "alice", Valid: true})
+	}
+	require.Empty(t, d.users)
+	require.Empty(t, d.sources)
+}
@@ -0,0 +1,191 @@
+// Package auditlog writes an append-only, tamper-evident log of audit
+// records, eg validations or admin.Event values, and verifies it.
+//
+// Every record commits to the previous one through a hash chain, so
+// modifying, removing or reordering a record breaks every following hash.
+// Truncating the end of the log is only detected against a head recorded
+// elsewhere, and anyone able to rewrite the file can recompute a plain
+// chain: keep the head (Writer.Head) in a separate system, or chain with a
+// secret key (WithKey) held by the writer and the auditors only.
+package auditlog
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrTampered is matched by the *ChainError of a log that was modified.
+var ErrTampered = errors.New("Audit log chain is broken")
+
+// ChainError reports the first record of a log that fails verification.
+type ChainError struct {
+	// Seq of the record, or of the record expected at this position.
+	Seq    uint64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%v at record %d: %s", ErrTampered, e.Seq, e.Reason)
+}
+
+// Is reports whether target is ErrTampered.
+func (e *ChainError) Is(target error) bool {
+	return target == ErrTampered
+}
+
+// Record is a line of the log.
+type Record struct {
+	// Seq numbers the records from 1.
+	Seq  uint64          `json:"seq"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+	// Prev is the Hash of the previous record, empty for the first one.
+	Prev string `json:"prev"`
+	// Hash commits to the fields above.
+	Hash string `json:"hash"`
+}
+
+// sum returns the hash of r chained with key, nil for a plain SHA-256.
+func (r *Record) sum(key []byte) string {
+	var h hash.Hash
+	if key != nil {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+
+	var n [8]byte
+	field := func(b []byte) {
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	binary.BigEndian.PutUint64(n[:], r.Seq)
+	h.Write(n[:])
+	field([]byte(r.Time.UTC().Format(time.RFC3339Nano)))
+	field(r.Data)
+	field([]byte(r.Prev))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Writer appends records to a log. It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	key  []byte
+	seq  uint64
+	head string
+	now  func() time.Time
+}
+
+// WriterOpt configures a Writer.
+type WriterOpt func(w *Writer)
+
+// WithKey chains the records with HMAC-SHA256 under key instead of a plain
+// SHA-256, so the chain cannot be recomputed without the key.
+func WithKey(key []byte) WriterOpt {
+	return func(w *Writer) {
+		w.key = key
+	}
+}
+
+// WithHead continues an existing log whose last record has seq and hash,
+// as returned by Verify.
+func WithHead(seq uint64, hash string) WriterOpt {
+	return func(w *Writer) {
+		w.seq, w.head = seq, hash
+	}
+}
+
+// NewWriter creates a Writer appending JSON lines to w.
+func NewWriter(w io.Writer, opts ...WriterOpt) *Writer {
+	lw := &Writer{enc: json.NewEncoder(w)}
+	for _, opt := range opts {
+		opt(lw)
+	}
+	return lw
+}
+
+func (w *Writer) clock() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
+
+// Append writes v, encoded as JSON, as the next record. The head only
+// advances when the record was written, so a failed write can be retried.
+func (w *Writer) Append(v interface{}) (Record, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Record{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	r := Record{
+		Seq:  w.seq + 1,
+		Time: w.clock().UTC(),
+		Data: data,
+		Prev: w.head,
+	}
+	r.Hash = r.sum(w.key)
+
+	if err := w.enc.Encode(&r); err != nil {
+		return Record{}, err
+	}
+	w.seq, w.head = r.Seq, r.Hash
+	return r, nil
+}
+
+// Head returns the sequence number and hash of the last record written.
+func (w *Writer) Head() (uint64, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seq, w.head
+}
+
+// Verify reads a log from r and checks its chain, with key when it was
+// written WithKey. It returns the sequence number and hash of the last
+// record, to compare with a head kept elsewhere or to continue the log
+// WithHead. A broken chain fails with a *ChainError.
+func Verify(r io.Reader, key []byte) (uint64, string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+
+	var seq uint64
+	var head string
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return seq, head, &ChainError{Seq: seq + 1, Reason: err.Error()}
+		}
+
+		switch {
+		case rec.Seq != seq+1:
+			return seq, head, &ChainError{Seq: seq + 1, Reason: fmt.Sprintf("found record %d", rec.Seq)}
+		case rec.Prev != head:
+			return seq, head, &ChainError{Seq: rec.Seq, Reason: "previous hash mismatch"}
+		case !hmac.Equal([]byte(rec.sum(key)), []byte(rec.Hash)):
+			return seq, head, &ChainError{Seq: rec.Seq, Reason: "hash mismatch"}
+		}
+		seq, head = rec.Seq, rec.Hash
+	}
+	return seq, head, sc.Err()
+}
@@ -0,0 +1,82 @@
+package auditlog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	User   string `json:"user"`
+	Action string `json:"action"`
+}
+
+func writeLog(t *testing.T, key []byte) (*bytes.Buffer, *Writer) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithKey(key))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	for _, action := range []string{"enroll", "validate", "revoke <all>"} {
+		_, err := w.Append(event{User: "alice", Action: action})
+		require.NoError(t, err)
+	}
+	return &buf, w
+}
+
+func TestVerify(t *testing.T) {
+	for _, key := range [][]byte{nil, []byte("audit key")} {
+		buf, w := writeLog(t, key)
+
+		seq, head, err := Verify(bytes.NewReader(buf.Bytes()), key)
+		require.NoError(t, err)
+		wseq, whead := w.Head()
+		require.Equal(t, uint64(3), seq)
+		require.Equal(t, wseq, seq)
+		require.Equal(t, whead, head)
+	}
+
+	// The wrong key breaks the chain.
+	buf, _ := writeLog(t, []byte("audit key"))
+	_, _, err := Verify(buf, []byte("other key"))
+	require.True(t, errors.Is(err, ErrTampered))
+}
+
+func TestVerifyTampered(t *testing.T) {
+	buf, _ := writeLog(t, nil)
+	lines := strings.SplitAfter(strings.TrimSpace(buf.String()), "\n")
+
+	cases := map[string]string{
+		"modified":  strings.Replace(buf.String(), `"validate"`, `"revoke"`, 1),
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[0] + strings.TrimSpace(lines[2]) + "\n" + lines[1],
+	}
+	for name, log := range cases {
+		_, _, err := Verify(strings.NewReader(log), nil)
+		var cerr *ChainError
+		require.True(t, errors.As(err, &cerr), name)
+		require.Equal(t, uint64(2), cerr.Seq, name)
+	}
+}
+
+func TestWithHead(t *testing.T) {
+	buf, _ := writeLog(t, nil)
+	seq, head, err := Verify(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+
+	w := NewWriter(buf, WithHead(seq, head))
+	r, err := w.Append(event{User: "bob", Action: "enroll"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), r.Seq)
+	require.Equal(t, head, r.Prev)
+
+	seq, _, err = Verify(buf, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), seq)
+}
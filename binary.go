@@ -0,0 +1,197 @@
+package otp
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// binaryKeyVersion is the first byte of the binary form of a Key. Version
+// 2 added the metadata; version 1 is still decoded.
+const binaryKeyVersion = 2
+
+// Types in the binary form of a Key.
+const (
+	binaryTypeTOTP = 1
+	binaryTypeHOTP = 2
+)
+
+// Flags recording which optional parameters the binary form carries.
+const (
+	binaryHasDigits = 1 << iota
+	binaryHasAlgorithm
+	binaryHasPeriod
+	binaryHasCounter
+)
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MarshalBinary implements encoding.BinaryMarshaler with a compact,
+// versioned encoding, for storing keys in key-value stores more cheaply
+// than as URLs. The secret is stored as raw bytes, so only keys whose
+// parameters are valid can be encoded.
+//
+// The format is a version byte followed by the type, a flags byte, the
+// algorithm and digits, the period or counter as a uvarint, and then the
+// secret, issuer, label, any other URL parameters and the metadata, each
+// prefixed with its uvarint length.
+func (k *Key) MarshalBinary() ([]byte, error) {
+	ks := k.load()
+
+	kc, err := ks.codeParams()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ks.params
+
+	typ := byte(binaryTypeTOTP)
+	moving := kc.period
+	var flags byte
+	if p.period != "" {
+		flags |= binaryHasPeriod
+	}
+	if kc.period == 0 {
+		typ = binaryTypeHOTP
+		moving = kc.counter
+		flags = 0
+		if p.counter != "" {
+			flags |= binaryHasCounter
+		}
+	}
+	if p.digits != "" {
+		flags |= binaryHasDigits
+	}
+	if p.algorithm != "" {
+		flags |= binaryHasAlgorithm
+	}
+
+	var extra string
+	if p.extra != nil {
+		extra = p.extra.Encode()
+	}
+	var meta string
+	if ks.meta != nil {
+		v := make(url.Values, len(ks.meta))
+		for name, value := range ks.meta {
+			v.Set(name, value)
+		}
+		meta = v.Encode()
+	}
+	label := strings.TrimPrefix(ks.path, "/")
+
+	fields := []string{string(kc.key), p.issuer, label, extra, meta}
+
+	b := make([]byte, 0, 5+6*binary.MaxVarintLen64+len(kc.key)+len(p.issuer)+len(label)+len(extra)+len(meta))
+	b = append(b, binaryKeyVersion, typ, flags, byte(kc.algorithm), byte(kc.digits))
+	b = appendUvarint(b, moving)
+	for _, field := range fields {
+		b = appendUvarint(b, uint64(len(field)))
+		b = append(b, field...)
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The key's URL is
+// in the canonical form described by Canonicalize.
+func (k *Key) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 || data[0] < 1 || data[0] > binaryKeyVersion {
+		return ErrInvalidBinaryKey
+	}
+
+	// Version 1 has no metadata field.
+	fields := make([]string, 5)
+	if data[0] == 1 {
+		fields = fields[:4]
+	}
+
+	typ, flags := data[1], data[2]
+	algorithm, digits := Algorithm(data[3]), Digits(data[4])
+	data = data[5:]
+
+	moving, n := binary.Uvarint(data)
+	if n <= 0 {
+		return ErrInvalidBinaryKey
+	}
+	data = data[n:]
+
+	for i := range fields {
+		l, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < l {
+			return ErrInvalidBinaryKey
+		}
+		fields[i] = string(data[n : n+int(l)])
+		data = data[n+int(l):]
+	}
+	if len(data) != 0 {
+		return ErrInvalidBinaryKey
+	}
+
+	ks := &keyState{
+		scheme: "otpauth",
+		path:   "/" + fields[2],
+		params: keyParams{
+			secret: b32NoPadding.EncodeToString([]byte(fields[0])),
+			issuer: fields[1],
+		},
+	}
+	p := &ks.params
+
+	switch typ {
+	case binaryTypeTOTP:
+		ks.typ = "totp"
+		if flags&binaryHasPeriod != 0 {
+			p.period = strconv.FormatUint(moving, 10)
+		}
+	case binaryTypeHOTP:
+		ks.typ = "hotp"
+		if flags&binaryHasCounter != 0 {
+			p.counter = strconv.FormatUint(moving, 10)
+		}
+	default:
+		return ErrInvalidBinaryKey
+	}
+
+	if flags&binaryHasDigits != 0 {
+		if err := digits.Check(); err != nil {
+			return err
+		}
+		p.digits = digits.String()
+	}
+	if flags&binaryHasAlgorithm != 0 {
+		if err := algorithm.Check(); err != nil {
+			return err
+		}
+		p.algorithm = algorithm.String()
+	}
+
+	if fields[3] != "" {
+		extra, err := url.ParseQuery(fields[3])
+		if err != nil {
+			return ErrInvalidBinaryKey
+		}
+		p.extra = extra
+	}
+
+	if len(fields) > 4 && fields[4] != "" {
+		meta, err := url.ParseQuery(fields[4])
+		if err != nil {
+			return ErrInvalidBinaryKey
+		}
+		for name := range meta {
+			WithMetadata(name, meta.Get(name))(ks)
+		}
+	}
+
+	k.state.Store(ks)
+
+	return nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
@@ -0,0 +1,63 @@
+package otp
+
+import (
+	"encoding"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Key)(nil)
+	_ encoding.BinaryUnmarshaler = (*Key)(nil)
+)
+
+func TestKeyBinary(t *testing.T) {
+	for _, u := range []string{
+		"otpauth://totp/Example:alice@google.com?algorithm=SHA256&digits=8&issuer=Example&period=60&secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://hotp/Example:alice?counter=42&issuer=Example&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"otpauth://totp/ACME-PROD%20%2F%20jane?image=https%3A%2F%2Fexample.com%2Fa.png&issuer=ACME&secret=JBSWY3DPEHPK3PXP",
+	} {
+		k, err := NewKeyFromURL(u)
+		require.NoError(t, err)
+
+		b, err := k.MarshalBinary()
+		require.NoError(t, err)
+		require.True(t, len(b) < len(u), "binary form is compact")
+
+		var got Key
+		require.NoError(t, got.UnmarshalBinary(b))
+		require.Equal(t, k.Canonicalize().String(), got.String())
+	}
+}
+
+func TestKeyBinaryErrors(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://totp/alice?secret=JBSWY3D1")
+	require.NoError(t, err)
+	_, err = k.MarshalBinary()
+	require.True(t, errors.Is(err, ErrValidateSecretInvalidBase32))
+
+	k, err = NewKeyFromURL("otpauth://totp/alice?secret=JBSWY3DP")
+	require.NoError(t, err)
+	b, err := k.MarshalBinary()
+	require.NoError(t, err)
+
+	var got Key
+	require.Equal(t, ErrInvalidBinaryKey, got.UnmarshalBinary(nil))
+	require.Equal(t, ErrInvalidBinaryKey, got.UnmarshalBinary(b[:len(b)-1]))
+	require.Equal(t, ErrInvalidBinaryKey, got.UnmarshalBinary(append(b, 0)))
+
+	b[0] = 3
+	require.Equal(t, ErrInvalidBinaryKey, got.UnmarshalBinary(b), "unknown version")
+}
+
+func TestKeyBinaryVersion1(t *testing.T) {
+	// A version 1 key has no metadata field.
+	b := []byte{1, binaryTypeTOTP, 0, 0, 0, 0, 2, 'h', 'i', 0, 5, 'a', 'l', 'i', 'c', 'e', 0}
+
+	var k Key
+	require.NoError(t, k.UnmarshalBinary(b))
+	require.Equal(t, "otpauth://totp/alice?secret=NBUQ", k.String())
+}
@@ -0,0 +1,56 @@
+package otp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Canonicalize returns a copy of the key whose URL is in canonical form, so
+// two keys with the same parameters always produce the same string. This
+// makes stored URLs stable, diffable and usable as cache keys.
+//
+// In canonical form the scheme and type are lower case, query parameters
+// are sorted by name and percent-encoded consistently, the secret is upper
+// case without padding, the algorithm is upper case, numeric parameters
+// have no leading zeros, and empty parameters are dropped.
+func (k *Key) Canonicalize() *Key {
+	old := k.load()
+
+	ks := &keyState{
+		scheme: strings.ToLower(old.scheme),
+		typ:    strings.ToLower(old.typ),
+		path:   old.path,
+		params: old.params,
+		meta:   old.meta,
+	}
+
+	p := &ks.params
+	p.secret = strings.ToUpper(strings.TrimRight(strings.TrimSpace(p.secret), "="))
+	p.algorithm = strings.ToUpper(p.algorithm)
+	p.period = canonicalUint(p.period)
+	p.digits = canonicalUint(p.digits)
+	p.counter = canonicalUint(p.counter)
+
+	if p.extra != nil {
+		extra := make(map[string][]string, len(p.extra))
+		for name, values := range p.extra {
+			if len(values) == 1 && values[0] == "" {
+				continue
+			}
+			extra[name] = values
+		}
+		p.extra = extra
+	}
+
+	return newKey(ks)
+}
+
+// canonicalUint strips leading zeros from a decimal parameter. Values that
+// are not decimal numbers are kept verbatim.
+func canonicalUint(s string) string {
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return s
+	}
+	return strconv.FormatUint(u, 10)
+}
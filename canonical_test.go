@@ -0,0 +1,22 @@
+package otp
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	a, err := NewKeyFromURL(`OTPAUTH://TOTP/Example:alice@google.com?secret=jbswy3dpehpk3pxp%3D%3D%3D%3D&period=030&issuer=Example&algorithm=sha1&image=`)
+	require.NoError(t, err)
+	b, err := NewKeyFromURL(`otpauth://totp/Example:alice%40google.com?issuer=Example&algorithm=SHA1&period=30&secret=JBSWY3DPEHPK3PXP`)
+	require.NoError(t, err)
+
+	expected := "otpauth://totp/Example:alice@google.com?algorithm=SHA1&issuer=Example&period=30&secret=JBSWY3DPEHPK3PXP"
+	require.Equal(t, expected, a.Canonicalize().String())
+	require.Equal(t, expected, b.Canonicalize().String())
+	require.Equal(t, expected, a.Canonicalize().Canonicalize().URL())
+
+	// The original key is left untouched.
+	require.Equal(t, "030", a.load().params.period)
+}
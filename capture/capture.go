@@ -0,0 +1,235 @@
+// Package capture records TOTP validations to a stream and replays them
+// against the current version of the library, so a production "code
+// rejected" incident can be reproduced, and bisected, away from production.
+//
+// Records hold no secret: the replayer looks secrets up by fingerprint.
+// Nor do they hold the submitted passcode, only its hash keyed with the
+// secret, from which the replayer recovers the passcode when it is one of
+// the codes around the recorded time. Passcodes are recorded as submitted
+// only with totp.WithRecordedPasscodes.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// Entry is the JSON form of a totp.ValidationRecord, one per line.
+type Entry struct {
+	Time      time.Time     `json:"time"`
+	Period    uint          `json:"period"`
+	Skew      uint          `json:"skew"`
+	Digits    otp.Digits    `json:"digits"`
+	Algorithm otp.Algorithm `json:"algorithm"`
+	// T0 is the epoch of the periods in Unix seconds, 0 for the Unix epoch.
+	T0                int64         `json:"t0,omitempty"`
+	BoundaryTolerance time.Duration `json:"boundary_tolerance,omitempty"`
+	PadLeadingZeros   bool          `json:"pad_leading_zeros,omitempty"`
+	NormalizeInput    bool          `json:"normalize_input,omitempty"`
+	Fingerprint       string        `json:"fingerprint"`
+	PasscodeHash      string        `json:"passcode_hash"`
+	Passcode          string        `json:"passcode,omitempty"`
+	Valid             bool          `json:"valid"`
+	Err               string        `json:"error,omitempty"`
+}
+
+// NewEntry converts rec to an Entry.
+func NewEntry(rec totp.ValidationRecord) Entry {
+	e := Entry{
+		Time:              rec.Time,
+		Period:            rec.Period,
+		Skew:              rec.Skew,
+		Digits:            rec.Digits,
+		Algorithm:         rec.Algorithm,
+		BoundaryTolerance: rec.BoundaryTolerance,
+		PadLeadingZeros:   rec.PadLeadingZeros,
+		NormalizeInput:    rec.NormalizeInput,
+		Fingerprint:       rec.Fingerprint,
+		PasscodeHash:      rec.PasscodeHash,
+		Passcode:          rec.Passcode,
+		Valid:             rec.Valid,
+	}
+	if !rec.Epoch.IsZero() {
+		e.T0 = rec.Epoch.Unix()
+	}
+	if rec.Err != nil {
+		e.Err = rec.Err.Error()
+	}
+	return e
+}
+
+// Record returns the totp.ValidationRecord of e. Err only keeps the text
+// of the original error.
+func (e Entry) Record() totp.ValidationRecord {
+	rec := totp.ValidationRecord{
+		Time:              e.Time,
+		Period:            e.Period,
+		Skew:              e.Skew,
+		Digits:            e.Digits,
+		Algorithm:         e.Algorithm,
+		BoundaryTolerance: e.BoundaryTolerance,
+		PadLeadingZeros:   e.PadLeadingZeros,
+		NormalizeInput:    e.NormalizeInput,
+		Fingerprint:       e.Fingerprint,
+		PasscodeHash:      e.PasscodeHash,
+		Passcode:          e.Passcode,
+		Valid:             e.Valid,
+	}
+	if e.T0 != 0 {
+		rec.Epoch = time.Unix(e.T0, 0)
+	}
+	if e.Err != "" {
+		rec.Err = errors.New(e.Err)
+	}
+	return rec
+}
+
+// Recorder writes validation records to w as JSON lines. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// Errors reports write failures, which would otherwise be dropped as
+	// validation must not fail because of the recorder. Defaults to
+	// ignoring them.
+	Errors func(err error)
+}
+
+// NewRecorder creates a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Record writes rec. Pass it to totp.WithRecorder.
+func (r *Recorder) Record(rec totp.ValidationRecord) {
+	r.mu.Lock()
+	err := r.enc.Encode(NewEntry(rec))
+	r.mu.Unlock()
+
+	if err != nil && r.Errors != nil {
+		r.Errors(err)
+	}
+}
+
+// SecretFunc returns the secret whose otp.SecretFingerprint is fingerprint,
+// and false when it is unknown.
+type SecretFunc func(fingerprint string) (string, bool)
+
+// Result is the outcome of replaying an Entry.
+type Result struct {
+	// Line of the entry in the stream, starting at 1.
+	Line  int
+	Entry Entry
+	// Valid and Err are the outcome of the validation run again.
+	Valid bool
+	Err   error
+}
+
+// Changed reports whether the replayed outcome differs from the recorded one.
+// An entry whose passcode was not recovered only changed if it was valid,
+// as the codes around the recorded time are all tried.
+func (r *Result) Changed() bool {
+	if r.Err == ErrPasscodeNotRecovered {
+		return r.Entry.Valid
+	}
+	if r.Valid != r.Entry.Valid {
+		return true
+	}
+	if r.Err == nil {
+		return r.Entry.Err != ""
+	}
+	return r.Err.Error() != r.Entry.Err
+}
+
+// ErrUnknownSecret is returned when a replayed entry has no known secret.
+var ErrUnknownSecret = errors.New("No secret for fingerprint")
+
+// ErrPasscodeNotRecovered is returned when the hash of a replayed entry
+// matches none of the codes around its time, and its passcode was not
+// recorded.
+var ErrPasscodeNotRecovered = errors.New("Passcode matches no code near the recorded time")
+
+// recoverSlack is the number of periods beyond the skew window searched for
+// the passcode of an entry.
+const recoverSlack = 2
+
+// Replay reads the entries written by a Recorder from r, validates each
+// again with its recorded options and time, and calls fn with the result.
+// Entries whose secret is unknown to secrets get ErrUnknownSecret as their
+// Err, and those whose passcode cannot be recovered ErrPasscodeNotRecovered.
+// Replay stops at the first malformed line.
+func Replay(r io.Reader, secrets SecretFunc, fn func(res Result)) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("capture: line %d: %w", line, err)
+		}
+
+		res := Result{Line: line, Entry: e}
+		secret, ok := secrets(e.Fingerprint)
+		if !ok {
+			res.Err = ErrUnknownSecret
+			fn(res)
+			continue
+		}
+
+		rec := e.Record()
+		opts := rec.ValidateOpts()
+		passcode := e.Passcode
+		if passcode == "" {
+			passcode, ok = recoverPasscode(opts, e, secret)
+		}
+		if ok {
+			res.Valid, res.Err = opts.Validate(passcode, secret, e.Time)
+		} else {
+			res.Err = ErrPasscodeNotRecovered
+		}
+		fn(res)
+	}
+	return sc.Err()
+}
+
+// recoverPasscode returns the code around the time of e whose hash is the
+// PasscodeHash of e, and false when there is none.
+func recoverPasscode(opts totp.ValidateOpts, e Entry, secret string) (string, bool) {
+	period := time.Duration(e.Period) * time.Second
+	if period == 0 {
+		period = time.Duration(totp.LoadDefaults().Period) * time.Second
+	}
+	span := int(e.Skew) + recoverSlack
+	for i := -span; i <= span; i++ {
+		code, err := opts.GenerateCode(secret, e.Time.Add(time.Duration(i)*period))
+		if err != nil {
+			return "", false
+		}
+		if totp.PasscodeHash(secret, code) == e.PasscodeHash {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// Secrets returns a SecretFunc looking up the fingerprints of secrets.
+func Secrets(secrets ...string) SecretFunc {
+	m := make(map[string]string, len(secrets))
+	for _, s := range secrets {
+		m[otp.SecretFingerprint(s)] = s
+	}
+	return func(fingerprint string) (string, bool) {
+		s, ok := m[fingerprint]
+		return s, ok
+	}
+}
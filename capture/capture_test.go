@@ -0,0 +1,98 @@
+package capture
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "JBSWY3DPEHPK3PXP"
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+
+	now := time.Unix(1600000000, 0).UTC()
+	code, err := totp.GenerateCodeCustom(secret, now, totp.ValidateOpts{Period: 30, Digits: otp.DigitsSix})
+	require.NoError(t, err)
+
+	opts := []totp.ValidateOpt{totp.WithTime(now), totp.WithSkew(1), totp.WithRecorder(rec.Record)}
+	ok, err := totp.ValidateWithOpts(code, secret, opts...)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = totp.ValidateWithOpts("000000", secret, opts...)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NotContains(t, buf.String(), secret)
+	require.NotContains(t, buf.String(), code, "passcodes are only hashed")
+	require.Equal(t, 2, strings.Count(buf.String(), "\n"))
+
+	var results []Result
+	err = Replay(bytes.NewReader(buf.Bytes()), Secrets(secret), func(res Result) {
+		results = append(results, res)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Valid)
+	require.False(t, results[1].Valid)
+	require.Equal(t, ErrPasscodeNotRecovered, results[1].Err)
+	for _, res := range results {
+		require.False(t, res.Changed())
+		require.Equal(t, now, res.Entry.Time)
+		require.Equal(t, uint(1), res.Entry.Skew)
+	}
+
+	// An outcome that no longer matches the recording is reported.
+	changed := strings.Replace(buf.String(), `"valid":false`, `"valid":true`, 1)
+	var n int
+	err = Replay(strings.NewReader(changed), Secrets(secret), func(res Result) {
+		if res.Changed() {
+			n++
+		}
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestRecordPasscodes(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+
+	now := time.Unix(1600000000, 0).UTC()
+	_, err := totp.ValidateWithOpts("000000", secret, totp.WithTime(now),
+		totp.WithRecorder(rec.Record), totp.WithRecordedPasscodes())
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `"passcode":"000000"`)
+
+	err = Replay(&buf, Secrets(secret), func(res Result) {
+		require.NoError(t, res.Err)
+		require.False(t, res.Valid)
+		require.False(t, res.Changed())
+	})
+	require.NoError(t, err)
+}
+
+func TestReplayUnknownSecret(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	_, err := totp.ValidateWithOpts("123456", secret, totp.WithRecorder(rec.Record))
+	require.NoError(t, err)
+
+	err = Replay(&buf, Secrets("GEZDGNBVGY3TQOJQ"), func(res Result) {
+		require.Equal(t, ErrUnknownSecret, res.Err)
+		require.True(t, res.Changed())
+	})
+	require.NoError(t, err)
+}
+
+func TestReplayMalformed(t *testing.T) {
+	err := Replay(strings.NewReader("\n{"), Secrets(), func(Result) {})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 2")
+}
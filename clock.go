@@ -0,0 +1,57 @@
+package otp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock provides the current time to code that validates or generates
+// passcodes "now", so tests can fix it and services can adjust it, eg
+// with the offset measured against an NTP server.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc func() time.Time
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock reading the local wall clock with time.Now.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clockHolder gives every value of defaultClock the same concrete type,
+// as atomic.Value requires.
+type clockHolder struct {
+	Clock
+}
+
+var defaultClock atomic.Value
+
+func init() {
+	defaultClock.Store(clockHolder{SystemClock})
+}
+
+// SetDefaultClock atomically replaces the Clock used when no time or
+// Clock is given, eg by totp.Validate. A nil c restores SystemClock.
+func SetDefaultClock(c Clock) {
+	if c == nil {
+		c = SystemClock
+	}
+	defaultClock.Store(clockHolder{c})
+}
+
+// DefaultClock returns the Clock set with SetDefaultClock, SystemClock
+// unless changed.
+func DefaultClock() Clock {
+	return defaultClock.Load().(clockHolder).Clock
+}
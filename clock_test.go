@@ -0,0 +1,19 @@
+package otp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultClock(t *testing.T) {
+	require.Equal(t, SystemClock, DefaultClock())
+
+	fixed := time.Unix(1700000000, 0)
+	SetDefaultClock(ClockFunc(func() time.Time { return fixed }))
+	require.True(t, fixed.Equal(DefaultClock().Now()))
+
+	SetDefaultClock(nil)
+	require.Equal(t, SystemClock, DefaultClock())
+}
@@ -0,0 +1,71 @@
+// Command otpconform generates codes across a matrix of algorithms, digits,
+// periods, secrets and counters, and compares this library with a second
+// implementation: the reference bundled with the command, or the output of
+// another tool.
+//
+// Compare with the bundled reference:
+//
+//	otpconform
+//
+// Write the matrix with the library's codes, one tab separated line per
+// case, to feed or compare with another implementation:
+//
+//	otpconform -emit > codes.tsv
+//
+// Compare with the codes written by another implementation in the same
+// format, "kind algorithm digits period secret factor code", where factor
+// is the counter for hotp and the Unix time for totp:
+//
+//	otpconform -against other.tsv
+//
+// The exit status is 1 when the implementations disagree.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var (
+		emit    = flag.Bool("emit", false, "write the matrix with the library's codes and exit")
+		against = flag.String("against", "", "compare with the codes in `file` instead of the bundled reference")
+		secrets = flag.Int("secrets", 8, "number of random secrets added to the RFC secrets")
+		seed    = flag.Int64("seed", 1, "seed of the random secrets")
+	)
+	flag.Parse()
+
+	cases := Matrix(*secrets, *seed)
+
+	if *emit {
+		if err := WriteCodes(os.Stdout, cases, Library); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	want := Generator(Reference)
+	if *against != "" {
+		f, err := os.Open(*against)
+		if err != nil {
+			fail(err)
+		}
+		want, err = ParseCodes(f)
+		f.Close()
+		if err != nil {
+			fail(fmt.Errorf("%s: %w", *against, err))
+		}
+	}
+
+	report := Compare(cases, Library, want)
+	report.Write(os.Stdout)
+	if len(report.Mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "otpconform:", err)
+	os.Exit(2)
+}
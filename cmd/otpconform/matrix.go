@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/testvectors"
+	"github.com/pquerna/otp/totp"
+)
+
+// Case is a combination of parameters of the matrix. Factor is the
+// counter of HOTP cases and the Unix time of TOTP cases.
+type Case struct {
+	Kind      string
+	Algorithm otp.Algorithm
+	Digits    otp.Digits
+	Period    uint
+	Secret    string
+	Factor    uint64
+}
+
+var (
+	algorithms = []otp.Algorithm{
+		otp.AlgorithmSHA1,
+		otp.AlgorithmSHA256,
+		otp.AlgorithmSHA512,
+		otp.AlgorithmMD5,
+		otp.AlgorithmSHA224,
+		otp.AlgorithmSHA512_256,
+	}
+	digits  = []otp.Digits{otp.DigitsSix, otp.DigitsSeven, otp.DigitsEight, otp.DigitsNine, otp.DigitsTen}
+	periods = []uint{15, 30, 60}
+
+	counters = []uint64{0, 1, 9, 1 << 31, 1<<32 + 1, 1<<63 - 1, 1<<64 - 1}
+	times    = []uint64{0, 59, 1111111109, 1234567890, 2000000000, 20000000000}
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Matrix returns every combination of parameters for the RFC secrets and
+// n random secrets of sizes from 1 to 64 bytes drawn from seed.
+func Matrix(n int, seed int64) []Case {
+	secrets := []string{
+		b32.EncodeToString(testvectors.SecretSHA1),
+		b32.EncodeToString(testvectors.SecretSHA256),
+		b32.EncodeToString(testvectors.SecretSHA512),
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	for i := 0; i < n; i++ {
+		b := make([]byte, 1+rnd.Intn(64))
+		rnd.Read(b)
+		secrets = append(secrets, b32.EncodeToString(b))
+	}
+
+	var cases []Case
+	for _, secret := range secrets {
+		for _, a := range algorithms {
+			for _, d := range digits {
+				for _, c := range counters {
+					cases = append(cases, Case{Kind: "hotp", Algorithm: a, Digits: d, Secret: secret, Factor: c})
+				}
+				for _, p := range periods {
+					for _, t := range times {
+						cases = append(cases, Case{Kind: "totp", Algorithm: a, Digits: d, Period: p, Secret: secret, Factor: t})
+					}
+				}
+			}
+		}
+	}
+	return cases
+}
+
+// Generator returns the code of a Case.
+type Generator func(c Case) (string, error)
+
+// Library generates codes with this library.
+func Library(c Case) (string, error) {
+	if c.Kind == "totp" {
+		return totp.GenerateCodeCustom(c.Secret, time.Unix(int64(c.Factor), 0).UTC(), totp.ValidateOpts{
+			Period:    c.Period,
+			Digits:    c.Digits,
+			Algorithm: c.Algorithm,
+		})
+	}
+	return hotp.GenerateCodeCustom(c.Secret, c.Factor, hotp.ValidateOpts{
+		Digits:    c.Digits,
+		Algorithm: c.Algorithm,
+	})
+}
+
+// referenceHashes are the hash functions of the reference implementation.
+var referenceHashes = map[otp.Algorithm]func() hash.Hash{
+	otp.AlgorithmSHA1:       sha1.New,
+	otp.AlgorithmSHA256:     sha256.New,
+	otp.AlgorithmSHA512:     sha512.New,
+	otp.AlgorithmMD5:        md5.New,
+	otp.AlgorithmSHA224:     sha256.New224,
+	otp.AlgorithmSHA512_256: sha512.New512_256,
+}
+
+// Reference generates codes with a plain transcription of RFC 4226 and
+// RFC 6238 that shares no code with the library.
+func Reference(c Case) (string, error) {
+	key, err := b32.DecodeString(c.Secret)
+	if err != nil {
+		return "", err
+	}
+	newHash, ok := referenceHashes[c.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("unknown algorithm %d", c.Algorithm)
+	}
+
+	counter := c.Factor
+	if c.Kind == "totp" {
+		counter = c.Factor / uint64(c.Period)
+	}
+
+	mac := hmac.New(newHash, key)
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := int(sum[len(sum)-1] & 0xf)
+	// RFC 4226 assumes digests of at least 20 bytes; like the library,
+	// keep the offset of shorter ones (MD5) within the digest.
+	if offset > len(sum)-4 {
+		offset = len(sum) - 4
+	}
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint64(1)
+	for i := 0; i < int(c.Digits); i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", int(c.Digits), uint64(value)%mod), nil
+}
+
+// String returns the tab separated fields of c, as read by ParseCodes.
+func (c Case) String() string {
+	return strings.Join([]string{
+		c.Kind,
+		c.Algorithm.String(),
+		c.Digits.String(),
+		strconv.FormatUint(uint64(c.Period), 10),
+		c.Secret,
+		strconv.FormatUint(c.Factor, 10),
+	}, "\t")
+}
+
+// WriteCodes writes a line per case with its fields and the code of gen.
+func WriteCodes(w io.Writer, cases []Case, gen Generator) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range cases {
+		code, err := gen(c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+		fmt.Fprintf(bw, "%s\t%s\n", c, code)
+	}
+	return bw.Flush()
+}
+
+// ParseCodes reads the output of WriteCodes, or of another implementation
+// writing the same format, and returns a Generator answering from it.
+// Blank lines and lines starting with # are ignored.
+func ParseCodes(r io.Reader) (Generator, error) {
+	codes := make(map[string]string)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		f := strings.Split(text, "\t")
+		if len(f) != 7 {
+			return nil, fmt.Errorf("line %d: want 7 tab separated fields, got %d", line, len(f))
+		}
+		c, err := parseCase(f[:6])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		codes[c.String()] = f[6]
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return func(c Case) (string, error) {
+		code, ok := codes[c.String()]
+		if !ok {
+			return "", errMissing
+		}
+		return code, nil
+	}, nil
+}
+
+// errMissing is returned for cases absent from the parsed codes.
+var errMissing = fmt.Errorf("no code")
+
+func parseCase(f []string) (Case, error) {
+	c := Case{Kind: f[0], Secret: f[4]}
+	if c.Kind != "hotp" && c.Kind != "totp" {
+		return c, fmt.Errorf("unknown kind %q", c.Kind)
+	}
+
+	var err error
+	if c.Algorithm, err = otp.ParseAlgorithm(f[1]); err != nil {
+		return c, err
+	}
+	if c.Digits, err = otp.ParseDigits(f[2]); err != nil {
+		return c, err
+	}
+	period, err := strconv.ParseUint(f[3], 10, 32)
+	if err != nil {
+		return c, err
+	}
+	c.Period = uint(period)
+	if c.Factor, err = strconv.ParseUint(f[5], 10, 64); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// Report is the result of a comparison.
+type Report struct {
+	Cases      int
+	Mismatches []Mismatch
+	// Missing counts the cases the other implementation has no code for.
+	Missing int
+}
+
+// Mismatch is a case both implementations disagree on.
+type Mismatch struct {
+	Case      Case
+	Got, Want string
+	GotErr    error
+	WantErr   error
+}
+
+// Compare generates every case with got and want.
+func Compare(cases []Case, got, want Generator) Report {
+	r := Report{Cases: len(cases)}
+	for _, c := range cases {
+		w, werr := want(c)
+		if werr == errMissing {
+			r.Missing++
+			continue
+		}
+		g, gerr := got(c)
+		if g != w || (gerr == nil) != (werr == nil) {
+			r.Mismatches = append(r.Mismatches, Mismatch{Case: c, Got: g, Want: w, GotErr: gerr, WantErr: werr})
+		}
+	}
+	return r
+}
+
+// Write prints the report, one line per mismatch and a summary.
+func (r *Report) Write(w io.Writer) {
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(w, "MISMATCH %s\tgot %s", m.Case, result(m.Got, m.GotErr))
+		fmt.Fprintf(w, "\twant %s\n", result(m.Want, m.WantErr))
+	}
+	fmt.Fprintf(w, "%d cases, %d mismatches, %d missing\n", r.Cases, len(r.Mismatches), r.Missing)
+}
+
+func result(code string, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return code
+}
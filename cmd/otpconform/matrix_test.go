@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLibraryMatchesReference(t *testing.T) {
+	r := Compare(Matrix(4, 1), Library, Reference)
+	require.Empty(t, r.Mismatches)
+	require.Zero(t, r.Missing)
+}
+
+func TestCompareAgainstCodes(t *testing.T) {
+	cases := Matrix(0, 1)[:3]
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCodes(&buf, cases, Reference))
+
+	// Corrupt the second code and drop the third case.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	lines[1] = lines[1][:len(lines[1])-6] + "000000"
+	other := "# from another tool\n" + lines[0] + "\n\n" + lines[1] + "\n"
+
+	want, err := ParseCodes(strings.NewReader(other))
+	require.NoError(t, err)
+
+	r := Compare(cases, Library, want)
+	require.Equal(t, 3, r.Cases)
+	require.Equal(t, 1, r.Missing)
+	require.Len(t, r.Mismatches, 1)
+	require.Equal(t, cases[1], r.Mismatches[0].Case)
+	require.Equal(t, "000000", r.Mismatches[0].Want)
+
+	var out bytes.Buffer
+	r.Write(&out)
+	require.Contains(t, out.String(), "want 000000")
+	require.Contains(t, out.String(), "3 cases, 1 mismatches, 1 missing")
+}
+
+func TestParseCodesInvalid(t *testing.T) {
+	_, err := ParseCodes(strings.NewReader("hotp\tSHA1\t6\n"))
+	require.EqualError(t, err, "line 1: want 7 tab separated fields, got 3")
+
+	_, err = ParseCodes(strings.NewReader("hotp\tSHA3\t6\t0\tAAAA\t0\t123456\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package otp
+
+import "crypto/subtle"
+
+// Comparator decides whether a submitted passcode matches a generated one.
+// Implementations must take time independent of where the passcodes
+// differ, so comparisons do not leak the generated passcode.
+type Comparator interface {
+	// Equal reports whether submitted matches generated.
+	Equal(generated, submitted []byte) bool
+}
+
+// ConstantTimeComparator is a Comparator that also reports a match as 1
+// or 0, so callers can combine the results of several comparisons without
+// branching on them. The Comparators of this package implement it.
+type ConstantTimeComparator interface {
+	Comparator
+	// ConstantTimeEqual returns 1 if submitted matches generated, 0
+	// otherwise.
+	ConstantTimeEqual(generated, submitted []byte) int
+}
+
+// ConstantTimeEqual returns 1 if submitted matches generated according to
+// c, 0 otherwise. A Comparator that does not implement
+// ConstantTimeComparator is adapted, which branches on its result.
+func ConstantTimeEqual(c Comparator, generated, submitted []byte) int {
+	if ct, ok := c.(ConstantTimeComparator); ok {
+		return ct.ConstantTimeEqual(generated, submitted)
+	}
+	if c.Equal(generated, submitted) {
+		return 1
+	}
+	return 0
+}
+
+// The Comparators provided by this package. CompareExact, the default,
+// requires identical bytes. CompareFold also accepts ASCII letters in
+// either case, for alphanumeric encoders such as EncoderCrockford or the
+// Steam alphabet, whose users may type codes in lower case.
+var (
+	CompareExact Comparator = exactComparator{}
+	CompareFold  Comparator = foldComparator{}
+)
+
+type exactComparator struct{}
+
+func (c exactComparator) Equal(generated, submitted []byte) bool {
+	return c.ConstantTimeEqual(generated, submitted) == 1
+}
+
+func (exactComparator) ConstantTimeEqual(generated, submitted []byte) int {
+	return subtle.ConstantTimeCompare(generated, submitted)
+}
+
+type foldComparator struct{}
+
+func (c foldComparator) Equal(generated, submitted []byte) bool {
+	return c.ConstantTimeEqual(generated, submitted) == 1
+}
+
+func (foldComparator) ConstantTimeEqual(generated, submitted []byte) int {
+	if len(generated) != len(submitted) {
+		return 0
+	}
+
+	var diff byte
+	for i := range generated {
+		diff |= foldByte(generated[i]) ^ foldByte(submitted[i])
+	}
+	return subtle.ConstantTimeByteEq(diff, 0)
+}
+
+// foldByte maps an ASCII upper case letter to lower case without branching
+// on its value.
+func foldByte(c byte) byte {
+	upper := subtle.ConstantTimeLessOrEq('A', int(c)) & subtle.ConstantTimeLessOrEq(int(c), 'Z')
+	return c | byte(upper<<5)
+}
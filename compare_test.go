@@ -0,0 +1,34 @@
+package otp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparators(t *testing.T) {
+	for _, tx := range []struct {
+		generated, submitted string
+		exact, fold          bool
+	}{
+		{"123456", "123456", true, true},
+		{"123456", "123457", false, false},
+		{"123456", "12345", false, false},
+		{"0697KRR", "0697krr", false, true},
+		{"0697KRR", "0697KRR", true, true},
+		{"@[`{", "`{@[", false, false},
+	} {
+		require.Equal(t, tx.exact, CompareExact.Equal([]byte(tx.generated), []byte(tx.submitted)), tx.submitted)
+		require.Equal(t, tx.fold, CompareFold.Equal([]byte(tx.generated), []byte(tx.submitted)), tx.submitted)
+		require.Equal(t, tx.exact, ConstantTimeEqual(CompareExact, []byte(tx.generated), []byte(tx.submitted)) == 1, tx.submitted)
+		require.Equal(t, tx.fold, ConstantTimeEqual(CompareFold, []byte(tx.generated), []byte(tx.submitted)) == 1, tx.submitted)
+		require.Equal(t, tx.exact, ConstantTimeEqual(plainComparator{}, []byte(tx.generated), []byte(tx.submitted)) == 1, tx.submitted)
+	}
+}
+
+// plainComparator is a Comparator without ConstantTimeEqual.
+type plainComparator struct{}
+
+func (plainComparator) Equal(generated, submitted []byte) bool {
+	return string(generated) == string(submitted)
+}
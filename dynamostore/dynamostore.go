@@ -0,0 +1,226 @@
+// Package dynamostore implements the otp store interfaces on a DynamoDB
+// table: keys, HOTP counters, used passcodes for replay protection and
+// validation attempts for rate limiting.
+//
+// All items live in one table with the string partition key "pk". Counters
+// and attempts are updated with compare-and-swap conditional writes, and
+// used passcodes are inserted with a condition that they do not exist yet.
+// Used passcodes and attempts carry an "expires_at" attribute, in Unix
+// seconds; enable the table's TTL on it to have them expire automatically.
+//
+// The package does not depend on the AWS SDK; adapt the client of your
+// choice to the Table interface.
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// The condition of a conditional write did not hold. Table implementations
+// return it for ConditionalCheckFailedException.
+var ErrConditionFailed = errors.New("Conditional check failed")
+
+// Attribute names of the items.
+const (
+	AttrPK        = "pk"
+	AttrURL       = "url"
+	AttrNext      = "next"
+	AttrAttempts  = "attempts"
+	AttrExpiresAt = "expires_at"
+)
+
+// Item is a DynamoDB item. Values are strings, int64 numbers or []int64
+// number lists.
+type Item map[string]interface{}
+
+// Condition is the condition of a conditional write.
+type Condition struct {
+	// Name of the attribute.
+	Name string
+	// Value the attribute must have in the stored item. Nil requires the
+	// attribute not to exist, which holds for a missing item.
+	Value interface{}
+}
+
+// Table is the subset of a DynamoDB client the Store needs, bound to one
+// table.
+type Table interface {
+	// Get returns the item with partition key pk, or nil when there is
+	// none. It must use a strongly consistent read.
+	Get(ctx context.Context, pk string) (Item, error)
+	// Put writes item, replacing any item with the same partition key. A
+	// non-nil cond makes the write conditional; when the condition does
+	// not hold Put returns ErrConditionFailed.
+	Put(ctx context.Context, item Item, cond *Condition) error
+	// Delete removes the item with partition key pk, if any.
+	Delete(ctx context.Context, pk string) error
+}
+
+// Store implements otp.KeyStore, otp.CounterStore, otp.ReplayStore and
+// otp.AttemptStore on a DynamoDB table.
+// A Store is safe for concurrent use.
+type Store struct {
+	table     Table
+	replayTTL time.Duration
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+// StoreOpt configures a Store.
+type StoreOpt func(s *Store)
+
+// WithReplayTTL sets how long used passcodes are remembered. It must be at
+// least as long as a passcode can be accepted, ie (2*Skew+1)*Period for
+// TOTP. Defaults to 10 minutes.
+func WithReplayTTL(ttl time.Duration) StoreOpt {
+	return func(s *Store) {
+		s.replayTTL = ttl
+	}
+}
+
+// New creates a Store on table.
+func New(table Table, storeOpts ...StoreOpt) *Store {
+	s := &Store{table: table, replayTTL: 10 * time.Minute}
+	for _, opt := range storeOpts {
+		opt(s)
+	}
+	return s
+}
+
+// Get implements otp.KeyStore.
+func (s *Store) Get(ctx context.Context, id string) (*otp.Key, error) {
+	item, err := s.table.Get(ctx, "key#"+id)
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+	u, ok := item[AttrURL].(string)
+	if !ok {
+		return nil, otp.ErrKeyNotFound
+	}
+
+	key, err := otp.NewKeyFromURL(u)
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+	return key, nil
+}
+
+// Put implements otp.KeyStore. The key is stored as its URL, including
+// its metadata.
+func (s *Store) Put(ctx context.Context, id string, key *otp.Key) error {
+	err := s.table.Put(ctx, Item{AttrPK: "key#" + id, AttrURL: key.MetadataURL()}, nil)
+	return otp.WrapStoreError("Put", id, err)
+}
+
+// Delete implements otp.KeyStore.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return otp.WrapStoreError("Delete", id, s.table.Delete(ctx, "key#"+id))
+}
+
+// Next implements otp.CounterStore. The counter is advanced with a
+// conditional write on its previous value, retried when another caller
+// advanced it first.
+func (s *Store) Next(ctx context.Context, id string) (uint64, error) {
+	pk := "counter#" + id
+	for {
+		item, err := s.table.Get(ctx, pk)
+		if err != nil {
+			return 0, otp.WrapStoreError("Next", id, err)
+		}
+
+		cond := &Condition{Name: AttrNext}
+		var c int64
+		if next, ok := item[AttrNext].(int64); ok {
+			c = next
+			cond.Value = next
+		}
+
+		err = s.table.Put(ctx, Item{AttrPK: pk, AttrNext: c + 1}, cond)
+		switch {
+		case err == nil:
+			return uint64(c), nil
+		case !errors.Is(err, ErrConditionFailed):
+			return 0, otp.WrapStoreError("Next", id, err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Use implements otp.ReplayStore.
+func (s *Store) Use(ctx context.Context, id string, counter uint64) (bool, error) {
+	item := Item{
+		AttrPK:        "replay#" + id + "#" + strconv.FormatUint(counter, 10),
+		AttrExpiresAt: s.clock().Add(s.replayTTL).Unix(),
+	}
+
+	err := s.table.Put(ctx, item, &Condition{Name: AttrPK})
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrConditionFailed):
+		return false, nil
+	}
+	return false, otp.WrapStoreError("Use", id, err)
+}
+
+// Add implements otp.AttemptStore. The attempts of id are kept in one item
+// as a list of Unix nanosecond times, updated with a conditional write.
+func (s *Store) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	pk := "attempts#" + id
+	for {
+		item, err := s.table.Get(ctx, pk)
+		if err != nil {
+			return 0, otp.WrapStoreError("Add", id, err)
+		}
+
+		now := s.clock()
+		cond := &Condition{Name: AttrAttempts}
+		var kept []int64
+		if attempts, ok := item[AttrAttempts].([]int64); ok {
+			cond.Value = attempts
+			for _, at := range attempts {
+				if now.Sub(time.Unix(0, at)) < window {
+					kept = append(kept, at)
+				}
+			}
+		}
+		kept = append(kept, now.UnixNano())
+
+		err = s.table.Put(ctx, Item{
+			AttrPK:        pk,
+			AttrAttempts:  kept,
+			AttrExpiresAt: now.Add(window).Unix(),
+		}, cond)
+		switch {
+		case err == nil:
+			return len(kept), nil
+		case !errors.Is(err, ErrConditionFailed):
+			return 0, otp.WrapStoreError("Add", id, err)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Reset implements otp.AttemptStore.
+func (s *Store) Reset(ctx context.Context, id string) error {
+	return otp.WrapStoreError("Reset", id, s.table.Delete(ctx, "attempts#"+id))
+}
+
+// clock returns the current time.
+func (s *Store) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
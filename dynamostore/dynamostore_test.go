@@ -0,0 +1,146 @@
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// memTable is a Table held in memory.
+type memTable struct {
+	mu    sync.Mutex
+	items map[string]Item
+	// beforePut is called before every Put, to simulate concurrent writers.
+	beforePut func(t *memTable)
+}
+
+func newTable() *memTable {
+	return &memTable{items: map[string]Item{}}
+}
+
+func (t *memTable) Get(ctx context.Context, pk string) (Item, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.items[pk], nil
+}
+
+func (t *memTable) Put(ctx context.Context, item Item, cond *Condition) error {
+	if t.beforePut != nil {
+		fn := t.beforePut
+		t.beforePut = nil
+		fn(t)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pk := item[AttrPK].(string)
+	if cond != nil {
+		v, ok := t.items[pk][cond.Name]
+		if cond.Value == nil && ok || cond.Value != nil && !reflect.DeepEqual(v, cond.Value) {
+			return ErrConditionFailed
+		}
+	}
+	t.items[pk] = item
+	return nil
+}
+
+func (t *memTable) Delete(ctx context.Context, pk string) error {
+	t.mu.Lock()
+	delete(t.items, pk)
+	t.mu.Unlock()
+	return nil
+}
+
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	s := New(newTable())
+
+	_, err := s.Get(ctx, "SnakeOil:alice")
+	require.True(t, errors.Is(err, otp.ErrKeyNotFound))
+
+	key, err := otp.NewKeyFromURL("otpauth://totp/SnakeOil:alice?secret=JBSWY3DPEHPK3PXP&issuer=SnakeOil&meta-team=core")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "SnakeOil:alice", key))
+
+	got, err := s.Get(ctx, "SnakeOil:alice")
+	require.NoError(t, err)
+	require.Equal(t, key.Secret(), got.Secret())
+	require.Equal(t, key.Metadata(), got.Metadata())
+
+	require.NoError(t, s.Delete(ctx, "SnakeOil:alice"))
+	_, err = s.Get(ctx, "SnakeOil:alice")
+	require.True(t, errors.Is(err, otp.ErrKeyNotFound))
+}
+
+func TestNextRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	table := newTable()
+	s := New(table)
+
+	c, err := s.Next(ctx, "SnakeOil:alice")
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), c)
+
+	// Another process advances the counter between our read and write.
+	table.beforePut = func(t *memTable) {
+		t.items["counter#SnakeOil:alice"] = Item{AttrPK: "counter#SnakeOil:alice", AttrNext: int64(2)}
+	}
+	c, err = s.Next(ctx, "SnakeOil:alice")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), c)
+
+	c, err = s.Next(ctx, "SnakeOil:alice")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), c)
+}
+
+func TestUse(t *testing.T) {
+	ctx := context.Background()
+	table := newTable()
+	s := New(table, WithReplayTTL(time.Minute))
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	ok, err := s.Use(ctx, "SnakeOil:alice", 7)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, now.Add(time.Minute).Unix(), table.items["replay#SnakeOil:alice#7"][AttrExpiresAt])
+
+	ok, err = s.Use(ctx, "SnakeOil:alice", 7)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = s.Use(ctx, "SnakeOil:alice", 8)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestAttempts(t *testing.T) {
+	ctx := context.Background()
+	s := New(newTable())
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	for i := 1; i <= 3; i++ {
+		n, err := s.Add(ctx, "SnakeOil:alice", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, i, n)
+		now = now.Add(20 * time.Second)
+	}
+
+	n, err := s.Add(ctx, "SnakeOil:alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 3, n, "the first attempt left the window")
+
+	require.NoError(t, s.Reset(ctx, "SnakeOil:alice"))
+	n, err = s.Add(ctx, "SnakeOil:alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
@@ -0,0 +1,182 @@
+// Package ecies encrypts key URLs to the public key of a managed device,
+// so enrollment material passing through MDM pipelines is useless if
+// intercepted: only the device holding the private key can import it.
+//
+// Payloads use ECIES over P-256: an ephemeral ECDH key agreement, HKDF-SHA256
+// and AES-256-GCM. A payload is
+//
+//	version (1) | ephemeral public key (65, uncompressed) | nonce (12) | ciphertext
+//
+// and is delivered as an otpauth-ecies URL, in a QR code or an NDEF record.
+package ecies
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"image"
+	"io"
+	"math/big"
+	"net/url"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/ndef"
+)
+
+var (
+	// ErrInvalidPayload is returned for payloads that are malformed, were
+	// not encrypted to the key, or were modified.
+	ErrInvalidPayload = errors.New("Invalid encrypted provisioning payload")
+	// ErrInvalidPublicKey is returned for public keys that are not P-256
+	// points.
+	ErrInvalidPublicKey = errors.New("Invalid P-256 public key")
+)
+
+const (
+	version    = 1
+	pointSize  = 65
+	nonceSize  = 12
+	headerSize = 1 + pointSize + nonceSize
+)
+
+// info binds derived keys to this use.
+var info = []byte("otpauth-ecies v1")
+
+// Scheme is the scheme of sealed URLs.
+const Scheme = "otpauth-ecies"
+
+// NDEFType is the NFC Forum external type of sealed NDEF records.
+const NDEFType = "github.com:otp-ecies"
+
+// ParsePublicKey parses an uncompressed P-256 point, as exported by device
+// keystores.
+func ParsePublicKey(b []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.Unmarshal(elliptic.P256(), b)
+	if x == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// Seal encrypts the URL of key to pub. rand defaults to crypto/rand.
+func Seal(rnd io.Reader, pub *ecdsa.PublicKey, key *otp.Key) ([]byte, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	if pub.Curve != elliptic.P256() || !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, ErrInvalidPublicKey
+	}
+
+	eph, err := ecdsa.GenerateKey(elliptic.P256(), rnd)
+	if err != nil {
+		return nil, err
+	}
+	ephPub := elliptic.Marshal(elliptic.P256(), eph.X, eph.Y)
+
+	aead, err := newAEAD(ephPub, pub, eph.D)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, headerSize, headerSize+len(key.URL())+aead.Overhead())
+	out[0] = version
+	copy(out[1:], ephPub)
+	if _, err := io.ReadFull(rnd, out[1+pointSize:headerSize]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[1+pointSize:headerSize], []byte(key.URL()), out[:1+pointSize]), nil
+}
+
+// Open decrypts a payload sealed to the public key of priv and parses the
+// key URL it holds.
+func Open(priv *ecdsa.PrivateKey, payload []byte) (*otp.Key, error) {
+	if len(payload) < headerSize || payload[0] != version {
+		return nil, ErrInvalidPayload
+	}
+	ephPub := payload[1 : 1+pointSize]
+	x, y := elliptic.Unmarshal(elliptic.P256(), ephPub)
+	if x == nil {
+		return nil, ErrInvalidPayload
+	}
+
+	aead, err := newAEAD(ephPub, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, priv.D)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, payload[1+pointSize:headerSize], payload[headerSize:], payload[:1+pointSize])
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return otp.NewKeyFromURL(string(plain))
+}
+
+// newAEAD returns the AES-GCM cipher keyed by the ECDH of scalar and peer,
+// with the ephemeral public key as HKDF salt.
+func newAEAD(ephPub []byte, peer *ecdsa.PublicKey, scalar *big.Int) (cipher.AEAD, error) {
+	sx, _ := elliptic.P256().ScalarMult(peer.X, peer.Y, scalar.Bytes())
+	shared := make([]byte, 32)
+	b := sx.Bytes()
+	copy(shared[len(shared)-len(b):], b)
+
+	block, err := aes.NewCipher(hkdf(ephPub, shared, info))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// hkdf derives a 32 byte key with HKDF-SHA256 (RFC 5869).
+func hkdf(salt, secret, info []byte) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// URL returns the otpauth-ecies URL of a payload, for QR codes and links
+// handled by the device's enrollment app.
+func URL(payload []byte) string {
+	return Scheme + "://v1?data=" + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// ParseURL returns the payload of an otpauth-ecies URL.
+func ParseURL(s string) ([]byte, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != Scheme {
+		return nil, ErrInvalidPayload
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(u.Query().Get("data"))
+	if err != nil {
+		return nil, ErrInvalidPayload
+	}
+	return payload, nil
+}
+
+// QRCode returns a QR code of the URL of a payload.
+func QRCode(payload []byte, size int) (image.Image, error) {
+	if size <= 0 || size > otp.MaxImageSize {
+		return nil, &otp.OptionError{Name: "Image size", Value: size, Err: otp.ErrInvalidImageSize}
+	}
+	b, err := qr.Encode(URL(payload), qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	return barcode.Scale(b, size, size)
+}
+
+// NDEFRecord returns an external NDEF record holding a payload.
+func NDEFRecord(payload []byte) ndef.Record {
+	return ndef.Record{TNF: ndef.TNFExternal, Type: []byte(NDEFType), Payload: payload}
+}
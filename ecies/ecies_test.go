@@ -0,0 +1,75 @@
+package ecies
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/ndef"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	device, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(elliptic.Marshal(elliptic.P256(), device.X, device.Y))
+	require.NoError(t, err)
+
+	key, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+
+	payload, err := Seal(nil, pub, key)
+	require.NoError(t, err)
+	require.NotContains(t, string(payload), "JBSWY3DPEHPK3PXP")
+
+	// Through a URL.
+	u := URL(payload)
+	require.True(t, strings.HasPrefix(u, "otpauth-ecies://v1?data="))
+	got, err := ParseURL(u)
+	require.NoError(t, err)
+	opened, err := Open(device, got)
+	require.NoError(t, err)
+	require.Equal(t, key.URL(), opened.URL())
+
+	// Through NDEF.
+	records, err := ndef.ParseMessage(ndef.Message(NDEFRecord(payload)))
+	require.NoError(t, err)
+	opened, err = Open(device, records[0].Payload)
+	require.NoError(t, err)
+	require.Equal(t, key.Secret(), opened.Secret())
+
+	img, err := QRCode(payload, 300)
+	require.NoError(t, err)
+	require.Equal(t, 300, img.Bounds().Dx())
+}
+
+func TestOpenRejects(t *testing.T) {
+	device, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	key, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	payload, err := Seal(nil, &device.PublicKey, key)
+	require.NoError(t, err)
+
+	_, err = Open(other, payload)
+	require.Equal(t, ErrInvalidPayload, err)
+
+	tampered := append([]byte{}, payload...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = Open(device, tampered)
+	require.Equal(t, ErrInvalidPayload, err)
+
+	_, err = Open(device, payload[:10])
+	require.Equal(t, ErrInvalidPayload, err)
+
+	_, err = ParsePublicKey([]byte{4, 1, 2})
+	require.Equal(t, ErrInvalidPublicKey, err)
+	_, err = ParseURL("otpauth://totp/x")
+	require.Equal(t, ErrInvalidPayload, err)
+}
@@ -0,0 +1,38 @@
+package otp
+
+// Encoder renders the 31-bit value produced by HOTP dynamic truncation as a
+// passcode of a given number of characters.
+type Encoder interface {
+	// AppendCode appends the passcode for value to dst and returns the
+	// extended buffer.
+	AppendCode(dst []byte, value uint32, digits Digits) []byte
+}
+
+// The Encoders provided by this package. EncoderDecimal is the RFC 4226
+// encoding expected by authenticator apps; the others trade compatibility
+// for more entropy per character, for internal tools.
+var (
+	EncoderDecimal   Encoder = alphabetEncoder("0123456789")
+	EncoderHex       Encoder = alphabetEncoder("0123456789ABCDEF")
+	EncoderCrockford Encoder = alphabetEncoder("0123456789ABCDEFGHJKMNPQRSTVWXYZ")
+)
+
+// alphabetEncoder writes the value, reduced modulo len(alphabet)^digits, in
+// the positional system formed by its characters, zero-filled.
+type alphabetEncoder string
+
+func (a alphabetEncoder) AppendCode(dst []byte, value uint32, digits Digits) []byte {
+	n := digits.Length()
+	base := uint32(len(a))
+
+	start := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, a[0])
+	}
+	for i := len(dst) - 1; i >= start; i-- {
+		dst[i] = a[value%base]
+		value /= base
+	}
+
+	return dst
+}
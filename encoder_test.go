@@ -0,0 +1,19 @@
+package otp
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"testing"
+)
+
+func TestEncoders(t *testing.T) {
+	// 0x4c93cf18 is the truncated value for counter 0 of the RFC 4226
+	// test secret, whose decimal code is 755224.
+	const value = 0x4c93cf18
+
+	require.Equal(t, "755224", string(EncoderDecimal.AppendCode(nil, value, DigitsSix)))
+	require.Equal(t, "84755224", string(EncoderDecimal.AppendCode(nil, value, DigitsEight)))
+	require.Equal(t, "93CF18", string(EncoderHex.AppendCode(nil, value, DigitsSix)))
+	require.Equal(t, "4C93CF18", string(EncoderHex.AppendCode(nil, value, DigitsEight)))
+	require.Equal(t, "0697KRR", string(EncoderCrockford.AppendCode([]byte("0"), value, DigitsSix)))
+}
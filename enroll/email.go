@@ -0,0 +1,273 @@
+package enroll
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrInvalidRecipient is returned for recipients that would inject headers.
+var ErrInvalidRecipient = errors.New("Invalid email recipient")
+
+// Embed selects how the QR code is embedded in an email.
+type Embed int
+
+const (
+	// EmbedCID attaches the QR code as an inline image referenced by
+	// Content-ID, which most mail clients display.
+	EmbedCID Embed = iota
+	// EmbedDataURI inlines the QR code as a data URI, which some clients,
+	// including Gmail and Outlook, block.
+	EmbedDataURI
+)
+
+// qrContentID is the Content-ID of the inline QR code.
+const qrContentID = "qr@otp"
+
+// Message is a rendered email.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+	// Inline images referenced by the HTML part.
+	Inline []Inline
+}
+
+// Inline is an image referenced by Content-ID.
+type Inline struct {
+	ContentID   string
+	ContentType string
+	Data        []byte
+}
+
+// Sender delivers rendered emails, eg through SMTP or a mail API.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Email describes an enrollment email. Emails travel and are stored in
+// plain text: prefer sending a link to an authenticated enrollment page,
+// and only email the key where that is not possible.
+type Email struct {
+	Key *otp.Key
+	// Subject of the email. Defaults to "Set up two-factor authentication".
+	Subject string
+	// Embed selects how the QR code is embedded. Defaults to EmbedCID.
+	Embed Embed
+	// QRSize is the size of the QR code in pixels. Defaults to
+	// DefaultQRSize.
+	QRSize int
+	// HTMLTemplate and TextTemplate replace the default templates. They
+	// are executed with an EmailData.
+	HTMLTemplate *template.Template
+	TextTemplate *texttemplate.Template
+}
+
+// EmailData is the data of email templates.
+type EmailData struct {
+	Key *otp.Key
+	// QR is the source of the QR code image.
+	QR template.URL
+	// Link is the otpauth URL of the key, opening the authenticator app on
+	// phones.
+	Link template.URL
+	// Secret is the secret in groups of 4 characters, for manual entry.
+	Secret string
+	// Details lists the parameters to enter along with the secret, if
+	// they differ from the defaults.
+	Details []string
+}
+
+// Render renders the email to to.
+func (e *Email) Render(to string) (*Message, error) {
+	qr, err := qrPNG(e.Key, e.QRSize)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{To: to, Subject: e.Subject}
+	if msg.Subject == "" {
+		msg.Subject = "Set up two-factor authentication"
+	}
+
+	data := EmailData{
+		Key:     e.Key,
+		Link:    template.URL(e.Key.URL()),
+		Secret:  groupedSecret(e.Key),
+		Details: details(e.Key),
+	}
+	if e.Embed == EmbedDataURI {
+		data.QR = dataURI(qr)
+	} else {
+		data.QR = template.URL("cid:" + qrContentID)
+		msg.Inline = []Inline{{ContentID: qrContentID, ContentType: "image/png", Data: qr}}
+	}
+
+	htmlTmpl, textTmpl := e.HTMLTemplate, e.TextTemplate
+	if htmlTmpl == nil {
+		htmlTmpl = emailHTMLTemplate
+	}
+	if textTmpl == nil {
+		textTmpl = emailTextTemplate
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	msg.HTML = buf.String()
+	buf.Reset()
+	if err := textTmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	msg.Text = buf.String()
+
+	return msg, nil
+}
+
+// Send renders the email to to and delivers it with sender.
+func (e *Email) Send(ctx context.Context, sender Sender, to string) error {
+	msg, err := e.Render(to)
+	if err != nil {
+		return err
+	}
+	return sender.Send(ctx, msg)
+}
+
+// WriteMIME writes msg as a MIME message, with the text and HTML parts as
+// alternatives and the inline images related to the HTML part, ready for
+// net/smtp. The From header and the envelope are left to the sender.
+func (msg *Message) WriteMIME(w io.Writer) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return ErrInvalidRecipient
+	}
+
+	var body bytes.Buffer
+	alt := multipart.NewWriter(&body)
+
+	if err := writeQP(alt, "text/plain; charset=utf-8", msg.Text); err != nil {
+		return err
+	}
+
+	if len(msg.Inline) == 0 {
+		if err := writeQP(alt, "text/html; charset=utf-8", msg.HTML); err != nil {
+			return err
+		}
+	} else {
+		var related bytes.Buffer
+		rel := multipart.NewWriter(&related)
+		if err := writeQP(rel, "text/html; charset=utf-8", msg.HTML); err != nil {
+			return err
+		}
+		for _, img := range msg.Inline {
+			if err := writeInline(rel, img); err != nil {
+				return err
+			}
+		}
+		if err := rel.Close(); err != nil {
+			return err
+		}
+
+		pw, err := alt.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"multipart/related; boundary=" + rel.Boundary()},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := pw.Write(related.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := alt.Close(); err != nil {
+		return err
+	}
+
+	header := fmt.Sprintf("To: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n",
+		msg.To, mime.QEncoding.Encode("utf-8", msg.Subject), alt.Boundary())
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// writeQP writes a quoted-printable part.
+func writeQP(mw *multipart.Writer, contentType, text string) error {
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qw := quotedprintable.NewWriter(pw)
+	if _, err := io.WriteString(qw, text); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// writeInline writes an inline image part, in base64 lines of 76
+// characters.
+func writeInline(mw *multipart.Writer, img Inline) error {
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {img.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Id":                {"<" + img.ContentID + ">"},
+		"Content-Disposition":       {"inline"},
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := base64.StdEncoding.EncodeToString(img.Data)
+	for len(enc) > 0 {
+		n := 76
+		if n > len(enc) {
+			n = len(enc)
+		}
+		if _, err := io.WriteString(pw, enc[:n]+"\r\n"); err != nil {
+			return err
+		}
+		enc = enc[n:]
+	}
+	return nil
+}
+
+var emailHTMLTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<p>Set up two-factor authentication for {{with .Key.Issuer}}{{.}} &middot; {{end}}{{.Key.AccountName}}.</p>
+<p>On your phone, <a href="{{.Link}}">open this link</a> to add the account to your authenticator app. On another device, scan this QR code with the app:</p>
+<p><img src="{{.QR}}" width="200" height="200" alt="QR code"></p>
+<p>If you cannot scan the code, add the account by hand with this setup key:</p>
+<p style="font-family: monospace; font-size: 16px;">{{.Secret}}</p>
+{{range .Details}}<p>{{.}}</p>
+{{end}}<p>This email gives access to your account. Delete it once you are set up.</p>
+</body>
+</html>
+`))
+
+var emailTextTemplate = texttemplate.Must(texttemplate.New("email").Parse(`Set up two-factor authentication for {{with .Key.Issuer}}{{.}} - {{end}}{{.Key.AccountName}}.
+
+Add the account to your authenticator app by hand with this setup key:
+
+    {{.Secret}}
+{{range .Details}}
+{{.}}{{end}}
+
+This email gives access to your account. Delete it once you are set up.
+`))
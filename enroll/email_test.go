@@ -0,0 +1,96 @@
+package enroll
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type senderFunc func(ctx context.Context, msg *Message) error
+
+func (f senderFunc) Send(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+func TestEmailRender(t *testing.T) {
+	e := &Email{Key: testKey(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&period=60")}
+
+	msg, err := e.Render("alice@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "Set up two-factor authentication", msg.Subject)
+	require.Contains(t, msg.HTML, `src="cid:qr@otp"`)
+	require.Contains(t, msg.HTML, `href="otpauth://totp/Example:alice?`)
+	require.Contains(t, msg.HTML, "JBSW Y3DP EHPK 3PXP")
+	require.Contains(t, msg.Text, "JBSW Y3DP EHPK 3PXP")
+	require.Contains(t, msg.Text, "Period: 60 seconds")
+	require.Len(t, msg.Inline, 1)
+	require.Equal(t, "image/png", msg.Inline[0].ContentType)
+
+	e.Embed = EmbedDataURI
+	msg, err = e.Render("alice@example.com")
+	require.NoError(t, err)
+	require.Contains(t, msg.HTML, `src="data:image/png;base64,`)
+	require.Empty(t, msg.Inline)
+}
+
+func TestEmailSend(t *testing.T) {
+	e := &Email{Key: testKey(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"), Subject: "Welcome"}
+
+	var sent *Message
+	err := e.Send(context.Background(), senderFunc(func(_ context.Context, msg *Message) error {
+		sent = msg
+		return nil
+	}), "alice@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "Welcome", sent.Subject)
+	require.Equal(t, "alice@example.com", sent.To)
+}
+
+func TestWriteMIME(t *testing.T) {
+	e := &Email{Key: testKey(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"), Subject: "Bienvenue à bord"}
+	msg, err := e.Render("alice@example.com")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, msg.WriteMIME(&buf))
+
+	m, err := mail.ReadMessage(&buf)
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "Bienvenue à bord", subject)
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	alt := multipart.NewReader(m.Body, params["boundary"])
+	text, err := alt.NextPart()
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(text)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "JBSW Y3DP EHPK 3PXP")
+
+	related, err := alt.NextPart()
+	require.NoError(t, err)
+	mediaType, params, err = mime.ParseMediaType(related.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/related", mediaType)
+
+	rel := multipart.NewReader(related, params["boundary"])
+	html, err := rel.NextPart()
+	require.NoError(t, err)
+	require.Equal(t, "text/html; charset=utf-8", html.Header.Get("Content-Type"))
+	img, err := rel.NextPart()
+	require.NoError(t, err)
+	require.Equal(t, "<qr@otp>", img.Header.Get("Content-Id"))
+
+	msg.To = "alice@example.com\r\nBcc: eve@example.com"
+	require.Equal(t, ErrInvalidRecipient, msg.WriteMIME(&buf))
+}
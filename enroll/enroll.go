@@ -0,0 +1,32 @@
+// Package enroll renders enrollment material for a key: a printable
+// enrollment sheet and enrollment emails.
+package enroll
+
+import (
+	"encoding/base64"
+	"html/template"
+
+	"github.com/pquerna/otp"
+)
+
+// DefaultQRSize is the width and height in pixels of rendered QR codes.
+const DefaultQRSize = 256
+
+// qrPNG returns the QR code of key as a PNG image.
+func qrPNG(key *otp.Key, size int) ([]byte, error) {
+	if size == 0 {
+		size = DefaultQRSize
+	}
+	return key.QRCodePNG(size, size)
+}
+
+// dataURI returns a data URI of a PNG image, trusted by html/template.
+func dataURI(png []byte) template.URL {
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+}
+
+// groupedSecret returns the secret of key in groups of 4 characters, as
+// typed in authenticator apps.
+func groupedSecret(key *otp.Key) string {
+	return otp.FormatCode(key.Secret(), otp.GroupsOf(4))
+}
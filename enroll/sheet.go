@@ -0,0 +1,122 @@
+package enroll
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+
+	"github.com/pquerna/otp"
+)
+
+// DefaultInstructions are the steps printed on a Sheet without
+// Instructions.
+var DefaultInstructions = []string{
+	"Install an authenticator app, such as Google Authenticator, on your phone.",
+	"In the app, add an account and scan the QR code.",
+	"If you cannot scan it, choose to enter a setup key and type the secret key below.",
+	"Enter the code shown by the app to finish enrollment.",
+	"Keep the recovery codes somewhere safe. Each one can be used once if you lose your phone.",
+}
+
+// Sheet is a printable enrollment document.
+type Sheet struct {
+	Key *otp.Key
+	// RecoveryCodes printed below the key, if any.
+	RecoveryCodes []string
+	// Title of the document. Defaults to "Two-factor authentication".
+	Title string
+	// Instructions printed as numbered steps. Defaults to
+	// DefaultInstructions.
+	Instructions []string
+	// QRSize is the size of the QR code in pixels. Defaults to
+	// DefaultQRSize.
+	QRSize int
+}
+
+// sheetData is the data of sheetTemplate.
+type sheetData struct {
+	*Sheet
+	QR     template.URL
+	Secret string
+	// Details lists the parameters apps must be told about when entering
+	// the secret by hand, if they differ from the defaults.
+	Details []string
+}
+
+// WriteHTML renders the sheet as a self-contained HTML page laid out for
+// printing on a single A4 or Letter page. The page holds the secret: serve
+// it with caching disabled.
+func (s *Sheet) WriteHTML(w io.Writer) error {
+	qr, err := qrPNG(s.Key, s.QRSize)
+	if err != nil {
+		return err
+	}
+
+	data := sheetData{Sheet: s, QR: dataURI(qr), Secret: groupedSecret(s.Key), Details: details(s.Key)}
+	if data.Title == "" {
+		data.Title = "Two-factor authentication"
+	}
+	if data.Instructions == nil {
+		data.Instructions = DefaultInstructions
+	}
+	return sheetTemplate.Execute(w, data)
+}
+
+// details lists the non-default parameters of key.
+func details(key *otp.Key) []string {
+	var d []string
+	if key.Type() == "hotp" {
+		d = append(d, "Type: counter based")
+	}
+	if key.Digits() != otp.DefaultDigits {
+		d = append(d, "Digits: "+key.Digits().String())
+	}
+	if key.Algorithm() != otp.DefaultAlgorithm {
+		d = append(d, "Algorithm: "+key.Algorithm().String())
+	}
+	if key.Type() == "totp" && key.Period() != otp.DefaultPeriod {
+		d = append(d, "Period: "+strconv.FormatUint(key.Period(), 10)+" seconds")
+	}
+	return d
+}
+
+var sheetTemplate = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+@page { size: auto; margin: 20mm; }
+body { font-family: sans-serif; color: #000; background: #fff; max-width: 170mm; margin: 0 auto; }
+h1 { font-size: 20pt; margin-bottom: 4pt; }
+.account { font-size: 12pt; margin-top: 0; }
+.key { display: flex; gap: 10mm; align-items: center; page-break-inside: avoid; }
+.qr { width: 50mm; height: 50mm; image-rendering: pixelated; }
+.secret, .codes { font-family: monospace; font-size: 14pt; letter-spacing: 1pt; }
+.codes { columns: 2; list-style: none; padding: 0; page-break-inside: avoid; }
+.notice { font-size: 9pt; border-top: 1px solid #000; padding-top: 4pt; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="account">{{with .Key.Issuer}}{{.}} &middot; {{end}}{{.Key.AccountName}}</p>
+<div class="key">
+<img class="qr" src="{{.QR}}" alt="QR code">
+<div>
+<p>Secret key</p>
+<p class="secret">{{.Secret}}</p>
+{{range .Details}}<p>{{.}}</p>
+{{end}}</div>
+</div>
+<h2>Instructions</h2>
+<ol>
+{{range .Instructions}}<li>{{.}}</li>
+{{end}}</ol>
+{{if .RecoveryCodes}}<h2>Recovery codes</h2>
+<ul class="codes">
+{{range .RecoveryCodes}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}<p class="notice">This sheet gives access to your account. Store it safely, and destroy it if you no longer need it.</p>
+</body>
+</html>
+`))
@@ -0,0 +1,51 @@
+package enroll
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(t *testing.T, url string) *otp.Key {
+	key, err := otp.NewKeyFromURL(url)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSheetHTML(t *testing.T) {
+	s := &Sheet{
+		Key:           testKey(t, "otpauth://totp/Example:alice%3Cb%3E?secret=JBSWY3DPEHPK3PXPJBSW&issuer=Example&digits=8"),
+		RecoveryCodes: []string{"abcd-efgh", "ijkl-mnop"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteHTML(&buf))
+	html := buf.String()
+
+	require.Contains(t, html, "<title>Two-factor authentication</title>")
+	require.Contains(t, html, `src="data:image/png;base64,`)
+	require.Contains(t, html, "JBSW Y3DP EHPK 3PXP JBSW")
+	require.Contains(t, html, "<li>abcd-efgh</li>")
+	require.Contains(t, html, "<p>Digits: 8</p>")
+	require.Contains(t, html, DefaultInstructions[0])
+	// Names are escaped.
+	require.Contains(t, html, "alice&lt;b&gt;")
+	require.False(t, strings.Contains(html, "Period:"))
+}
+
+func TestSheetWithoutRecoveryCodes(t *testing.T) {
+	s := &Sheet{
+		Key:          testKey(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"),
+		Title:        "ACME sign-in",
+		Instructions: []string{"Scan the code."},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteHTML(&buf))
+	require.Contains(t, buf.String(), "<h1>ACME sign-in</h1>")
+	require.Contains(t, buf.String(), "<li>Scan the code.</li>")
+	require.NotContains(t, buf.String(), "Recovery codes")
+}
@@ -0,0 +1,219 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The Key URL could not be parsed. Errors returned by NewKeyFromURL
+// match it with errors.Is.
+var ErrInvalidURL = errors.New("Invalid OTP URL")
+
+// The Algorithm is not one this package implements.
+var ErrUnsupportedAlgorithm = errors.New("Unsupported algorithm")
+
+// The number of digits is not one this package implements.
+var ErrUnsupportedDigits = errors.New("Unsupported number of digits")
+
+// The issuer prefix of a key's label and its issuer parameter disagree.
+var ErrIssuerMismatch = errors.New("Issuer of label and parameter differ")
+
+// The binary form of a Key could not be decoded.
+var ErrInvalidBinaryKey = errors.New("Invalid binary key")
+
+// The key's type is neither "totp" nor "hotp".
+var ErrUnsupportedType = errors.New("Unsupported key type")
+
+// The period is not a positive number of seconds.
+var ErrInvalidPeriod = errors.New("Period must be a positive number of seconds")
+
+// The requested QR code dimensions are not positive or exceed MaxImageSize.
+var ErrInvalidImageSize = errors.New("Invalid image size")
+
+// The QR code image could not be produced.
+var ErrImageEncoding = errors.New("QR code encoding failed")
+
+// The random source could not provide a secret. Every RandError matches
+// it with errors.Is.
+var ErrRandFailure = errors.New("Reading random secret failed")
+
+// The random source returned a single repeated byte.
+var ErrRandStuck = errors.New("Random source returned a repeated byte")
+
+// An option passed to a generate or validate function is unusable.
+// Every OptionError matches it with errors.Is.
+var ErrInvalidOption = errors.New("Invalid option")
+
+// URLError records a Key URL that could not be parsed and the cause.
+type URLError struct {
+	URL string
+	Err error
+}
+
+func (e *URLError) Error() string {
+	return fmt.Sprintf("%v %q: %v", ErrInvalidURL, e.URL, e.Err)
+}
+
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidURL.
+func (e *URLError) Is(target error) bool {
+	return target == ErrInvalidURL
+}
+
+// OptionError records an option that failed validation.
+type OptionError struct {
+	// Name of the option, eg "Skew".
+	Name string
+	// Value the option was set to.
+	Value interface{}
+	// Err is the specific failure, eg ErrValidateSkewTooLarge.
+	Err error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("%v %s=%v: %v", ErrInvalidOption, e.Name, e.Value, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidOption.
+func (e *OptionError) Is(target error) bool {
+	return target == ErrInvalidOption
+}
+
+// SecretError describes why a secret failed to decode as base32.
+// It matches ErrValidateSecretInvalidBase32 with errors.Is.
+type SecretError struct {
+	// Offset is the byte offset of the problem in the secret, after
+	// surrounding whitespace is removed.
+	Offset int
+	// Char is the offending character, or 0 when the problem is the
+	// length of the secret.
+	Char rune
+	// Padding is true when misplaced or superfluous '=' padding, or a
+	// length that no amount of padding can fix, is the cause.
+	Padding bool
+}
+
+func (e *SecretError) Error() string {
+	switch {
+	case e.Char == 0:
+		return fmt.Sprintf("Decoding of secret as base32 failed: invalid length %d", e.Offset)
+	case e.Padding:
+		return fmt.Sprintf("Decoding of secret as base32 failed: misplaced padding at offset %d", e.Offset)
+	}
+
+	msg := fmt.Sprintf("Decoding of secret as base32 failed: invalid character %q at offset %d", e.Char, e.Offset)
+	switch e.Char {
+	case '0':
+		msg += " (did you mean 'O'?)"
+	case '1':
+		msg += " (did you mean 'I' or 'L'?)"
+	case '8':
+		msg += " (did you mean 'B'?)"
+	}
+	return msg
+}
+
+// Is reports whether target is ErrValidateSecretInvalidBase32.
+func (e *SecretError) Is(target error) bool {
+	return target == ErrValidateSecretInvalidBase32
+}
+
+// RandError reports a random source that failed to provide a secret, so
+// a flaky entropy source fails key generation instead of producing a
+// short or predictable secret.
+type RandError struct {
+	// Size of the requested secret in bytes.
+	Size int
+	// Read is the number of bytes read by the last attempt.
+	Read int
+	// Err is the error of the last attempt, ErrRandStuck when the source
+	// returned a repeated byte.
+	Err error
+}
+
+func (e *RandError) Error() string {
+	return fmt.Sprintf("Reading random secret failed: read %d of %d bytes: %v", e.Read, e.Size, e.Err)
+}
+
+func (e *RandError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrRandFailure.
+func (e *RandError) Is(target error) bool {
+	return target == ErrRandFailure
+}
+
+// GenerateError lists every invalid field of a request to generate a Key,
+// so enrollment forms can report all of them at once. Each field is an
+// OptionError named after it, eg "Issuer" or "SecretSize".
+//
+// errors.Is and errors.As match any of the fields, so
+// errors.Is(err, ErrGenerateMissingIssuer) keeps working.
+type GenerateError struct {
+	Fields []*OptionError
+}
+
+func (e *GenerateError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Name + ": " + f.Err.Error()
+	}
+	return "Invalid key request: " + strings.Join(msgs, "; ")
+}
+
+// Field returns the error of the named field, or nil if it is valid.
+func (e *GenerateError) Field(name string) error {
+	for _, f := range e.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Is reports whether any field matches target.
+func (e *GenerateError) Is(target error) bool {
+	for _, f := range e.Fields {
+		if errors.Is(f, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first field that matches target.
+func (e *GenerateError) As(target interface{}) bool {
+	for _, f := range e.Fields {
+		if errors.As(f, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records an invalid field. An OptionError is added as is, any other
+// error is wrapped in one named after the field.
+func (e *GenerateError) Add(name string, value interface{}, err error) {
+	var optErr *OptionError
+	if !errors.As(err, &optErr) {
+		optErr = &OptionError{Name: name, Value: value, Err: err}
+	}
+	e.Fields = append(e.Fields, optErr)
+}
+
+// Err returns e, or nil if no field was recorded.
+func (e *GenerateError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
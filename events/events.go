@@ -0,0 +1,74 @@
+// Package events defines the lifecycle events of OTP keys and the
+// Publisher interface that ships them to security analytics pipelines.
+// The kafka and nats subpackages publish them to those brokers.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Type is the kind of an Event.
+type Type string
+
+// The lifecycle events.
+const (
+	// Enrolled is a new key.
+	Enrolled Type = "enrolled"
+	// Validated is an accepted passcode.
+	Validated Type = "validated"
+	// Failed is a rejected passcode.
+	Failed Type = "failed"
+	// LockedOut is an account locked after too many failures.
+	LockedOut Type = "locked_out"
+	// Rotated is a key replaced by a new one.
+	Rotated Type = "rotated"
+	// Anomaly is a suspicious pattern of failures, see package anomaly.
+	Anomaly Type = "anomaly"
+)
+
+// Event is a lifecycle event of an OTP key.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// User the key belongs to.
+	User string `json:"user"`
+	// KeyID of the key, when known.
+	KeyID string `json:"key_id,omitempty"`
+	// Attrs are additional details, eg the client IP or the reason of a
+	// failure. They must not contain secrets or passcodes.
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Encode returns the JSON encoding of the Event, the payload the adapters
+// publish.
+func (e Event) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Publisher ships Events.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc func(ctx context.Context, e Event) error
+
+// Publish implements Publisher.
+func (f PublisherFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// Multi returns a Publisher publishing every Event to each of publishers,
+// in order, stopping at the first error.
+func Multi(publishers ...Publisher) Publisher {
+	return PublisherFunc(func(ctx context.Context, e Event) error {
+		for _, p := range publishers {
+			if err := p.Publish(ctx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	e := Event{Type: Validated, Time: time.Unix(1700000000, 0).UTC(), User: "alice", Attrs: map[string]string{"ip": "192.0.2.1"}}
+
+	b, err := e.Encode()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"validated","time":"2023-11-14T22:13:20Z","user":"alice","attrs":{"ip":"192.0.2.1"}}`, string(b))
+}
+
+func TestMulti(t *testing.T) {
+	var got []string
+	record := func(name string, err error) Publisher {
+		return PublisherFunc(func(ctx context.Context, e Event) error {
+			got = append(got, name)
+			return err
+		})
+	}
+
+	err := Multi(record("a", nil), record("b", errors.New("down")), record("c", nil)).Publish(context.Background(), Event{Type: Failed})
+	require.EqualError(t, err, "down")
+	require.Equal(t, []string{"a", "b"}, got)
+}
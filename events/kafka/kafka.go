@@ -0,0 +1,53 @@
+// Package kafka publishes OTP lifecycle events to Kafka.
+//
+// The package does not depend on a Kafka client; adapt the producer of
+// your choice to the Writer interface.
+package kafka
+
+import (
+	"context"
+
+	"github.com/pquerna/otp/events"
+)
+
+// Message is a Kafka record.
+type Message struct {
+	Topic string
+	// Key partitions the records; events of one user share a partition,
+	// so they are consumed in order.
+	Key   []byte
+	Value []byte
+	// Headers of the record.
+	Headers map[string]string
+}
+
+// Writer is the subset of a Kafka producer the Publisher needs.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...Message) error
+}
+
+// Publisher is an events.Publisher writing JSON encoded events to a topic,
+// keyed by user, with the event type in the "type" header.
+type Publisher struct {
+	w     Writer
+	topic string
+}
+
+// NewPublisher creates a Publisher writing to topic with w.
+func NewPublisher(w Writer, topic string) *Publisher {
+	return &Publisher{w: w, topic: topic}
+}
+
+// Publish implements events.Publisher.
+func (p *Publisher) Publish(ctx context.Context, e events.Event) error {
+	value, err := e.Encode()
+	if err != nil {
+		return err
+	}
+	return p.w.WriteMessages(ctx, Message{
+		Topic:   p.topic,
+		Key:     []byte(e.User),
+		Value:   value,
+		Headers: map[string]string{"type": string(e.Type)},
+	})
+}
@@ -0,0 +1,30 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pquerna/otp/events"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingWriter struct {
+	msgs []Message
+}
+
+func (w *recordingWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	w := &recordingWriter{}
+	p := NewPublisher(w, "otp-events")
+
+	require.NoError(t, p.Publish(context.Background(), events.Event{Type: events.Enrolled, User: "alice"}))
+	require.Len(t, w.msgs, 1)
+	require.Equal(t, "otp-events", w.msgs[0].Topic)
+	require.Equal(t, []byte("alice"), w.msgs[0].Key)
+	require.Equal(t, "enrolled", w.msgs[0].Headers["type"])
+	require.Contains(t, string(w.msgs[0].Value), `"type":"enrolled"`)
+}
@@ -0,0 +1,42 @@
+// Package nats publishes OTP lifecycle events to NATS.
+//
+// The package does not depend on the NATS client; its *nats.Conn satisfies
+// the Conn interface as is.
+package nats
+
+import (
+	"context"
+
+	"github.com/pquerna/otp/events"
+)
+
+// Conn is the subset of a NATS connection the Publisher needs.
+type Conn interface {
+	Publish(subject string, data []byte) error
+}
+
+// Publisher is an events.Publisher publishing JSON encoded events to the
+// subject "<prefix>.<type>", eg "otp.events.validated", so subscribers can
+// filter by type with wildcards.
+type Publisher struct {
+	conn   Conn
+	prefix string
+}
+
+// NewPublisher creates a Publisher publishing on conn under prefix.
+func NewPublisher(conn Conn, prefix string) *Publisher {
+	return &Publisher{conn: conn, prefix: prefix}
+}
+
+// Publish implements events.Publisher. NATS publishing does not block, so
+// ctx is only checked before publishing.
+func (p *Publisher) Publish(ctx context.Context, e events.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := e.Encode()
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.prefix+"."+string(e.Type), data)
+}
@@ -0,0 +1,30 @@
+package nats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pquerna/otp/events"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingConn struct {
+	subjects []string
+}
+
+func (c *recordingConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	c := &recordingConn{}
+	p := NewPublisher(c, "otp.events")
+
+	require.NoError(t, p.Publish(context.Background(), events.Event{Type: events.LockedOut, User: "alice"}))
+	require.Equal(t, []string{"otp.events.locked_out"}, c.subjects)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, p.Publish(ctx, events.Event{Type: events.Failed}))
+}
@@ -0,0 +1,112 @@
+package otp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FormatOpt configures FormatCode and ParseCode.
+type FormatOpt func(opts *formatOpts)
+
+type formatOpts struct {
+	group     int
+	separator string
+}
+
+// GroupsOf splits a code into groups of n characters, counted from the
+// left. Defaults to 3.
+func GroupsOf(n int) FormatOpt {
+	return func(opts *formatOpts) {
+		opts.group = n
+	}
+}
+
+// WithSeparator sets the string placed between groups. Defaults to a space.
+func WithSeparator(sep string) FormatOpt {
+	return func(opts *formatOpts) {
+		opts.separator = sep
+	}
+}
+
+func newFormatOpts(fmtOpts []FormatOpt) *formatOpts {
+	opts := &formatOpts{group: 3, separator: " "}
+	for _, opt := range fmtOpts {
+		opt(opts)
+	}
+	return opts
+}
+
+// FormatCode groups a passcode for display, eg FormatCode("123456",
+// GroupsOf(3)) returns "123 456". A group size of 0 or less returns the
+// code unchanged.
+func FormatCode(code string, fmtOpts ...FormatOpt) string {
+	opts := newFormatOpts(fmtOpts)
+
+	if opts.group <= 0 || len(code) <= opts.group {
+		return code
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(code); i += opts.group {
+		if i > 0 {
+			b.WriteString(opts.separator)
+		}
+		end := i + opts.group
+		if end > len(code) {
+			end = len(code)
+		}
+		b.WriteString(code[i:end])
+	}
+	return b.String()
+}
+
+// ParseCode reverses FormatCode. It is tolerant of how users type codes:
+// the configured separator, whitespace and hyphens are removed wherever
+// they appear.
+func ParseCode(s string, fmtOpts ...FormatOpt) string {
+	opts := newFormatOpts(fmtOpts)
+
+	if opts.separator != "" {
+		s = strings.Replace(s, opts.separator, "", -1)
+	}
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// NormalizeCode cleans up a pasted or autofilled passcode: whitespace,
+// dashes and invisible formatting characters are removed, and decimal
+// digits of other scripts, such as fullwidth or Arabic-Indic digits, are
+// replaced by their ASCII equivalents.
+func NormalizeCode(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < utf8.RuneSelf:
+			if r == ' ' || r == '-' || unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		case unicode.IsSpace(r), unicode.Is(unicode.Pd, r), unicode.Is(unicode.Cf, r):
+			return -1
+		case unicode.IsDigit(r):
+			return '0' + digitValue(r)
+		}
+		return r
+	}, s)
+}
+
+// digitValue returns the value of a Unicode decimal digit. Decimal digits
+// are encoded in contiguous runs of complete 0 to 9 sequences, so the value
+// is the distance from the start of the run, modulo 10.
+func digitValue(r rune) rune {
+	start := r
+	for unicode.IsDigit(start - 1) {
+		start--
+	}
+	return (r - start) % 10
+}
@@ -0,0 +1,39 @@
+package otp
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"testing"
+)
+
+func TestFormatCode(t *testing.T) {
+	require.Equal(t, "123 456", FormatCode("123456", GroupsOf(3)))
+	require.Equal(t, "123 456", FormatCode("123456"))
+	require.Equal(t, "1234-5678", FormatCode("12345678", GroupsOf(4), WithSeparator("-")))
+	require.Equal(t, "123 456 7", FormatCode("1234567"))
+	require.Equal(t, "123456", FormatCode("123456", GroupsOf(0)))
+	require.Equal(t, "12", FormatCode("12"))
+}
+
+func TestParseCode(t *testing.T) {
+	require.Equal(t, "123456", ParseCode("123 456"))
+	require.Equal(t, "123456", ParseCode(" 123-456\n"))
+	require.Equal(t, "12345678", ParseCode("1234.5678", WithSeparator(".")))
+	require.Equal(t, "123456", ParseCode(FormatCode("123456", WithSeparator(" · ")), WithSeparator(" · ")))
+}
+
+func TestNormalizeCode(t *testing.T) {
+	for in, want := range map[string]string{
+		"123456":                               "123456",
+		"123 456":                              "123456",
+		"123-456":                              "123456",
+		"123\u2013456":                         "123456",
+		"\u200b123\u00a0456\n":                 "123456",
+		"\uff11\uff12\uff13\uff14\uff15\uff16": "123456",
+		"\u0661\u0662\u0663\u0664\u0665\u0660": "123450",
+		"\U0001d7ec\U0001d7ed":                 "01",
+		"0X7F-KR":                              "0X7FKR",
+	} {
+		require.Equal(t, want, NormalizeCode(in), "%q", in)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build go1.18
+// +build go1.18
+
+package otp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzNewKeyFromURL guarantees untrusted URIs can never crash the process.
+func FuzzNewKeyFromURL(f *testing.F) {
+	f.Add(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example`)
+	f.Add(`otpauth://hotp/alice@google.com?secret=JBSWY3DPEHPK3PXP&counter=1&period=x&digits=99&algorithm=MD4`)
+	f.Add(`otpauth:totp?secret=%zz`)
+	f.Add(`://`)
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		k, err := NewKeyFromURL(s)
+		if err != nil {
+			require.True(t, errors.Is(err, ErrInvalidURL))
+			return
+		}
+		_ = k.Type()
+		_ = k.Issuer()
+		_ = k.AccountName()
+		_ = k.Secret()
+		_ = k.Period()
+		_ = k.String()
+		_ = k.URL()
+		_, _ = k.Image(64, 64)
+	})
+}
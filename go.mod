@@ -5,4 +5,5 @@ go 1.12
 require (
 	github.com/boombuler/barcode v1.0.1-0.20190219062509-6c824513bacc
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/text v0.13.0
 )
@@ -0,0 +1,86 @@
+package hotp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// SecretCache is a bounded, least-recently-used cache mapping secret
+// fingerprints to decoded key material. Secrets are indexed by a SHA-256
+// fingerprint, so the cache never holds the encoded secret itself.
+// A SecretCache is safe for concurrent use.
+type SecretCache struct {
+	mu      sync.Mutex
+	size    int
+	ll      *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type secretCacheEntry struct {
+	fp  [sha256.Size]byte
+	key []byte
+}
+
+// NewSecretCache creates a SecretCache holding at most size decoded secrets.
+// A size of 0 or less disables caching.
+func NewSecretCache(size int) *SecretCache {
+	return &SecretCache{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Decode returns the key material for secret, decoding it with DecodeSecret
+// on a cache miss. The returned slice is shared and must not be modified.
+func (c *SecretCache) Decode(secret string) ([]byte, error) {
+	if c == nil || c.size <= 0 {
+		return DecodeSecret(secret)
+	}
+
+	fp := fingerprint(secret)
+
+	c.mu.Lock()
+	if e, ok := c.entries[fp]; ok {
+		c.ll.MoveToFront(e)
+		key := e.Value.(*secretCacheEntry).key
+		c.mu.Unlock()
+		return key, nil
+	}
+	c.mu.Unlock()
+
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[fp]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*secretCacheEntry).key, nil
+	}
+
+	c.entries[fp] = c.ll.PushFront(&secretCacheEntry{fp: fp, key: key})
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.entries, e.Value.(*secretCacheEntry).fp)
+	}
+
+	return key, nil
+}
+
+// Len returns the number of secrets currently cached.
+func (c *SecretCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// fingerprint returns the cache index for secret.
+func fingerprint(secret string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(secret))
+}
@@ -0,0 +1,37 @@
+package hotp
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"encoding/base32"
+	"testing"
+)
+
+func TestSecretCacheEviction(t *testing.T) {
+	c := NewSecretCache(2)
+
+	a := base32.StdEncoding.EncodeToString([]byte("aaaaaaaaaa"))
+	b := base32.StdEncoding.EncodeToString([]byte("bbbbbbbbbb"))
+	d := base32.StdEncoding.EncodeToString([]byte("dddddddddd"))
+
+	key, err := c.Decode(a)
+	require.NoError(t, err)
+	require.Equal(t, []byte("aaaaaaaaaa"), key)
+
+	_, err = c.Decode(b)
+	require.NoError(t, err)
+	_, err = c.Decode(a)
+	require.NoError(t, err)
+	_, err = c.Decode(d)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Len())
+
+	_, hasA := c.entries[fingerprint(a)]
+	_, hasB := c.entries[fingerprint(b)]
+	require.True(t, hasA, "recently used secret was evicted")
+	require.False(t, hasB, "least recently used secret was kept")
+
+	_, err = c.Decode("not base32!")
+	require.Error(t, err)
+	require.Equal(t, 2, c.Len())
+}
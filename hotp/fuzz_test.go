@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package hotp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzGenerateCodeCustom guarantees untrusted secrets and options can never
+// crash the process. Every Digits and Algorithm value is tried, supported
+// or not.
+func FuzzGenerateCodeCustom(f *testing.F) {
+	f.Add(secSha1, uint64(0), 6, 0)
+	f.Add("not base32!", uint64(1), 8, 3)
+	f.Add("JBSWY3DPEHPK3PX", uint64(7), 6, 99)
+	f.Add(secSha1, uint64(2), -1, 1)
+	f.Add(secSha1, uint64(3), 1<<40, 2)
+
+	f.Fuzz(func(t *testing.T, secret string, counter uint64, digits int, algorithm int) {
+		d := otp.Digits(digits)
+		code, err := GenerateCodeCustom(secret, counter, ValidateOpts{
+			Digits:    d,
+			Algorithm: otp.Algorithm(algorithm),
+		})
+		if d.Check() != nil {
+			require.True(t, errors.Is(err, otp.ErrUnsupportedDigits) || errors.Is(err, otp.ErrUnsupportedAlgorithm))
+			return
+		}
+		if err == nil {
+			require.Len(t, code, d.Length())
+		}
+	})
+}
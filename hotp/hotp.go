@@ -23,13 +23,14 @@ import (
 	"os"
 
 	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/internal/truncate"
 
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base32"
 	"encoding/binary"
-	"net/url"
+	"hash"
 	"strings"
 )
 
@@ -46,8 +47,8 @@ func Validate(passcode string, counter uint64, secret string) bool {
 		counter,
 		secret,
 		ValidateOpts{
-			Digits:    otp.DigitsSix,
-			Algorithm: otp.AlgorithmSHA1,
+			Digits:    otp.DefaultDigits,
+			Algorithm: otp.DefaultAlgorithm,
 		},
 	)
 	return rv
@@ -59,6 +60,18 @@ type ValidateOpts struct {
 	Digits otp.Digits
 	// Algorithm to use for HMAC. Defaults to SHA1.
 	Algorithm otp.Algorithm
+	// Encoder rendering the passcode. Defaults to decimal digits.
+	Encoder otp.Encoder
+	// Left-pad numeric input shorter than Digits with zeros, for users who
+	// drop the leading zero of codes like 012345. Defaults to false.
+	PadLeadingZeros bool
+	// Remove spaces, dashes and invisible characters and convert
+	// non-ASCII digits, as injected by copy-paste and mobile autofill.
+	// See otp.NormalizeCode. Defaults to false.
+	NormalizeInput bool
+	// Comparator matching the submitted passcode with the generated one.
+	// Defaults to otp.CompareExact.
+	Comparator otp.Comparator
 }
 
 // GenerateCode creates a HOTP passcode given a counter and secret.
@@ -66,70 +79,154 @@ type ValidateOpts struct {
 // are compatible with Google-Authenticator.
 func GenerateCode(secret string, counter uint64) (string, error) {
 	return GenerateCodeCustom(secret, counter, ValidateOpts{
-		Digits:    otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
+		Digits:    otp.DefaultDigits,
+		Algorithm: otp.DefaultAlgorithm,
 	})
 }
 
 // GenerateCodeCustom uses a counter and secret value and options struct to
-// create a passcode.
+// create a passcode. Unsupported digits or algorithms fail with an
+// *otp.OptionError.
 func GenerateCodeCustom(secret string, counter uint64, opts ValidateOpts) (passcode string, err error) {
-	// As noted in issue #10 and #17 this adds support for TOTP secrets that are
-	// missing their padding.
-	secret = strings.TrimSpace(secret)
-	if n := len(secret) % 8; n != 0 {
-		secret = secret + strings.Repeat("=", 8-n)
+	if err := opts.Algorithm.Check(); err != nil {
+		return "", err
+	}
+	if err := opts.Digits.Check(); err != nil {
+		return "", err
 	}
 
-	// As noted in issue #24 Google has started producing base32 in lower case,
-	// but the StdEncoding (and the RFC), expect a dictionary of only upper case letters.
-	secret = strings.ToUpper(secret)
-
-	secretBytes, err := base32.StdEncoding.DecodeString(secret)
+	secretBytes, err := DecodeSecret(secret)
 	if err != nil {
-		return "", otp.ErrValidateSecretInvalidBase32
+		return "", err
+	}
+
+	g := NewGenerator(secretBytes, opts)
+
+	return string(g.AppendCode(nil, counter)), nil
+}
+
+// DecodeSecret converts a base32 encoded secret into the raw key material
+// used for the HMAC operation. It is otp.DecodeSecret.
+func DecodeSecret(secret string) ([]byte, error) {
+	return otp.DecodeSecret(secret)
+}
+
+// Generator computes passcodes for a single decoded secret, reusing its
+// HMAC state and scratch buffers between counters. A Generator is not safe
+// for concurrent use.
+type Generator struct {
+	mac     hash.Hash
+	digits  otp.Digits
+	encoder otp.Encoder
+	buf     [8]byte
+	sum     []byte
+	code    []byte
+}
+
+// NewGenerator creates a Generator for the raw key material and options.
+// The algorithm must have passed otp.Algorithm.Check.
+func NewGenerator(key []byte, opts ValidateOpts) *Generator {
+	return &Generator{
+		mac:     hmac.New(opts.Algorithm.Hash, key),
+		digits:  opts.Digits,
+		encoder: opts.Encoder,
 	}
+}
 
-	buf := make([]byte, 8)
-	mac := hmac.New(opts.Algorithm.Hash, secretBytes)
+// Value returns the truncated HOTP value for counter, reduced to the
+// configured number of digits.
+func (g *Generator) Value(counter uint64) int32 {
+	value := g.Truncate(counter)
 
-	binary.BigEndian.PutUint64(buf, counter)
+	mod := g.digits.Reduce(value)
 
 	if debug {
-		dlog.Printf("counter=%v\n", counter)
-		dlog.Printf("buf=%v\n", buf)
+		dlog.Printf("mod'ed=%v\n", mod)
 	}
 
-	if _, err := mac.Write(buf); err != nil {
-		return "", err
+	return mod
+}
+
+// Truncate returns the 31-bit value produced by the "Dynamic truncation"
+// of RFC 4226 for counter, before it is reduced to a passcode.
+func (g *Generator) Truncate(counter uint64) uint32 {
+	binary.BigEndian.PutUint64(g.buf[:], counter)
+
+	if debug {
+		dlog.Printf("counter=%v\n", counter)
+		dlog.Printf("buf=%v\n", g.buf)
 	}
 
-	sum := mac.Sum(nil)
+	g.mac.Reset()
+	g.mac.Write(g.buf[:])
+	g.sum = g.mac.Sum(g.sum[:0])
 
 	// "Dynamic truncation" in RFC 4226
 	// http://tools.ietf.org/html/rfc4226#section-5.4
-	offset := sum[len(sum)-1] & 0xf
-
-	value := int64(((int(sum[offset]) & 0x7f) << 24) |
-		((int(sum[offset+1] & 0xff)) << 16) |
-		((int(sum[offset+2] & 0xff)) << 8) |
-		(int(sum[offset+3]) & 0xff))
-
-	mod := int32(value % int64(opts.Digits.Base()))
+	value := truncate.Truncate(g.sum)
 
 	if debug {
-		dlog.Printf("offset=%v\n", offset)
 		dlog.Printf("value=%v\n", value)
-		dlog.Printf("mod'ed=%v\n", mod)
 	}
 
-	return opts.Digits.Format(mod), nil
+	return value
+}
+
+// AppendCode appends the zero-filled passcode for counter to dst and
+// returns the extended buffer.
+func (g *Generator) AppendCode(dst []byte, counter uint64) []byte {
+	if g.encoder != nil {
+		return g.encoder.AppendCode(dst, g.Truncate(counter), g.digits)
+	}
+
+	v := g.Value(counter)
+
+	n := g.digits.Length()
+	if n <= 0 {
+		return append(dst, g.digits.Format(v)...)
+	}
+
+	start := len(dst)
+	for i := 0; i < n; i++ {
+		dst = append(dst, '0')
+	}
+	for i := len(dst) - 1; i >= start && v > 0; i-- {
+		dst[i] = byte('0' + v%10)
+		v /= 10
+	}
+
+	return dst
+}
+
+// Match returns the first of counters whose passcode matches passcode
+// according to compare. Every counter is generated and compared, and,
+// when compare implements otp.ConstantTimeComparator as the Comparators of
+// package otp do, the match is selected without branching, so the time
+// taken does not reveal whether or where in counters the passcode matched.
+func (g *Generator) Match(passcode []byte, counters []uint64, compare otp.Comparator) (uint64, bool) {
+	matched, found := g.ConstantTimeMatch(passcode, counters, compare)
+	return matched, found == 1
+}
+
+// ConstantTimeMatch is Match reporting whether a counter matched as 1 or
+// 0, for callers combining several matches without branching.
+func (g *Generator) ConstantTimeMatch(passcode []byte, counters []uint64, compare otp.Comparator) (matched uint64, found int) {
+	for _, counter := range counters {
+		g.code = g.AppendCode(g.code[:0], counter)
+		eq := otp.ConstantTimeEqual(compare, g.code, passcode)
+		// Keep only the first match.
+		first := subtle.ConstantTimeSelect(found, 0, eq)
+		mask := -uint64(first)
+		matched = matched&^mask | counter&mask
+		found |= first
+	}
+	return matched, found
 }
 
 // ValidateCustom validates an HOTP with customizable options. Most users should
 // use Validate().
 func ValidateCustom(passcode string, counter uint64, secret string, opts ValidateOpts) (bool, error) {
-	passcode = strings.TrimSpace(passcode)
+	passcode = NormalizePasscode(passcode, opts)
 
 	if len(passcode) != opts.Digits.Length() {
 		return false, otp.ErrValidateInputInvalidLength
@@ -140,13 +237,50 @@ func ValidateCustom(passcode string, counter uint64, secret string, opts Validat
 		return false, err
 	}
 
-	if subtle.ConstantTimeCompare([]byte(otpstr), []byte(passcode)) == 1 {
+	if opts.comparator().Equal([]byte(otpstr), []byte(passcode)) {
 		return true, nil
 	}
 
 	return false, nil
 }
 
+// comparator returns the configured Comparator or the default.
+func (opts ValidateOpts) comparator() otp.Comparator {
+	if opts.Comparator == nil {
+		return otp.CompareExact
+	}
+	return opts.Comparator
+}
+
+// NormalizePasscode prepares user input for comparison with a generated
+// passcode, according to opts. Surrounding whitespace is always removed.
+func NormalizePasscode(passcode string, opts ValidateOpts) string {
+	passcode = strings.TrimSpace(passcode)
+
+	if opts.NormalizeInput {
+		passcode = otp.NormalizeCode(passcode)
+	}
+
+	if opts.PadLeadingZeros && (opts.Encoder == nil || opts.Encoder == otp.EncoderDecimal) {
+		passcode = padLeadingZeros(passcode, opts.Digits.Length())
+	}
+
+	return passcode
+}
+
+// padLeadingZeros left-pads a short, purely numeric passcode with zeros.
+func padLeadingZeros(passcode string, length int) string {
+	if passcode == "" || len(passcode) >= length {
+		return passcode
+	}
+	for i := 0; i < len(passcode); i++ {
+		if passcode[i] < '0' || passcode[i] > '9' {
+			return passcode
+		}
+	}
+	return strings.Repeat("0", length-len(passcode)) + passcode
+}
+
 // GenerateOpts provides options for .Generate()
 type GenerateOpts struct {
 	// Name of the issuing Organization/Company.
@@ -163,6 +297,18 @@ type GenerateOpts struct {
 	Algorithm otp.Algorithm
 	// Reader to use for generating HOTP Key.
 	Rand io.Reader
+	// Label builds the label shown by authenticator apps.
+	// Defaults to "Issuer:AccountName".
+	Label otp.LabelFunc
+	// Generate personal-use keys without an Issuer, whose label is only
+	// the AccountName, instead of failing with otp.ErrGenerateMissingIssuer.
+	AllowMissingIssuer bool
+	// ValidateAccountName enforces a naming policy on AccountName, eg
+	// otp.ValidateEmail. Its error is reported for the AccountName field.
+	ValidateAccountName func(name string) error
+	// Policy restricting the digits and algorithm. Violations are reported
+	// for the Digits and Algorithm fields. Defaults to no restriction.
+	Policy *otp.Policy
 }
 
 var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
@@ -170,20 +316,49 @@ var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
 // Generate creates a new HOTP Key.
 func Generate(opts GenerateOpts) (*otp.Key, error) {
 	// url encode the Issuer/AccountName
-	if opts.Issuer == "" {
-		return nil, otp.ErrGenerateMissingIssuer
+	var genErr otp.GenerateError
+
+	if opts.Issuer == "" && !opts.AllowMissingIssuer {
+		genErr.Add("Issuer", opts.Issuer, otp.ErrGenerateMissingIssuer)
 	}
 
 	if opts.AccountName == "" {
-		return nil, otp.ErrGenerateMissingAccountName
+		genErr.Add("AccountName", opts.AccountName, otp.ErrGenerateMissingAccountName)
+	} else if opts.ValidateAccountName != nil {
+		if err := opts.ValidateAccountName(opts.AccountName); err != nil {
+			genErr.Add("AccountName", opts.AccountName, err)
+		}
 	}
 
 	if opts.SecretSize == 0 {
-		opts.SecretSize = 10
+		opts.SecretSize = otp.DefaultHOTPSecretSize
+	}
+
+	if len(opts.Secret) == 0 && opts.SecretSize < otp.MinSecretSize {
+		genErr.Add("SecretSize", opts.SecretSize, otp.ErrGenerateSecretTooShort)
 	}
 
 	if opts.Digits == 0 {
-		opts.Digits = otp.DigitsSix
+		opts.Digits = otp.DefaultDigits
+	}
+
+	if err := opts.Digits.Check(); err != nil {
+		genErr.Add("Digits", opts.Digits, err)
+	}
+
+	if err := opts.Algorithm.Check(); err != nil {
+		genErr.Add("Algorithm", opts.Algorithm, err)
+	}
+
+	if opts.Policy != nil {
+		if err := opts.Policy.CheckParams(opts.Algorithm, opts.Digits); err != nil {
+			optErr := err.(*otp.OptionError)
+			genErr.Add(optErr.Name, optErr.Value, optErr.Err)
+		}
+	}
+
+	if err := genErr.Err(); err != nil {
+		return nil, err
 	}
 
 	if opts.Rand == nil {
@@ -192,28 +367,27 @@ func Generate(opts GenerateOpts) (*otp.Key, error) {
 
 	// otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example
 
-	v := url.Values{}
-	if len(opts.Secret) != 0 {
-		v.Set("secret", b32NoPadding.EncodeToString(opts.Secret))
-	} else {
-		secret := make([]byte, opts.SecretSize)
-		_, err := opts.Rand.Read(secret)
+	secret := opts.Secret
+	if len(secret) == 0 {
+		var err error
+		secret, err = otp.ReadSecret(opts.Rand, int(opts.SecretSize))
 		if err != nil {
 			return nil, err
 		}
-		v.Set("secret", b32NoPadding.EncodeToString(secret))
 	}
 
-	v.Set("issuer", opts.Issuer)
-	v.Set("algorithm", opts.Algorithm.String())
-	v.Set("digits", opts.Digits.String())
-
-	u := url.URL{
-		Scheme:   "otpauth",
-		Host:     "hotp",
-		Path:     "/" + opts.Issuer + ":" + opts.AccountName,
-		RawQuery: v.Encode(),
+	var label string
+	if opts.Label != nil {
+		label = opts.Label(opts.Issuer, opts.AccountName)
 	}
 
-	return otp.NewKeyFromURL(u.String())
+	return otp.NewKey(otp.KeyOpts{
+		Type:        "hotp",
+		Issuer:      opts.Issuer,
+		AccountName: opts.AccountName,
+		Secret:      b32NoPadding.EncodeToString(secret),
+		Digits:      opts.Digits,
+		Algorithm:   opts.Algorithm,
+		Label:       label,
+	}), nil
 }
@@ -22,7 +22,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"bytes"
 	"encoding/base32"
+	"errors"
+	"math"
 	"testing"
 )
 
@@ -152,14 +155,14 @@ func TestGenerate(t *testing.T) {
 		Issuer:      "",
 		AccountName: "alice@example.com",
 	})
-	require.Equal(t, otp.ErrGenerateMissingIssuer, err, "generate missing issuer")
+	require.True(t, errors.Is(err, otp.ErrGenerateMissingIssuer), "generate missing issuer")
 	require.Nil(t, k, "key should be nil on error.")
 
 	k, err = Generate(GenerateOpts{
 		Issuer:      "Foobar, Inc",
 		AccountName: "",
 	})
-	require.Equal(t, otp.ErrGenerateMissingAccountName, err, "generate missing account name.")
+	require.True(t, errors.Is(err, otp.ErrGenerateMissingAccountName), "generate missing account name.")
 	require.Nil(t, k, "key should be nil on error.")
 
 	k, err = Generate(GenerateOpts{
@@ -180,3 +183,155 @@ func TestGenerate(t *testing.T) {
 	require.NoError(t, err, "Secret wa not valid base32")
 	require.Equal(t, sec, []byte("helloworld"), "Specified Secret was not kept")
 }
+
+func TestGenerateWithOpts(t *testing.T) {
+	k, err := GenerateWithOpts(
+		WithIssuer("SnakeOil"),
+		WithAccountName("alice@example.com"),
+		WithGenDigits(otp.DigitsEight),
+		WithGenAlgorithm(otp.AlgorithmSHA256),
+		WithRand(bytes.NewReader([]byte("helloworld"))),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "SnakeOil", k.Issuer())
+	require.Equal(t, "hotp", k.Type())
+	require.Equal(t, otp.DigitsEight, k.Digits())
+	require.Equal(t, otp.AlgorithmSHA256, k.Algorithm())
+	require.Equal(t, b32NoPadding.EncodeToString([]byte("helloworld")), k.Secret())
+
+	k, err = GenerateWithOpts(WithAccountName("alice@example.com"))
+	require.True(t, errors.Is(err, otp.ErrGenerateMissingIssuer))
+	require.Nil(t, k)
+
+	k, err = GenerateWithOpts(WithAccountName("alice@example.com"), WithoutIssuer(), WithSecret([]byte("helloworld")))
+	require.NoError(t, err)
+	require.Equal(t, "", k.Issuer())
+	sec, err := b32NoPadding.DecodeString(k.Secret())
+	require.NoError(t, err)
+	require.Equal(t, []byte("helloworld"), sec)
+}
+
+func TestGenerateMD5(t *testing.T) {
+	// MD5 digests are shorter than the RFC's dynamic truncation assumes;
+	// every counter must still produce a code.
+	for counter := uint64(0); counter < 256; counter++ {
+		code, err := GenerateCodeCustom(secSha1, counter, ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmMD5,
+		})
+		require.NoError(t, err)
+		require.Len(t, code, 6)
+	}
+}
+
+func TestDecodeSecretErrors(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   otp.SecretError
+	}{
+		{"JBSW0DPE", otp.SecretError{Offset: 4, Char: '0'}},
+		{"  jbsw1dpe", otp.SecretError{Offset: 4, Char: '1'}},
+		{"JBSW==PE", otp.SecretError{Offset: 4, Char: '=', Padding: true}},
+		{"JBSWY3", otp.SecretError{Offset: 6, Padding: true}},
+		{"JBSWY3DPEHPK3PXP========", otp.SecretError{Offset: 16, Char: '=', Padding: true}},
+	}
+
+	for _, tx := range tests {
+		_, err := DecodeSecret(tx.secret)
+		require.True(t, errors.Is(err, otp.ErrValidateSecretInvalidBase32), "secret=%q", tx.secret)
+
+		var secErr *otp.SecretError
+		require.True(t, errors.As(err, &secErr), "secret=%q", tx.secret)
+		require.Equal(t, tx.want, *secErr, "secret=%q err=%v", tx.secret, err)
+	}
+}
+
+func TestMust(t *testing.T) {
+	k := MustGenerate(GenerateOpts{Issuer: "SnakeOil", AccountName: "alice@example.com"})
+	require.Equal(t, "hotp", k.Type())
+	require.Equal(t, "755224", MustGenerateCode(secSha1, 0))
+
+	require.Panics(t, func() { MustGenerate(GenerateOpts{Issuer: "SnakeOil"}) })
+	require.Panics(t, func() { MustGenerateCode("not base32!", 0) })
+}
+
+func TestValidateWithWindow(t *testing.T) {
+	// RFC 4226 Appendix D: counters 0 to 9.
+	codes := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	next, ok, err := ValidateWithWindow(codes[4], 2, secSha1, 3)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(5), next)
+
+	next, ok, err = ValidateWithWindow(codes[6], 2, secSha1, 3)
+	require.NoError(t, err)
+	require.False(t, ok, "outside the look-ahead window")
+	require.Equal(t, uint64(2), next)
+
+	next, ok, err = ValidateWithWindow(codes[1], 2, secSha1, 3)
+	require.NoError(t, err)
+	require.False(t, ok, "counters behind are never accepted")
+	require.Equal(t, uint64(2), next)
+
+	next, ok, err = ValidateWithWindow(codes[2], 2, secSha1, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(3), next)
+
+	_, _, err = ValidateWithWindow("1234", 2, secSha1, 3)
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err)
+
+	opts := ValidateOpts{Digits: otp.DigitsSix, NormalizeInput: true}
+	next, ok, err = opts.ValidateWithWindow("399 871", 0, secSha1, 10)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(9), next)
+
+	next, ok, err = ValidateWithWindow(codes[4], 2, secSha1, math.MaxUint32)
+	var optErr *otp.OptionError
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "LookAhead", optErr.Name)
+	require.True(t, errors.Is(err, otp.ErrInvalidOption))
+	require.False(t, ok)
+	require.Equal(t, uint64(2), next)
+
+	// The last counter is never matched, as its successor wraps to 0.
+	last, err := GenerateCode(secSha1, math.MaxUint64)
+	require.NoError(t, err)
+	next, ok, err = ValidateWithWindow(last, math.MaxUint64-1, secSha1, 3)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, uint64(math.MaxUint64-1), next)
+
+	_, ok, err = ValidateWithWindow(last, math.MaxUint64, secSha1, 3)
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "Counter", optErr.Name)
+	require.False(t, ok)
+}
+
+func TestGeneratorMatch(t *testing.T) {
+	key, err := DecodeSecret(secSha1)
+	require.NoError(t, err)
+	g := NewGenerator(key, ValidateOpts{Digits: otp.DigitsSix})
+
+	// RFC 4226 Appendix D: counter 4 is 338314.
+	counter, ok := g.Match([]byte("338314"), []uint64{2, 3, 4, 5}, otp.CompareExact)
+	require.True(t, ok)
+	require.Equal(t, uint64(4), counter)
+
+	counter, ok = g.Match([]byte("338314"), []uint64{4, 4}, otp.CompareExact)
+	require.True(t, ok)
+	require.Equal(t, uint64(4), counter)
+
+	_, ok = g.Match([]byte("338314"), []uint64{0, 1, 2}, otp.CompareExact)
+	require.False(t, ok)
+	_, ok = g.Match([]byte("338314"), nil, otp.CompareExact)
+	require.False(t, ok)
+
+	counter, found := g.ConstantTimeMatch([]byte("338314"), []uint64{3, 4, 4}, otp.CompareFold)
+	require.Equal(t, 1, found)
+	require.Equal(t, uint64(4), counter)
+	_, found = g.ConstantTimeMatch([]byte("338314"), []uint64{5}, otp.CompareExact)
+	require.Equal(t, 0, found)
+}
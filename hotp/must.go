@@ -0,0 +1,23 @@
+package hotp
+
+import "github.com/pquerna/otp"
+
+// MustGenerate is like Generate but panics if the key cannot be generated.
+// It simplifies tests, examples and init-time fixtures.
+func MustGenerate(opts GenerateOpts) *otp.Key {
+	key, err := Generate(opts)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// MustGenerateCode is like GenerateCode but panics if the code cannot be
+// generated. It simplifies tests, examples and init-time fixtures.
+func MustGenerateCode(secret string, counter uint64) string {
+	passcode, err := GenerateCode(secret, counter)
+	if err != nil {
+		panic(err)
+	}
+	return passcode
+}
@@ -0,0 +1,100 @@
+package hotp
+
+import (
+	"io"
+
+	"github.com/pquerna/otp"
+)
+
+// GenerateOpt sets a field of the GenerateOpts of GenerateWithOpts.
+type GenerateOpt func(opts *GenerateOpts)
+
+// WithIssuer sets the name of the issuing organization.
+func WithIssuer(issuer string) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Issuer = issuer
+	}
+}
+
+// WithAccountName sets the name of the user's account.
+func WithAccountName(account string) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.AccountName = account
+	}
+}
+
+// WithSecret stores secret instead of a randomly generated one.
+func WithSecret(secret []byte) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Secret = secret
+	}
+}
+
+// WithSecretSize sets the size in bytes of the generated secret.
+func WithSecretSize(size uint) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.SecretSize = size
+	}
+}
+
+// WithRand reads the generated secret from r instead of crypto/rand.
+func WithRand(r io.Reader) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Rand = r
+	}
+}
+
+// WithGenDigits sets the digits of the passcodes of the key.
+func WithGenDigits(digits otp.Digits) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Digits = digits
+	}
+}
+
+// WithGenAlgorithm sets the HMAC algorithm of the key.
+func WithGenAlgorithm(algo otp.Algorithm) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Algorithm = algo
+	}
+}
+
+// WithoutIssuer allows generating a personal-use key without an issuer,
+// whose label is only the account name.
+func WithoutIssuer() GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.AllowMissingIssuer = true
+	}
+}
+
+// WithAccountNameValidator enforces a naming policy on the account name,
+// eg otp.ValidateEmail or otp.ValidateUsername.
+func WithAccountNameValidator(fn func(name string) error) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.ValidateAccountName = fn
+	}
+}
+
+// WithLabel builds the label shown by authenticator apps with fn instead
+// of the default "Issuer:AccountName".
+func WithLabel(fn otp.LabelFunc) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Label = fn
+	}
+}
+
+// WithGenPolicy rejects digits and algorithms policy does not allow.
+func WithGenPolicy(policy *otp.Policy) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Policy = policy
+	}
+}
+
+// GenerateWithOpts creates a new HOTP Key from functional options, like
+// totp.GenerateWithOpts. Unset options take the defaults of Generate.
+func GenerateWithOpts(genOpts ...GenerateOpt) (*otp.Key, error) {
+	var opts GenerateOpts
+	for _, opt := range genOpts {
+		opt(&opts)
+	}
+	return Generate(opts)
+}
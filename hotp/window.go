@@ -0,0 +1,75 @@
+package hotp
+
+import (
+	"math"
+
+	"github.com/pquerna/otp"
+)
+
+// MaxLookAhead is the largest lookAhead ValidateWithWindow accepts. RFC
+// 4226 section 7.4 recommends a small window; this bound only keeps a
+// misconfigured window from allocating and hashing without limit.
+const MaxLookAhead = 1000
+
+// ValidateWithWindow validates passcode against the counters from counter
+// to counter+lookAhead, to resynchronize tokens whose counter moved ahead
+// of the server's, eg after button presses that were never submitted
+// (RFC 4226 section 7.4). On success it returns the counter following the
+// matched one, which the caller must store so the passcode cannot be used
+// again; otherwise it returns counter unchanged.
+// This is a shortcut for ValidateOpts.ValidateWithWindow, with parameters
+// that are compatible with Google-Authenticator.
+func ValidateWithWindow(passcode string, counter uint64, secret string, lookAhead uint) (uint64, bool, error) {
+	opts := ValidateOpts{
+		Digits:    otp.DefaultDigits,
+		Algorithm: otp.DefaultAlgorithm,
+	}
+	return opts.ValidateWithWindow(passcode, counter, secret, lookAhead)
+}
+
+// ValidateWithWindow is ValidateWithWindow with opts. Every counter of the
+// window is tried, so the time taken does not reveal which one matched.
+// Keep lookAhead small: each counter of the window is one more passcode
+// an attacker may guess. A lookAhead above MaxLookAhead fails with an
+// *otp.OptionError, as does the last counter, math.MaxUint64, which has
+// no following counter to store: the window stops before it.
+func (opts ValidateOpts) ValidateWithWindow(passcode string, counter uint64, secret string, lookAhead uint) (uint64, bool, error) {
+	if lookAhead > MaxLookAhead {
+		return counter, false, &otp.OptionError{Name: "LookAhead", Value: lookAhead, Err: otp.ErrInvalidOption}
+	}
+	if counter == math.MaxUint64 {
+		return counter, false, &otp.OptionError{Name: "Counter", Value: counter, Err: otp.ErrInvalidOption}
+	}
+
+	passcode = NormalizePasscode(passcode, opts)
+
+	if len(passcode) != opts.Digits.Length() {
+		return counter, false, otp.ErrValidateInputInvalidLength
+	}
+
+	if err := opts.Algorithm.Check(); err != nil {
+		return counter, false, err
+	}
+
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return counter, false, err
+	}
+
+	// Matching math.MaxUint64 would return 0 and reopen every passcode.
+	if uint64(lookAhead) >= math.MaxUint64-counter {
+		lookAhead = uint(math.MaxUint64 - counter - 1)
+	}
+
+	counters := make([]uint64, lookAhead+1)
+	for i := range counters {
+		counters[i] = counter + uint64(i)
+	}
+
+	g := NewGenerator(key, opts)
+	matched, ok := g.Match([]byte(passcode), counters, opts.comparator())
+	if !ok {
+		return counter, false, nil
+	}
+	return matched + 1, true, nil
+}
@@ -0,0 +1,219 @@
+// Package hwtoken exports provisioned secrets in the layouts expected by
+// programmable hardware tokens and the tools importing their seeds, for
+// organizations issuing physical OTP devices.
+//
+// Every format holds the secrets in plain text: write them to protected
+// storage only, and delete them once imported.
+package hwtoken
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"strconv"
+
+	"github.com/pquerna/otp"
+)
+
+// Token is a hardware token and the key burnt into it.
+type Token struct {
+	// Serial number printed on the token.
+	Serial string
+	Key    *otp.Key
+	// User the token is assigned to, eg the UPN of Azure AD.
+	User string
+	// Manufacturer and Model of the token.
+	Manufacturer string
+	Model        string
+}
+
+// secret returns the decoded secret of the token.
+func (t *Token) secret() ([]byte, error) {
+	return otp.DecodeSecret(t.Key.Secret())
+}
+
+// WriteSeedCSV writes the tokens as the generic seed file of token burning
+// tools: a header, then serial, hex seed, type, digits, period (0 for
+// HOTP), algorithm and counter.
+func WriteSeedCSV(w io.Writer, tokens []Token) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"serial", "seed", "type", "digits", "period", "algorithm", "counter"})
+	for _, t := range tokens {
+		secret, err := t.secret()
+		if err != nil {
+			return err
+		}
+		period := "0"
+		if t.Key.Type() == "totp" {
+			period = strconv.FormatUint(t.Key.Period(), 10)
+		}
+		cw.Write([]string{
+			t.Serial,
+			hex.EncodeToString(secret),
+			t.Key.Type(),
+			t.Key.Digits().String(),
+			period,
+			t.Key.Algorithm().String(),
+			strconv.FormatUint(t.Key.Counter(), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteAzureCSV writes the tokens in the CSV layout Azure AD imports OATH
+// hardware tokens from. Azure only supports 6 digit SHA1 TOTP keys with a
+// period of 30 or 60 seconds; other keys fail with an *otp.OptionError.
+func WriteAzureCSV(w io.Writer, tokens []Token) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"upn", "serial number", "secret key", "time interval", "manufacturer", "model"})
+	for _, t := range tokens {
+		if err := checkAzure(t.Key); err != nil {
+			return err
+		}
+		cw.Write([]string{
+			t.User,
+			t.Serial,
+			t.Key.Secret(),
+			strconv.FormatUint(t.Key.Period(), 10),
+			t.Manufacturer,
+			t.Model,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func checkAzure(key *otp.Key) error {
+	switch {
+	case key.Type() != "totp":
+		return &otp.OptionError{Name: "Type", Value: key.Type(), Err: otp.ErrUnsupportedType}
+	case key.Digits() != otp.DigitsSix:
+		return &otp.OptionError{Name: "Digits", Value: key.Digits(), Err: otp.ErrUnsupportedDigits}
+	case key.Algorithm() != otp.AlgorithmSHA1:
+		return &otp.OptionError{Name: "Algorithm", Value: key.Algorithm(), Err: otp.ErrUnsupportedAlgorithm}
+	case key.Period() != 30 && key.Period() != 60:
+		return &otp.OptionError{Name: "Period", Value: key.Period(), Err: otp.ErrInvalidPeriod}
+	}
+	return nil
+}
+
+// PSKC algorithm URIs of RFC 6030.
+const (
+	PSKCNamespace = "urn:ietf:params:xml:ns:keyprov:pskc"
+	PSKCHOTP      = "urn:ietf:params:xml:ns:keyprov:pskc:hotp"
+	PSKCTOTP      = "urn:ietf:params:xml:ns:keyprov:pskc:totp"
+)
+
+// KeyContainer is the document of a PSKC (RFC 6030) file, holding plain
+// text secrets.
+type KeyContainer struct {
+	XMLName  xml.Name     `xml:"urn:ietf:params:xml:ns:keyprov:pskc KeyContainer"`
+	Version  string       `xml:"Version,attr"`
+	Packages []KeyPackage `xml:"KeyPackage"`
+}
+
+// KeyPackage is a token and its key.
+type KeyPackage struct {
+	Device DeviceInfo `xml:"DeviceInfo"`
+	Key    PSKCKey    `xml:"Key"`
+}
+
+// DeviceInfo describes a token.
+type DeviceInfo struct {
+	Manufacturer string `xml:"Manufacturer,omitempty"`
+	SerialNo     string `xml:"SerialNo"`
+	Model        string `xml:"Model,omitempty"`
+}
+
+// PSKCKey is a key of a KeyPackage.
+type PSKCKey struct {
+	ID        string         `xml:"Id,attr"`
+	Algorithm string         `xml:"Algorithm,attr"`
+	Issuer    string         `xml:"Issuer,omitempty"`
+	Params    PSKCParameters `xml:"AlgorithmParameters"`
+	Data      PSKCData       `xml:"Data"`
+	UserID    string         `xml:"UserId,omitempty"`
+}
+
+// PSKCParameters are the algorithm parameters of a key.
+type PSKCParameters struct {
+	// Suite is the HMAC of the key, eg HMAC-SHA256.
+	Suite  string         `xml:"Suite,omitempty"`
+	Format ResponseFormat `xml:"ResponseFormat"`
+}
+
+// ResponseFormat describes the passcodes of a key.
+type ResponseFormat struct {
+	Length   int    `xml:"Length,attr"`
+	Encoding string `xml:"Encoding,attr"`
+}
+
+// PSKCData holds the secret and the moving factor of a key.
+type PSKCData struct {
+	Secret       PlainValue  `xml:"Secret"`
+	Counter      *PlainValue `xml:"Counter,omitempty"`
+	TimeInterval *PlainValue `xml:"TimeInterval,omitempty"`
+}
+
+// PlainValue is an unencrypted value; secrets are base64.
+type PlainValue struct {
+	Value string `xml:"PlainValue"`
+}
+
+// PSKC returns the KeyContainer of tokens.
+func PSKC(tokens []Token) (*KeyContainer, error) {
+	kc := &KeyContainer{Version: "1.0"}
+	for _, t := range tokens {
+		secret, err := t.secret()
+		if err != nil {
+			return nil, err
+		}
+
+		key := PSKCKey{
+			ID:     t.Serial,
+			Issuer: t.Key.Issuer(),
+			Params: PSKCParameters{
+				Suite:  "HMAC-" + t.Key.Algorithm().String(),
+				Format: ResponseFormat{Length: t.Key.Digits().Length(), Encoding: "DECIMAL"},
+			},
+			Data:   PSKCData{Secret: PlainValue{base64.StdEncoding.EncodeToString(secret)}},
+			UserID: t.User,
+		}
+		if t.Key.Type() == "totp" {
+			key.Algorithm = PSKCTOTP
+			key.Data.TimeInterval = &PlainValue{strconv.FormatUint(t.Key.Period(), 10)}
+		} else {
+			key.Algorithm = PSKCHOTP
+			key.Data.Counter = &PlainValue{strconv.FormatUint(t.Key.Counter(), 10)}
+		}
+
+		kc.Packages = append(kc.Packages, KeyPackage{
+			Device: DeviceInfo{Manufacturer: t.Manufacturer, SerialNo: t.Serial, Model: t.Model},
+			Key:    key,
+		})
+	}
+	return kc, nil
+}
+
+// WritePSKC writes the tokens as a PSKC file, the format most token
+// vendors accept for seed import.
+func WritePSKC(w io.Writer, tokens []Token) error {
+	kc, err := PSKC(tokens)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(kc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
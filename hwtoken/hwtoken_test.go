@@ -0,0 +1,72 @@
+package hwtoken
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func token(t *testing.T, url, serial string) Token {
+	key, err := otp.NewKeyFromURL(url)
+	require.NoError(t, err)
+	return Token{Serial: serial, Key: key, User: "alice@example.com", Manufacturer: "Token2", Model: "C202"}
+}
+
+func TestWriteSeedCSV(t *testing.T) {
+	tokens := []Token{
+		token(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example", "1001"),
+		token(t, "otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&counter=7&digits=8&algorithm=SHA256", "1002"),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSeedCSV(&buf, tokens))
+	require.Equal(t, "serial,seed,type,digits,period,algorithm,counter\n"+
+		"1001,48656c6c6f21deadbeef,totp,6,30,SHA1,0\n"+
+		"1002,48656c6c6f21deadbeef,hotp,8,0,SHA256,7\n", buf.String())
+}
+
+func TestWriteAzureCSV(t *testing.T) {
+	var buf bytes.Buffer
+	tokens := []Token{token(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&period=60", "1001")}
+	require.NoError(t, WriteAzureCSV(&buf, tokens))
+	require.Equal(t, "upn,serial number,secret key,time interval,manufacturer,model\n"+
+		"alice@example.com,1001,JBSWY3DPEHPK3PXP,60,Token2,C202\n", buf.String())
+
+	tokens = []Token{token(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&digits=8", "1001")}
+	err := WriteAzureCSV(&buf, tokens)
+	require.True(t, errors.Is(err, otp.ErrUnsupportedDigits))
+}
+
+func TestWritePSKC(t *testing.T) {
+	tokens := []Token{
+		token(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example", "1001"),
+		token(t, "otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&counter=7", "1002"),
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePSKC(&buf, tokens))
+	require.True(t, strings.HasPrefix(buf.String(), xml.Header+"<KeyContainer "))
+
+	var kc KeyContainer
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &kc))
+	require.Len(t, kc.Packages, 2)
+
+	totp := kc.Packages[0]
+	require.Equal(t, "1001", totp.Device.SerialNo)
+	require.Equal(t, PSKCTOTP, totp.Key.Algorithm)
+	require.Equal(t, "SGVsbG8h3q2+7w==", totp.Key.Data.Secret.Value)
+	require.Equal(t, "30", totp.Key.Data.TimeInterval.Value)
+	require.Nil(t, totp.Key.Data.Counter)
+	require.Equal(t, ResponseFormat{Length: 6, Encoding: "DECIMAL"}, totp.Key.Params.Format)
+	require.Equal(t, "HMAC-SHA1", totp.Key.Params.Suite)
+
+	hotp := kc.Packages[1]
+	require.Equal(t, PSKCHOTP, hotp.Key.Algorithm)
+	require.Equal(t, "7", hotp.Key.Data.Counter.Value)
+	require.Nil(t, hotp.Key.Data.TimeInterval)
+}
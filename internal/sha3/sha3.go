@@ -0,0 +1,129 @@
+// Package sha3 implements the SHA3-256 and SHA3-512 hash functions of
+// FIPS 202 for the otp package, which cannot depend on golang.org/x/crypto
+// or the crypto/sha3 package of newer Go releases.
+package sha3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// New256 returns a new hash.Hash computing the SHA3-256 checksum.
+func New256() hash.Hash {
+	return &digest{rate: 136, size: 32}
+}
+
+// New512 returns a new hash.Hash computing the SHA3-512 checksum.
+func New512() hash.Hash {
+	return &digest{rate: 72, size: 64}
+}
+
+// digest is a Keccak sponge with the SHA3 domain padding.
+type digest struct {
+	a    [25]uint64
+	buf  [168]byte
+	n    int
+	rate int
+	size int
+}
+
+func (d *digest) Size() int { return d.size }
+
+// BlockSize returns the rate of the sponge, which HMAC uses as its block
+// size as specified for HMAC-SHA3.
+func (d *digest) BlockSize() int { return d.rate }
+
+func (d *digest) Reset() {
+	d.a = [25]uint64{}
+	d.n = 0
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		c := copy(d.buf[d.n:d.rate], p)
+		d.n += c
+		p = p[c:]
+		if d.n == d.rate {
+			d.absorb()
+		}
+	}
+	return written, nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	dup := *d
+
+	// SHA3 domain bits 01, then the pad10*1 rule.
+	for i := dup.n; i < dup.rate; i++ {
+		dup.buf[i] = 0
+	}
+	dup.buf[dup.n] ^= 0x06
+	dup.buf[dup.rate-1] ^= 0x80
+	dup.absorb()
+
+	var out [64]byte
+	for i := 0; i < dup.size/8; i++ {
+		binary.LittleEndian.PutUint64(out[i*8:], dup.a[i])
+	}
+	return append(b, out[:dup.size]...)
+}
+
+// absorb XORs a full block into the state and permutes it.
+func (d *digest) absorb() {
+	for i := 0; i < d.rate/8; i++ {
+		d.a[i] ^= binary.LittleEndian.Uint64(d.buf[i*8:])
+	}
+	keccakF1600(&d.a)
+	d.n = 0
+}
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotations and piLanes drive the combined rho and pi steps.
+var (
+	rotations = [24]int{1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44}
+	piLanes   = [24]int{10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1}
+)
+
+// keccakF1600 applies the Keccak-f[1600] permutation to a.
+func keccakF1600(a *[25]uint64) {
+	var c [5]uint64
+	for _, rc := range roundConstants {
+		// Theta.
+		for i := 0; i < 5; i++ {
+			c[i] = a[i] ^ a[i+5] ^ a[i+10] ^ a[i+15] ^ a[i+20]
+		}
+		for i := 0; i < 5; i++ {
+			t := c[(i+4)%5] ^ bits.RotateLeft64(c[(i+1)%5], 1)
+			for j := 0; j < 25; j += 5 {
+				a[j+i] ^= t
+			}
+		}
+
+		// Rho and pi.
+		t := a[1]
+		for i, j := range piLanes {
+			t, a[j] = a[j], bits.RotateLeft64(t, rotations[i])
+		}
+
+		// Chi.
+		for j := 0; j < 25; j += 5 {
+			copy(c[:], a[j:j+5])
+			for i := 0; i < 5; i++ {
+				a[j+i] ^= ^c[(i+1)%5] & c[(i+2)%5]
+			}
+		}
+
+		// Iota.
+		a[0] ^= rc
+	}
+}
@@ -0,0 +1,55 @@
+package sha3
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"hash"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectors(t *testing.T) {
+	long := strings.Repeat("a", 200)
+	cases := []struct {
+		h    func() hash.Hash
+		msg  string
+		want string
+	}{
+		{New256, "", "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+		{New256, "abc", "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+		{New256, long, "cce34485baf2bf2aca99b94833892a4f52896d3d153f7b840cc4f9fe695f1387"},
+		{New512, "", "a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26"},
+		{New512, "abc", "b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0"},
+		{New512, long, "eae6c85c6904f11075de9f9d5e1064371d000510fa3d2d79d40cf9be34892fb01859d0a0234e138bcb0ad5c84f6c0dca226a414b0c9a2897cb695f5185fe36ec"},
+	}
+	for _, c := range cases {
+		h := c.h()
+		// Write in uneven pieces to cross block boundaries.
+		for msg := c.msg; len(msg) > 0; {
+			n := 7
+			if n > len(msg) {
+				n = len(msg)
+			}
+			h.Write([]byte(msg[:n]))
+			msg = msg[n:]
+		}
+		require.Equal(t, c.want, hex.EncodeToString(h.Sum(nil)))
+		require.Equal(t, c.want, hex.EncodeToString(h.Sum(nil)), "Sum does not change the state")
+
+		h.Reset()
+		h.Write([]byte(c.msg))
+		require.Equal(t, c.want, hex.EncodeToString(h.Sum(nil)))
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	mac := hmac.New(New256, []byte("12345678901234567890"))
+	mac.Write([]byte{0, 0, 0, 0, 0, 0, 0, 1})
+	require.Equal(t, "6fc70dae0b6b943ebc239278bcfcd010e70ab22550b376572dcceed68bd6f10a", hex.EncodeToString(mac.Sum(nil)))
+
+	mac = hmac.New(New512, []byte(strings.Repeat("k", 200)))
+	mac.Write([]byte("msg"))
+	require.Equal(t, "d51bc7b20e47765dcf05c50adaf322d5464c1dcb0a4b41c0593fe169e402f2c9a6fd6ce1bd01866cc7e7b8cecd57005e1cf1dc08cb07b61094fd09ae0729f318", hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,20 @@
+// Package truncate implements the "Dynamic truncation" of RFC 4226 shared
+// by the otp, hotp and totp packages.
+package truncate
+
+// Truncate returns the 31-bit value selected from an HMAC digest.
+// http://tools.ietf.org/html/rfc4226#section-5.4
+func Truncate(sum []byte) uint32 {
+	offset := int(sum[len(sum)-1] & 0xf)
+
+	// Digests shorter than SHA1 (MD5) can point past the end of the
+	// digest; clamp so the four bytes read are always in range.
+	if max := len(sum) - 4; offset > max {
+		offset = max
+	}
+
+	return uint32(sum[offset]&0x7f)<<24 |
+		uint32(sum[offset+1])<<16 |
+		uint32(sum[offset+2])<<8 |
+		uint32(sum[offset+3])
+}
@@ -0,0 +1,237 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp/internal/truncate"
+)
+
+// Digits returns the number of digits of the passcode. If no digits are
+// defined 6 is the default.
+func (k *Key) Digits() Digits {
+	if d, err := ParseDigits(k.load().params.digits); err == nil {
+		return d
+	}
+	return DefaultDigits
+}
+
+// Algorithm returns the hashing function used for the HMAC. If no
+// algorithm is defined SHA1 is the default.
+func (k *Key) Algorithm() Algorithm {
+	if a, err := ParseAlgorithm(k.load().params.algorithm); err == nil {
+		return a
+	}
+	return DefaultAlgorithm
+}
+
+// Counter returns the counter of a HOTP key, or 0 when it has none.
+func (k *Key) Counter() uint64 {
+	u, _ := strconv.ParseUint(k.load().params.counter, 10, 64)
+	return u
+}
+
+// Validate checks passcode using the key's own secret, digits, algorithm
+// and period, so validation always matches what was provisioned.
+// TOTP keys accept the passcode of t and of one period either side; HOTP
+// keys accept the passcode of their counter parameter and ignore t.
+// Keys past their expiry fail with an *ExpiredError, see WithExpiry, and
+// sealed keys with ErrKeySealed.
+func (k *Key) Validate(passcode string, t time.Time) (bool, error) {
+	_, _, ok, err := k.match(passcode, t)
+	return ok, err
+}
+
+// Match is Validate, also returning the counter of the matched passcode,
+// eg to record it in a ReplayStore.
+func (k *Key) Match(passcode string, t time.Time) (counter uint64, ok bool, err error) {
+	counter, _, ok, err = k.match(passcode, t)
+	return counter, ok, err
+}
+
+// match is Match, also returning the drift of the matched passcode in
+// periods: -1, 0 or 1 for TOTP keys, always 0 for HOTP keys.
+func (k *Key) match(passcode string, t time.Time) (uint64, int, bool, error) {
+	ks := k.load()
+	if err := ks.params.checkExpiry(t); err != nil {
+		return 0, 0, false, err
+	}
+
+	kc, err := ks.codeParams()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != kc.digits.Length() {
+		return 0, 0, false, ErrValidateInputInvalidLength
+	}
+
+	counters := []uint64{kc.counter}
+	if kc.period != 0 {
+		c := kc.counterAt(t)
+		counters = []uint64{c, c + 1, c - 1}
+	}
+
+	// Compare every counter and select the first match without
+	// branching, so the time taken does not reveal which one matched.
+	drifts := []int{0, 1, -1}
+	var matched uint64
+	drift, found := 0, 0
+	for i, counter := range counters {
+		eq := subtle.ConstantTimeCompare(kc.code(counter), []byte(passcode))
+		first := eq &^ found
+		drift = subtle.ConstantTimeSelect(first, drifts[i], drift)
+		matched = matched&^-uint64(first) | counter&-uint64(first)
+		found |= first
+	}
+
+	return matched, drift, found == 1, nil
+}
+
+// GenerateCode returns the passcode of the key at t, using the key's own
+// secret, digits, algorithm and period. HOTP keys return the passcode of
+// their counter parameter and ignore t.
+func (k *Key) GenerateCode(t time.Time) (string, error) {
+	kc, err := k.load().codeParams()
+	if err != nil {
+		return "", err
+	}
+
+	return string(kc.code(kc.counterAt(t))), nil
+}
+
+// ID returns the identifier of the key in stores: its issuer and account
+// name separated by a colon.
+func (k *Key) ID() string {
+	return k.Issuer() + ":" + k.AccountName()
+}
+
+// NextCode consumes the next counter of a HOTP key from store and returns
+// its passcode. The store counts the codes used since the key was
+// provisioned, so the first code is the one of the key's counter parameter.
+// A failing store is reported with an error matching ErrStore; the context's
+// deadline and cancellation are reported with the context's error.
+func (k *Key) NextCode(ctx context.Context, store CounterStore) (string, error) {
+	kc, err := k.load().codeParams()
+	if err != nil {
+		return "", err
+	}
+
+	if kc.period != 0 {
+		return "", &OptionError{Name: "Type", Value: k.Type(), Err: ErrUnsupportedType}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := k.ID()
+	n, err := store.Next(ctx, id)
+	if err != nil {
+		return "", WrapStoreError("Next", id, err)
+	}
+
+	return string(kc.code(kc.counter + n)), nil
+}
+
+// keyCode holds the parameters of a key needed to compute its passcodes.
+type keyCode struct {
+	key       []byte
+	digits    Digits
+	algorithm Algorithm
+	// period of a TOTP key in seconds, 0 for HOTP keys.
+	period uint64
+	// epoch of a TOTP key in Unix seconds, see EpochParam.
+	epoch int64
+	// counter of a HOTP key.
+	counter uint64
+}
+
+// codeParams parses the parameters needed to compute passcodes, applying
+// the defaults of the Key URI format to missing ones.
+func (ks *keyState) codeParams() (*keyCode, error) {
+	kc := &keyCode{digits: DefaultDigits, algorithm: DefaultAlgorithm}
+	p := &ks.params
+	if p.extra.Get(SealedParam) != "" {
+		return nil, ErrKeySealed
+	}
+
+	var err error
+	if kc.key, err = DecodeSecret(p.secret); err != nil {
+		return nil, err
+	}
+
+	if p.digits != "" {
+		if kc.digits, err = ParseDigits(p.digits); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.algorithm != "" {
+		if kc.algorithm, err = ParseAlgorithm(p.algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	switch strings.ToLower(ks.typ) {
+	case "totp":
+		kc.period = DefaultPeriod
+		if p.period != "" {
+			kc.period, err = strconv.ParseUint(p.period, 10, 64)
+			if err != nil || kc.period == 0 {
+				return nil, &OptionError{Name: "Period", Value: p.period, Err: ErrInvalidPeriod}
+			}
+		}
+		if kc.epoch, err = p.epoch(); err != nil {
+			return nil, err
+		}
+	case "hotp":
+		if p.counter != "" {
+			if kc.counter, err = strconv.ParseUint(p.counter, 10, 64); err != nil {
+				return nil, &OptionError{Name: "Counter", Value: p.counter, Err: err}
+			}
+		}
+	default:
+		return nil, &OptionError{Name: "Type", Value: ks.typ, Err: ErrUnsupportedType}
+	}
+
+	return kc, nil
+}
+
+// counterAt returns the counter to generate the passcode of t with. Like
+// totp, it rounds down, so times before the epoch give negative counters,
+// which wrap around.
+func (kc *keyCode) counterAt(t time.Time) uint64 {
+	if kc.period == 0 {
+		return kc.counter
+	}
+	return uint64(floorDiv(t.Unix()-kc.epoch, int64(kc.period)))
+}
+
+// floorDiv returns a/b rounded down.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return q
+}
+
+// code returns the zero-filled decimal passcode for counter.
+func (kc *keyCode) code(counter uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+
+	mac := hmac.New(kc.algorithm.Hash, kc.key)
+	mac.Write(buf[:])
+
+	v := kc.digits.Reduce(truncate.Truncate(mac.Sum(nil)))
+
+	return []byte(kc.digits.Format(v))
+}
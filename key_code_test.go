@@ -0,0 +1,223 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 Appendix B, SHA1 seed "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestKeyValidate(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=" + rfcSecret + "&digits=8")
+	require.NoError(t, err)
+	require.Equal(t, DigitsEight, k.Digits())
+	require.Equal(t, AlgorithmSHA1, k.Algorithm())
+
+	ts := time.Unix(1111111109, 0)
+	valid, err := k.Validate("07081804", ts)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = k.Validate("07081804", ts.Add(30*time.Second))
+	require.NoError(t, err)
+	require.True(t, valid, "one period of skew")
+
+	valid, err = k.Validate("07081804", ts.Add(90*time.Second))
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	_, err = k.Validate("081804", ts)
+	require.Equal(t, ErrValidateInputInvalidLength, err, "digits come from the key")
+}
+
+func TestKeyMatch(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=" + rfcSecret + "&digits=8")
+	require.NoError(t, err)
+
+	ts := time.Unix(1111111109, 0)
+	counter, ok, err := k.Match("07081804", ts.Add(30*time.Second))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(1111111109/30), counter, "the counter of the matched period")
+
+	_, ok, err = k.Match("00000000", ts)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestKeyGenerateCode(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=" + rfcSecret + "&digits=8")
+	require.NoError(t, err)
+
+	code, err := k.GenerateCode(time.Unix(59, 0))
+	require.NoError(t, err)
+	require.Equal(t, "94287082", code)
+
+	k, err = NewKeyFromURL("otpauth://hotp/Example:alice?secret=" + rfcSecret + "&counter=9")
+	require.NoError(t, err)
+
+	code, err = k.GenerateCode(time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "520489", code)
+
+	k.SetSecret("JBSWY3D1")
+	_, err = k.GenerateCode(time.Now())
+	require.True(t, errors.Is(err, ErrValidateSecretInvalidBase32))
+}
+
+func TestKeyValidateHOTP(t *testing.T) {
+	// RFC 4226 Appendix D, count 1.
+	k, err := NewKeyFromURL("otpauth://hotp/Example:alice?secret=" + rfcSecret + "&counter=1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), k.Counter())
+
+	valid, err := k.Validate("287082", time.Time{})
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestKeyValidateErrors(t *testing.T) {
+	for _, tx := range []struct {
+		url string
+		err error
+	}{
+		{"otpauth://totp/a?secret=JBSWY3D1", ErrValidateSecretInvalidBase32},
+		{"otpauth://totp/a?secret=JBSWY3DP&digits=5", ErrUnsupportedDigits},
+		{"otpauth://totp/a?secret=JBSWY3DP&algorithm=SHA3", ErrUnsupportedAlgorithm},
+		{"otpauth://totp/a?secret=JBSWY3DP&period=0", ErrInvalidPeriod},
+		{"otpauth://motp/a?secret=JBSWY3DP", ErrUnsupportedType},
+	} {
+		k, err := NewKeyFromURL(tx.url)
+		require.NoError(t, err)
+
+		_, err = k.Validate("123456", time.Now())
+		require.True(t, errors.Is(err, tx.err), tx.url)
+	}
+}
+
+func TestKeyNextCode(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://hotp/Example:alice?secret=" + rfcSecret + "&counter=1")
+	require.NoError(t, err)
+
+	var store MemoryCounterStore
+	ctx := context.Background()
+
+	// RFC 4226 Appendix D, counts 1 to 3.
+	for _, want := range []string{"287082", "359152", "969429"} {
+		code, err := k.NextCode(ctx, &store)
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+
+	other, err := NewKeyFromURL("otpauth://hotp/Example:bob?secret=" + rfcSecret)
+	require.NoError(t, err)
+	code, err := other.NextCode(ctx, &store)
+	require.NoError(t, err)
+	require.Equal(t, "755224", code, "counters are kept per key")
+
+	totp, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=" + rfcSecret)
+	require.NoError(t, err)
+	_, err = totp.NextCode(ctx, &store)
+	require.True(t, errors.Is(err, ErrUnsupportedType))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = k.NextCode(canceled, &store)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestKeyDigits(t *testing.T) {
+	for _, d := range []Digits{DigitsSeven, DigitsNine, DigitsTen} {
+		require.NoError(t, d.Check())
+
+		k := NewKey(KeyOpts{Type: "totp", AccountName: "alice", Secret: rfcSecret, Period: 30, Digits: d})
+		parsed, err := NewKeyFromURL(k.String())
+		require.NoError(t, err)
+		require.Equal(t, d, parsed.Digits())
+
+		code, err := parsed.GenerateCode(time.Unix(59, 0))
+		require.NoError(t, err)
+		require.Len(t, code, d.Length())
+		// The codes share their low-order digits with the RFC 6238 vector.
+		require.Equal(t, "4287082", code[len(code)-7:])
+	}
+
+	require.Equal(t, "0000012345", DigitsTen.Format(12345))
+	require.Equal(t, int32(2147483647), DigitsTen.Reduce(1<<31-1))
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	for _, a := range []Algorithm{AlgorithmSHA224, AlgorithmSHA512_256, AlgorithmSHA3_256, AlgorithmSHA3_512} {
+		require.NoError(t, a.Check())
+
+		k := NewKey(KeyOpts{Type: "totp", AccountName: "alice", Secret: rfcSecret, Period: 30, Digits: DigitsSix, Algorithm: a})
+		parsed, err := NewKeyFromURL(k.String())
+		require.NoError(t, err)
+		require.Equal(t, a, parsed.Algorithm())
+		require.Equal(t, k.String(), parsed.URL())
+
+		text, err := a.MarshalText()
+		require.NoError(t, err)
+		var b Algorithm
+		require.NoError(t, b.UnmarshalText(text))
+		require.Equal(t, a, b)
+
+		code, err := parsed.GenerateCode(time.Unix(59, 0))
+		require.NoError(t, err)
+		require.Len(t, code, 6)
+	}
+
+	require.Contains(t, NewKey(KeyOpts{Type: "totp", Algorithm: AlgorithmSHA512_256}).String(), "algorithm=SHA512%2F256")
+
+	a, err := ParseAlgorithm("sha512-256")
+	require.NoError(t, err)
+	require.Equal(t, AlgorithmSHA512_256, a)
+
+	require.Contains(t, NewKey(KeyOpts{Type: "totp", Algorithm: AlgorithmSHA3_256}).String(), "algorithm=SHA3-256")
+	a, err = ParseAlgorithm("sha3_512")
+	require.NoError(t, err)
+	require.Equal(t, AlgorithmSHA3_512, a)
+
+	// HMAC-SHA3 codes of the RFC 4226 secret at counter 1.
+	for a, want := range map[Algorithm]string{AlgorithmSHA3_256: "902588", AlgorithmSHA3_512: "625483"} {
+		k := NewKey(KeyOpts{Type: "hotp", AccountName: "alice", Secret: rfcSecret, Digits: DigitsSix, Algorithm: a}).Clone(WithCounter(1))
+		code, err := k.GenerateCode(time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+}
+
+type failingCounterStore struct{ err error }
+
+func (s failingCounterStore) Next(ctx context.Context, id string) (uint64, error) {
+	if s.err == nil {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return 0, s.err
+}
+
+func TestKeyNextCodeStoreErrors(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://hotp/Example:alice?secret=" + rfcSecret)
+	require.NoError(t, err)
+
+	cause := errors.New("connection refused")
+	_, err = k.NextCode(context.Background(), failingCounterStore{err: cause})
+	require.True(t, errors.Is(err, ErrStore))
+	require.True(t, errors.Is(err, cause))
+
+	var storeErr *StoreError
+	require.True(t, errors.As(err, &storeErr))
+	require.Equal(t, "Next", storeErr.Op)
+	require.Equal(t, "Example:alice", storeErr.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = k.NextCode(ctx, failingCounterStore{})
+	require.Equal(t, context.DeadlineExceeded, err, "deadlines are not store failures")
+}
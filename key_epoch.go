@@ -0,0 +1,50 @@
+package otp
+
+import (
+	"strconv"
+	"time"
+)
+
+// EpochParam is the URL parameter holding the T0 of a TOTP key, the Unix
+// time in seconds its periods are counted from (RFC 6238 section 4.1).
+// Keys without it count from the Unix epoch, as most authenticator apps
+// do regardless of the parameter.
+const EpochParam = "t0"
+
+// WithEpoch sets the time the periods of a TOTP key are counted from, for
+// interop with systems using a non-zero T0. The zero time removes it.
+func WithEpoch(t0 time.Time) KeyOpt {
+	return func(ks *keyState) {
+		if t0.IsZero() {
+			ks.params.extra.Del(EpochParam)
+			return
+		}
+		if ks.params.extra == nil {
+			ks.params.extra = make(map[string][]string, 1)
+		}
+		ks.params.extra.Set(EpochParam, strconv.FormatInt(t0.Unix(), 10))
+	}
+}
+
+// Epoch returns the time the periods of the key are counted from, the
+// zero time when the key has no valid t0 parameter.
+func (k *Key) Epoch() time.Time {
+	t0, err := k.load().params.epoch()
+	if err != nil || t0 == 0 {
+		return time.Time{}
+	}
+	return time.Unix(t0, 0)
+}
+
+// epoch parses the t0 parameter, returning 0 when there is none.
+func (p *keyParams) epoch() (int64, error) {
+	s := p.extra.Get(EpochParam)
+	if s == "" {
+		return 0, nil
+	}
+	t0, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &OptionError{Name: "T0", Value: s, Err: err}
+	}
+	return t0, nil
+}
@@ -0,0 +1,47 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyEpoch(t *testing.T) {
+	key, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.True(t, key.Epoch().IsZero())
+
+	t0 := time.Unix(1000000015, 0)
+	shifted := key.Clone(WithEpoch(t0))
+	require.Contains(t, shifted.String(), "t0=1000000015")
+	require.True(t, t0.Equal(shifted.Epoch()))
+
+	at := time.Unix(1111111109, 0)
+	want, err := key.GenerateCode(at.Add(-1000000015 * time.Second))
+	require.NoError(t, err)
+	code, err := shifted.GenerateCode(at)
+	require.NoError(t, err)
+	require.Equal(t, want, code)
+
+	valid, err := shifted.Validate(code, at)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	require.True(t, shifted.Clone(WithEpoch(time.Time{})).Epoch().IsZero())
+
+	// Before T0 the counter rounds down to -1, as in totp.
+	last, err := NewKeyFromURL("otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&counter=18446744073709551615")
+	require.NoError(t, err)
+	want, err = last.GenerateCode(time.Time{})
+	require.NoError(t, err)
+	code, err = shifted.GenerateCode(t0.Add(-15 * time.Second))
+	require.NoError(t, err)
+	require.Equal(t, want, code)
+
+	bad, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&t0=soon")
+	require.NoError(t, err)
+	_, err = bad.GenerateCode(at)
+	require.True(t, errors.Is(err, ErrInvalidOption))
+}
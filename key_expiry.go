@@ -0,0 +1,73 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ExpiresParam is the URL parameter holding the expiry of a key, in Unix
+// seconds. Authenticator apps ignore it.
+const ExpiresParam = "expires"
+
+// The key has expired and must be re-enrolled. Every ExpiredError matches
+// it with errors.Is.
+var ErrKeyExpired = errors.New("Key expired")
+
+// ExpiredError records the expiry of a key rejected by Validate.
+type ExpiredError struct {
+	Expiry time.Time
+}
+
+func (e *ExpiredError) Error() string {
+	return fmt.Sprintf("%v at %s", ErrKeyExpired, e.Expiry.UTC().Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrKeyExpired.
+func (e *ExpiredError) Is(target error) bool {
+	return target == ErrKeyExpired
+}
+
+// WithExpiry sets the time after which the key is rejected by Validate,
+// for policies requiring periodic re-enrollment. The zero time removes the
+// expiry.
+func WithExpiry(expiry time.Time) KeyOpt {
+	return func(ks *keyState) {
+		if expiry.IsZero() {
+			ks.params.extra.Del(ExpiresParam)
+			return
+		}
+		if ks.params.extra == nil {
+			ks.params.extra = make(map[string][]string, 1)
+		}
+		ks.params.extra.Set(ExpiresParam, strconv.FormatInt(expiry.Unix(), 10))
+	}
+}
+
+// Expiry returns the expiry of the key, and false when it has none.
+func (k *Key) Expiry() (time.Time, bool) {
+	return k.load().params.expiry()
+}
+
+// expiry parses the expires parameter. An unparsable value is treated as
+// already expired, so a corrupted expiry fails closed.
+func (p *keyParams) expiry() (time.Time, bool) {
+	s := p.extra.Get(ExpiresParam)
+	if s == "" {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Unix(0, 0), true
+	}
+	return time.Unix(n, 0), true
+}
+
+// checkExpiry returns an *ExpiredError if the key has expired at t.
+func (p *keyParams) checkExpiry(t time.Time) error {
+	if expiry, ok := p.expiry(); ok && !t.Before(expiry) {
+		return &ExpiredError{Expiry: expiry}
+	}
+	return nil
+}
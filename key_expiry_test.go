@@ -0,0 +1,50 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyExpiry(t *testing.T) {
+	key, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	_, ok := key.Expiry()
+	require.False(t, ok)
+
+	expiry := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiring := key.Clone(WithExpiry(expiry))
+	require.Contains(t, expiring.String(), "expires=1735689600")
+
+	parsed, err := NewKeyFromURL(expiring.String())
+	require.NoError(t, err)
+	got, ok := parsed.Expiry()
+	require.True(t, ok)
+	require.True(t, expiry.Equal(got))
+
+	before := expiry.Add(-time.Minute)
+	code, err := parsed.GenerateCode(before)
+	require.NoError(t, err)
+	valid, err := parsed.Validate(code, before)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	code, err = parsed.GenerateCode(expiry)
+	require.NoError(t, err)
+	_, err = parsed.Validate(code, expiry)
+	require.True(t, errors.Is(err, ErrKeyExpired))
+	var eerr *ExpiredError
+	require.True(t, errors.As(err, &eerr))
+	require.True(t, expiry.Equal(eerr.Expiry))
+
+	_, ok = expiring.Clone(WithExpiry(time.Time{})).Expiry()
+	require.False(t, ok)
+
+	// A corrupted expiry fails closed.
+	corrupt, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&expires=soon")
+	require.NoError(t, err)
+	_, err = corrupt.Validate("123456", before)
+	require.True(t, errors.Is(err, ErrKeyExpired))
+}
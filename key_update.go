@@ -0,0 +1,186 @@
+package otp
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SetIssuer changes the issuing organization in both the label and the
+// issuer parameter.
+func (k *Key) SetIssuer(issuer string) {
+	k.update(WithIssuer(issuer))
+}
+
+// SetAccountName changes the name of the user's account.
+func (k *Key) SetAccountName(name string) {
+	k.update(WithAccountName(name))
+}
+
+// SetSecret changes the base32 encoded secret.
+func (k *Key) SetSecret(secret string) {
+	k.update(WithSecret(secret))
+}
+
+// SetPeriod changes the rotation time in seconds.
+func (k *Key) SetPeriod(period uint64) {
+	k.update(WithPeriod(period))
+}
+
+// SetDigits changes the number of digits of the passcode.
+func (k *Key) SetDigits(digits Digits) {
+	k.update(WithDigits(digits))
+}
+
+// SetAlgorithm changes the hashing function used for the HMAC.
+func (k *Key) SetAlgorithm(algorithm Algorithm) {
+	k.update(WithAlgorithm(algorithm))
+}
+
+// KeyOpt changes a component of a Key, see Clone.
+type KeyOpt func(ks *keyState)
+
+// WithIssuer sets the issuing organization in both the label and the
+// issuer parameter.
+func WithIssuer(issuer string) KeyOpt {
+	issuer = normName(issuer)
+	return func(ks *keyState) {
+		ks.path = label(issuer, accountName(ks.path))
+		ks.params.issuer = issuer
+	}
+}
+
+// WithAccountName sets the name of the user's account.
+func WithAccountName(name string) KeyOpt {
+	name = normName(name)
+	return func(ks *keyState) {
+		ks.path = label(labelIssuer(ks.path), name)
+	}
+}
+
+// WithSecret sets the base32 encoded secret.
+func WithSecret(secret string) KeyOpt {
+	return func(ks *keyState) {
+		ks.params.secret = secret
+	}
+}
+
+// WithPeriod sets the rotation time in seconds.
+func WithPeriod(period uint64) KeyOpt {
+	return func(ks *keyState) {
+		ks.params.period = strconv.FormatUint(period, 10)
+	}
+}
+
+// WithDigits sets the number of digits of the passcode.
+func WithDigits(digits Digits) KeyOpt {
+	return func(ks *keyState) {
+		ks.params.digits = digits.String()
+	}
+}
+
+// WithAlgorithm sets the hashing function used for the HMAC.
+func WithAlgorithm(algorithm Algorithm) KeyOpt {
+	return func(ks *keyState) {
+		ks.params.algorithm = algorithm.String()
+	}
+}
+
+// WithCounter sets the counter of a HOTP key, eg to the next value after
+// a passcode was accepted.
+func WithCounter(counter uint64) KeyOpt {
+	return func(ks *keyState) {
+		ks.params.counter = strconv.FormatUint(counter, 10)
+	}
+}
+
+// Clone returns a new Key with the same components as k, changed by
+// keyOpts, eg k.Clone(WithPeriod(60), WithDigits(DigitsEight)) when
+// migrating users to stronger settings. The URL of the new Key is
+// generated from its components.
+func (k *Key) Clone(keyOpts ...KeyOpt) *Key {
+	ks := k.load().clone()
+	for _, opt := range keyOpts {
+		opt(ks)
+	}
+
+	return newKey(ks)
+}
+
+// updateMu serializes the updates of all keys. Updates are rare, so one
+// mutex costs less than a field in every Key; readers never take it.
+var updateMu sync.Mutex
+
+// update publishes a copy of the key's components modified by fn.
+// Concurrent updates are serialized, so none of them is lost.
+func (k *Key) update(fn func(ks *keyState)) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	ks := &keyState{scheme: "otpauth"}
+	if old, ok := k.state.Load().(*keyState); ok {
+		ks = old.clone()
+	}
+	fn(ks)
+
+	k.state.Store(ks)
+}
+
+// clone copies the components of ks.
+func (ks *keyState) clone() *keyState {
+	c := &keyState{
+		scheme: ks.scheme,
+		typ:    ks.typ,
+		path:   ks.path,
+		params: ks.params,
+		meta:   ks.meta,
+	}
+	if ks.params.extra != nil {
+		c.params.extra = make(url.Values, len(ks.params.extra))
+		for name, values := range ks.params.extra {
+			c.params.extra[name] = values
+		}
+	}
+	return c
+}
+
+// normName normalizes an issuer or account name to Unicode NFC, so names
+// typed with combining characters and precomposed ones are the same.
+func normName(s string) string {
+	return norm.NFC.String(s)
+}
+
+// label builds the path of a key URL.
+func label(issuer, accountName string) string {
+	if issuer == "" {
+		return "/" + accountName
+	}
+	return "/" + issuer + ":" + accountName
+}
+
+// labelIssuer returns the issuer prefix of a key URL path.
+func labelIssuer(path string) string {
+	p := strings.TrimPrefix(path, "/")
+	i := strings.Index(p, ":")
+
+	if i == -1 {
+		return ""
+	}
+
+	return p[:i]
+}
+
+// accountName returns the account name of a key URL path.
+func accountName(path string) string {
+	p := strings.TrimPrefix(path, "/")
+	i := strings.Index(p, ":")
+
+	if i == -1 {
+		return p
+	}
+
+	return p[i+1:]
+}
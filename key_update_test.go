@@ -0,0 +1,70 @@
+package otp
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"sync"
+	"testing"
+)
+
+func TestKeySetters(t *testing.T) {
+	k, err := NewKeyFromURL(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example`)
+	require.NoError(t, err)
+
+	k.SetPeriod(60)
+	k.SetDigits(DigitsEight)
+	k.SetAlgorithm(AlgorithmSHA256)
+	k.SetIssuer("ACME")
+	k.SetAccountName("bob@example.com")
+
+	require.Equal(t, "ACME", k.Issuer())
+	require.Equal(t, "bob@example.com", k.AccountName())
+	require.Equal(t, uint64(60), k.Period())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", k.Secret())
+	require.Equal(t,
+		"otpauth://totp/ACME:bob@example.com?algorithm=SHA256&digits=8&issuer=ACME&period=60&secret=JBSWY3DPEHPK3PXP",
+		k.String())
+
+	var zero Key
+	zero.SetSecret("JBSWY3DPEHPK3PXP")
+	require.Equal(t, "JBSWY3DPEHPK3PXP", zero.Secret())
+}
+
+func TestKeyConcurrentUpdates(t *testing.T) {
+	k, err := NewKeyFromURL(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example`)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			k.SetPeriod(uint64(30 + i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			_ = k.String()
+			_ = k.Period()
+		}()
+	}
+	wg.Wait()
+
+	require.True(t, k.Period() >= 30 && k.Period() < 38)
+	require.Contains(t, k.String(), "secret=JBSWY3DPEHPK3PXP")
+}
+
+func TestKeyClone(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&image=x")
+	require.NoError(t, err)
+
+	c := k.Clone(WithPeriod(60), WithDigits(DigitsEight), WithAlgorithm(AlgorithmSHA256))
+	require.Equal(t, "otpauth://totp/Example:alice?algorithm=SHA256&digits=8&image=x&issuer=Example&period=60&secret=JBSWY3DPEHPK3PXP", c.String())
+	require.Equal(t, k.Secret(), c.Secret())
+
+	require.Equal(t, "otpauth://totp/Example:alice?image=x&issuer=Example&secret=JBSWY3DPEHPK3PXP", k.String(), "original is unchanged")
+
+	c = k.Clone()
+	require.Equal(t, k.Issuer(), c.Issuer())
+	require.Equal(t, k.AccountName(), c.AccountName())
+	require.Equal(t, k.Secret(), c.Secret())
+}
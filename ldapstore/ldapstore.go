@@ -0,0 +1,185 @@
+// Package ldapstore keeps OTP keys in the entries of an LDAP directory, for
+// organizations whose directory is the source of truth for identities.
+//
+// Each key is held in two attributes of the entry of its id: the secret,
+// and the key URL without its secret for the other parameters and any
+// metadata. The secret can be encrypted with an otp.KeyWrapper, so
+// directory administrators and backups never see it in plain text.
+//
+// The package does not depend on an LDAP client library; adapt the client
+// of your choice to the Directory interface.
+package ldapstore
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/pquerna/otp"
+)
+
+// Directory is the subset of an LDAP client the Store needs.
+type Directory interface {
+	// Read returns the values of attrs of the entry dn. Missing attributes
+	// are left out of the result. A missing entry is reported with an error
+	// matching otp.ErrKeyNotFound.
+	Read(ctx context.Context, dn string, attrs []string) (map[string][]string, error)
+	// Replace replaces the values of the attributes of the entry dn. An
+	// attribute without values is deleted.
+	Replace(ctx context.Context, dn string, attrs map[string][]string) error
+}
+
+// Schema maps keys to the directory.
+type Schema struct {
+	// DN returns the distinguished name of the entry holding the key of id.
+	DN func(id string) string
+	// SecretAttr is the attribute holding the base32 secret, or the base64
+	// of the wrapped secret when a KeyWrapper is used.
+	SecretAttr string
+	// URLAttr is the attribute holding the key URL without its secret.
+	URLAttr string
+}
+
+// DefaultSchema returns a Schema keeping keys in the entries
+// "uid=<id>,<baseDN>", in the attributes otpSecret and otpKeyURL.
+func DefaultSchema(baseDN string) Schema {
+	return Schema{
+		DN: func(id string) string {
+			return "uid=" + EscapeDN(id) + "," + baseDN
+		},
+		SecretAttr: "otpSecret",
+		URLAttr:    "otpKeyURL",
+	}
+}
+
+// Store is an otp.KeyStore backed by an LDAP directory.
+type Store struct {
+	dir     Directory
+	schema  Schema
+	wrapper otp.KeyWrapper
+}
+
+// StoreOpt configures a Store.
+type StoreOpt func(s *Store)
+
+// WithKeyWrapper encrypts secrets with w before they are written to the
+// directory.
+func WithKeyWrapper(w otp.KeyWrapper) StoreOpt {
+	return func(s *Store) {
+		s.wrapper = w
+	}
+}
+
+// New creates a Store keeping keys in dir as described by schema.
+func New(dir Directory, schema Schema, storeOpts ...StoreOpt) *Store {
+	s := &Store{dir: dir, schema: schema}
+	for _, opt := range storeOpts {
+		opt(s)
+	}
+	return s
+}
+
+// Get implements otp.KeyStore.
+func (s *Store) Get(ctx context.Context, id string) (*otp.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	attrs, err := s.dir.Read(ctx, s.schema.DN(id), []string{s.schema.SecretAttr, s.schema.URLAttr})
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+
+	secrets, urls := attrs[s.schema.SecretAttr], attrs[s.schema.URLAttr]
+	if len(secrets) == 0 || len(urls) == 0 {
+		return nil, otp.ErrKeyNotFound
+	}
+
+	secret := secrets[0]
+	if s.wrapper != nil {
+		wrapped, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, otp.WrapStoreError("Get", id, err)
+		}
+		raw, err := s.wrapper.Unwrap(ctx, wrapped, []byte(id))
+		if err != nil {
+			return nil, otp.WrapStoreError("Get", id, err)
+		}
+		secret = b32NoPadding.EncodeToString(raw)
+	}
+
+	key, err := otp.NewKeyFromURL(urls[0])
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+	return key.Clone(otp.WithSecret(secret)), nil
+}
+
+// Put implements otp.KeyStore.
+func (s *Store) Put(ctx context.Context, id string, key *otp.Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	secret := key.Secret()
+	if s.wrapper != nil {
+		raw, err := otp.DecodeSecret(secret)
+		if err != nil {
+			return err
+		}
+		wrapped, err := s.wrapper.Wrap(ctx, raw, []byte(id))
+		if err != nil {
+			return otp.WrapStoreError("Put", id, err)
+		}
+		secret = base64.StdEncoding.EncodeToString(wrapped)
+	}
+
+	err := s.dir.Replace(ctx, s.schema.DN(id), map[string][]string{
+		s.schema.SecretAttr: {secret},
+		s.schema.URLAttr:    {key.Clone(otp.WithSecret("")).MetadataURL()},
+	})
+	return otp.WrapStoreError("Put", id, err)
+}
+
+// Delete implements otp.KeyStore. It removes the key's attributes and
+// leaves the rest of the entry alone.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.dir.Replace(ctx, s.schema.DN(id), map[string][]string{
+		s.schema.SecretAttr: nil,
+		s.schema.URLAttr:    nil,
+	})
+	if errors.Is(err, otp.ErrKeyNotFound) {
+		return nil
+	}
+	return otp.WrapStoreError("Delete", id, err)
+}
+
+// EscapeDN escapes an attribute value for use in a distinguished name, as
+// described by RFC 4514.
+func EscapeDN(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == ',' || c == '+' || c == '"' || c == '\\' || c == '<' || c == '>' || c == ';' || c == '=':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == 0:
+			b.WriteString(`\00`)
+		case i == 0 && (c == ' ' || c == '#'), i == len(value)-1 && c == ' ':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
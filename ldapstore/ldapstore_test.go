@@ -0,0 +1,139 @@
+package ldapstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// memDirectory is a Directory of entries held in memory.
+type memDirectory struct {
+	entries map[string]map[string][]string
+	err     error
+}
+
+func (d *memDirectory) Read(ctx context.Context, dn string, attrs []string) (map[string][]string, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	entry, ok := d.entries[dn]
+	if !ok {
+		return nil, otp.ErrKeyNotFound
+	}
+	out := map[string][]string{}
+	for _, attr := range attrs {
+		if values, ok := entry[attr]; ok {
+			out[attr] = values
+		}
+	}
+	return out, nil
+}
+
+func (d *memDirectory) Replace(ctx context.Context, dn string, attrs map[string][]string) error {
+	if d.err != nil {
+		return d.err
+	}
+	entry, ok := d.entries[dn]
+	if !ok {
+		return otp.ErrKeyNotFound
+	}
+	for attr, values := range attrs {
+		if len(values) == 0 {
+			delete(entry, attr)
+		} else {
+			entry[attr] = values
+		}
+	}
+	return nil
+}
+
+// xorWrapper is a KeyWrapper for tests only.
+type xorWrapper struct{}
+
+func (xorWrapper) Wrap(ctx context.Context, secret, aad []byte) ([]byte, error) {
+	return xor(secret), nil
+}
+
+func (xorWrapper) Unwrap(ctx context.Context, wrapped, aad []byte) ([]byte, error) {
+	return xor(wrapped), nil
+}
+
+func xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ 0x5a
+	}
+	return out
+}
+
+func newDirectory() *memDirectory {
+	return &memDirectory{entries: map[string]map[string][]string{
+		"uid=alice,ou=people,dc=example,dc=com": {"cn": {"Alice"}},
+	}}
+}
+
+func testKey(t *testing.T) *otp.Key {
+	key, err := otp.NewKeyFromURL("otpauth://totp/SnakeOil:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=SnakeOil&digits=8&meta-team=core")
+	require.NoError(t, err)
+	return key
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	dir := newDirectory()
+	s := New(dir, DefaultSchema("ou=people,dc=example,dc=com"))
+	key := testKey(t)
+
+	_, err := s.Get(ctx, "alice")
+	require.True(t, errors.Is(err, otp.ErrKeyNotFound))
+
+	require.NoError(t, s.Put(ctx, "alice", key))
+	entry := dir.entries["uid=alice,ou=people,dc=example,dc=com"]
+	require.Equal(t, []string{"JBSWY3DPEHPK3PXP"}, entry["otpSecret"])
+	require.NotContains(t, entry["otpKeyURL"][0], "secret=")
+
+	got, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, key.Secret(), got.Secret())
+	require.Equal(t, otp.DigitsEight, got.Digits())
+	require.Equal(t, key.Metadata(), got.Metadata())
+
+	require.NoError(t, s.Delete(ctx, "alice"))
+	require.Equal(t, map[string][]string{"cn": {"Alice"}}, entry, "the rest of the entry is kept")
+	require.NoError(t, s.Delete(ctx, "bob"))
+}
+
+func TestStoreKeyWrapper(t *testing.T) {
+	ctx := context.Background()
+	dir := newDirectory()
+	s := New(dir, DefaultSchema("ou=people,dc=example,dc=com"), WithKeyWrapper(xorWrapper{}))
+	key := testKey(t)
+
+	require.NoError(t, s.Put(ctx, "alice", key))
+	stored := dir.entries["uid=alice,ou=people,dc=example,dc=com"]["otpSecret"][0]
+	require.NotEqual(t, key.Secret(), stored)
+
+	got, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, key.Secret(), got.Secret())
+}
+
+func TestStoreError(t *testing.T) {
+	dir := newDirectory()
+	dir.err = errors.New("connection reset")
+	s := New(dir, DefaultSchema("ou=people,dc=example,dc=com"))
+
+	_, err := s.Get(context.Background(), "alice")
+	require.True(t, errors.Is(err, otp.ErrStore))
+}
+
+func TestEscapeDN(t *testing.T) {
+	require.Equal(t, `alice`, EscapeDN("alice"))
+	require.Equal(t, `Smith\, John`, EscapeDN("Smith, John"))
+	require.Equal(t, `\#1\=\+ \ `, EscapeDN("#1=+  "))
+	require.False(t, strings.Contains(EscapeDN("a\x00b"), "\x00"))
+}
@@ -0,0 +1,127 @@
+package otp
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// MetadataParamPrefix starts the names of the URL parameters that carry
+// key metadata in MetadataURL. NewKeyFromURL reads such parameters back
+// into the metadata.
+const MetadataParamPrefix = "meta-"
+
+// Metadata returns a copy of the tags attached to the key, eg the
+// enrollment source or device name. Metadata is not part of the URL
+// returned by String and URL, so it is never shown in QR codes.
+func (k *Key) Metadata() map[string]string {
+	meta := k.load().meta
+	c := make(map[string]string, len(meta))
+	for name, value := range meta {
+		c[name] = value
+	}
+	return c
+}
+
+// SetMetadata attaches a tag to the key. An empty value removes the tag.
+func (k *Key) SetMetadata(name, value string) {
+	k.update(WithMetadata(name, value))
+}
+
+// WithMetadata sets a tag of the key. An empty value removes the tag.
+func WithMetadata(name, value string) KeyOpt {
+	return func(ks *keyState) {
+		meta := make(map[string]string, len(ks.meta)+1)
+		for n, v := range ks.meta {
+			meta[n] = v
+		}
+		if value == "" {
+			delete(meta, name)
+		} else {
+			meta[name] = value
+		}
+		if len(meta) == 0 {
+			meta = nil
+		}
+		ks.meta = meta
+	}
+}
+
+// MetadataURL returns the URL of the key with its metadata added as
+// parameters named MetadataParamPrefix followed by the tag name, for
+// systems that store keys as URLs.
+func (k *Key) MetadataURL() string {
+	ks := k.load()
+	if len(ks.meta) == 0 {
+		return ks.url()
+	}
+
+	c := ks.clone()
+	c.params.extra = make(url.Values, len(ks.params.extra)+len(ks.meta))
+	for name, values := range ks.params.extra {
+		c.params.extra[name] = values
+	}
+	for name, value := range ks.meta {
+		c.params.extra.Set(MetadataParamPrefix+name, value)
+	}
+
+	return c.url()
+}
+
+// splitMetadata moves the metadata parameters out of the extra parameters.
+func (p *keyParams) splitMetadata() map[string]string {
+	var meta map[string]string
+	for name, values := range p.extra {
+		if !strings.HasPrefix(name, MetadataParamPrefix) {
+			continue
+		}
+		if meta == nil {
+			meta = map[string]string{}
+		}
+		meta[strings.TrimPrefix(name, MetadataParamPrefix)] = values[0]
+		delete(p.extra, name)
+	}
+	if len(p.extra) == 0 {
+		p.extra = nil
+	}
+	return meta
+}
+
+// keyJSON is the JSON form of a Key.
+type keyJSON struct {
+	URL      string            `json:"url"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. A Key is encoded as an object
+// holding its URL and its metadata.
+func (k *Key) MarshalJSON() ([]byte, error) {
+	ks := k.load()
+	return json.Marshal(keyJSON{URL: k.String(), Metadata: ks.meta})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (k *Key) UnmarshalJSON(data []byte) error {
+	var v keyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := NewKeyFromURL(v.URL)
+	if err != nil {
+		return err
+	}
+
+	ks := parsed.load()
+	if len(v.Metadata) != 0 {
+		if ks.meta == nil {
+			ks.meta = map[string]string{}
+		}
+		for name, value := range v.Metadata {
+			ks.meta[name] = value
+		}
+	}
+	k.state.Store(ks)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package otp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyMetadata(t *testing.T) {
+	k := NewKey(KeyOpts{
+		Type:        "totp",
+		Issuer:      "Example",
+		AccountName: "alice",
+		Secret:      "JBSWY3DPEHPK3PXP",
+		Metadata:    map[string]string{"source": "web"},
+	})
+	k.SetMetadata("device", "Pixel 7")
+
+	require.Equal(t, map[string]string{"source": "web", "device": "Pixel 7"}, k.Metadata())
+	require.NotContains(t, k.String(), "meta-", "metadata stays out of QR codes")
+
+	u := k.MetadataURL()
+	require.Contains(t, u, "meta-device=Pixel+7")
+	parsed, err := NewKeyFromURL(u)
+	require.NoError(t, err)
+	require.Equal(t, k.Metadata(), parsed.Metadata())
+	require.Equal(t, k.String(), parsed.String())
+
+	k.SetMetadata("source", "")
+	require.Equal(t, map[string]string{"device": "Pixel 7"}, k.Metadata())
+
+	c := k.Clone(WithMetadata("policy", "v2"))
+	require.Len(t, c.Metadata(), 2)
+	require.Len(t, k.Metadata(), 1, "clones do not share metadata")
+}
+
+func TestKeyMetadataSerialization(t *testing.T) {
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?issuer=Example&secret=JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	k.SetMetadata("device", "Pixel 7")
+
+	b, err := json.Marshal(k)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"url":"otpauth://totp/Example:alice?issuer=Example&secret=JBSWY3DPEHPK3PXP","metadata":{"device":"Pixel 7"}}`, string(b))
+
+	var fromJSON Key
+	require.NoError(t, json.Unmarshal(b, &fromJSON))
+	require.Equal(t, k.String(), fromJSON.String())
+	require.Equal(t, k.Metadata(), fromJSON.Metadata())
+
+	bin, err := k.MarshalBinary()
+	require.NoError(t, err)
+
+	var fromBinary Key
+	require.NoError(t, fromBinary.UnmarshalBinary(bin))
+	require.Equal(t, k.Metadata(), fromBinary.Metadata())
+}
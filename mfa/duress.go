@@ -0,0 +1,66 @@
+package mfa
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// Duress is the duress credential of a user: a static Code, a Key whose
+// passcodes signal duress, or both.
+type Duress struct {
+	Code string
+	Key  *otp.Key
+}
+
+// DuressVerifier wraps a Verifier for environments that need coercion
+// signaling. A user under duress submits their duress code instead of a
+// real one: it is accepted like a real code, so whoever coerces the user
+// sees a normal sign-in, and Alert is called. Real codes are checked first
+// and never trigger the alert.
+type DuressVerifier struct {
+	Verifier
+	// Duress returns the duress credential of user, the zero Duress when
+	// the user has none.
+	Duress func(ctx context.Context, user string) (Duress, error)
+	// Alert is called when a duress code is accepted. It must not fail
+	// the sign-in or otherwise reveal the alert to the client.
+	Alert func(ctx context.Context, user string, t time.Time)
+}
+
+// Verify implements Verifier. The duress credential is checked whenever
+// the real code is not accepted, including when the Verifier fails, eg on
+// a duress code whose length differs from the real codes or on a store
+// error; the error of the Verifier is only returned if the duress
+// credential does not match either.
+func (v DuressVerifier) Verify(ctx context.Context, user, code string, t time.Time) (bool, error) {
+	ok, verr := v.Verifier.Verify(ctx, user, code, t)
+	if ok {
+		return true, nil
+	}
+
+	d, err := v.Duress(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	if !d.matches(code, t) {
+		return false, verr
+	}
+
+	v.Alert(ctx, user, t)
+	return true, nil
+}
+
+// matches reports whether code is the duress code or a passcode of the
+// duress key.
+func (d Duress) matches(code string, t time.Time) bool {
+	code = strings.TrimSpace(code)
+	matched := d.Code != "" && subtle.ConstantTimeCompare([]byte(d.Code), []byte(code)) == 1
+	if !matched && d.Key != nil {
+		matched, _ = d.Key.Validate(code, t)
+	}
+	return matched
+}
@@ -0,0 +1,141 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuressVerifier(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1600000000, 0)
+
+	keys := &otp.MemoryKeyStore{}
+	real, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, keys.Put(ctx, "alice", real))
+	duressKey, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=GEZDGNBVGY3TQOJQ&issuer=Example")
+	require.NoError(t, err)
+
+	var alerts []string
+	v := DuressVerifier{
+		Verifier: KeyVerifier{Keys: keys},
+		Duress: func(_ context.Context, user string) (Duress, error) {
+			if user == "alice" {
+				return Duress{Code: "999111", Key: duressKey}, nil
+			}
+			return Duress{}, nil
+		},
+		Alert: func(_ context.Context, user string, _ time.Time) {
+			alerts = append(alerts, user)
+		},
+	}
+
+	code, err := real.GenerateCode(now)
+	require.NoError(t, err)
+	ok, err := v.Verify(ctx, "alice", code, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, alerts)
+
+	ok, err = v.Verify(ctx, "alice", "999111", now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"alice"}, alerts)
+
+	code, err = duressKey.GenerateCode(now)
+	require.NoError(t, err)
+	ok, err = v.Verify(ctx, "alice", code, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, alerts, 2)
+
+	ok, err = v.Verify(ctx, "alice", "000000", now)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Users without a duress credential are unaffected.
+	ok, err = v.Verify(ctx, "bob", "999111", now)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	enrolled, err := v.Enrolled(ctx, "alice")
+	require.NoError(t, err)
+	require.True(t, enrolled)
+}
+
+func TestDuressVerifierError(t *testing.T) {
+	boom := errors.New("boom")
+	v := DuressVerifier{
+		Verifier: KeyVerifier{Keys: &otp.MemoryKeyStore{}},
+		Duress: func(context.Context, string) (Duress, error) {
+			return Duress{}, boom
+		},
+	}
+	_, err := v.Verify(context.Background(), "alice", "123456", time.Now())
+	require.Equal(t, boom, err)
+}
+
+func TestDuressVerifierOtherLength(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1600000000, 0)
+
+	keys := &otp.MemoryKeyStore{}
+	real, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, keys.Put(ctx, "alice", real))
+
+	var alerts int
+	v := DuressVerifier{
+		Verifier: KeyVerifier{Keys: keys},
+		Duress: func(context.Context, string) (Duress, error) {
+			return Duress{Code: "99991111"}, nil
+		},
+		Alert: func(context.Context, string, time.Time) { alerts++ },
+	}
+
+	ok, err := v.Verify(ctx, "alice", "99991111", now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, alerts)
+
+	// Other codes of the wrong length still fail as before.
+	ok, err = v.Verify(ctx, "alice", "12345678", now)
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err)
+	require.False(t, ok)
+	require.Equal(t, 1, alerts)
+}
+
+func TestDuressVerifierStoreError(t *testing.T) {
+	boom := errors.New("boom")
+	alerted := false
+	v := DuressVerifier{
+		Verifier: failingVerifier{boom},
+		Duress: func(context.Context, string) (Duress, error) {
+			return Duress{Code: "999111"}, nil
+		},
+		Alert: func(context.Context, string, time.Time) { alerted = true },
+	}
+
+	ok, err := v.Verify(context.Background(), "alice", "999111", time.Now())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, alerted)
+
+	_, err = v.Verify(context.Background(), "alice", "123456", time.Now())
+	require.Equal(t, boom, err)
+}
+
+type failingVerifier struct{ err error }
+
+func (f failingVerifier) Verify(context.Context, string, string, time.Time) (bool, error) {
+	return false, f.err
+}
+
+func (f failingVerifier) Enrolled(context.Context, string) (bool, error) {
+	return false, f.err
+}
@@ -0,0 +1,324 @@
+// Package mfa combines OTP factors into one authorization decision.
+//
+// An Engine holds ordered rules, one per factor: which factors are
+// accepted, for which purposes, which are only a fallback for users
+// without the primary factors, and which flag the account when used, as
+// recovery codes should. Applications call Authorize with every code a
+// user submits, instead of wiring the checks of each factor themselves.
+package mfa
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// Factor names a kind of code.
+type Factor string
+
+// The factors the Engine knows about. Applications may define others.
+const (
+	FactorTOTP     Factor = "totp"
+	FactorHOTP     Factor = "hotp"
+	FactorRecovery Factor = "recovery"
+	FactorOOB      Factor = "oob"
+)
+
+// Purpose is what a code is submitted for.
+type Purpose int
+
+const (
+	// PurposeLogin is a regular authentication.
+	PurposeLogin Purpose = iota
+	// PurposeEnrollment confirms the enrollment of a new factor.
+	PurposeEnrollment
+)
+
+// The submitted factor is not allowed by the rules for its purpose.
+var ErrFactorNotAllowed = errors.New("Factor not allowed")
+
+// The submitted factor is a fallback, and the user has a primary factor.
+var ErrPrimaryFactorEnrolled = errors.New("Primary factor enrolled, fallback not allowed")
+
+// The risk hook denied the submission.
+var ErrRiskDenied = errors.New("Denied by risk assessment")
+
+// The risk hook requires an additional challenge, eg a CAPTCHA, before the
+// code is checked. Submit the code again with ChallengePassed set once the
+// user passed it.
+var ErrChallengeRequired = errors.New("Additional challenge required")
+
+// Verifier checks the codes of one factor.
+type Verifier interface {
+	// Enrolled reports whether user has the factor.
+	Enrolled(ctx context.Context, user string) (bool, error)
+	// Verify checks code for user at t, consuming it when the factor's
+	// codes are single use.
+	Verify(ctx context.Context, user, code string, t time.Time) (bool, error)
+}
+
+// Rule accepts one factor.
+type Rule struct {
+	Factor   Factor
+	Verifier Verifier
+	// Purposes the factor is accepted for. Empty accepts every purpose.
+	Purposes []Purpose
+	// Fallback makes the factor acceptable only for users without any of
+	// the factors of the non-fallback rules before it.
+	Fallback bool
+	// Flag reports a successful use in Decision.Flagged and to the
+	// Engine's OnFlag, eg for recovery codes after which the account
+	// should be reviewed.
+	Flag bool
+}
+
+// allows reports whether the rule accepts purpose.
+func (r *Rule) allows(purpose Purpose) bool {
+	if len(r.Purposes) == 0 {
+		return true
+	}
+	for _, p := range r.Purposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// Submission is a code submitted by a user.
+type Submission struct {
+	Factor  Factor
+	Code    string
+	Purpose Purpose
+	// Time the code was submitted at. Defaults to the current time.
+	Time time.Time
+	// IP address of the client, for the risk hook.
+	IP string
+	// Device is a fingerprint of the client device, for the risk hook.
+	Device string
+	// ChallengePassed is true when the user passed the challenge required
+	// by the risk hook.
+	ChallengePassed bool
+}
+
+// RiskVerdict is the outcome of a risk assessment.
+type RiskVerdict int
+
+const (
+	// RiskAllow checks the code as usual.
+	RiskAllow RiskVerdict = iota
+	// RiskChallenge requires an additional challenge before the code is
+	// checked.
+	RiskChallenge
+	// RiskDeny rejects the submission without checking the code.
+	RiskDeny
+)
+
+// RiskRequest is what the risk hook assesses.
+type RiskRequest struct {
+	User string
+	// Submission without its code, which the risk hook has no use for.
+	Submission Submission
+	// Attempts is the number of submissions of the user within the
+	// Engine's AttemptWindow, including this one, or 0 without an
+	// AttemptStore.
+	Attempts int
+}
+
+// Decision is the outcome of Authorize.
+type Decision struct {
+	// Allowed is true when the code was accepted.
+	Allowed bool
+	// Factor of the submission.
+	Factor Factor
+	// Flagged is true when the accepted factor flags the account.
+	Flagged bool
+}
+
+// Engine evaluates Submissions against ordered rules.
+// An Engine is safe for concurrent use when its Verifiers are.
+type Engine struct {
+	rules []Rule
+	// Risk is called before any code is checked, so fraud and risk systems
+	// can deny submissions or require more friction. Its error fails the
+	// authorization.
+	Risk func(ctx context.Context, req RiskRequest) (RiskVerdict, error)
+	// Attempts counts the submissions of each user within AttemptWindow,
+	// for the risk hook. Accepted codes reset the count.
+	Attempts      otp.AttemptStore
+	AttemptWindow time.Duration
+	// OnFlag is called when a flagging factor is accepted. An error fails
+	// the authorization.
+	OnFlag func(ctx context.Context, user string, factor Factor) error
+}
+
+// NewEngine creates an Engine with rules, in order.
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Authorize checks sub for user. Submissions the rules do not allow fail
+// with an error matching ErrFactorNotAllowed or ErrPrimaryFactorEnrolled;
+// a wrong code returns a Decision that is not Allowed and no error.
+func (e *Engine) Authorize(ctx context.Context, user string, sub Submission) (Decision, error) {
+	d := Decision{Factor: sub.Factor}
+	if sub.Time.IsZero() {
+		sub.Time = time.Now()
+	}
+
+	if err := e.assessRisk(ctx, user, sub); err != nil {
+		return d, err
+	}
+
+	for i := range e.rules {
+		r := &e.rules[i]
+		if r.Factor != sub.Factor || !r.allows(sub.Purpose) {
+			continue
+		}
+
+		if r.Fallback {
+			primary, err := e.primaryEnrolled(ctx, user, i)
+			if err != nil {
+				return d, err
+			}
+			if primary {
+				return d, ErrPrimaryFactorEnrolled
+			}
+		}
+
+		ok, err := r.Verifier.Verify(ctx, user, sub.Code, sub.Time)
+		if err != nil || !ok {
+			return d, err
+		}
+
+		d.Allowed = true
+		if e.Attempts != nil {
+			if err := e.Attempts.Reset(ctx, user); err != nil {
+				return Decision{Factor: sub.Factor}, otp.WrapStoreError("Reset", user, err)
+			}
+		}
+		if r.Flag {
+			d.Flagged = true
+			if e.OnFlag != nil {
+				if err := e.OnFlag(ctx, user, r.Factor); err != nil {
+					return Decision{Factor: sub.Factor}, err
+				}
+			}
+		}
+		return d, nil
+	}
+
+	return d, ErrFactorNotAllowed
+}
+
+// assessRisk records the attempt and asks the risk hook about it.
+func (e *Engine) assessRisk(ctx context.Context, user string, sub Submission) error {
+	req := RiskRequest{User: user, Submission: sub}
+	req.Submission.Code = ""
+
+	if e.Attempts != nil {
+		n, err := e.Attempts.Add(ctx, user, e.AttemptWindow)
+		if err != nil {
+			return otp.WrapStoreError("Add", user, err)
+		}
+		req.Attempts = n
+	}
+
+	if e.Risk == nil {
+		return nil
+	}
+	verdict, err := e.Risk(ctx, req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case verdict == RiskDeny:
+		return ErrRiskDenied
+	case verdict == RiskChallenge && !sub.ChallengePassed:
+		return ErrChallengeRequired
+	}
+	return nil
+}
+
+// primaryEnrolled reports whether user has the factor of a non-fallback
+// rule before rule i.
+func (e *Engine) primaryEnrolled(ctx context.Context, user string, i int) (bool, error) {
+	for _, r := range e.rules[:i] {
+		if r.Fallback {
+			continue
+		}
+		ok, err := r.Verifier.Enrolled(ctx, user)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// KeyVerifier is a Verifier of the keys of a KeyStore, stored under the
+// user. Accepted passcodes of HOTP keys advance the stored counter.
+//
+// Accepted passcodes are also recorded in Replay, by secret fingerprint and
+// counter, so none is accepted twice, even by concurrent requests. Without
+// Replay, a TOTP passcode is accepted again within its period and the
+// periods either side, and concurrent requests with the same HOTP passcode
+// may all be accepted before the counter is advanced.
+type KeyVerifier struct {
+	Keys   otp.KeyStore
+	Replay otp.ReplayStore
+}
+
+// Enrolled implements Verifier.
+func (v KeyVerifier) Enrolled(ctx context.Context, user string) (bool, error) {
+	_, err := v.Keys.Get(ctx, user)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, otp.ErrKeyNotFound):
+		return false, nil
+	}
+	return false, otp.WrapStoreError("Get", user, err)
+}
+
+// Verify implements Verifier. Users without a key are rejected, and
+// passcodes already recorded in Replay fail with otp.ErrValidateReplayed.
+func (v KeyVerifier) Verify(ctx context.Context, user, code string, t time.Time) (bool, error) {
+	key, err := v.Keys.Get(ctx, user)
+	if errors.Is(err, otp.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, otp.WrapStoreError("Get", user, err)
+	}
+
+	counter, ok, err := key.Match(code, t)
+	if err != nil || !ok {
+		return false, err
+	}
+	// The counter following the last one would wrap to 0.
+	hotp := key.Type() == "hotp"
+	if hotp && counter == math.MaxUint64 {
+		return false, nil
+	}
+
+	if v.Replay != nil {
+		id := otp.SecretFingerprint(key.Secret())
+		first, err := v.Replay.Use(ctx, id, counter)
+		if err != nil {
+			return false, otp.WrapStoreError("Use", id, err)
+		}
+		if !first {
+			return false, otp.ErrValidateReplayed
+		}
+	}
+
+	if hotp {
+		if err := v.Keys.Put(ctx, user, key.Clone(otp.WithCounter(counter+1))); err != nil {
+			return false, otp.WrapStoreError("Put", user, err)
+		}
+	}
+	return true, nil
+}
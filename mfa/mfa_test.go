@@ -0,0 +1,245 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/replay"
+	"github.com/stretchr/testify/require"
+)
+
+// codeVerifier accepts single use codes held in memory.
+type codeVerifier struct {
+	codes map[string]map[string]bool
+}
+
+func (v *codeVerifier) Enrolled(ctx context.Context, user string) (bool, error) {
+	return len(v.codes[user]) > 0, nil
+}
+
+func (v *codeVerifier) Verify(ctx context.Context, user, code string, t time.Time) (bool, error) {
+	if !v.codes[user][code] {
+		return false, nil
+	}
+	delete(v.codes[user], code)
+	return true, nil
+}
+
+func newEngine(t *testing.T) (*Engine, *otp.MemoryKeyStore, *otp.MemoryKeyStore) {
+	totpKeys, hotpKeys := &otp.MemoryKeyStore{}, &otp.MemoryKeyStore{}
+	ctx := context.Background()
+
+	k, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, totpKeys.Put(ctx, "alice", k))
+
+	k, err = otp.NewKeyFromURL("otpauth://hotp/Example:bob?secret=JBSWY3DPEHPK3PXP&issuer=Example&counter=0")
+	require.NoError(t, err)
+	require.NoError(t, hotpKeys.Put(ctx, "bob", k))
+	require.NoError(t, hotpKeys.Put(ctx, "alice", k))
+
+	e := NewEngine(
+		Rule{Factor: FactorTOTP, Verifier: KeyVerifier{Keys: totpKeys}},
+		Rule{Factor: FactorHOTP, Verifier: KeyVerifier{Keys: hotpKeys}, Fallback: true},
+		Rule{Factor: FactorRecovery, Verifier: &codeVerifier{codes: map[string]map[string]bool{
+			"alice": {"recovery-1": true},
+		}}, Flag: true},
+		Rule{Factor: FactorOOB, Verifier: &codeVerifier{codes: map[string]map[string]bool{
+			"alice": {"424242": true, "434343": true},
+		}}, Purposes: []Purpose{PurposeEnrollment}},
+	)
+	return e, totpKeys, hotpKeys
+}
+
+func TestAuthorizeTOTP(t *testing.T) {
+	ctx := context.Background()
+	e, totpKeys, _ := newEngine(t)
+	now := time.Unix(1700000000, 0)
+
+	k, err := totpKeys.Get(ctx, "alice")
+	require.NoError(t, err)
+	code, err := k.GenerateCode(now)
+	require.NoError(t, err)
+
+	d, err := e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: code, Time: now})
+	require.NoError(t, err)
+	require.Equal(t, Decision{Allowed: true, Factor: FactorTOTP}, d)
+
+	d, err = e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: code, Time: now.Add(time.Hour)})
+	require.NoError(t, err)
+	require.False(t, d.Allowed)
+}
+
+func TestAuthorizeHOTPFallback(t *testing.T) {
+	ctx := context.Background()
+	e, _, hotpKeys := newEngine(t)
+
+	k, err := hotpKeys.Get(ctx, "bob")
+	require.NoError(t, err)
+	code, err := k.GenerateCode(time.Time{})
+	require.NoError(t, err)
+
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: FactorHOTP, Code: code})
+	require.True(t, errors.Is(err, ErrPrimaryFactorEnrolled), "alice has TOTP")
+
+	d, err := e.Authorize(ctx, "bob", Submission{Factor: FactorHOTP, Code: code})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+
+	d, err = e.Authorize(ctx, "bob", Submission{Factor: FactorHOTP, Code: code})
+	require.NoError(t, err)
+	require.False(t, d.Allowed, "the counter advanced")
+
+	k, err = hotpKeys.Get(ctx, "bob")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), k.Counter())
+}
+
+func TestAuthorizeRecoveryFlags(t *testing.T) {
+	ctx := context.Background()
+	e, _, _ := newEngine(t)
+
+	var flagged []string
+	e.OnFlag = func(ctx context.Context, user string, factor Factor) error {
+		flagged = append(flagged, user+":"+string(factor))
+		return nil
+	}
+
+	d, err := e.Authorize(ctx, "alice", Submission{Factor: FactorRecovery, Code: "recovery-1"})
+	require.NoError(t, err)
+	require.Equal(t, Decision{Allowed: true, Factor: FactorRecovery, Flagged: true}, d)
+	require.Equal(t, []string{"alice:recovery"}, flagged)
+
+	d, err = e.Authorize(ctx, "alice", Submission{Factor: FactorRecovery, Code: "recovery-1"})
+	require.NoError(t, err)
+	require.False(t, d.Allowed, "recovery codes are consumed")
+}
+
+func TestAuthorizeOOBEnrollmentOnly(t *testing.T) {
+	ctx := context.Background()
+	e, _, _ := newEngine(t)
+
+	_, err := e.Authorize(ctx, "alice", Submission{Factor: FactorOOB, Code: "424242"})
+	require.True(t, errors.Is(err, ErrFactorNotAllowed))
+
+	d, err := e.Authorize(ctx, "alice", Submission{Factor: FactorOOB, Code: "424242", Purpose: PurposeEnrollment})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: "sms", Code: "424242"})
+	require.True(t, errors.Is(err, ErrFactorNotAllowed))
+}
+
+func TestAuthorizeRisk(t *testing.T) {
+	ctx := context.Background()
+	e, totpKeys, _ := newEngine(t)
+	e.Attempts = &otp.MemoryAttemptStore{}
+	e.AttemptWindow = time.Minute
+
+	var seen []RiskRequest
+	e.Risk = func(ctx context.Context, req RiskRequest) (RiskVerdict, error) {
+		seen = append(seen, req)
+		switch {
+		case req.Submission.IP == "203.0.113.7":
+			return RiskDeny, nil
+		case req.Attempts > 2:
+			return RiskChallenge, nil
+		}
+		return RiskAllow, nil
+	}
+
+	now := time.Now()
+	k, err := totpKeys.Get(ctx, "alice")
+	require.NoError(t, err)
+	code, err := k.GenerateCode(now)
+	require.NoError(t, err)
+
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: code, Time: now, IP: "203.0.113.7"})
+	require.True(t, errors.Is(err, ErrRiskDenied))
+	require.Equal(t, "", seen[0].Submission.Code, "the hook does not see the code")
+
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: "000000", Time: now, IP: "192.0.2.1"})
+	require.NoError(t, err)
+
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: code, Time: now, IP: "192.0.2.1"})
+	require.True(t, errors.Is(err, ErrChallengeRequired))
+
+	d, err := e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: code, Time: now, IP: "192.0.2.1", ChallengePassed: true})
+	require.NoError(t, err)
+	require.True(t, d.Allowed)
+	require.Equal(t, 4, seen[3].Attempts)
+
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: code, Time: now, IP: "192.0.2.1"})
+	require.NoError(t, err)
+	require.Equal(t, 1, seen[4].Attempts, "accepted codes reset the attempts")
+}
+
+// downKeyStore is an otp.KeyStore whose backend is down.
+type downKeyStore struct {
+	otp.MemoryKeyStore
+}
+
+func (*downKeyStore) Get(ctx context.Context, id string) (*otp.Key, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestAuthorizeStoreErrors(t *testing.T) {
+	e := NewEngine(Rule{Factor: FactorTOTP, Verifier: KeyVerifier{Keys: &downKeyStore{}}})
+	d, err := e.Authorize(context.Background(), "alice", Submission{Factor: FactorTOTP, Code: "123456"})
+	require.True(t, errors.Is(err, otp.ErrStore))
+	require.False(t, d.Allowed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e, _, _ = newEngine(t)
+	_, err = e.Authorize(ctx, "alice", Submission{Factor: FactorTOTP, Code: "123456"})
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestKeyVerifierReplay(t *testing.T) {
+	ctx := context.Background()
+	_, totpKeys, hotpKeys := newEngine(t)
+	now := time.Unix(1700000000, 0)
+
+	v := KeyVerifier{Keys: totpKeys, Replay: replay.NewStore(30*time.Second, 1, 0)}
+	k, err := totpKeys.Get(ctx, "alice")
+	require.NoError(t, err)
+	code, err := k.GenerateCode(now)
+	require.NoError(t, err)
+
+	ok, err := v.Verify(ctx, "alice", code, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = v.Verify(ctx, "alice", code, now.Add(30*time.Second))
+	require.Equal(t, otp.ErrValidateReplayed, err, "the code is still within the window")
+	require.False(t, ok)
+
+	// A request racing the first one reads the key before the counter is
+	// advanced, as a store that does not persist Put shows.
+	v = KeyVerifier{Keys: staleKeyStore{hotpKeys}, Replay: replay.NewStore(time.Minute, 0, 0)}
+	k, err = hotpKeys.Get(ctx, "bob")
+	require.NoError(t, err)
+	code, err = k.GenerateCode(time.Time{})
+	require.NoError(t, err)
+
+	ok, err = v.Verify(ctx, "bob", code, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = v.Verify(ctx, "bob", code, now)
+	require.Equal(t, otp.ErrValidateReplayed, err)
+	require.False(t, ok)
+}
+
+// staleKeyStore discards Put.
+type staleKeyStore struct {
+	otp.KeyStore
+}
+
+func (s staleKeyStore) Put(ctx context.Context, id string, key *otp.Key) error {
+	return nil
+}
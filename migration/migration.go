@@ -0,0 +1,419 @@
+// Package migration parses and generates the otpauth-migration URLs of the
+// export feature of Google Authenticator, so whole batches of keys can be
+// imported from or exported to it.
+//
+// A migration URL has the form otpauth-migration://offline?data=... where
+// data is the base64 encoding of a protobuf MigrationPayload:
+//
+//	message MigrationPayload {
+//	  message OtpParameters {
+//	    bytes secret = 1;
+//	    string name = 2;
+//	    string issuer = 3;
+//	    Algorithm algorithm = 4;
+//	    DigitCount digits = 5;
+//	    OtpType type = 6;
+//	    int64 counter = 7;
+//	  }
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2;
+//	  int32 batch_size = 3;
+//	  int32 batch_index = 4;
+//	  int32 batch_id = 5;
+//	}
+//
+// The payload is encoded by hand, so the package needs no protobuf runtime.
+package migration
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/pquerna/otp"
+)
+
+// Scheme is the scheme of migration URLs.
+const Scheme = "otpauth-migration"
+
+// DefaultBatchSize is the number of keys per URL of Export, which keeps
+// the QR codes of the URLs scannable.
+const DefaultBatchSize = 10
+
+var (
+	// ErrInvalidPayload is the cause of the *otp.URLError of migration URLs
+	// whose payload cannot be decoded.
+	ErrInvalidPayload = errors.New("Invalid migration payload")
+	// ErrUnsupportedKey is the cause of the *otp.OptionError of keys whose
+	// parameters migration payloads cannot hold, eg a period other than 30
+	// seconds.
+	ErrUnsupportedKey = errors.New("Key not supported by migration payloads")
+)
+
+// The enums of MigrationPayload.
+const (
+	algorithmSHA1   = 1
+	algorithmSHA256 = 2
+	algorithmSHA512 = 3
+	algorithmMD5    = 4
+
+	digitsSix   = 1
+	digitsEight = 2
+
+	typeHOTP = 1
+	typeTOTP = 2
+)
+
+// payloadVersion is the version Google Authenticator writes.
+const payloadVersion = 1
+
+// b32 encodes secrets as in key URLs.
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Batch is the content of one migration URL. Exports of many keys are
+// split into batches sharing an ID.
+type Batch struct {
+	Keys []*otp.Key
+	// Size is the number of batches of the export, and Index the position
+	// of this one, starting at 0.
+	Size  int
+	Index int
+	// ID identifies the export the batch belongs to.
+	ID int32
+}
+
+// Parse parses a migration URL. Failures are reported in an
+// *otp.URLError.
+func Parse(rawURL string) (*Batch, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, &otp.URLError{URL: rawURL, Err: err}
+	}
+	if u.Scheme != Scheme {
+		return nil, &otp.URLError{URL: rawURL, Err: otp.ErrInvalidURL}
+	}
+
+	// The standard alphabet's '+' may arrive unescaped and be read as a
+	// space.
+	data := strings.Replace(u.Query().Get("data"), " ", "+", -1)
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		raw, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+	if err != nil {
+		return nil, &otp.URLError{URL: rawURL, Err: ErrInvalidPayload}
+	}
+
+	b, err := decodePayload(raw)
+	if err != nil {
+		return nil, &otp.URLError{URL: rawURL, Err: err}
+	}
+	return b, nil
+}
+
+// URL returns the migration URL of b. Keys that migration payloads cannot
+// hold are reported in an *otp.OptionError matching ErrUnsupportedKey.
+func (b *Batch) URL() (string, error) {
+	raw, err := b.encode()
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{"data": {base64.StdEncoding.EncodeToString(raw)}}
+	u := url.URL{Scheme: Scheme, Host: "offline", RawQuery: q.Encode()}
+	return u.String(), nil
+}
+
+// Export returns the migration URLs of keys, batchSize keys per URL, all
+// with the export id. A batchSize of 0 is DefaultBatchSize.
+func Export(keys []*otp.Key, batchSize int, id int32) ([]string, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	n := (len(keys) + batchSize - 1) / batchSize
+	urls := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		end := (i + 1) * batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		b := &Batch{Keys: keys[i*batchSize : end], Size: n, Index: i, ID: id}
+		u, err := b.URL()
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// Import parses the migration URLs of an export and returns all their
+// keys, in order.
+func Import(urls ...string) ([]*otp.Key, error) {
+	var keys []*otp.Key
+	for _, u := range urls {
+		b, err := Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, b.Keys...)
+	}
+	return keys, nil
+}
+
+// encode returns the MigrationPayload of b.
+func (b *Batch) encode() ([]byte, error) {
+	var buf []byte
+	for _, key := range b.Keys {
+		params, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		buf = appendBytes(buf, 1, params)
+	}
+	buf = appendVarint(buf, 2, payloadVersion)
+	buf = appendVarint(buf, 3, uint64(b.Size))
+	buf = appendVarint(buf, 4, uint64(b.Index))
+	buf = appendVarint(buf, 5, uint64(uint32(b.ID)))
+	return buf, nil
+}
+
+// encodeKey returns the OtpParameters of key.
+func encodeKey(key *otp.Key) ([]byte, error) {
+	secret, err := otp.DecodeSecret(key.Secret())
+	if err != nil {
+		return nil, err
+	}
+
+	var algorithm uint64
+	switch key.Algorithm() {
+	case otp.AlgorithmSHA1:
+		algorithm = algorithmSHA1
+	case otp.AlgorithmSHA256:
+		algorithm = algorithmSHA256
+	case otp.AlgorithmSHA512:
+		algorithm = algorithmSHA512
+	case otp.AlgorithmMD5:
+		algorithm = algorithmMD5
+	default:
+		return nil, &otp.OptionError{Name: "Algorithm", Value: key.Algorithm(), Err: ErrUnsupportedKey}
+	}
+
+	var digits uint64
+	switch key.Digits() {
+	case otp.DigitsSix:
+		digits = digitsSix
+	case otp.DigitsEight:
+		digits = digitsEight
+	default:
+		return nil, &otp.OptionError{Name: "Digits", Value: key.Digits(), Err: ErrUnsupportedKey}
+	}
+
+	var typ uint64
+	switch key.Type() {
+	case "totp":
+		if key.Period() != otp.DefaultPeriod {
+			return nil, &otp.OptionError{Name: "Period", Value: key.Period(), Err: ErrUnsupportedKey}
+		}
+		typ = typeTOTP
+	case "hotp":
+		typ = typeHOTP
+	default:
+		return nil, &otp.OptionError{Name: "Type", Value: key.Type(), Err: otp.ErrUnsupportedType}
+	}
+
+	var buf []byte
+	buf = appendBytes(buf, 1, secret)
+	buf = appendBytes(buf, 2, []byte(key.AccountName()))
+	if key.Issuer() != "" {
+		buf = appendBytes(buf, 3, []byte(key.Issuer()))
+	}
+	buf = appendVarint(buf, 4, algorithm)
+	buf = appendVarint(buf, 5, digits)
+	buf = appendVarint(buf, 6, typ)
+	if typ == typeHOTP {
+		buf = appendVarint(buf, 7, key.Counter())
+	}
+	return buf, nil
+}
+
+// decodePayload parses a MigrationPayload.
+func decodePayload(raw []byte) (*Batch, error) {
+	b := &Batch{}
+	err := decodeFields(raw, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			key, err := decodeKey(data)
+			if err != nil {
+				return err
+			}
+			b.Keys = append(b.Keys, key)
+		case 3:
+			b.Size = int(int32(v))
+		case 4:
+			b.Index = int(int32(v))
+		case 5:
+			b.ID = int32(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// decodeKey parses OtpParameters into a Key.
+func decodeKey(raw []byte) (*otp.Key, error) {
+	opts := otp.KeyOpts{
+		Type:      "totp",
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+	var name string
+	var counter uint64
+	err := decodeFields(raw, func(num int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			opts.Secret = b32.EncodeToString(data)
+		case 2:
+			name = string(data)
+		case 3:
+			opts.Issuer = string(data)
+		case 4:
+			switch v {
+			case algorithmSHA256:
+				opts.Algorithm = otp.AlgorithmSHA256
+			case algorithmSHA512:
+				opts.Algorithm = otp.AlgorithmSHA512
+			case algorithmMD5:
+				opts.Algorithm = otp.AlgorithmMD5
+			}
+		case 5:
+			if v == digitsEight {
+				opts.Digits = otp.DigitsEight
+			}
+		case 6:
+			if v == typeHOTP {
+				opts.Type = "hotp"
+			}
+		case 7:
+			counter = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Secret == "" {
+		return nil, ErrInvalidPayload
+	}
+
+	// Names are often "Issuer:account", as in the label of key URLs.
+	opts.AccountName = name
+	if i := strings.Index(name, ":"); i >= 0 {
+		if prefix := strings.TrimSpace(name[:i]); opts.Issuer == "" || prefix == opts.Issuer {
+			opts.Issuer = prefix
+			opts.AccountName = strings.TrimSpace(name[i+1:])
+		}
+	}
+
+	key := otp.NewKey(opts)
+	if opts.Type == "hotp" {
+		key = key.Clone(otp.WithCounter(counter))
+	}
+	return key, nil
+}
+
+// The protobuf wire types used by MigrationPayload.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendVarint(buf []byte, num int, v uint64) []byte {
+	buf = appendUvarint(buf, uint64(num)<<3|wireVarint)
+	return appendUvarint(buf, v)
+}
+
+func appendBytes(buf []byte, num int, data []byte) []byte {
+	buf = appendUvarint(buf, uint64(num)<<3|wireBytes)
+	buf = appendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// readUvarint returns the varint at the start of raw and its length, or a
+// length of 0 if raw does not start with a valid varint.
+func readUvarint(raw []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(raw) && i < 10; i++ {
+		v |= uint64(raw[i]&0x7f) << (7 * uint(i))
+		if raw[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// decodeFields calls fn with each field of the message raw: the value of
+// varint fields, the data of length-delimited ones. Fixed-size fields are
+// skipped.
+func decodeFields(raw []byte, fn func(num int, v uint64, data []byte) error) error {
+	for len(raw) > 0 {
+		tag, n := readUvarint(raw)
+		if n == 0 || tag>>3 == 0 {
+			return ErrInvalidPayload
+		}
+		raw = raw[n:]
+
+		num := int(tag >> 3)
+		var v uint64
+		var data []byte
+		switch tag & 7 {
+		case wireVarint:
+			if v, n = readUvarint(raw); n == 0 {
+				return ErrInvalidPayload
+			}
+			raw = raw[n:]
+		case wireBytes:
+			l, n := readUvarint(raw)
+			if n == 0 || l > uint64(len(raw)-n) {
+				return ErrInvalidPayload
+			}
+			data = raw[n : n+int(l)]
+			raw = raw[n+int(l):]
+		case wireFixed64:
+			if len(raw) < 8 {
+				return ErrInvalidPayload
+			}
+			raw = raw[8:]
+			continue
+		case wireFixed32:
+			if len(raw) < 4 {
+				return ErrInvalidPayload
+			}
+			raw = raw[4:]
+			continue
+		default:
+			return ErrInvalidPayload
+		}
+
+		if err := fn(num, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// An export of Google Authenticator with a single key.
+const googleExport = "otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZSABKAEwAhABGAEgACgA"
+
+func TestParse(t *testing.T) {
+	b, err := Parse(googleExport)
+	require.NoError(t, err)
+	require.Equal(t, 1, b.Size)
+	require.Equal(t, 0, b.Index)
+	require.Len(t, b.Keys, 1)
+
+	key := b.Keys[0]
+	require.Equal(t, "totp", key.Type())
+	require.Equal(t, "Example", key.Issuer())
+	require.Equal(t, "alice@google.com", key.AccountName())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", key.Secret())
+	require.Equal(t, otp.DigitsSix, key.Digits())
+	require.Equal(t, otp.AlgorithmSHA1, key.Algorithm())
+
+	_, err = Parse("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP")
+	require.True(t, errors.Is(err, otp.ErrInvalidURL))
+	_, err = Parse(Scheme + "://offline?data=Cg")
+	require.True(t, errors.Is(err, ErrInvalidPayload))
+}
+
+func TestExportImport(t *testing.T) {
+	var keys []*otp.Key
+	for i := 0; i < 5; i++ {
+		keys = append(keys, otp.NewKey(otp.KeyOpts{
+			Type:        "totp",
+			Issuer:      "Example",
+			AccountName: fmt.Sprintf("user%d@example.com", i),
+			Secret:      "JBSWY3DPEHPK3PXP",
+			Digits:      otp.DigitsEight,
+			Algorithm:   otp.AlgorithmSHA256,
+		}))
+	}
+	hotpKey := otp.NewKey(otp.KeyOpts{Type: "hotp", AccountName: "bob", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}).Clone(otp.WithCounter(1 << 40))
+	keys = append(keys, hotpKey)
+
+	urls, err := Export(keys, 4, 42)
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+
+	b, err := Parse(urls[1])
+	require.NoError(t, err)
+	require.Equal(t, 2, b.Size)
+	require.Equal(t, 1, b.Index)
+	require.Equal(t, int32(42), b.ID)
+
+	got, err := Import(urls...)
+	require.NoError(t, err)
+	require.Len(t, got, len(keys))
+	for i, key := range keys {
+		require.Equal(t, key.Type(), got[i].Type())
+		require.Equal(t, key.Issuer(), got[i].Issuer())
+		require.Equal(t, key.AccountName(), got[i].AccountName())
+		require.Equal(t, key.Secret(), got[i].Secret())
+		require.Equal(t, key.Digits(), got[i].Digits())
+		require.Equal(t, key.Algorithm(), got[i].Algorithm())
+		require.Equal(t, key.Counter(), got[i].Counter())
+	}
+}
+
+func TestExportUnsupported(t *testing.T) {
+	key, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&period=60")
+	require.NoError(t, err)
+	_, err = Export([]*otp.Key{key}, 0, 1)
+	require.True(t, errors.Is(err, ErrUnsupportedKey))
+	var optErr *otp.OptionError
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "Period", optErr.Name)
+
+	key, err = otp.NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&digits=7")
+	require.NoError(t, err)
+	_, err = Export([]*otp.Key{key}, 0, 1)
+	require.True(t, errors.Is(err, ErrUnsupportedKey))
+}
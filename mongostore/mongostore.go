@@ -0,0 +1,203 @@
+// Package mongostore implements the otp store interfaces on MongoDB: keys,
+// HOTP counters, used passcodes for replay protection and validation
+// attempts for rate limiting.
+//
+// Counters are advanced with findAndModify, so concurrent callers never
+// consume the same value. Used passcodes and attempts carry an
+// "expires_at" date covered by a TTL index, created by Setup, so MongoDB
+// removes them once they no longer matter.
+//
+// The package does not depend on the MongoDB driver; adapt the driver's
+// collections to the Collection interface. Documents, filters and updates
+// use the driver's syntax.
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// An inserted document has the _id of an existing one. Collection
+// implementations return it for duplicate key write errors.
+var ErrDuplicateKey = errors.New("Duplicate key")
+
+// Document is a MongoDB document, filter or update, eg a bson.M.
+type Document map[string]interface{}
+
+// Collection is the subset of a MongoDB collection the Store needs.
+type Collection interface {
+	// FindOne returns the first document matching filter, or nil when
+	// there is none.
+	FindOne(ctx context.Context, filter Document) (Document, error)
+	// ReplaceOne replaces the document matching filter, inserting it when
+	// there is none.
+	ReplaceOne(ctx context.Context, filter, doc Document) error
+	// InsertOne inserts doc, failing with ErrDuplicateKey when its _id
+	// exists.
+	InsertOne(ctx context.Context, doc Document) error
+	// FindOneAndUpdate applies update to the document matching filter,
+	// inserting it when there is none, and returns the document as it was
+	// before the update, or nil when it was inserted.
+	FindOneAndUpdate(ctx context.Context, filter, update Document) (Document, error)
+	// CountDocuments returns the number of documents matching filter.
+	CountDocuments(ctx context.Context, filter Document) (int64, error)
+	// DeleteMany removes the documents matching filter.
+	DeleteMany(ctx context.Context, filter Document) error
+	// CreateTTLIndex creates, if missing, an index on field removing
+	// documents once the date in field has passed (expireAfterSeconds 0).
+	CreateTTLIndex(ctx context.Context, field string) error
+}
+
+// Database returns the collections of a MongoDB database.
+type Database interface {
+	Collection(name string) Collection
+}
+
+// Store implements otp.KeyStore, otp.CounterStore, otp.ReplayStore and
+// otp.AttemptStore on MongoDB, in the collections otp_keys, otp_counters,
+// otp_replay and otp_attempts.
+type Store struct {
+	keys, counters, replay, attempts Collection
+
+	replayTTL time.Duration
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+// StoreOpt configures a Store.
+type StoreOpt func(s *Store)
+
+// WithReplayTTL sets how long used passcodes are remembered. It must be at
+// least as long as a passcode can be accepted, ie (2*Skew+1)*Period for
+// TOTP. Defaults to 10 minutes.
+func WithReplayTTL(ttl time.Duration) StoreOpt {
+	return func(s *Store) {
+		s.replayTTL = ttl
+	}
+}
+
+// New creates a Store on db.
+func New(db Database, storeOpts ...StoreOpt) *Store {
+	s := &Store{
+		keys:      db.Collection("otp_keys"),
+		counters:  db.Collection("otp_counters"),
+		replay:    db.Collection("otp_replay"),
+		attempts:  db.Collection("otp_attempts"),
+		replayTTL: 10 * time.Minute,
+	}
+	for _, opt := range storeOpts {
+		opt(s)
+	}
+	return s
+}
+
+// Setup creates the TTL indexes of the used passcodes and attempts. It is
+// safe to call on every start.
+func (s *Store) Setup(ctx context.Context) error {
+	for _, c := range []Collection{s.replay, s.attempts} {
+		if err := c.CreateTTLIndex(ctx, "expires_at"); err != nil {
+			return otp.WrapStoreError("Setup", "", err)
+		}
+	}
+	return nil
+}
+
+// Get implements otp.KeyStore.
+func (s *Store) Get(ctx context.Context, id string) (*otp.Key, error) {
+	doc, err := s.keys.FindOne(ctx, Document{"_id": id})
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+	u, ok := doc["url"].(string)
+	if !ok {
+		return nil, otp.ErrKeyNotFound
+	}
+
+	key, err := otp.NewKeyFromURL(u)
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+	return key, nil
+}
+
+// Put implements otp.KeyStore. The key is stored as its URL, including
+// its metadata.
+func (s *Store) Put(ctx context.Context, id string, key *otp.Key) error {
+	err := s.keys.ReplaceOne(ctx, Document{"_id": id}, Document{
+		"_id":        id,
+		"url":        key.MetadataURL(),
+		"updated_at": s.clock(),
+	})
+	return otp.WrapStoreError("Put", id, err)
+}
+
+// Delete implements otp.KeyStore.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	return otp.WrapStoreError("Delete", id, s.keys.DeleteMany(ctx, Document{"_id": id}))
+}
+
+// Next implements otp.CounterStore.
+func (s *Store) Next(ctx context.Context, id string) (uint64, error) {
+	doc, err := s.counters.FindOneAndUpdate(ctx, Document{"_id": id}, Document{"$inc": Document{"next": int64(1)}})
+	if err != nil {
+		return 0, otp.WrapStoreError("Next", id, err)
+	}
+	c, _ := doc["next"].(int64)
+	return uint64(c), nil
+}
+
+// Use implements otp.ReplayStore.
+func (s *Store) Use(ctx context.Context, id string, counter uint64) (bool, error) {
+	err := s.replay.InsertOne(ctx, Document{
+		"_id":        id + "#" + strconv.FormatUint(counter, 10),
+		"expires_at": s.clock().Add(s.replayTTL),
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrDuplicateKey):
+		return false, nil
+	}
+	return false, otp.WrapStoreError("Use", id, err)
+}
+
+// Add implements otp.AttemptStore. Every attempt is a document expiring
+// with the window.
+func (s *Store) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	now := s.clock()
+
+	err := s.attempts.InsertOne(ctx, Document{
+		"id":         id,
+		"at":         now,
+		"expires_at": now.Add(window),
+	})
+	if err != nil {
+		return 0, otp.WrapStoreError("Add", id, err)
+	}
+
+	n, err := s.attempts.CountDocuments(ctx, Document{
+		"id": id,
+		"at": Document{"$gt": now.Add(-window)},
+	})
+	if err != nil {
+		return 0, otp.WrapStoreError("Add", id, err)
+	}
+	return int(n), nil
+}
+
+// Reset implements otp.AttemptStore.
+func (s *Store) Reset(ctx context.Context, id string) error {
+	return otp.WrapStoreError("Reset", id, s.attempts.DeleteMany(ctx, Document{"id": id}))
+}
+
+// clock returns the current time.
+func (s *Store) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
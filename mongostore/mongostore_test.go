@@ -0,0 +1,230 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// memDatabase is a Database held in memory, understanding the filters and
+// updates the Store uses.
+type memDatabase struct {
+	collections map[string]*memCollection
+}
+
+func (db *memDatabase) Collection(name string) Collection {
+	if db.collections == nil {
+		db.collections = map[string]*memCollection{}
+	}
+	c, ok := db.collections[name]
+	if !ok {
+		c = &memCollection{}
+		db.collections[name] = c
+	}
+	return c
+}
+
+type memCollection struct {
+	mu      sync.Mutex
+	docs    []Document
+	ttl     []string
+	nextOID int
+}
+
+func matches(doc, filter Document) bool {
+	for name, want := range filter {
+		if op, ok := want.(Document); ok {
+			t, _ := doc[name].(time.Time)
+			if !t.After(op["$gt"].(time.Time)) {
+				return false
+			}
+			continue
+		}
+		if doc[name] != want {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *memCollection) find(filter Document) int {
+	for i, doc := range c.docs {
+		if matches(doc, filter) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *memCollection) FindOne(ctx context.Context, filter Document) (Document, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.find(filter); i != -1 {
+		return c.docs[i], nil
+	}
+	return nil, nil
+}
+
+func (c *memCollection) ReplaceOne(ctx context.Context, filter, doc Document) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.find(filter); i != -1 {
+		c.docs[i] = doc
+	} else {
+		c.docs = append(c.docs, doc)
+	}
+	return nil
+}
+
+func (c *memCollection) InsertOne(ctx context.Context, doc Document) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := doc["_id"]; !ok {
+		c.nextOID++
+		doc["_id"] = c.nextOID
+	}
+	if c.find(Document{"_id": doc["_id"]}) != -1 {
+		return ErrDuplicateKey
+	}
+	c.docs = append(c.docs, doc)
+	return nil
+}
+
+func (c *memCollection) FindOneAndUpdate(ctx context.Context, filter, update Document) (Document, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var before Document
+	i := c.find(filter)
+	if i == -1 {
+		doc := Document{}
+		for name, value := range filter {
+			doc[name] = value
+		}
+		c.docs = append(c.docs, doc)
+		i = len(c.docs) - 1
+	} else {
+		before = Document{}
+		for name, value := range c.docs[i] {
+			before[name] = value
+		}
+	}
+	for name, inc := range update["$inc"].(Document) {
+		v, _ := c.docs[i][name].(int64)
+		c.docs[i][name] = v + inc.(int64)
+	}
+	return before, nil
+}
+
+func (c *memCollection) CountDocuments(ctx context.Context, filter Document) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, doc := range c.docs {
+		if matches(doc, filter) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (c *memCollection) DeleteMany(ctx context.Context, filter Document) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := c.docs[:0]
+	for _, doc := range c.docs {
+		if !matches(doc, filter) {
+			kept = append(kept, doc)
+		}
+	}
+	c.docs = kept
+	return nil
+}
+
+func (c *memCollection) CreateTTLIndex(ctx context.Context, field string) error {
+	c.ttl = append(c.ttl, field)
+	return nil
+}
+
+func TestSetup(t *testing.T) {
+	db := &memDatabase{}
+	require.NoError(t, New(db).Setup(context.Background()))
+	require.Equal(t, []string{"expires_at"}, db.collections["otp_replay"].ttl)
+	require.Equal(t, []string{"expires_at"}, db.collections["otp_attempts"].ttl)
+	require.Empty(t, db.collections["otp_keys"].ttl)
+}
+
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	s := New(&memDatabase{})
+
+	_, err := s.Get(ctx, "SnakeOil:alice")
+	require.True(t, errors.Is(err, otp.ErrKeyNotFound))
+
+	key, err := otp.NewKeyFromURL("otpauth://totp/SnakeOil:alice?secret=JBSWY3DPEHPK3PXP&issuer=SnakeOil&meta-team=core")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "SnakeOil:alice", key))
+	require.NoError(t, s.Put(ctx, "SnakeOil:alice", key))
+
+	got, err := s.Get(ctx, "SnakeOil:alice")
+	require.NoError(t, err)
+	require.Equal(t, key.Secret(), got.Secret())
+	require.Equal(t, key.Metadata(), got.Metadata())
+
+	require.NoError(t, s.Delete(ctx, "SnakeOil:alice"))
+	_, err = s.Get(ctx, "SnakeOil:alice")
+	require.True(t, errors.Is(err, otp.ErrKeyNotFound))
+}
+
+func TestNext(t *testing.T) {
+	s := New(&memDatabase{})
+	for want := uint64(0); want < 3; want++ {
+		c, err := s.Next(context.Background(), "SnakeOil:alice")
+		require.NoError(t, err)
+		require.Equal(t, want, c)
+	}
+}
+
+func TestUse(t *testing.T) {
+	ctx := context.Background()
+	db := &memDatabase{}
+	s := New(db, WithReplayTTL(time.Minute))
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	ok, err := s.Use(ctx, "SnakeOil:alice", 7)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, now.Add(time.Minute), db.collections["otp_replay"].docs[0]["expires_at"])
+
+	ok, err = s.Use(ctx, "SnakeOil:alice", 7)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestAttempts(t *testing.T) {
+	ctx := context.Background()
+	s := New(&memDatabase{})
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	for i := 1; i <= 3; i++ {
+		n, err := s.Add(ctx, "SnakeOil:alice", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, i, n)
+		now = now.Add(20 * time.Second)
+	}
+
+	n, err := s.Add(ctx, "SnakeOil:alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 3, n, "the first attempt left the window")
+
+	require.NoError(t, s.Reset(ctx, "SnakeOil:alice"))
+	n, err = s.Add(ctx, "SnakeOil:bob", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
@@ -0,0 +1,187 @@
+// Package ndef builds NFC Data Exchange Format (NDEF) messages carrying a
+// key URL, so enrollment can be done by tapping an NFC tag instead of
+// scanning a QR code.
+//
+// A URI record is opened by any phone, handing the otpauth URL to the
+// registered authenticator app; it is as sensitive as the QR code. A
+// sealed record holds the URL encrypted with an otp.KeyWrapper, for
+// enrollment apps that share the wrapping key with the server.
+package ndef
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrInvalidMessage is returned when parsing a malformed NDEF message.
+var ErrInvalidMessage = errors.New("Invalid NDEF message")
+
+// Type name formats of NDEF records.
+const (
+	TNFWellKnown byte = 0x01
+	TNFExternal  byte = 0x04
+)
+
+// SealedType is the NFC Forum external type of sealed records.
+const SealedType = "github.com:otp-sealed"
+
+// record header flags.
+const (
+	flagMB = 0x80
+	flagME = 0x40
+	flagCF = 0x20
+	flagSR = 0x10
+	flagIL = 0x08
+)
+
+// Record is an NDEF record.
+type Record struct {
+	TNF     byte
+	Type    []byte
+	ID      []byte
+	Payload []byte
+}
+
+// URIRecord returns the well-known URI record of the key URL. otpauth has
+// no URI abbreviation, so the identifier code is 0.
+func URIRecord(key *otp.Key) Record {
+	return Record{
+		TNF:     TNFWellKnown,
+		Type:    []byte("U"),
+		Payload: append([]byte{0}, key.URL()...),
+	}
+}
+
+// SealedRecord returns an external record holding the key URL encrypted
+// with wrapper, with SealedType as additional data.
+func SealedRecord(ctx context.Context, key *otp.Key, wrapper otp.KeyWrapper) (Record, error) {
+	payload, err := wrapper.Wrap(ctx, []byte(key.URL()), []byte(SealedType))
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{TNF: TNFExternal, Type: []byte(SealedType), Payload: payload}, nil
+}
+
+// Key returns the key held by a record built by URIRecord or SealedRecord.
+// wrapper may be nil for URI records.
+func (r *Record) Key(ctx context.Context, wrapper otp.KeyWrapper) (*otp.Key, error) {
+	switch {
+	case r.TNF == TNFWellKnown && string(r.Type) == "U" && len(r.Payload) > 0 && r.Payload[0] == 0:
+		return otp.NewKeyFromURL(string(r.Payload[1:]))
+	case r.TNF == TNFExternal && string(r.Type) == SealedType && wrapper != nil:
+		url, err := wrapper.Unwrap(ctx, r.Payload, []byte(SealedType))
+		if err != nil {
+			return nil, err
+		}
+		return otp.NewKeyFromURL(string(url))
+	}
+	return nil, ErrInvalidMessage
+}
+
+// Message encodes records as an NDEF message.
+func Message(records ...Record) []byte {
+	var msg []byte
+	for i, r := range records {
+		header := r.TNF & 0x07
+		if i == 0 {
+			header |= flagMB
+		}
+		if i == len(records)-1 {
+			header |= flagME
+		}
+		short := len(r.Payload) < 256
+		if short {
+			header |= flagSR
+		}
+		if len(r.ID) > 0 {
+			header |= flagIL
+		}
+
+		msg = append(msg, header, byte(len(r.Type)))
+		if short {
+			msg = append(msg, byte(len(r.Payload)))
+		} else {
+			n := len(r.Payload)
+			msg = append(msg, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+		if len(r.ID) > 0 {
+			msg = append(msg, byte(len(r.ID)))
+		}
+		msg = append(msg, r.Type...)
+		msg = append(msg, r.ID...)
+		msg = append(msg, r.Payload...)
+	}
+	return msg
+}
+
+// TLV wraps an NDEF message in the NDEF message TLV and terminator TLV of
+// NFC Forum Type 2 tags, such as NTAG21x, ready to be written from the
+// first data page.
+func TLV(msg []byte) []byte {
+	out := []byte{0x03}
+	if len(msg) < 0xff {
+		out = append(out, byte(len(msg)))
+	} else {
+		out = append(out, 0xff, byte(len(msg)>>8), byte(len(msg)))
+	}
+	out = append(out, msg...)
+	return append(out, 0xfe)
+}
+
+// ParseMessage decodes an NDEF message. Chunked records are not supported.
+func ParseMessage(msg []byte) ([]Record, error) {
+	var records []Record
+	for len(msg) > 0 {
+		header := msg[0]
+		if (len(records) == 0) != (header&flagMB != 0) || header&flagCF != 0 {
+			return nil, ErrInvalidMessage
+		}
+		msg = msg[1:]
+
+		n := 2
+		if header&flagSR == 0 {
+			n = 5
+		}
+		if header&flagIL != 0 {
+			n++
+		}
+		if len(msg) < n {
+			return nil, ErrInvalidMessage
+		}
+
+		typeLen := int(msg[0])
+		var payloadLen int
+		if header&flagSR != 0 {
+			payloadLen = int(msg[1])
+		} else {
+			payloadLen = int(msg[1])<<24 | int(msg[2])<<16 | int(msg[3])<<8 | int(msg[4])
+		}
+		idLen := 0
+		if header&flagIL != 0 {
+			idLen = int(msg[n-1])
+		}
+		msg = msg[n:]
+
+		if payloadLen < 0 || len(msg) < typeLen+idLen+payloadLen {
+			return nil, ErrInvalidMessage
+		}
+		r := Record{TNF: header & 0x07, Type: msg[:typeLen]}
+		msg = msg[typeLen:]
+		if idLen > 0 {
+			r.ID = msg[:idLen]
+		}
+		r.Payload = msg[idLen : idLen+payloadLen]
+		msg = msg[idLen+payloadLen:]
+		records = append(records, r)
+
+		if header&flagME != 0 {
+			if len(msg) != 0 {
+				return nil, ErrInvalidMessage
+			}
+			return records, nil
+		}
+	}
+	return nil, ErrInvalidMessage
+}
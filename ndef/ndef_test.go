@@ -0,0 +1,83 @@
+package ndef
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+type xorWrapper byte
+
+func (x xorWrapper) Wrap(_ context.Context, b, aad []byte) ([]byte, error) {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ byte(x)
+	}
+	return out, nil
+}
+
+func (x xorWrapper) Unwrap(ctx context.Context, b, aad []byte) ([]byte, error) {
+	return x.Wrap(ctx, b, aad)
+}
+
+const url = "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"
+
+func TestURIRecord(t *testing.T) {
+	key, err := otp.NewKeyFromURL(url)
+	require.NoError(t, err)
+
+	msg := Message(URIRecord(key))
+	require.Equal(t, []byte{0xd1, 1, byte(len(key.URL()) + 1), 'U', 0}, msg[:5])
+	require.Equal(t, key.URL(), string(msg[5:]))
+
+	tlv := TLV(msg)
+	require.Equal(t, []byte{0x03, byte(len(msg))}, tlv[:2])
+	require.Equal(t, byte(0xfe), tlv[len(tlv)-1])
+
+	records, err := ParseMessage(msg)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	got, err := records[0].Key(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, key.URL(), got.URL())
+}
+
+func TestSealedRecord(t *testing.T) {
+	ctx := context.Background()
+	key, err := otp.NewKeyFromURL(url)
+	require.NoError(t, err)
+
+	sealed, err := SealedRecord(ctx, key, xorWrapper(0x5a))
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed.Payload), "JBSWY3DPEHPK3PXP")
+
+	// A sealed record next to a long record, encoded without SR and with
+	// an ID.
+	long := Record{TNF: TNFExternal, Type: []byte("example.com:pad"), ID: []byte("1"), Payload: make([]byte, 300)}
+	records, err := ParseMessage(Message(sealed, long))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, long, records[1])
+
+	got, err := records[0].Key(ctx, xorWrapper(0x5a))
+	require.NoError(t, err)
+	require.Equal(t, key.URL(), got.URL())
+
+	_, err = records[0].Key(ctx, nil)
+	require.Equal(t, ErrInvalidMessage, err)
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	msg := Message(Record{TNF: TNFWellKnown, Type: []byte("U"), Payload: []byte{0, 'x'}})
+	for _, bad := range [][]byte{
+		msg[:len(msg)-1],
+		append(append([]byte{}, msg...), 0),
+		append([]byte{msg[0] &^ flagMB}, msg[1:]...),
+		append([]byte{msg[0] &^ flagME}, msg[1:]...),
+	} {
+		_, err := ParseMessage(bad)
+		require.Equal(t, ErrInvalidMessage, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package ntp implements an otp.TimeSource backed by a (S)NTP server.
+package ntp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900)
+// and the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// The server response was malformed or unusable.
+var ErrInvalidResponse = errors.New("Invalid NTP response")
+
+// Source queries an NTP server for the current time. The offset between
+// the local clock and the server is cached, so a busy validator does not
+// query the server on every call.
+// A Source is safe for concurrent use.
+type Source struct {
+	// Server address as host:port. Defaults to pool.ntp.org:123.
+	Server string
+	// Timeout of a single query. Defaults to 5 seconds.
+	Timeout time.Duration
+	// How long a measured offset is reused. Defaults to 5 minutes.
+	CacheFor time.Duration
+
+	mu       sync.Mutex
+	offset   time.Duration
+	measured time.Time
+	// refreshing is set while a query for a stale offset is in flight.
+	refreshing bool
+}
+
+// Now returns the local time corrected by the offset to the server.
+func (s *Source) Now() (time.Time, error) {
+	offset, err := s.Offset()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(offset), nil
+}
+
+// Offset returns how far the server's clock is ahead of the local clock,
+// querying the server if the cached offset is stale. The server is queried
+// without holding the lock: while one caller refreshes a stale offset,
+// the others keep using it.
+func (s *Source) Offset() (time.Duration, error) {
+	cacheFor := s.CacheFor
+	if cacheFor == 0 {
+		cacheFor = 5 * time.Minute
+	}
+
+	s.mu.Lock()
+	measured := !s.measured.IsZero()
+	if measured && (s.refreshing || time.Since(s.measured) < cacheFor) {
+		offset := s.offset
+		s.mu.Unlock()
+		return offset, nil
+	}
+	s.refreshing = true
+	s.mu.Unlock()
+
+	offset, err := s.query()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshing = false
+	if err != nil {
+		return 0, err
+	}
+	s.offset = offset
+	s.measured = time.Now()
+
+	return offset, nil
+}
+
+// query performs a single SNTP exchange (RFC 4330) and returns the offset.
+func (s *Source) query() (time.Duration, error) {
+	server := s.Server
+	if server == "" {
+		server = "pool.ntp.org:123"
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 48)
+	// LI = 0, VN = 4, Mode = 3 (client).
+	req[0] = 0x23
+
+	t0 := time.Now()
+	putTimestamp(req[40:], t0)
+
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	t3 := time.Now()
+
+	if n < 48 || resp[0]&0x7 != 4 || resp[1] == 0 {
+		return 0, ErrInvalidResponse
+	}
+	// The server copies our transmit timestamp to the originate
+	// timestamp; anything else is a stale or spoofed reply.
+	if !bytes.Equal(resp[24:32], req[40:48]) {
+		return 0, ErrInvalidResponse
+	}
+
+	t1 := timestamp(resp[32:])
+	t2 := timestamp(resp[40:])
+
+	// Clock offset as defined by RFC 4330 section 5.
+	return (t1.Sub(t0) + t2.Sub(t3)) / 2, nil
+}
+
+// timestamp decodes a 64 bit NTP timestamp.
+func timestamp(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(sec, frac*1e9>>32)
+}
+
+// putTimestamp encodes t as a 64 bit NTP timestamp.
+func putTimestamp(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32(int64(t.Nanosecond())<<32/1e9))
+}
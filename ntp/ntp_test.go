@@ -0,0 +1,65 @@
+package ntp
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"net"
+	"testing"
+	"time"
+)
+
+// serve answers NTP requests with a clock running skew ahead of local time.
+// Unless echo is set, the originate timestamp does not match the request.
+func serve(t *testing.T, skew time.Duration, echo bool) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			// LI = 0, VN = 4, Mode = 4 (server), stratum 1.
+			resp[0] = 0x24
+			resp[1] = 1
+			if echo {
+				copy(resp[24:32], buf[40:48])
+			}
+			putTimestamp(resp[32:], time.Now().Add(skew))
+			putTimestamp(resp[40:], time.Now().Add(skew))
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestSourceOffset(t *testing.T) {
+	s := &Source{Server: serve(t, 42*time.Second, true), Timeout: time.Second}
+
+	offset, err := s.Offset()
+	require.NoError(t, err)
+	require.InDelta(t, float64(42*time.Second), float64(offset), float64(100*time.Millisecond))
+
+	now, err := s.Now()
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(42*time.Second), now, 100*time.Millisecond)
+}
+
+func TestSourceOriginate(t *testing.T) {
+	s := &Source{Server: serve(t, 42*time.Second, false), Timeout: time.Second}
+
+	_, err := s.Offset()
+	require.Equal(t, ErrInvalidResponse, err)
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	in := time.Unix(1600000000, 123456789)
+	b := make([]byte, 8)
+	putTimestamp(b, in)
+	require.WithinDuration(t, in, timestamp(b), time.Microsecond)
+}
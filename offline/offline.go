@@ -0,0 +1,171 @@
+// Package offline exports time-bounded bundles of pre-computed TOTP code
+// hashes, so an air-gapped or intermittently connected validator can check
+// codes for the coming hours without holding the secrets.
+//
+// A bundle reveals no secret and nothing about codes outside its validity,
+// but the code space is small: whoever holds a bundle can recover the codes
+// it covers by brute force. Protect bundles like the codes themselves, and
+// keep their validity short.
+package offline
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrBundleExpired is returned when validating outside the validity of a
+// bundle.
+var ErrBundleExpired = errors.New("Offline bundle not valid at this time")
+
+// ErrUnknownKey is returned when validating a key absent from a bundle.
+var ErrUnknownKey = errors.New("Key not in offline bundle")
+
+// Bundle holds the code hashes of keys for the periods of its validity. It
+// is meant to be encoded as JSON.
+type Bundle struct {
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	// Skew is the number of periods accepted either side of the current one.
+	Skew uint `json:"skew"`
+	// Salt of the code hashes, random for every bundle.
+	Salt []byte          `json:"salt"`
+	Keys map[string]*Key `json:"keys"`
+}
+
+// Key holds the code hashes of one key, by key ID.
+type Key struct {
+	Period uint64     `json:"period"`
+	Digits otp.Digits `json:"digits"`
+	// T0 is the Unix time the periods are counted from, see otp.WithEpoch.
+	T0 int64 `json:"t0,omitempty"`
+	// First is the counter of the first hash.
+	First  uint64   `json:"first"`
+	Hashes [][]byte `json:"hashes"`
+}
+
+// ExportOpts are the options of Export.
+type ExportOpts struct {
+	// Skew is the number of periods accepted either side of the current
+	// one. Defaults to 0; use otp.DefaultSkew to match online validation.
+	Skew uint
+	// Rand is the source of the salt. Defaults to crypto/rand.
+	Rand io.Reader
+}
+
+// Export returns a bundle valid from notBefore for d, holding the code
+// hashes of keys, which must be TOTP keys, indexed by their ID.
+func Export(keys []*otp.Key, notBefore time.Time, d time.Duration, opts ExportOpts) (*Bundle, error) {
+	if opts.Rand == nil {
+		opts.Rand = rand.Reader
+	}
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(opts.Rand, salt); err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(d),
+		Skew:      opts.Skew,
+		Salt:      salt,
+		Keys:      make(map[string]*Key, len(keys)),
+	}
+
+	for _, key := range keys {
+		period := key.Period()
+		if key.Type() != "totp" || period == 0 {
+			return nil, &otp.OptionError{Name: "Type", Value: key.Type(), Err: otp.ErrUnsupportedType}
+		}
+
+		var t0 int64
+		if epoch := key.Epoch(); !epoch.IsZero() {
+			t0 = epoch.Unix()
+		}
+		if b.NotBefore.Unix() < t0 {
+			return nil, &otp.OptionError{Name: "NotBefore", Value: b.NotBefore, Err: otp.ErrInvalidOption}
+		}
+
+		id := key.ID()
+		first := counterAt(b.NotBefore, period, t0)
+		if first < uint64(opts.Skew) {
+			first = 0
+		} else {
+			first -= uint64(opts.Skew)
+		}
+		last := counterAt(b.NotAfter, period, t0) + uint64(opts.Skew)
+
+		k := &Key{Period: period, Digits: key.Digits(), T0: t0, First: first}
+		for c := first; c <= last; c++ {
+			code, err := key.GenerateCode(time.Unix(t0+int64(c*period), 0))
+			if err != nil {
+				return nil, err
+			}
+			k.Hashes = append(k.Hashes, b.hash(id, c, code))
+		}
+		b.Keys[id] = k
+	}
+
+	return b, nil
+}
+
+// Validate checks passcode for the key with the given ID at t.
+func (b *Bundle) Validate(id, passcode string, t time.Time) (bool, error) {
+	if t.Before(b.NotBefore) || t.After(b.NotAfter) {
+		return false, ErrBundleExpired
+	}
+	k, ok := b.Keys[id]
+	if !ok {
+		return false, ErrUnknownKey
+	}
+
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != k.Digits.Length() {
+		return false, otp.ErrValidateInputInvalidLength
+	}
+
+	if t.Unix() < k.T0 {
+		return false, ErrBundleExpired
+	}
+	current := counterAt(t, k.Period, k.T0)
+	from := uint64(0)
+	if current > uint64(b.Skew) {
+		from = current - uint64(b.Skew)
+	}
+	found := 0
+	for c := from; c <= current+uint64(b.Skew); c++ {
+		i := c - k.First
+		if c < k.First || i >= uint64(len(k.Hashes)) {
+			continue
+		}
+		found |= subtle.ConstantTimeCompare(b.hash(id, c, passcode), k.Hashes[i])
+	}
+	return found == 1, nil
+}
+
+// hash returns the hash of the code of key id at counter.
+func (b *Bundle) hash(id string, counter uint64, code string) []byte {
+	mac := hmac.New(sha256.New, b.Salt)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(id)))
+	mac.Write(n[:])
+	mac.Write([]byte(id))
+	binary.BigEndian.PutUint64(n[:], counter)
+	mac.Write(n[:])
+	mac.Write([]byte(code))
+	return mac.Sum(nil)
+}
+
+// counterAt returns the counter of t for periods counted from t0, which
+// must not be after t.
+func counterAt(t time.Time, period uint64, t0 int64) uint64 {
+	return uint64(t.Unix()-t0) / period
+}
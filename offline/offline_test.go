@@ -0,0 +1,88 @@
+package offline
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	key, err := totp.GenerateWithOpts(totp.WithIssuer("Example"), totp.WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b, err := Export([]*otp.Key{key}, start, time.Hour, ExportOpts{Skew: 1})
+	require.NoError(t, err)
+
+	// The bundle survives encoding and holds no secret.
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), key.Secret())
+	b = nil
+	require.NoError(t, json.Unmarshal(data, &b))
+
+	at := start.Add(30 * time.Minute)
+	code, err := key.GenerateCode(at)
+	require.NoError(t, err)
+
+	ok, err := b.Validate(key.ID(), code, at)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// One period of skew is accepted, two are not.
+	ok, err = b.Validate(key.ID(), code, at.Add(30*time.Second))
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = b.Validate(key.ID(), code, at.Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// The skew is covered at the edges of the validity.
+	code, err = key.GenerateCode(start.Add(-30 * time.Second))
+	require.NoError(t, err)
+	ok, err = b.Validate(key.ID(), code, start)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = b.Validate(key.ID(), code, start.Add(2*time.Hour))
+	require.Equal(t, ErrBundleExpired, err)
+	_, err = b.Validate("Example:bob@example.com", code, at)
+	require.Equal(t, ErrUnknownKey, err)
+	_, err = b.Validate(key.ID(), "123", at)
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err)
+}
+
+func TestExportRejectsHOTP(t *testing.T) {
+	key, err := otp.NewKeyFromURL("otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&counter=0")
+	require.NoError(t, err)
+
+	_, err = Export([]*otp.Key{key}, time.Now(), time.Hour, ExportOpts{})
+	require.True(t, errors.Is(err, otp.ErrUnsupportedType))
+}
+
+func TestBundleEpoch(t *testing.T) {
+	key, err := totp.GenerateWithOpts(totp.WithIssuer("Example"), totp.WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+	key = key.Clone(otp.WithEpoch(time.Unix(15, 0)))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b, err := Export([]*otp.Key{key}, start, 10*time.Minute, ExportOpts{})
+	require.NoError(t, err)
+	require.Equal(t, int64(15), b.Keys[key.ID()].T0)
+
+	for at := start; !at.After(b.NotAfter); at = at.Add(5 * time.Second) {
+		code, err := key.GenerateCode(at)
+		require.NoError(t, err)
+		ok, err := b.Validate(key.ID(), code, at)
+		require.NoError(t, err)
+		require.True(t, ok, "at %v", at)
+	}
+
+	_, err = Export([]*otp.Key{key}, time.Unix(10, 0), time.Hour, ExportOpts{})
+	require.True(t, errors.Is(err, otp.ErrInvalidOption))
+}
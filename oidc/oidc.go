@@ -0,0 +1,128 @@
+// Package oidc maps successful OTP validation to the acr and amr claims of
+// OpenID Connect, for providers implementing step-up authentication, and
+// verifies those claims on resource servers.
+//
+// The amr values are those registered by RFC 8176. Resource servers that
+// find the claims insufficient can answer with the challenge of RFC 9470.
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// Authentication method references registered by RFC 8176.
+const (
+	// AMROTP is a one time password.
+	AMROTP = "otp"
+	// AMRMFA is the use of multiple authentication factors.
+	AMRMFA = "mfa"
+	// AMRPassword is a password.
+	AMRPassword = "pwd"
+)
+
+// The acr claim does not name the level the Policy requires.
+var ErrInsufficientACR = errors.New("Insufficient authentication context class")
+
+// The amr claim does not include a one time password.
+var ErrMissingOTP = errors.New("Authentication methods do not include an OTP")
+
+// The OTP authentication is older than the Policy allows.
+var ErrAuthTooOld = errors.New("Authentication is too old")
+
+// Claims are the authentication claims of an ID or access token.
+type Claims struct {
+	// ACR is the authentication context class reference.
+	ACR string
+	// AMR lists the authentication methods used.
+	AMR []string
+	// AuthTime is when the user last authenticated.
+	AuthTime time.Time
+}
+
+// HasAMR reports whether method is one of the authentication methods.
+func (c Claims) HasAMR(method string) bool {
+	for _, m := range c.AMR {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy describes the step-up level granted by OTP validation.
+type Policy struct {
+	// ACR granted by OTP validation and required by Verify, eg
+	// "urn:example:acr:mfa".
+	ACR string
+	// MaxAge is how long an OTP authentication satisfies Verify.
+	// Zero accepts authentications of any age.
+	MaxAge time.Duration
+}
+
+// Grant returns the claims of a session after the user validated a
+// passcode at t: the Policy's acr, "otp" added to the methods, "mfa" when
+// the session already used another method, and t as the authentication
+// time.
+func (p Policy) Grant(c Claims, t time.Time) Claims {
+	amr := make([]string, 0, len(c.AMR)+2)
+	amr = append(amr, c.AMR...)
+
+	if !c.HasAMR(AMROTP) {
+		amr = append(amr, AMROTP)
+	}
+	if len(c.AMR) > 0 && !c.HasAMR(AMRMFA) {
+		for _, m := range c.AMR {
+			if m != AMROTP {
+				amr = append(amr, AMRMFA)
+				break
+			}
+		}
+	}
+
+	return Claims{ACR: p.ACR, AMR: amr, AuthTime: t}
+}
+
+// Validate checks passcode with key at t and, when it is valid, returns the
+// claims granted by the Policy. Invalid passcodes leave the claims as they
+// are.
+func (p Policy) Validate(c Claims, key *otp.Key, passcode string, t time.Time) (Claims, bool, error) {
+	ok, err := key.Validate(passcode, t)
+	if err != nil || !ok {
+		return c, false, err
+	}
+	return p.Grant(c, t), true, nil
+}
+
+// Verify checks on a resource server that the claims satisfy the Policy
+// at now. The error matches ErrInsufficientACR, ErrMissingOTP or
+// ErrAuthTooOld with errors.Is.
+func (p Policy) Verify(c Claims, now time.Time) error {
+	if p.ACR != "" && c.ACR != p.ACR {
+		return fmt.Errorf("%w: got %q, want %q", ErrInsufficientACR, c.ACR, p.ACR)
+	}
+	if !c.HasAMR(AMROTP) {
+		return ErrMissingOTP
+	}
+	if p.MaxAge > 0 && now.Sub(c.AuthTime) > p.MaxAge {
+		return fmt.Errorf("%w: authenticated %v ago, max age %v", ErrAuthTooOld, now.Sub(c.AuthTime), p.MaxAge)
+	}
+	return nil
+}
+
+// Challenge returns the WWW-Authenticate header value of RFC 9470 asking
+// the client to step up to the Policy.
+func (p Policy) Challenge() string {
+	s := `Bearer error="insufficient_user_authentication"`
+	if p.ACR != "" {
+		s += `, acr_values="` + p.ACR + `"`
+	}
+	if p.MaxAge > 0 {
+		s += `, max_age=` + strconv.FormatInt(int64(p.MaxAge/time.Second), 10)
+	}
+	return s
+}
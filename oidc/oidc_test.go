@@ -0,0 +1,57 @@
+package oidc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+var mfa = Policy{ACR: "urn:example:acr:mfa", MaxAge: 10 * time.Minute}
+
+func TestGrant(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	c := mfa.Grant(Claims{ACR: "urn:example:acr:pwd", AMR: []string{AMRPassword}}, now)
+	require.Equal(t, Claims{ACR: mfa.ACR, AMR: []string{AMRPassword, AMROTP, AMRMFA}, AuthTime: now}, c)
+
+	c = mfa.Grant(Claims{}, now)
+	require.Equal(t, []string{AMROTP}, c.AMR)
+
+	c = mfa.Grant(mfa.Grant(Claims{AMR: []string{AMRPassword}}, now), now)
+	require.Equal(t, []string{AMRPassword, AMROTP, AMRMFA}, c.AMR)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := mfa.Grant(Claims{AMR: []string{AMRPassword}}, now)
+
+	require.NoError(t, mfa.Verify(c, now.Add(time.Minute)))
+	require.True(t, errors.Is(mfa.Verify(c, now.Add(time.Hour)), ErrAuthTooOld))
+	require.True(t, errors.Is(mfa.Verify(Claims{ACR: mfa.ACR}, now), ErrMissingOTP))
+	require.True(t, errors.Is(mfa.Verify(Claims{ACR: "urn:example:acr:pwd", AMR: []string{AMROTP}}, now), ErrInsufficientACR))
+
+	require.Equal(t, `Bearer error="insufficient_user_authentication", acr_values="urn:example:acr:mfa", max_age=600`, mfa.Challenge())
+}
+
+func TestValidate(t *testing.T) {
+	key, err := totp.GenerateWithOpts(totp.WithIssuer("SnakeOil"), totp.WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := key.GenerateCode(now)
+	require.NoError(t, err)
+
+	before := Claims{AMR: []string{AMRPassword}}
+	c, ok, err := mfa.Validate(before, key, code, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, mfa.Verify(c, now))
+
+	c, ok, err = mfa.Validate(before, key, "000000", now.Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, before, c)
+}
@@ -19,7 +19,7 @@ package otp
 
 import (
 	"github.com/boombuler/barcode"
-	"github.com/boombuler/barcode/qr"
+	"github.com/pquerna/otp/internal/sha3"
 
 	"crypto/md5"
 	"crypto/sha1"
@@ -32,6 +32,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 // Error when attempting to convert the secret from base32 to raw bytes.
@@ -40,16 +41,143 @@ var ErrValidateSecretInvalidBase32 = errors.New("Decoding of secret as base32 fa
 // The user provided passcode length was not expected.
 var ErrValidateInputInvalidLength = errors.New("Input length unexpected")
 
+// The requested skew is larger than the maximum allowed skew.
+var ErrValidateSkewTooLarge = errors.New("Skew exceeds the maximum allowed skew")
+
+// The boundary tolerance is negative or not shorter than the period.
+var ErrValidateToleranceTooLarge = errors.New("Boundary tolerance must be shorter than the period")
+
+// The wall clock stepped backwards since the previous validation.
+var ErrValidateClockJumped = errors.New("Wall clock jumped backwards")
+
+// The passcode was already accepted, see totp.WithReplayProtection.
+var ErrValidateReplayed = errors.New("Passcode already used")
+
+// Too many passcodes were submitted recently, see totp.WithRateLimit.
+var ErrValidateRateLimited = errors.New("Too many validation attempts")
+
 // When generating a Key, the Issuer must be set.
 var ErrGenerateMissingIssuer = errors.New("Issuer must be set")
 
 // When generating a Key, the Account Name must be set.
 var ErrGenerateMissingAccountName = errors.New("AccountName must be set")
 
+// MinSecretSize is the smallest SecretSize, in bytes, Generate accepts.
+const MinSecretSize = 10
+
+// When generating a Key, the SecretSize must be at least MinSecretSize.
+var ErrGenerateSecretTooShort = errors.New("SecretSize must be at least 10 bytes")
+
 // Key represents an TOTP or HTOP key.
+//
+// A Key keeps the parsed components of its URL rather than the URL itself;
+// the URL string is built, with its parameters sorted by name, every time
+// String, URL or Image is called. This keeps large numbers of keys cheap to
+// hold in memory.
+//
+// A Key is safe for concurrent use. Its components are immutable; the Set
+// methods publish a modified copy atomically, so readers always observe a
+// consistent set of parameters.
 type Key struct {
-	orig string
-	url  *url.URL
+	state atomic.Value // *keyState
+}
+
+// keyState is an immutable snapshot of a Key's components.
+type keyState struct {
+	scheme string
+	typ    string
+	path   string
+	params keyParams
+	// metadata tags, nil if there are none. Never modified once published.
+	meta map[string]string
+}
+
+// keyParams holds the query parameters of a Key.
+type keyParams struct {
+	issuer    string
+	secret    string
+	period    string
+	digits    string
+	algorithm string
+	counter   string
+	// any other parameters, nil if there are none.
+	extra url.Values
+}
+
+// KeyOpts describes the components of a Key created with NewKey.
+type KeyOpts struct {
+	// Type of the key, "totp" or "hotp".
+	Type string
+	// Name of the issuing Organization/Company.
+	Issuer string
+	// Name of the User's Account (eg, email address)
+	AccountName string
+	// Base32 encoded secret.
+	Secret string
+	// Number of seconds a TOTP hash is valid for. Omitted from the URL when 0.
+	Period uint
+	// Digits of the passcode.
+	Digits Digits
+	// Algorithm to use for HMAC.
+	Algorithm Algorithm
+	// Label shown by authenticator apps. Defaults to "Issuer:AccountName".
+	// It is escaped when the URL is built, so it may contain any text.
+	Label string
+	// Metadata tags attached to the key, see Key.Metadata.
+	Metadata map[string]string
+}
+
+// LabelFunc builds the label of a generated key from its issuer and
+// account name, for products whose authenticator entries follow a naming
+// convention such as "ACME-PROD / jane".
+type LabelFunc func(issuer, accountName string) string
+
+// NewKey creates a new Key from its components. Its URL is in the
+// canonical form described by Canonicalize. Issuer, AccountName and Label
+// are normalized to Unicode NFC, so the same logical name always produces
+// the same URL.
+func NewKey(opts KeyOpts) *Key {
+	ks := &keyState{
+		scheme: "otpauth",
+		typ:    opts.Type,
+		path:   label(normName(opts.Issuer), normName(opts.AccountName)),
+		params: keyParams{
+			issuer:    normName(opts.Issuer),
+			secret:    opts.Secret,
+			digits:    opts.Digits.String(),
+			algorithm: opts.Algorithm.String(),
+		},
+	}
+
+	if opts.Label != "" {
+		ks.path = "/" + normName(opts.Label)
+	}
+
+	for name, value := range opts.Metadata {
+		WithMetadata(name, value)(ks)
+	}
+
+	if opts.Period != 0 {
+		ks.params.period = strconv.FormatUint(uint64(opts.Period), 10)
+	}
+
+	return newKey(ks)
+}
+
+func newKey(ks *keyState) *Key {
+	k := &Key{}
+	k.state.Store(ks)
+	return k
+}
+
+var emptyKeyState = &keyState{}
+
+// load returns the current snapshot of the key's components.
+func (k *Key) load() *keyState {
+	if ks, ok := k.state.Load().(*keyState); ok {
+		return ks
+	}
+	return emptyKeyState
 }
 
 // NewKeyFromURL creates a new Key from an TOTP or HOTP url.
@@ -57,31 +185,242 @@ type Key struct {
 // The URL format is documented here:
 //   https://github.com/google/google-authenticator/wiki/Key-Uri-Format
 //
-func NewKeyFromURL(orig string) (*Key, error) {
+// Options such as StrictIssuer make parsing refuse URLs that are valid but
+// likely to be mislabeled by authenticator apps.
+func NewKeyFromURL(orig string, parseOpts ...ParseOpt) (*Key, error) {
 	s := strings.TrimSpace(orig)
 
 	u, err := url.Parse(s)
 
 	if err != nil {
-		return nil, err
+		return nil, &URLError{URL: s, Err: err}
+	}
+
+	ks := &keyState{
+		scheme: u.Scheme,
+		typ:    u.Host,
+		path:   u.Path,
+		params: parseKeyParams(u.RawQuery),
+	}
+	ks.meta = ks.params.splitMetadata()
+	ks.compact()
+
+	k := newKey(ks)
+
+	for _, opt := range parseOpts {
+		if err := opt(k); err != nil {
+			return nil, &URLError{URL: s, Err: err}
+		}
+	}
+
+	return k, nil
+}
+
+// ParseOpt is an additional check NewKeyFromURL makes on a parsed Key.
+type ParseOpt func(k *Key) error
+
+// StrictIssuer makes NewKeyFromURL fail with an error matching
+// ErrIssuerMismatch when the issuer prefix of the label and the issuer
+// parameter disagree, a common source of duplicate or mislabeled entries
+// in authenticator apps.
+func StrictIssuer() ParseOpt {
+	return (*Key).CheckIssuer
+}
+
+// CheckIssuer returns an error matching ErrIssuerMismatch when the issuer
+// prefix of the label and the issuer parameter are both set and disagree.
+// Callers that accept such keys can use it to log a warning.
+func (k *Key) CheckIssuer() error {
+	ks := k.load()
+	labelIss, paramIss := labelIssuer(ks.path), ks.params.issuer
+
+	if labelIss != "" && paramIss != "" && labelIss != paramIss {
+		return fmt.Errorf("%w: label %q, parameter %q", ErrIssuerMismatch, labelIss, paramIss)
+	}
+	return nil
+}
+
+// parseKeyParams splits a raw query into the parameters a Key knows about.
+// As with url.ParseQuery, the first value of a parameter wins and pairs
+// that are not properly escaped are skipped, but no map is allocated
+// unless there are other parameters. The values of other parameters are
+// copied, so they do not keep rawQuery alive.
+func parseKeyParams(rawQuery string) keyParams {
+	var p keyParams
+	var seen uint8
+
+	for rawQuery != "" {
+		pair := rawQuery
+		if i := strings.IndexByte(rawQuery, '&'); i >= 0 {
+			pair, rawQuery = rawQuery[:i], rawQuery[i+1:]
+		} else {
+			rawQuery = ""
+		}
+		if pair == "" || strings.Contains(pair, ";") {
+			continue
+		}
+
+		name, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			name, value = pair[:i], pair[i+1:]
+		}
+		name, err := url.QueryUnescape(name)
+		if err != nil {
+			continue
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+
+		var field *string
+		var bit uint8
+		switch name {
+		case "issuer":
+			field, bit = &p.issuer, 1<<0
+		case "secret":
+			field, bit = &p.secret, 1<<1
+		case "period":
+			field, bit = &p.period, 1<<2
+		case "digits":
+			field, bit = &p.digits, 1<<3
+		case "algorithm":
+			field, bit = &p.algorithm, 1<<4
+		case "counter":
+			field, bit = &p.counter, 1<<5
+		default:
+			if p.extra == nil {
+				p.extra = url.Values{}
+			}
+			name = cloneString(name)
+			p.extra[name] = append(p.extra[name], cloneString(value))
+			continue
+		}
+		if seen&bit == 0 {
+			*field = value
+			seen |= bit
+		}
+	}
+
+	return p
+}
+
+// commonValues are component values shared by most keys. A parsed Key
+// refers to these instead of holding copies.
+var commonValues = map[string]string{
+	"":        "",
+	"otpauth": "otpauth",
+	"totp":    "totp",
+	"hotp":    "hotp",
+	"30":      "30",
+	"60":      "60",
+	"6":       "6",
+	"8":       "8",
+	"SHA1":    "SHA1",
+	"SHA256":  "SHA256",
+	"SHA512":  "SHA512",
+}
+
+// compact copies the components of a parsed key into a single allocation,
+// sharing common values, so the key holds only the bytes it needs rather
+// than keeping the whole URL it was parsed from alive.
+func (ks *keyState) compact() {
+	fields := [...]*string{
+		&ks.scheme, &ks.typ, &ks.path,
+		&ks.params.issuer, &ks.params.secret, &ks.params.period,
+		&ks.params.digits, &ks.params.algorithm, &ks.params.counter,
+	}
+
+	var shared [len(fields)]bool
+	n := 0
+	for i, f := range fields {
+		if v, ok := commonValues[*f]; ok {
+			*f, shared[i] = v, true
+		} else {
+			n += len(*f)
+		}
+	}
+	if n == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.Grow(n)
+	for i, f := range fields {
+		if !shared[i] {
+			b.WriteString(*f)
+		}
+	}
+
+	all := b.String()
+	for i, f := range fields {
+		if !shared[i] {
+			*f, all = all[:len(*f)], all[len(*f):]
+		}
+	}
+}
+
+// cloneString returns a copy of s that does not share its memory.
+func cloneString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s)
+	return b.String()
+}
+
+// encode builds the raw query for the parameters, omitting empty values.
+func (p *keyParams) encode() string {
+	v := url.Values{}
+	for name, values := range p.extra {
+		v[name] = values
 	}
 
-	return &Key{
-		orig: s,
-		url:  u,
-	}, nil
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	set("issuer", p.issuer)
+	set("secret", p.secret)
+	set("period", p.period)
+	set("digits", p.digits)
+	set("algorithm", p.algorithm)
+	set("counter", p.counter)
+
+	return v.Encode()
 }
 
 func (k *Key) String() string {
-	return k.orig
+	return k.load().url()
+}
+
+// url serializes the key's components.
+func (ks *keyState) url() string {
+	u := url.URL{
+		Scheme:   ks.scheme,
+		Host:     ks.typ,
+		Path:     ks.path,
+		RawQuery: ks.params.encode(),
+	}
+	// A slash inside the label would otherwise be read as a path separator.
+	if l := strings.TrimPrefix(ks.path, "/"); strings.Contains(l, "/") {
+		u.RawPath = "/" + url.PathEscape(l)
+	}
+	return u.String()
 }
 
+// MaxImageSize is the largest width or height Image accepts.
+const MaxImageSize = 4096
+
 // Image returns an QR-Code image of the specified width and height,
 // suitable for use by many clients like Google-Authenricator
 // to enroll a user's TOTP/HOTP key.
 func (k *Key) Image(width int, height int) (image.Image, error) {
-	b, err := qr.Encode(k.orig, qr.M, qr.Auto)
+	if err := checkImageSize(width, height); err != nil {
+		return nil, err
+	}
 
+	b, err := k.qrCode(nil)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +428,7 @@ func (k *Key) Image(width int, height int) (image.Image, error) {
 	b, err = barcode.Scale(b, width, height)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrImageEncoding, err)
 	}
 
 	return b, nil
@@ -97,53 +436,34 @@ func (k *Key) Image(width int, height int) (image.Image, error) {
 
 // Type returns "hotp" or "totp".
 func (k *Key) Type() string {
-	return k.url.Host
+	return k.load().typ
 }
 
 // Issuer returns the name of the issuing organization.
 func (k *Key) Issuer() string {
-	q := k.url.Query()
-
-	issuer := q.Get("issuer")
+	ks := k.load()
+	issuer := ks.params.issuer
 
 	if issuer != "" {
-		return issuer
+		return normName(issuer)
 	}
 
-	p := strings.TrimPrefix(k.url.Path, "/")
-	i := strings.Index(p, ":")
-
-	if i == -1 {
-		return ""
-	}
-
-	return p[:i]
+	return normName(labelIssuer(ks.path))
 }
 
 // AccountName returns the name of the user's account.
 func (k *Key) AccountName() string {
-	p := strings.TrimPrefix(k.url.Path, "/")
-	i := strings.Index(p, ":")
-
-	if i == -1 {
-		return p
-	}
-
-	return p[i+1:]
+	return normName(accountName(k.load().path))
 }
 
 // Secret returns the opaque secret for this Key.
 func (k *Key) Secret() string {
-	q := k.url.Query()
-
-	return q.Get("secret")
+	return k.load().params.secret
 }
 
 // Period returns a tiny int representing the rotation time in seconds.
 func (k *Key) Period() uint64 {
-	q := k.url.Query()
-
-	if u, err := strconv.ParseUint(q.Get("period"), 10, 64); err == nil {
+	if u, err := strconv.ParseUint(k.load().params.period, 10, 64); err == nil {
 		return u
 	}
 
@@ -153,7 +473,7 @@ func (k *Key) Period() uint64 {
 
 // URL returns the OTP URL as a string
 func (k *Key) URL() string {
-	return k.url.String()
+	return k.load().url()
 }
 
 // Algorithm represents the hashing function to use in the HMAC
@@ -168,8 +488,26 @@ const (
 	AlgorithmSHA256
 	AlgorithmSHA512
 	AlgorithmMD5
+	AlgorithmSHA224
+	AlgorithmSHA512_256
+	// AlgorithmSHA3_256 and AlgorithmSHA3_512 are for token profiles that
+	// require SHA-3. Few authenticator apps support them.
+	AlgorithmSHA3_256
+	AlgorithmSHA3_512
 )
 
+// algorithms lists every supported Algorithm.
+var algorithms = []Algorithm{
+	AlgorithmSHA1,
+	AlgorithmSHA256,
+	AlgorithmSHA512,
+	AlgorithmMD5,
+	AlgorithmSHA224,
+	AlgorithmSHA512_256,
+	AlgorithmSHA3_256,
+	AlgorithmSHA3_512,
+}
+
 func (a Algorithm) String() string {
 	switch a {
 	case AlgorithmSHA1:
@@ -180,8 +518,66 @@ func (a Algorithm) String() string {
 		return "SHA512"
 	case AlgorithmMD5:
 		return "MD5"
+	case AlgorithmSHA224:
+		return "SHA224"
+	case AlgorithmSHA512_256:
+		return "SHA512/256"
+	case AlgorithmSHA3_256:
+		return "SHA3-256"
+	case AlgorithmSHA3_512:
+		return "SHA3-512"
 	}
-	panic("unreached")
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// Check returns an OptionError matching ErrUnsupportedAlgorithm if a is not
+// an algorithm this package implements.
+func (a Algorithm) Check() error {
+	for _, v := range algorithms {
+		if a == v {
+			return nil
+		}
+	}
+	return &OptionError{Name: "Algorithm", Value: a, Err: ErrUnsupportedAlgorithm}
+}
+
+// ParseAlgorithm returns the Algorithm named s, as used in the algorithm
+// parameter of a Key URL. The comparison is case insensitive, and
+// "SHA512-256" and "SHA512_256" are accepted for SHA512/256, as are
+// "SHA3_256" and "SHA3_512" for the SHA-3 algorithms.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch strings.ToUpper(s) {
+	case "SHA512-256", "SHA512_256":
+		return AlgorithmSHA512_256, nil
+	case "SHA3_256":
+		return AlgorithmSHA3_256, nil
+	case "SHA3_512":
+		return AlgorithmSHA3_512, nil
+	}
+	for _, a := range algorithms {
+		if strings.EqualFold(s, a.String()) {
+			return a, nil
+		}
+	}
+	return 0, &OptionError{Name: "Algorithm", Value: s, Err: ErrUnsupportedAlgorithm}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Algorithm) MarshalText() ([]byte, error) {
+	if err := a.Check(); err != nil {
+		return nil, err
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseAlgorithm.
+func (a *Algorithm) UnmarshalText(text []byte) error {
+	v, err := ParseAlgorithm(string(text))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
 }
 
 func (a Algorithm) Hash() hash.Hash {
@@ -194,6 +590,14 @@ func (a Algorithm) Hash() hash.Hash {
 		return sha512.New()
 	case AlgorithmMD5:
 		return md5.New()
+	case AlgorithmSHA224:
+		return sha256.New224()
+	case AlgorithmSHA512_256:
+		return sha512.New512_256()
+	case AlgorithmSHA3_256:
+		return sha3.New256()
+	case AlgorithmSHA3_512:
+		return sha3.New512()
 	}
 	panic("unreached")
 }
@@ -204,9 +608,21 @@ type Digits int
 
 const (
 	DigitsSix   Digits = 6
+	DigitsSeven Digits = 7
 	DigitsEight Digits = 8
+	DigitsNine  Digits = 9
+	DigitsTen   Digits = 10
 )
 
+// digitBases holds 10^d for every supported Digits d.
+var digitBases = map[Digits]uint64{
+	DigitsSix:   1e6,
+	DigitsSeven: 1e7,
+	DigitsEight: 1e8,
+	DigitsNine:  1e9,
+	DigitsTen:   1e10,
+}
+
 // Format converts an integer into the zero-filled size for this Digits.
 func (d Digits) Format(in int32) string {
 	return fmt.Sprintf(fmt.Sprintf("%%0%dd", d), in)
@@ -221,17 +637,74 @@ func (d Digits) Length() int {
 // calculated.
 // for six digit totp it is 10^6 or 1e6
 // for eight digit totp it equals to 10^8, or 1e8
+// For DigitsTen the base does not fit an int on 32-bit platforms; use Reduce.
 func (d Digits) Base() int {
-	switch d {
-	case DigitsSix:
-		return 1e6
-	case DigitsEight:
-		return 1e8
-	default:
-		return 1e6
+	return int(d.base())
+}
+
+func (d Digits) base() uint64 {
+	if b, ok := digitBases[d]; ok {
+		return b
 	}
+	return 1e6
+}
+
+// Reduce returns the passcode value for a truncated HMAC value: value
+// modulo 10^d. Every 31-bit value already has at most ten digits.
+func (d Digits) Reduce(value uint32) int32 {
+	return int32(uint64(value) % d.base())
 }
 
 func (d Digits) String() string {
 	return fmt.Sprintf("%d", d)
 }
+
+// Check returns an OptionError matching ErrUnsupportedDigits if d is not a
+// number of digits this package implements.
+func (d Digits) Check() error {
+	if _, ok := digitBases[d]; ok {
+		return nil
+	}
+	return &OptionError{Name: "Digits", Value: d, Err: ErrUnsupportedDigits}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Digits) MarshalText() ([]byte, error) {
+	if err := d.Check(); err != nil {
+		return nil, err
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseDigits.
+func (d *Digits) UnmarshalText(text []byte) error {
+	v, err := ParseDigits(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// UnmarshalJSON accepts digits written either as a JSON number or string.
+func (d *Digits) UnmarshalJSON(data []byte) error {
+	if s, err := strconv.Unquote(string(data)); err == nil {
+		return d.UnmarshalText([]byte(s))
+	}
+	return d.UnmarshalText(data)
+}
+
+// ParseDigits returns the Digits written in decimal in s, as used in the
+// digits parameter of a Key URL.
+func ParseDigits(s string) (Digits, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &OptionError{Name: "Digits", Value: s, Err: ErrUnsupportedDigits}
+	}
+
+	d := Digits(n)
+	if err := d.Check(); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
@@ -20,6 +20,9 @@ package otp
 import (
 	"github.com/stretchr/testify/require"
 
+	"errors"
+	"net/url"
+	"runtime"
 	"testing"
 )
 
@@ -32,6 +35,27 @@ func TestKeyAllThere(t *testing.T) {
 	require.Equal(t, "JBSWY3DPEHPK3PXP", k.Secret(), "Extracting Secret")
 }
 
+func TestParseKeyParams(t *testing.T) {
+	for _, q := range []string{
+		"secret=JBSWY3DPEHPK3PXP&issuer=Example",
+		"issuer=A&issuer=B&secret=&secret=X",
+		"issuer=Acme+Co%21&image=https%3A%2F%2Fexample.com%2Flogo.png&image=x",
+		"secret=%zz&issuer=Example&bad=%&=empty&&flag",
+	} {
+		want, _ := url.ParseQuery(q)
+		p := parseKeyParams(q)
+		require.Equal(t, want.Get("issuer"), p.issuer, q)
+		require.Equal(t, want.Get("secret"), p.secret, q)
+		for _, name := range []string{"issuer", "secret"} {
+			delete(want, name)
+		}
+		if len(want) == 0 {
+			want = nil
+		}
+		require.Equal(t, want, p.extra, q)
+	}
+}
+
 func TestKeyIssuerOnlyInPath(t *testing.T) {
 	k, err := NewKeyFromURL(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP`)
 	require.NoError(t, err, "failed to parse url")
@@ -53,3 +77,151 @@ func TestKeyWithNewLine(t *testing.T) {
 	sec := w.Secret()
 	require.Equal(t, "JBSWY3DPEHPK3PXP", sec)
 }
+
+func TestNewKey(t *testing.T) {
+	k := NewKey(KeyOpts{
+		Type:        "totp",
+		Issuer:      "Example",
+		AccountName: "alice@google.com",
+		Secret:      "JBSWY3DPEHPK3PXP",
+		Period:      30,
+		Digits:      DigitsSix,
+		Algorithm:   AlgorithmSHA1,
+	})
+	require.Equal(t, "totp", k.Type())
+	require.Equal(t, "Example", k.Issuer())
+	require.Equal(t, "alice@google.com", k.AccountName())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", k.Secret())
+	require.Equal(t, uint64(30), k.Period())
+
+	expected := "otpauth://totp/Example:alice@google.com?algorithm=SHA1&digits=6&issuer=Example&period=30&secret=JBSWY3DPEHPK3PXP"
+	require.Equal(t, expected, k.String())
+	require.Equal(t, expected, k.URL())
+
+	parsed, err := NewKeyFromURL(k.String())
+	require.NoError(t, err)
+	require.Equal(t, expected, parsed.URL())
+}
+
+func TestNewKeyLabel(t *testing.T) {
+	k := NewKey(KeyOpts{
+		Type:        "totp",
+		Issuer:      "ACME",
+		AccountName: "jane",
+		Secret:      "JBSWY3DPEHPK3PXP",
+		Digits:      DigitsSix,
+		Algorithm:   AlgorithmSHA1,
+		Label:       "ACME-PROD / jane?#%",
+	})
+	require.Equal(t, "otpauth://totp/ACME-PROD%20%2F%20jane%3F%23%25?algorithm=SHA1&digits=6&issuer=ACME&secret=JBSWY3DPEHPK3PXP", k.String())
+
+	parsed, err := NewKeyFromURL(k.String())
+	require.NoError(t, err)
+	require.Equal(t, "ACME", parsed.Issuer())
+	require.Equal(t, "ACME-PROD / jane?#%", parsed.AccountName())
+	require.Equal(t, k.String(), parsed.URL())
+}
+
+func TestKeyInvalidURL(t *testing.T) {
+	_, err := NewKeyFromURL("otpauth://totp/%zz")
+	require.True(t, errors.Is(err, ErrInvalidURL))
+
+	var urlErr *url.Error
+	require.True(t, errors.As(err, &urlErr), "underlying url error is preserved")
+}
+
+func TestKeyImageErrors(t *testing.T) {
+	k, err := NewKeyFromURL(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example`)
+	require.NoError(t, err)
+
+	_, err = k.Image(0, 200)
+	require.True(t, errors.Is(err, ErrInvalidImageSize))
+
+	_, err = k.Image(MaxImageSize+1, 200)
+	require.True(t, errors.Is(err, ErrInvalidImageSize))
+
+	_, err = k.Image(10, 10)
+	require.True(t, errors.Is(err, ErrImageEncoding))
+
+	img, err := k.Image(200, 200)
+	require.NoError(t, err)
+	require.NotNil(t, img)
+}
+
+func TestKeyStrictIssuer(t *testing.T) {
+	mismatch := "otpauth://totp/Acme:alice?issuer=Example&secret=JBSWY3DPEHPK3PXP"
+
+	k, err := NewKeyFromURL(mismatch)
+	require.NoError(t, err, "lenient by default")
+	require.True(t, errors.Is(k.CheckIssuer(), ErrIssuerMismatch))
+
+	_, err = NewKeyFromURL(mismatch, StrictIssuer())
+	require.True(t, errors.Is(err, ErrIssuerMismatch))
+	require.True(t, errors.Is(err, ErrInvalidURL))
+
+	for _, u := range []string{
+		"otpauth://totp/Example:alice?issuer=Example&secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice?issuer=Example&secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP",
+	} {
+		_, err := NewKeyFromURL(u, StrictIssuer())
+		require.NoError(t, err, u)
+	}
+}
+
+func TestKeyUnicodeNames(t *testing.T) {
+	for _, tx := range []struct {
+		issuer, account string
+	}{
+		{"東京銀行", "たろう@example.jp"},
+		{"Acme 🔐", "bob 🚀"},
+		{"شركة", "مستخدم"},
+		{"Zürich & Co", "a+b@example.com"},
+	} {
+		k := NewKey(KeyOpts{Type: "totp", Issuer: tx.issuer, AccountName: tx.account, Secret: "JBSWY3DPEHPK3PXP"})
+
+		parsed, err := NewKeyFromURL(k.String())
+		require.NoError(t, err)
+		require.Equal(t, tx.issuer, parsed.Issuer())
+		require.Equal(t, tx.account, parsed.AccountName())
+		require.Equal(t, k.String(), parsed.URL())
+	}
+
+	// "é" precomposed and as "e" followed by a combining acute accent.
+	composed := NewKey(KeyOpts{Type: "totp", Issuer: "Café", AccountName: "José"})
+	decomposed := NewKey(KeyOpts{Type: "totp", Issuer: "Cafe\u0301", AccountName: "Jose\u0301"})
+	require.Equal(t, composed.String(), decomposed.String())
+
+	parsed, err := NewKeyFromURL("otpauth://totp/Cafe%CC%81:Jose%CC%81?issuer=Cafe%CC%81")
+	require.NoError(t, err)
+	require.Equal(t, "Café", parsed.Issuer())
+	require.Equal(t, "José", parsed.AccountName())
+}
+
+// BenchmarkNewKeyFromURL reports the memory a parsed Key keeps alive as
+// B/key, besides the allocations made while parsing. Every URL is a fresh
+// string, like URLs read from a database.
+func BenchmarkNewKeyFromURL(b *testing.B) {
+	raw := []byte("otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example&period=30&digits=6&algorithm=SHA1")
+	keys := make([]*Key, b.N)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range keys {
+		k, err := NewKeyFromURL(string(raw))
+		if err != nil {
+			b.Fatal(err)
+		}
+		keys[i] = k
+	}
+	b.StopTimer()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/float64(b.N), "B/key")
+	runtime.KeepAlive(keys)
+}
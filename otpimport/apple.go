@@ -0,0 +1,50 @@
+package otpimport
+
+import (
+	"io"
+
+	"github.com/pquerna/otp"
+)
+
+// ParseApplePasswords parses the CSV exported by Apple's Passwords app and
+// iCloud Keychain, whose columns are Title, URL, Username, Password, Notes
+// and OTPAuth. Entries without an OTPAuth URL are skipped. Keys lacking an
+// issuer or account name take the Title or Username of their entry.
+func ParseApplePasswords(r io.Reader) ([]*otp.Key, error) {
+	t, err := newCSVTable(r, "OTPAuth")
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*otp.Key
+	for row := 1; ; row++ {
+		rec, err := t.next()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, &RowError{Row: row, Err: err}
+		}
+
+		url := rec.get("OTPAuth")
+		if url == "" {
+			continue
+		}
+		key, err := parseURL(url)
+		if err != nil {
+			return nil, &RowError{Row: row, Err: err}
+		}
+
+		var opts []otp.KeyOpt
+		if key.Issuer() == "" && rec.get("Title") != "" {
+			opts = append(opts, otp.WithIssuer(rec.get("Title")))
+		}
+		if key.AccountName() == "" && rec.get("Username") != "" {
+			opts = append(opts, otp.WithAccountName(rec.get("Username")))
+		}
+		if len(opts) > 0 {
+			key = key.Clone(opts...)
+		}
+		keys = append(keys, key)
+	}
+}
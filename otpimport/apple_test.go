@@ -0,0 +1,40 @@
+package otpimport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseApplePasswords(t *testing.T) {
+	export := "\ufeffTitle,URL,Username,Password,Notes,OTPAuth\n" +
+		"example.com,https://example.com/,alice,hunter2,,otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example\n" +
+		"no otp,https://other.example/,bob,secret,,\n" +
+		"GitHub,https://github.com/,carol,pw,\"multi\nline\",otpauth://totp/?secret=GEZDGNBVGY3TQOJQ\n"
+
+	keys, err := ParseApplePasswords(strings.NewReader(export))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	require.Equal(t, "Example", keys[0].Issuer())
+	require.Equal(t, "alice", keys[0].AccountName())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", keys[0].Secret())
+
+	require.Equal(t, "GitHub", keys[1].Issuer())
+	require.Equal(t, "carol", keys[1].AccountName())
+	require.Equal(t, "GEZDGNBVGY3TQOJQ", keys[1].Secret())
+}
+
+func TestParseApplePasswordsInvalid(t *testing.T) {
+	_, err := ParseApplePasswords(strings.NewReader("Title,URL,Username,Password\n"))
+	require.True(t, errors.Is(err, ErrMissingColumn))
+
+	_, err = ParseApplePasswords(strings.NewReader("Title,OTPAuth\nx,https://example.com/\n"))
+	var rerr *RowError
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, 1, rerr.Row)
+	require.True(t, errors.Is(err, otp.ErrInvalidURL))
+}
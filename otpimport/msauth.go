@@ -0,0 +1,85 @@
+package otpimport
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pquerna/otp"
+)
+
+// Account types of Microsoft Authenticator.
+const (
+	// MicrosoftTOTP is a third party account with a base32 secret and
+	// 6 digit codes.
+	MicrosoftTOTP = 0
+	// MicrosoftPersonal is a personal Microsoft account, whose secret is
+	// base64 and whose codes have 8 digits.
+	MicrosoftPersonal = 1
+	// MicrosoftWork is a work or school account, which usually relies on
+	// push notifications and has no secret.
+	MicrosoftWork = 2
+)
+
+// MicrosoftAccount is a row of the accounts table of the PhoneFactor
+// database kept by Microsoft Authenticator on Android.
+type MicrosoftAccount struct {
+	Name          string `json:"name"`
+	Username      string `json:"username"`
+	OATHSecretKey string `json:"oath_secret_key"`
+	AccountType   int    `json:"account_type"`
+}
+
+// ParseMicrosoftAuthenticator converts the accounts of Microsoft
+// Authenticator into TOTP keys. The app has no export; the accounts are
+// read from its database, eg on a rooted device or from an Android backup:
+//
+//	sqlite3 -json PhoneFactor \
+//		'select name, username, oath_secret_key, account_type from accounts'
+//
+// Accounts without a secret, such as push-only work accounts, are skipped.
+func ParseMicrosoftAuthenticator(accounts []MicrosoftAccount) ([]*otp.Key, error) {
+	var keys []*otp.Key
+	for i, a := range accounts {
+		secret := strings.TrimSpace(a.OATHSecretKey)
+		if secret == "" {
+			continue
+		}
+
+		opts := otp.KeyOpts{
+			Type:        "totp",
+			Issuer:      a.Name,
+			AccountName: a.Username,
+			Secret:      strings.ToUpper(strings.Replace(secret, " ", "", -1)),
+			Period:      otp.DefaultPeriod,
+			Digits:      otp.DigitsSix,
+			Algorithm:   otp.AlgorithmSHA1,
+		}
+		if a.AccountType == MicrosoftPersonal {
+			raw, err := base64.StdEncoding.DecodeString(secret)
+			if err != nil {
+				return nil, &RowError{Row: i + 1, Err: err}
+			}
+			opts.Secret = b32.EncodeToString(raw)
+			opts.Digits = otp.DigitsEight
+		}
+
+		key := otp.NewKey(opts)
+		if err := checkKey(key); err != nil {
+			return nil, &RowError{Row: i + 1, Err: err}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParseMicrosoftAuthenticatorJSON parses the accounts table dumped as a
+// JSON array, as by the sqlite3 command of ParseMicrosoftAuthenticator.
+func ParseMicrosoftAuthenticatorJSON(r io.Reader) ([]*otp.Key, error) {
+	var accounts []MicrosoftAccount
+	if err := json.NewDecoder(r).Decode(&accounts); err != nil {
+		return nil, err
+	}
+	return ParseMicrosoftAuthenticator(accounts)
+}
@@ -0,0 +1,42 @@
+package otpimport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMicrosoftAuthenticatorJSON(t *testing.T) {
+	dump := `[
+		{"name": "Example", "username": "alice@example.com", "oath_secret_key": "jbsw y3dp ehpk 3pxp", "account_type": 0},
+		{"name": "Microsoft", "username": "alice@outlook.com", "oath_secret_key": "SGVsbG8h3q2+7w==", "account_type": 1},
+		{"name": "Contoso", "username": "alice@contoso.com", "oath_secret_key": "", "account_type": 2}
+	]`
+
+	keys, err := ParseMicrosoftAuthenticatorJSON(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	require.Equal(t, "Example", keys[0].Issuer())
+	require.Equal(t, "alice@example.com", keys[0].AccountName())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", keys[0].Secret())
+	require.Equal(t, otp.DigitsSix, keys[0].Digits())
+
+	require.Equal(t, "Microsoft", keys[1].Issuer())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", keys[1].Secret())
+	require.Equal(t, otp.DigitsEight, keys[1].Digits())
+	require.Equal(t, uint64(30), keys[1].Period())
+}
+
+func TestParseMicrosoftAuthenticatorInvalid(t *testing.T) {
+	_, err := ParseMicrosoftAuthenticator([]MicrosoftAccount{
+		{Name: "Example", OATHSecretKey: "JBSWY3DPEHPK3PXP"},
+		{Name: "Microsoft", OATHSecretKey: "not base64!", AccountType: MicrosoftPersonal},
+	})
+	var rerr *RowError
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, 2, rerr.Row)
+}
@@ -0,0 +1,113 @@
+// Package otpimport parses the exports of other authenticators and OTP
+// platforms into keys, so their users can be onboarded in bulk.
+package otpimport
+
+import (
+	"encoding/base32"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrMissingColumn is returned for CSV exports lacking a required column.
+var ErrMissingColumn = errors.New("Export lacks a required column")
+
+// ErrNotOTPAuth is the cause of the *otp.URLError of URLs whose scheme is
+// not otpauth.
+var ErrNotOTPAuth = errors.New("URL scheme is not otpauth")
+
+// b32 encodes secrets as in key URLs.
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RowError reports the record of an export that could not be imported.
+type RowError struct {
+	// Row is the number of the record, starting at 1 after any header.
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// csvTable is a CSV export whose columns are looked up by header name.
+type csvTable struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// newCSVTable reads the header of r and checks it has the required columns.
+// Header names are matched case-insensitively.
+func newCSVTable(r io.Reader, required ...string) (*csvTable, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	t := &csvTable{r: cr, columns: make(map[string]int, len(header))}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		t.columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := t.columns[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+	return t, nil
+}
+
+// next returns the next record, or io.EOF.
+func (t *csvTable) next() (csvRecord, error) {
+	fields, err := t.r.Read()
+	return csvRecord{t, fields}, err
+}
+
+type csvRecord struct {
+	t      *csvTable
+	fields []string
+}
+
+// get returns the trimmed field of the named column, empty when missing.
+func (r csvRecord) get(name string) string {
+	i, ok := r.t.columns[strings.ToLower(name)]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+// parseURL parses an otpauth URL, which NewKeyFromURL accepts leniently,
+// and checks it describes a usable key.
+func parseURL(s string) (*otp.Key, error) {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), "otpauth://") {
+		return nil, &otp.URLError{URL: s, Err: ErrNotOTPAuth}
+	}
+	key, err := otp.NewKeyFromURL(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// checkKey checks the type and secret of an imported key.
+func checkKey(key *otp.Key) error {
+	if t := key.Type(); t != "totp" && t != "hotp" {
+		return &otp.OptionError{Name: "Type", Value: t, Err: otp.ErrUnsupportedType}
+	}
+	_, err := otp.DecodeSecret(key.Secret())
+	return err
+}
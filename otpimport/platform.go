@@ -0,0 +1,160 @@
+package otpimport
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pquerna/otp"
+)
+
+// MetaSerial is the metadata tag holding the serial number of imported
+// hardware tokens.
+const MetaSerial = "serial"
+
+// TokenOpts are the parameters of imported tokens that their export does
+// not record, as they are chosen per token model or factor profile.
+type TokenOpts struct {
+	// Type of the tokens, "totp" or "hotp". Defaults to "totp".
+	Type string
+	// Issuer of the imported keys.
+	Issuer string
+	// Digits of the passcode. Defaults to 6.
+	Digits otp.Digits
+	// Period of TOTP tokens. Defaults to 30 seconds.
+	Period uint
+	// Algorithm to use for HMAC. Defaults to SHA1.
+	Algorithm otp.Algorithm
+}
+
+func (opts TokenOpts) key(account, secret string, counter uint64, meta map[string]string) (*otp.Key, error) {
+	if opts.Type == "" {
+		opts.Type = "totp"
+	}
+	if opts.Digits == 0 {
+		opts.Digits = otp.DefaultDigits
+	}
+	if opts.Period == 0 && opts.Type == "totp" {
+		opts.Period = otp.DefaultPeriod
+	}
+
+	key := otp.NewKey(otp.KeyOpts{
+		Type:        opts.Type,
+		Issuer:      opts.Issuer,
+		AccountName: account,
+		Secret:      secret,
+		Period:      opts.Period,
+		Digits:      opts.Digits,
+		Algorithm:   opts.Algorithm,
+		Metadata:    meta,
+	})
+	if opts.Type == "hotp" {
+		key = key.Clone(otp.WithCounter(counter))
+	}
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseDuoTokens parses the OTP hardware token CSV of the Duo Admin Panel,
+// without header: serial number, hex secret and, for HOTP tokens, the
+// counter. Keys are named after the serial number, which is also kept in
+// the MetaSerial tag.
+func ParseDuoTokens(r io.Reader, opts TokenOpts) ([]*otp.Key, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+
+	var keys []*otp.Key
+	for row := 1; ; row++ {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, &RowError{Row: row, Err: err}
+		}
+		if len(fields) < 2 {
+			return nil, &RowError{Row: row, Err: ErrMissingColumn}
+		}
+
+		serial := strings.TrimSpace(fields[0])
+		raw, err := hex.DecodeString(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, &RowError{Row: row, Err: err}
+		}
+		var counter uint64
+		if len(fields) > 2 && strings.TrimSpace(fields[2]) != "" {
+			if counter, err = strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64); err != nil {
+				return nil, &RowError{Row: row, Err: err}
+			}
+		}
+
+		key, err := opts.key(serial, b32.EncodeToString(raw), counter, map[string]string{MetaSerial: serial})
+		if err != nil {
+			return nil, &RowError{Row: row, Err: err}
+		}
+		keys = append(keys, key)
+	}
+}
+
+// OktaFactor is a factor enrolled through the Okta Factors API with a
+// shared secret, as kept by organizations that seeded Okta themselves.
+type OktaFactor struct {
+	// Login of the user the factor belongs to.
+	Login      string `json:"login"`
+	FactorType string `json:"factorType"`
+	Provider   string `json:"provider"`
+	Profile    struct {
+		CredentialID string `json:"credentialId"`
+		SharedSecret string `json:"sharedSecret"`
+	} `json:"profile"`
+}
+
+// ErrUnsupportedFactor is returned for Okta factors without a shared
+// secret, such as push and SMS factors.
+var ErrUnsupportedFactor = errors.New("Factor has no shared secret")
+
+// ParseOktaFactors parses a JSON array of OktaFactor. token:hotp factors
+// are imported as HOTP keys at counter 0 and token:software:totp factors
+// as TOTP keys, regardless of opts.Type; the other parameters come from
+// opts, as set in the factor profile. Keys are named after the login, or
+// the credential ID when there is none.
+func ParseOktaFactors(r io.Reader, opts TokenOpts) ([]*otp.Key, error) {
+	var factors []OktaFactor
+	if err := json.NewDecoder(r).Decode(&factors); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*otp.Key, 0, len(factors))
+	for i, f := range factors {
+		switch f.FactorType {
+		case "token:hotp":
+			opts.Type = "hotp"
+		case "token:software:totp":
+			opts.Type = "totp"
+		default:
+			return nil, &RowError{Row: i + 1, Err: ErrUnsupportedFactor}
+		}
+		if f.Profile.SharedSecret == "" {
+			return nil, &RowError{Row: i + 1, Err: ErrUnsupportedFactor}
+		}
+
+		account := f.Login
+		if account == "" {
+			account = f.Profile.CredentialID
+		}
+		secret := strings.ToUpper(strings.TrimRight(f.Profile.SharedSecret, "="))
+		key, err := opts.key(account, secret, 0, nil)
+		if err != nil {
+			return nil, &RowError{Row: i + 1, Err: err}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
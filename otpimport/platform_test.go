@@ -0,0 +1,56 @@
+package otpimport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuoTokens(t *testing.T) {
+	export := "# serial,secret,counter\n" +
+		"100001,48656c6c6f21deadbeef,5\n" +
+		"100002,48656C6C6F21DEADBEEF\n"
+
+	keys, err := ParseDuoTokens(strings.NewReader(export), TokenOpts{Type: "hotp", Issuer: "Example", Digits: otp.DigitsEight})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	require.Equal(t, "hotp", keys[0].Type())
+	require.Equal(t, "Example", keys[0].Issuer())
+	require.Equal(t, "100001", keys[0].AccountName())
+	require.Equal(t, "100001", keys[0].Metadata()[MetaSerial])
+	require.Equal(t, "JBSWY3DPEHPK3PXP", keys[0].Secret())
+	require.Equal(t, uint64(5), keys[0].Counter())
+	require.Equal(t, otp.DigitsEight, keys[0].Digits())
+	require.Equal(t, uint64(0), keys[1].Counter())
+
+	_, err = ParseDuoTokens(strings.NewReader("100001,zz\n"), TokenOpts{})
+	var rerr *RowError
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, 1, rerr.Row)
+}
+
+func TestParseOktaFactors(t *testing.T) {
+	export := `[
+		{"login": "alice@example.com", "factorType": "token:hotp", "provider": "CUSTOM",
+		 "profile": {"credentialId": "alice", "sharedSecret": "jbswy3dpehpk3pxp"}},
+		{"factorType": "token:software:totp", "provider": "GOOGLE",
+		 "profile": {"credentialId": "bob", "sharedSecret": "GEZDGNBVGY3TQOJQ===="}}
+	]`
+
+	keys, err := ParseOktaFactors(strings.NewReader(export), TokenOpts{Issuer: "Example"})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Equal(t, "hotp", keys[0].Type())
+	require.Equal(t, "alice@example.com", keys[0].AccountName())
+	require.Equal(t, "JBSWY3DPEHPK3PXP", keys[0].Secret())
+	require.Equal(t, "totp", keys[1].Type())
+	require.Equal(t, "bob", keys[1].AccountName())
+	require.Equal(t, "GEZDGNBVGY3TQOJQ", keys[1].Secret())
+
+	_, err = ParseOktaFactors(strings.NewReader(`[{"factorType": "push"}]`), TokenOpts{})
+	require.True(t, errors.Is(err, ErrUnsupportedFactor))
+}
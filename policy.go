@@ -0,0 +1,70 @@
+package otp
+
+import (
+	"errors"
+)
+
+// A key or validation does not comply with a Policy. Policy violations are
+// reported as OptionErrors matching it with errors.Is.
+var ErrPolicyViolation = errors.New("Violates the OTP policy")
+
+// Policy restricts the parameters of keys and validation, eg the policy a
+// tenant configured. Enforce it everywhere keys enter the system: at
+// enrollment with the Policy options of the totp and hotp packages, at
+// import with ParseOpt, and at validation with totp.WithPolicy.
+// The zero Policy allows everything.
+type Policy struct {
+	// Algorithms allowed. Empty allows every algorithm.
+	Algorithms []Algorithm
+	// MinDigits is the smallest number of digits allowed.
+	MinDigits Digits
+	// MaxSkew is the largest skew validation may use. Zero allows any.
+	MaxSkew uint
+	// RequireReplay requires validation to reject reused passcodes.
+	RequireReplay bool
+}
+
+// CheckParams reports the first of algorithm and digits the Policy does
+// not allow.
+func (p *Policy) CheckParams(algorithm Algorithm, digits Digits) error {
+	if len(p.Algorithms) != 0 && !containsAlgorithm(p.Algorithms, algorithm) {
+		return &OptionError{Name: "Algorithm", Value: algorithm, Err: ErrPolicyViolation}
+	}
+	if digits < p.MinDigits {
+		return &OptionError{Name: "Digits", Value: digits, Err: ErrPolicyViolation}
+	}
+	return nil
+}
+
+// CheckKey reports a parameter of k the Policy does not allow. Missing
+// parameters take the defaults of the Key URI format.
+func (p *Policy) CheckKey(k *Key) error {
+	return p.CheckParams(k.Algorithm(), k.Digits())
+}
+
+// CheckValidate reports a validation with skew, and with replay protection
+// or not, that the Policy does not allow.
+func (p *Policy) CheckValidate(skew uint, replay bool) error {
+	if p.MaxSkew != 0 && skew > p.MaxSkew {
+		return &OptionError{Name: "Skew", Value: skew, Err: ErrPolicyViolation}
+	}
+	if p.RequireReplay && !replay {
+		return &OptionError{Name: "Replay", Value: replay, Err: ErrPolicyViolation}
+	}
+	return nil
+}
+
+// ParseOpt returns an option of NewKeyFromURL rejecting keys the Policy
+// does not allow, for imports.
+func (p *Policy) ParseOpt() ParseOpt {
+	return p.CheckKey
+}
+
+func containsAlgorithm(algorithms []Algorithm, a Algorithm) bool {
+	for _, b := range algorithms {
+		if a == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	p := &Policy{
+		Algorithms:    []Algorithm{AlgorithmSHA256, AlgorithmSHA512},
+		MinDigits:     DigitsEight,
+		MaxSkew:       1,
+		RequireReplay: true,
+	}
+
+	require.NoError(t, p.CheckParams(AlgorithmSHA256, DigitsEight))
+
+	var optErr *OptionError
+	err := p.CheckParams(AlgorithmSHA1, DigitsEight)
+	require.True(t, errors.Is(err, ErrPolicyViolation))
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "Algorithm", optErr.Name)
+
+	err = p.CheckParams(AlgorithmSHA512, DigitsSix)
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "Digits", optErr.Name)
+
+	require.NoError(t, p.CheckValidate(1, true))
+	require.True(t, errors.Is(p.CheckValidate(2, true), ErrPolicyViolation))
+	require.True(t, errors.Is(p.CheckValidate(1, false), ErrPolicyViolation))
+
+	require.NoError(t, (&Policy{}).CheckParams(AlgorithmMD5, DigitsSix), "the zero Policy allows everything")
+}
+
+func TestPolicyParseOpt(t *testing.T) {
+	p := &Policy{Algorithms: []Algorithm{AlgorithmSHA256}}
+
+	_, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example", p.ParseOpt())
+	require.True(t, errors.Is(err, ErrPolicyViolation), "a missing algorithm is SHA1")
+	require.True(t, errors.Is(err, ErrInvalidURL))
+
+	_, err = NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&algorithm=SHA256", p.ParseOpt())
+	require.NoError(t, err)
+}
@@ -0,0 +1,21 @@
+package otp
+
+// The parameters of the Google-Authenticator compatible profile, which
+// most authenticator apps support and the shortcut functions of the totp
+// and hotp packages use. Integrators building their own options can start
+// from these instead of repeating the values.
+const (
+	// DefaultPeriod is the number of seconds a TOTP passcode is valid for.
+	DefaultPeriod = 30
+	// DefaultSkew is the number of periods before and after the current
+	// time a TOTP passcode is accepted for.
+	DefaultSkew = 1
+	// DefaultDigits is the number of digits of a passcode.
+	DefaultDigits = DigitsSix
+	// DefaultAlgorithm is the hashing function used for the HMAC.
+	DefaultAlgorithm = AlgorithmSHA1
+	// DefaultTOTPSecretSize is the size in bytes of generated TOTP secrets.
+	DefaultTOTPSecretSize = 20
+	// DefaultHOTPSecretSize is the size in bytes of generated HOTP secrets.
+	DefaultHOTPSecretSize = 10
+)
@@ -0,0 +1,161 @@
+package otp
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strconv"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+)
+
+// QRLevel is the error correction level of a QR code: the share of the
+// code that can be damaged or covered, eg by a logo, and still be read.
+// Higher levels make denser codes.
+type QRLevel int
+
+const (
+	// QRLevelLow recovers 7% of the code.
+	QRLevelLow QRLevel = iota
+	// QRLevelMedium recovers 15% of the code. It is the default.
+	QRLevelMedium
+	// QRLevelQuartile recovers 25% of the code.
+	QRLevelQuartile
+	// QRLevelHigh recovers 30% of the code.
+	QRLevelHigh
+)
+
+// qrQuietZone is the margin, in modules, QR codes need around them to be
+// scanned reliably.
+const qrQuietZone = 4
+
+// QROpt is an option of QRCodePNG and QRCodeSVG.
+type QROpt func(opts *qrOpts)
+
+type qrOpts struct {
+	level QRLevel
+}
+
+// WithQRLevel sets the error correction level of the QR code.
+func WithQRLevel(level QRLevel) QROpt {
+	return func(opts *qrOpts) {
+		opts.level = level
+	}
+}
+
+// QRCodePNG returns the QR code of the key as a PNG image of width by
+// height pixels, ready to be served to the user enrolling it.
+func (k *Key) QRCodePNG(width, height int, opts ...QROpt) ([]byte, error) {
+	if err := checkImageSize(width, height); err != nil {
+		return nil, err
+	}
+
+	b, err := k.qrCode(opts)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = barcode.Scale(b, width, height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageEncoding, err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, b); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageEncoding, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// QRCodeSVG returns the QR code of the key as an SVG image of size by size
+// pixels, including the quiet zone scanners need around it. Being a vector
+// image, it stays sharp when scaled, eg for print.
+func (k *Key) QRCodeSVG(size int, opts ...QROpt) ([]byte, error) {
+	if err := checkImageSize(size, size); err != nil {
+		return nil, err
+	}
+
+	b, err := k.qrCode(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := b.Bounds()
+	n := strconv.Itoa(bounds.Dx() + 2*qrQuietZone)
+	s := strconv.Itoa(size)
+
+	var buf bytes.Buffer
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="` + s + `" height="` + s +
+		`" viewBox="0 0 ` + n + ` ` + n + `" shape-rendering="crispEdges">`)
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		// One subpath per run of dark modules.
+		for x := bounds.Min.X; x < bounds.Max.X; {
+			if !qrDark(b, x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < bounds.Max.X && qrDark(b, x, y) {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start-bounds.Min.X+qrQuietZone, y-bounds.Min.Y+qrQuietZone, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
+
+// qrCode encodes the URL of the key as an unscaled QR code.
+func (k *Key) qrCode(opts []QROpt) (b barcode.Barcode, err error) {
+	o := qrOpts{level: QRLevelMedium}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var level qr.ErrorCorrectionLevel
+	switch o.level {
+	case QRLevelLow:
+		level = qr.L
+	case QRLevelMedium:
+		level = qr.M
+	case QRLevelQuartile:
+		level = qr.Q
+	case QRLevelHigh:
+		level = qr.H
+	default:
+		return nil, &OptionError{Name: "QR level", Value: o.level, Err: ErrInvalidOption}
+	}
+
+	// The barcode package is not under our control; never let a
+	// malformed key bring down the caller.
+	defer func() {
+		if r := recover(); r != nil {
+			b, err = nil, fmt.Errorf("%w: %v", ErrImageEncoding, r)
+		}
+	}()
+
+	b, err = qr.Encode(k.String(), level, qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageEncoding, err)
+	}
+	return b, nil
+}
+
+// checkImageSize reports a width or height out of 1 to MaxImageSize.
+func checkImageSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > MaxImageSize || height > MaxImageSize {
+		return &OptionError{
+			Name:  "Image size",
+			Value: fmt.Sprintf("%dx%d", width, height),
+			Err:   ErrInvalidImageSize,
+		}
+	}
+	return nil
+}
+
+// qrDark reports whether the module at x, y is dark.
+func qrDark(b barcode.Barcode, x, y int) bool {
+	r, _, _, _ := b.At(x, y).RGBA()
+	return r < 0x8000
+}
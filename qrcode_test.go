@@ -0,0 +1,57 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQRCodePNG(t *testing.T) {
+	k, err := NewKeyFromURL(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example`)
+	require.NoError(t, err)
+
+	data, err := k.QRCodePNG(200, 200, WithQRLevel(QRLevelHigh))
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, 200, img.Bounds().Dx())
+	require.Equal(t, 200, img.Bounds().Dy())
+
+	_, err = k.QRCodePNG(0, 200)
+	require.True(t, errors.Is(err, ErrInvalidImageSize))
+	_, err = k.QRCodePNG(200, 200, WithQRLevel(QRLevel(7)))
+	require.True(t, errors.Is(err, ErrInvalidOption))
+}
+
+func TestQRCodeSVG(t *testing.T) {
+	k, err := NewKeyFromURL(`otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example`)
+	require.NoError(t, err)
+
+	var svg struct {
+		Width   string `xml:"width,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Path    struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+
+	data, err := k.QRCodeSVG(300)
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(data, &svg))
+	require.Equal(t, "300", svg.Width)
+	require.Equal(t, "0 0 45 45", svg.ViewBox, "37 modules and the quiet zone")
+	require.True(t, strings.HasPrefix(svg.Path.D, "M4 4h7v1h-7z"), "top left finder pattern")
+
+	data, err = k.QRCodeSVG(300, WithQRLevel(QRLevelLow))
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(data, &svg))
+	require.Equal(t, "0 0 41 41", svg.ViewBox, "lower levels make smaller codes")
+
+	_, err = k.QRCodeSVG(MaxImageSize + 1)
+	require.True(t, errors.Is(err, ErrInvalidImageSize))
+}
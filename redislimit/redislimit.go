@@ -0,0 +1,195 @@
+// Package redislimit implements otp.AttemptStore on Redis, including Redis
+// Cluster, for fleets that need one rate limit across all their nodes.
+//
+// The attempts of an id are a sorted set scored by time, trimmed to the
+// window and counted by a Lua script in one round trip, so the window
+// slides exactly. Each key uses a hash tag, so the script only touches a
+// single cluster slot.
+//
+// During a failover Redis answers with MOVED, ASK, TRYAGAIN, CLUSTERDOWN,
+// LOADING or READONLY errors, or the connection drops; such calls are
+// retried. A retried attempt reuses its sorted set member, so an attempt
+// whose reply was lost is never counted twice.
+//
+// The package does not depend on a Redis client; adapt the client of your
+// choice to the Scripter interface.
+package redislimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// addScript trims the attempts older than the window, adds this one,
+// refreshes the expiry and returns the count.
+//
+// KEYS[1] is the sorted set, ARGV[1] the current time and ARGV[2] the
+// window in milliseconds, ARGV[3] the member of this attempt.
+const addScript = `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1] - ARGV[2])
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return redis.call('ZCARD', KEYS[1])
+`
+
+// Scripter is the subset of a Redis client the Store needs.
+type Scripter interface {
+	// Eval runs a Lua script, eg with EVALSHA falling back to EVAL.
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+	// Del removes keys.
+	Del(ctx context.Context, keys ...string) error
+}
+
+// Store is an otp.AttemptStore on Redis.
+// A Store is safe for concurrent use.
+type Store struct {
+	client  Scripter
+	prefix  string
+	retries int
+	backoff time.Duration
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+	// sleep waits between retries, sleepContext when nil.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// maxBackoff caps the doubling of the wait between retries.
+const maxBackoff = time.Second
+
+// StoreOpt configures a Store.
+type StoreOpt func(s *Store)
+
+// WithRetries sets how many times a call failing during a failover is
+// retried, waiting backoff, doubled each time up to one second, in between.
+// Defaults to 3 retries and 50 milliseconds.
+func WithRetries(retries int, backoff time.Duration) StoreOpt {
+	return func(s *Store) {
+		s.retries = retries
+		s.backoff = backoff
+	}
+}
+
+// New creates a Store on client, with keys "<prefix>{<id>}".
+func New(client Scripter, prefix string, storeOpts ...StoreOpt) *Store {
+	s := &Store{client: client, prefix: prefix, retries: 3, backoff: 50 * time.Millisecond}
+	for _, opt := range storeOpts {
+		opt(s)
+	}
+	return s
+}
+
+// Add implements otp.AttemptStore.
+func (s *Store) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return 0, err
+	}
+
+	now := s.clock().UnixNano() / int64(time.Millisecond)
+	member := strconv.FormatInt(now, 10) + "-" + hex.EncodeToString(nonce[:])
+	args := []interface{}{now, int64(window / time.Millisecond), member}
+
+	var n int
+	err := s.retry(ctx, func() error {
+		reply, err := s.client.Eval(ctx, addScript, []string{s.key(id)}, args...)
+		if err != nil {
+			return err
+		}
+		count, ok := reply.(int64)
+		if !ok {
+			return fmt.Errorf("unexpected reply %T", reply)
+		}
+		n = int(count)
+		return nil
+	})
+	if err != nil {
+		return 0, otp.WrapStoreError("Add", id, err)
+	}
+	return n, nil
+}
+
+// Reset implements otp.AttemptStore.
+func (s *Store) Reset(ctx context.Context, id string) error {
+	err := s.retry(ctx, func() error {
+		return s.client.Del(ctx, s.key(id))
+	})
+	return otp.WrapStoreError("Reset", id, err)
+}
+
+// key returns the sorted set of id. The hash tag keeps it on one slot
+// whatever the prefix.
+func (s *Store) key(id string) string {
+	return s.prefix + "{" + id + "}"
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// caused by a failover, the retries run out or ctx is done.
+func (s *Store) retry(ctx context.Context, fn func() error) error {
+	sleep := sleepContext
+	if s.sleep != nil {
+		sleep = s.sleep
+	}
+
+	backoff := s.backoff
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= s.retries || !Retryable(err) {
+			return err
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		if backoff < maxBackoff {
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+}
+
+// sleepContext waits for d, or returns the error of ctx when it is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Retryable reports whether err is caused by a failover or resharding of
+// Redis, after which the call can be retried.
+func Retryable(err error) bool {
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := err.Error()
+	for _, prefix := range []string{"MOVED ", "ASK ", "TRYAGAIN", "CLUSTERDOWN", "LOADING", "READONLY"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "EOF")
+}
+
+// clock returns the current time.
+func (s *Store) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,167 @@
+package redislimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis runs addScript on sorted sets held in memory, failing calls as
+// scripted to simulate a failover.
+type fakeRedis struct {
+	sets map[string]map[string]int64
+	keys []string
+	// failures are returned by the next calls, before (lost=false) or
+	// after (lost=true) the script ran.
+	failures []failure
+}
+
+type failure struct {
+	err  error
+	lost bool
+}
+
+func (r *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	var f *failure
+	if len(r.failures) > 0 {
+		f = &r.failures[0]
+		r.failures = r.failures[1:]
+		if !f.lost {
+			return nil, f.err
+		}
+	}
+
+	r.keys = append(r.keys, keys[0])
+	now, window, member := args[0].(int64), args[1].(int64), args[2].(string)
+	if r.sets == nil {
+		r.sets = map[string]map[string]int64{}
+	}
+	set := r.sets[keys[0]]
+	if set == nil {
+		set = map[string]int64{}
+		r.sets[keys[0]] = set
+	}
+	for m, score := range set {
+		if score <= now-window {
+			delete(set, m)
+		}
+	}
+	set[member] = now
+
+	if f != nil {
+		return nil, f.err
+	}
+	return int64(len(set)), nil
+}
+
+func (r *fakeRedis) Del(ctx context.Context, keys ...string) error {
+	for _, k := range keys {
+		delete(r.sets, k)
+	}
+	return nil
+}
+
+func newStore(r *fakeRedis) (*Store, *time.Time) {
+	s := New(r, "otp:attempts:")
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	s.sleep = func(context.Context, time.Duration) error { return nil }
+	return s, &now
+}
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRedis{}
+	s, now := newStore(r)
+
+	for i := 1; i <= 3; i++ {
+		n, err := s.Add(ctx, "alice", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, i, n)
+		*now = now.Add(20 * time.Second)
+	}
+
+	n, err := s.Add(ctx, "alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 3, n, "the first attempt left the window")
+	require.Equal(t, "otp:attempts:{alice}", r.keys[0], "keys carry a hash tag")
+
+	require.NoError(t, s.Reset(ctx, "alice"))
+	n, err = s.Add(ctx, "alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	r := &fakeRedis{failures: []failure{
+		{err: errors.New("MOVED 3999 127.0.0.1:6381")},
+		{err: errors.New("CLUSTERDOWN The cluster is down")},
+		// The primary ran the script, then failed before replying.
+		{err: errors.New("read tcp: connection reset by peer"), lost: true},
+	}}
+	s, _ := newStore(r)
+
+	n, err := s.Add(ctx, "alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "a retried attempt is counted once")
+}
+
+func TestFailoverGivesUp(t *testing.T) {
+	r := &fakeRedis{failures: []failure{
+		{err: errors.New("TRYAGAIN")},
+		{err: errors.New("TRYAGAIN")},
+		{err: errors.New("TRYAGAIN")},
+		{err: errors.New("TRYAGAIN")},
+	}}
+	s, _ := newStore(r)
+
+	_, err := s.Add(context.Background(), "alice", time.Minute)
+	require.True(t, errors.Is(err, otp.ErrStore))
+
+	r.failures = []failure{{err: errors.New("NOSCRIPT No matching script")}}
+	_, err = s.Add(context.Background(), "alice", time.Minute)
+	require.True(t, errors.Is(err, otp.ErrStore))
+	require.Empty(t, r.keys, "errors unrelated to failover are not retried")
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var r fakeRedis
+	for i := 0; i < 8; i++ {
+		r.failures = append(r.failures, failure{err: errors.New("TRYAGAIN")})
+	}
+	s, _ := newStore(&r)
+	s.retries = 7
+	s.backoff = 300 * time.Millisecond
+
+	var waits []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	_, err := s.Add(context.Background(), "alice", time.Minute)
+	require.True(t, errors.Is(err, otp.ErrStore))
+	require.Equal(t, []time.Duration{
+		300 * time.Millisecond, 600 * time.Millisecond, time.Second,
+		time.Second, time.Second, time.Second, time.Second,
+	}, waits)
+
+	// A context cancelled during the wait ends it.
+	r.failures = []failure{{err: errors.New("TRYAGAIN")}}
+	s.sleep = nil
+	s.backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Add(ctx, "alice", time.Minute)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestRetryable(t *testing.T) {
+	require.True(t, Retryable(errors.New("ASK 3999 127.0.0.1:6381")))
+	require.True(t, Retryable(errors.New("READONLY You can't write against a read only replica.")))
+	require.False(t, Retryable(errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")))
+}
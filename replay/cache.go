@@ -0,0 +1,158 @@
+// Package replay provides in-memory bookkeeping of used one time passcodes,
+// so a passcode accepted once can be rejected if it is submitted again.
+package replay
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// numShards is the number of independent maps a Cache spreads entries
+// over. It must be a power of two.
+const numShards = 64
+
+// Cache records which (id, counter) pairs have been used.
+//
+// Entries expire by wall-clock time, not by counter, so keys with
+// different periods, T0s or HOTP counters can share a Cache: a passcode
+// for counter c can only be accepted for period×(2×skew+1), plus the
+// boundary tolerance on either side, and every entry is kept at least
+// that long. Each shard holds two generations of
+// entries; when the current generation is older than the retention time
+// it becomes the previous one and the expired previous generation is
+// dropped as a whole. Memory therefore stays proportional to the
+// passcodes used within two retention times, and no background scan is
+// ever needed.
+//
+// A pair always maps to the same shard, which has its own mutex, so
+// concurrent validations rarely contend and a passcode cannot be accepted
+// twice by racing calls.
+// A Cache is safe for concurrent use.
+type Cache struct {
+	ttl    time.Duration
+	shards [numShards]shard
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+type entry struct {
+	id      string
+	counter uint64
+}
+
+type shard struct {
+	mu        sync.Mutex
+	rotated   time.Time
+	cur, prev map[entry]struct{}
+}
+
+// NewCache creates an empty Cache for passcodes validated with the given
+// period, skew and boundary tolerance, see totp.WithBoundaryTolerance. A
+// Cache shared by keys of different periods must be created with the
+// longest of them.
+func NewCache(period time.Duration, skew uint, tolerance time.Duration) *Cache {
+	return &Cache{ttl: period*time.Duration(2*skew+1) + 2*tolerance}
+}
+
+// Use marks counter as used for id and reports whether this is the first
+// use.
+func (c *Cache) Use(id string, counter uint64) bool {
+	e := entry{id: id, counter: counter}
+	s := c.shard(e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(c.clock(), c.ttl)
+	if s.has(e) {
+		return false
+	}
+	if s.cur == nil {
+		s.cur = make(map[entry]struct{})
+	}
+	s.cur[e] = struct{}{}
+	return true
+}
+
+// Used reports whether counter has already been used for id.
+func (c *Cache) Used(id string, counter uint64) bool {
+	e := entry{id: id, counter: counter}
+	s := c.shard(e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(c.clock(), c.ttl)
+	return s.has(e)
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// shard selects the shard holding e using an inlined FNV-1a hash.
+func (c *Cache) shard(e entry) *shard {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+	for i := 0; i < len(e.id); i++ {
+		h ^= uint32(e.id[i])
+		h *= prime32
+	}
+	for i := uint(0); i < 64; i += 8 {
+		h ^= uint32(byte(e.counter >> i))
+		h *= prime32
+	}
+
+	return &c.shards[h&(numShards-1)]
+}
+
+// expire rotates the generations of s once the current one is older than
+// ttl, dropping the previous one. It is called with s.mu held.
+func (s *shard) expire(now time.Time, ttl time.Duration) {
+	age := now.Sub(s.rotated)
+	switch {
+	case age >= 2*ttl:
+		s.cur, s.prev = nil, nil
+	case age >= ttl:
+		s.cur, s.prev = nil, s.cur
+	default:
+		return
+	}
+	s.rotated = now
+}
+
+func (s *shard) has(e entry) bool {
+	if _, ok := s.cur[e]; ok {
+		return true
+	}
+	_, ok := s.prev[e]
+	return ok
+}
+
+// Store is an in-memory otp.ReplayStore backed by a Cache.
+// A Store is safe for concurrent use.
+type Store struct {
+	cache *Cache
+}
+
+// NewStore creates an empty Store for passcodes validated with the given
+// period, skew and boundary tolerance, see NewCache.
+func NewStore(period time.Duration, skew uint, tolerance time.Duration) *Store {
+	return &Store{cache: NewCache(period, skew, tolerance)}
+}
+
+// Use implements otp.ReplayStore.
+func (s *Store) Use(ctx context.Context, id string, counter uint64) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.cache.Use(id, counter), nil
+}
@@ -0,0 +1,111 @@
+package replay
+
+import (
+	"github.com/stretchr/testify/require"
+
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheUse(t *testing.T) {
+	c := NewCache(30*time.Second, 1, 0)
+
+	require.True(t, c.Use("alice", 10), "first use must succeed")
+	require.False(t, c.Use("alice", 10), "replay must be rejected")
+	require.True(t, c.Use("alice", 11), "other counter is independent")
+	require.True(t, c.Use("bob", 10), "other id is independent")
+	require.True(t, c.Used("alice", 10))
+	require.False(t, c.Used("alice", 12))
+}
+
+func TestCacheExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewCache(30*time.Second, 1, 0)
+	c.now = func() time.Time { return now }
+
+	require.True(t, c.Use("alice", 10))
+
+	// Entries are kept for at least period×(2×skew+1).
+	now = now.Add(89 * time.Second)
+	require.True(t, c.Used("alice", 10))
+	require.False(t, c.Use("alice", 10))
+
+	now = now.Add(2 * 90 * time.Second)
+	require.False(t, c.Used("alice", 10))
+	require.True(t, c.Use("alice", 10))
+}
+
+func TestCacheTolerance(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewCache(30*time.Second, 0, 5*time.Second)
+	c.now = func() time.Time { return now }
+
+	// Start a generation, then use a passcode at the start of its
+	// acceptance window, just before the generation rotates.
+	require.True(t, c.Use("alice", 1))
+	other := uint64(2)
+	for c.shard(entry{"bob", other}) != c.shard(entry{"alice", 1}) {
+		other++
+	}
+	now = now.Add(29 * time.Second)
+	require.True(t, c.Use("bob", other))
+
+	// A passcode is accepted for 40s: its 30s period and 5s either side.
+	// 31s later it must still be known, although the period has passed.
+	now = now.Add(31 * time.Second)
+	require.False(t, c.Use("bob", other))
+}
+
+func TestCacheMixedPeriods(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := NewCache(60*time.Second, 1, 0)
+	c.now = func() time.Time { return now }
+
+	// Counters of 30s and 60s keys and of HOTP keys are far apart; none
+	// may evict or shadow the others.
+	for i := 0; i < 10; i++ {
+		at := uint64(now.Unix())
+		require.True(t, c.Use("thirty", at/30))
+		require.True(t, c.Use("sixty", at/60))
+		require.True(t, c.Use("hotp", uint64(i)))
+		require.False(t, c.Use("sixty", at/60))
+		now = now.Add(60 * time.Second)
+	}
+}
+
+func BenchmarkCacheUseParallel(b *testing.B) {
+	c := NewCache(30*time.Second, 1, 0)
+
+	ids := make([]string, 1024)
+	for i := range ids {
+		ids[i] = "user-" + strconv.Itoa(i)
+	}
+
+	var n uint64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddUint64(&n, 1)
+			c.Use(ids[i%uint64(len(ids))], i/uint64(len(ids)))
+		}
+	})
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(30*time.Second, 1, 0)
+
+	first, err := s.Use(context.Background(), "alice", 10)
+	require.NoError(t, err)
+	require.True(t, first)
+	first, err = s.Use(context.Background(), "alice", 10)
+	require.NoError(t, err)
+	require.False(t, first)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Use(ctx, "alice", 11)
+	require.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,209 @@
+package otp
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SealedParam is the URL parameter marking a key whose secret is sealed,
+// see Key.Seal.
+const SealedParam = "sealed"
+
+// The secret was sealed under a KEK the AESWrapper does not hold.
+var ErrUnknownKEK = errors.New("Unknown key encryption key")
+
+// The key is sealed, see Key.Seal.
+var ErrKeySealed = errors.New("Key is sealed")
+
+// Unseal was called on a key that is not sealed.
+var ErrKeyNotSealed = errors.New("Key is not sealed")
+
+// Seal returns a copy of the key whose secret is encrypted by wrapper, so
+// it can be persisted without a plain text secret. The secret is bound to
+// id, the ID of the record the key is persisted as, and only unseals with
+// the same id. The sealed key keeps its other parameters in the clear and
+// is marked with SealedParam; Key.GenerateCode and Key.Validate fail with
+// ErrKeySealed until it is unsealed.
+func (k *Key) Seal(ctx context.Context, wrapper KeyWrapper, id string) (*Key, error) {
+	if k.Sealed() {
+		return nil, ErrKeySealed
+	}
+
+	secret, err := DecodeSecret(k.Secret())
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapper.Wrap(ctx, secret, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Clone(WithSecret(b32NoPadding.EncodeToString(wrapped)), withSealed(true)), nil
+}
+
+// Unseal returns a copy of a key sealed by Seal for id with its secret
+// decrypted by wrapper.
+func (k *Key) Unseal(ctx context.Context, wrapper KeyWrapper, id string) (*Key, error) {
+	if !k.Sealed() {
+		return nil, ErrKeyNotSealed
+	}
+
+	wrapped, err := DecodeSecret(k.Secret())
+	if err != nil {
+		return nil, err
+	}
+	secret, err := wrapper.Unwrap(ctx, wrapped, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	return k.Clone(WithSecret(b32NoPadding.EncodeToString(secret)), withSealed(false)), nil
+}
+
+// Reseal returns a copy of a sealed key with its secret encrypted again by
+// wrapper, eg under the current KEK of an AESWrapper after a rotation.
+func (k *Key) Reseal(ctx context.Context, wrapper KeyWrapper, id string) (*Key, error) {
+	key, err := k.Unseal(ctx, wrapper, id)
+	if err != nil {
+		return nil, err
+	}
+	return key.Seal(ctx, wrapper, id)
+}
+
+// Sealed reports whether the secret of the key is sealed.
+func (k *Key) Sealed() bool {
+	return k.load().params.extra.Get(SealedParam) != ""
+}
+
+func withSealed(sealed bool) KeyOpt {
+	return func(ks *keyState) {
+		if !sealed {
+			ks.params.extra.Del(SealedParam)
+			return
+		}
+		if ks.params.extra == nil {
+			ks.params.extra = make(map[string][]string, 1)
+		}
+		ks.params.extra.Set(SealedParam, "1")
+	}
+}
+
+// KEK is a key encryption key of an AESWrapper.
+type KEK struct {
+	// ID names the KEK in wrapped secrets, at most 255 bytes.
+	ID string
+	// Key is an AES key of 16, 24 or 32 bytes.
+	Key []byte
+}
+
+// sealVersion is the first byte of the secrets wrapped by AESWrapper.
+const sealVersion = 1
+
+// AESWrapper is a KeyWrapper encrypting secrets with AES-GCM under a
+// current KEK, for applications without a KMS. Wrapped secrets name their
+// KEK, so KEKs can be rotated: secrets wrapped under a previous KEK still
+// unwrap, and Key.Reseal or a Get and Put through an EncryptedKeyStore
+// re-encrypts them under the current one.
+// An AESWrapper is safe for concurrent use.
+type AESWrapper struct {
+	current string
+	aeads   map[string]cipher.AEAD
+	// rand is the source of nonces, crypto/rand when nil.
+	rand io.Reader
+}
+
+// NewAESWrapper returns an AESWrapper wrapping under current and also
+// unwrapping secrets wrapped under the previous KEKs.
+func NewAESWrapper(current KEK, previous ...KEK) (*AESWrapper, error) {
+	w := &AESWrapper{current: current.ID, aeads: make(map[string]cipher.AEAD, 1+len(previous))}
+	for _, kek := range append([]KEK{current}, previous...) {
+		if len(kek.ID) > 255 {
+			return nil, &OptionError{Name: "KEK ID", Value: kek.ID, Err: ErrInvalidOption}
+		}
+		if _, ok := w.aeads[kek.ID]; ok {
+			return nil, &OptionError{Name: "KEK ID", Value: kek.ID, Err: fmt.Errorf("%w: duplicate", ErrInvalidOption)}
+		}
+		block, err := aes.NewCipher(kek.Key)
+		if err != nil {
+			return nil, &OptionError{Name: "KEK", Value: kek.ID, Err: err}
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		w.aeads[kek.ID] = aead
+	}
+	return w, nil
+}
+
+// Wrap implements KeyWrapper. The wrapped secret is a version byte, the
+// length and ID of the current KEK, a nonce and the sealed secret, with
+// the header followed by aad authenticated as additional data.
+func (w *AESWrapper) Wrap(ctx context.Context, secret, aad []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	aead := w.aeads[w.current]
+	header := append([]byte{sealVersion, byte(len(w.current))}, w.current...)
+
+	r := w.rand
+	if r == nil {
+		r = rand.Reader
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, err
+	}
+
+	// The header is self-delimiting, so header and aad cannot be confused.
+	ad := append(append([]byte(nil), header...), aad...)
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, secret, ad), nil
+}
+
+// Unwrap implements KeyWrapper. Secrets wrapped under a KEK the wrapper
+// does not hold fail with an error matching ErrUnknownKEK.
+func (w *AESWrapper) Unwrap(ctx context.Context, wrapped, aad []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	id, rest, err := sealedKEK(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := w.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKEK, id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("wrapped secret too short")
+	}
+
+	ad := append(append([]byte(nil), wrapped[:len(wrapped)-len(rest)]...), aad...)
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, ad)
+}
+
+// NeedsRewrap reports whether wrapped was not wrapped under the current
+// KEK, and should be wrapped again to complete a rotation.
+func (w *AESWrapper) NeedsRewrap(wrapped []byte) bool {
+	id, _, err := sealedKEK(wrapped)
+	return err != nil || id != w.current
+}
+
+// sealedKEK returns the KEK ID of a wrapped secret and the bytes following
+// its header.
+func sealedKEK(wrapped []byte) (string, []byte, error) {
+	if len(wrapped) < 2 || wrapped[0] != sealVersion || len(wrapped) < 2+int(wrapped[1]) {
+		return "", nil, errors.New("wrapped secret has an invalid header")
+	}
+	n := 2 + int(wrapped[1])
+	return string(wrapped[2:n]), wrapped[n:], nil
+}
@@ -0,0 +1,118 @@
+package otp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySeal(t *testing.T) {
+	ctx := context.Background()
+	old := KEK{ID: "2024", Key: bytes.Repeat([]byte{1}, 32)}
+	cur := KEK{ID: "2025", Key: bytes.Repeat([]byte{2}, 16)}
+
+	key, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	code, err := key.GenerateCode(now)
+	require.NoError(t, err)
+
+	w, err := NewAESWrapper(old)
+	require.NoError(t, err)
+	sealed, err := key.Seal(ctx, w, "alice")
+	require.NoError(t, err)
+	require.True(t, sealed.Sealed())
+	require.False(t, key.Sealed())
+	require.NotContains(t, sealed.String(), "JBSWY3DPEHPK3PXP")
+	require.Contains(t, sealed.String(), "sealed=1")
+	require.Equal(t, "Example", sealed.Issuer())
+
+	_, err = sealed.GenerateCode(now)
+	require.Equal(t, ErrKeySealed, err)
+	_, err = sealed.Validate(code, now)
+	require.Equal(t, ErrKeySealed, err)
+	_, err = sealed.Seal(ctx, w, "alice")
+	require.Equal(t, ErrKeySealed, err)
+	_, err = key.Unseal(ctx, w, "alice")
+	require.Equal(t, ErrKeyNotSealed, err)
+
+	parsed, err := NewKeyFromURL(sealed.String())
+	require.NoError(t, err)
+	_, err = parsed.Unseal(ctx, w, "bob")
+	require.Error(t, err, "a sealed secret cannot be moved to another record")
+	unsealed, err := parsed.Unseal(ctx, w, "alice")
+	require.NoError(t, err)
+	require.Equal(t, key.Secret(), unsealed.Secret())
+	require.False(t, unsealed.Sealed())
+
+	// Rotation: the old KEK still unwraps, Reseal moves to the current one.
+	rotated, err := NewAESWrapper(cur, old)
+	require.NoError(t, err)
+	wrapped, err := DecodeSecret(sealed.Secret())
+	require.NoError(t, err)
+	require.True(t, rotated.NeedsRewrap(wrapped))
+
+	resealed, err := sealed.Reseal(ctx, rotated, "alice")
+	require.NoError(t, err)
+	wrapped, err = DecodeSecret(resealed.Secret())
+	require.NoError(t, err)
+	require.False(t, rotated.NeedsRewrap(wrapped))
+
+	_, err = resealed.Unseal(ctx, w, "alice")
+	require.True(t, errors.Is(err, ErrUnknownKEK))
+
+	retired, err := NewAESWrapper(cur)
+	require.NoError(t, err)
+	unsealed, err = resealed.Unseal(ctx, retired, "alice")
+	require.NoError(t, err)
+	valid, err := unsealed.Validate(code, now)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestAESWrapper(t *testing.T) {
+	ctx := context.Background()
+	kek := KEK{ID: "k1", Key: bytes.Repeat([]byte{7}, 32)}
+	w, err := NewAESWrapper(kek)
+	require.NoError(t, err)
+
+	secret := []byte("12345678901234567890")
+	aad := []byte("alice")
+	a, err := w.Wrap(ctx, secret, aad)
+	require.NoError(t, err)
+	b, err := w.Wrap(ctx, secret, aad)
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+
+	got, err := w.Unwrap(ctx, a, aad)
+	require.NoError(t, err)
+	require.Equal(t, secret, got)
+
+	// The secret is bound to its aad.
+	_, err = w.Unwrap(ctx, a, []byte("bob"))
+	require.Error(t, err)
+
+	// The KEK ID is authenticated, not only the ciphertext.
+	other, err := NewAESWrapper(KEK{ID: "k2", Key: kek.Key})
+	require.NoError(t, err)
+	tampered := append([]byte{a[0], 2, 'k', '2'}, a[4:]...)
+	_, err = other.Unwrap(ctx, tampered, aad)
+	require.Error(t, err)
+
+	tampered = append([]byte(nil), a...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = w.Unwrap(ctx, tampered, aad)
+	require.Error(t, err)
+
+	_, err = w.Unwrap(ctx, []byte{9}, aad)
+	require.Error(t, err)
+
+	_, err = NewAESWrapper(KEK{ID: "short", Key: []byte("short")})
+	require.True(t, errors.Is(err, ErrInvalidOption))
+	_, err = NewAESWrapper(kek, kek)
+	require.True(t, errors.Is(err, ErrInvalidOption))
+}
@@ -0,0 +1,116 @@
+package otp
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// DecodeSecret converts a base32 encoded secret into the raw key material
+// used for the HMAC operation.
+func DecodeSecret(secret string) ([]byte, error) {
+	// As noted in issue #10 and #17 this adds support for TOTP secrets that are
+	// missing their padding.
+	trimmed := strings.TrimSpace(secret)
+	secret = trimmed
+	if n := len(secret) % 8; n != 0 {
+		secret = secret + strings.Repeat("=", 8-n)
+	}
+
+	// As noted in issue #24 Google has started producing base32 in lower case,
+	// but the StdEncoding (and the RFC), expect a dictionary of only upper case letters.
+	secret = strings.ToUpper(secret)
+
+	secretBytes, err := base32.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, diagnoseSecret(trimmed)
+	}
+
+	return secretBytes, nil
+}
+
+// diagnoseSecret locates the problem in a secret that failed to decode.
+func diagnoseSecret(secret string) *SecretError {
+	padding := -1
+	for i, r := range secret {
+		switch {
+		case r == '=':
+			if padding == -1 {
+				padding = i
+			}
+		case padding != -1:
+			return &SecretError{Offset: padding, Char: '=', Padding: true}
+		case (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (r < '2' || r > '7'):
+			return &SecretError{Offset: i, Char: r}
+		}
+	}
+
+	data := len(secret)
+	if padding != -1 {
+		data = padding
+	}
+
+	// A final base32 block can only hold 2, 4, 5 or 7 data characters.
+	switch data % 8 {
+	case 1, 3, 6:
+		return &SecretError{Offset: data, Padding: true}
+	}
+
+	if padding != -1 {
+		return &SecretError{Offset: padding, Char: '=', Padding: true}
+	}
+
+	return &SecretError{Offset: len(secret), Padding: true}
+}
+
+// randAttempts is how many times ReadSecret reads from a failing reader.
+const randAttempts = 3
+
+// ReadSecret reads a secret of size bytes from r. Short reads are
+// completed with io.ReadFull, and a failing read or a secret of a single
+// repeated byte, the output of a stuck entropy source, is retried a few
+// times before failing with a *RandError.
+func ReadSecret(r io.Reader, size int) ([]byte, error) {
+	secret := make([]byte, size)
+
+	rerr := &RandError{Size: size}
+	for i := 0; i < randAttempts; i++ {
+		rerr.Read, rerr.Err = io.ReadFull(r, secret)
+		if rerr.Err == nil && !repeatedByte(secret) {
+			return secret, nil
+		}
+	}
+	if rerr.Err == nil {
+		rerr.Err = ErrRandStuck
+	}
+
+	return nil, rerr
+}
+
+// repeatedByte reports whether b has more than one byte, all of them the
+// same.
+func repeatedByte(b []byte) bool {
+	if len(b) < 2 {
+		return false
+	}
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// SecretFingerprint identifies a secret without revealing it, eg in logs
+// and audit records: the first 8 bytes of the SHA-256 of the decoded
+// secret, in hex. Secrets that fail to decode are hashed as they are.
+func SecretFingerprint(secret string) string {
+	b, err := DecodeSecret(secret)
+	if err != nil {
+		b = []byte(secret)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,56 @@
+package otp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecret(t *testing.T) {
+	secret, err := ReadSecret(iotest.OneByteReader(rand.Reader), 20)
+	require.NoError(t, err)
+	require.Len(t, secret, 20)
+
+	_, err = ReadSecret(bytes.NewReader(make([]byte, 5)), 20)
+	require.True(t, errors.Is(err, ErrRandFailure))
+	require.True(t, errors.Is(err, io.EOF))
+
+	var rerr *RandError
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, 20, rerr.Size)
+	require.Equal(t, 0, rerr.Read)
+
+	_, err = ReadSecret(bytes.NewReader(make([]byte, 100)), 20)
+	require.True(t, errors.Is(err, ErrRandStuck))
+
+	// A failing first read is retried.
+	secret, err = ReadSecret(&flakyReader{fail: 1}, 20)
+	require.NoError(t, err)
+	require.Len(t, secret, 20)
+}
+
+// flakyReader fails its first reads, then reads from crypto/rand.
+type flakyReader struct {
+	fail int
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if r.fail > 0 {
+		r.fail--
+		return 0, errors.New("entropy source unavailable")
+	}
+	return rand.Read(p)
+}
+
+func TestSecretFingerprint(t *testing.T) {
+	fp := SecretFingerprint("JBSWY3DPEHPK3PXP")
+	require.Len(t, fp, 16)
+	require.Equal(t, fp, SecretFingerprint("jbswy3dpehpk3pxp"))
+	require.NotEqual(t, fp, SecretFingerprint("GEZDGNBVGY3TQOJQ"))
+	require.Len(t, SecretFingerprint("not base32!"), 16)
+}
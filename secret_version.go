@@ -0,0 +1,91 @@
+package otp
+
+import (
+	"context"
+	"errors"
+)
+
+// SecretVersionParam is the URL parameter naming the version of the
+// secret of a key, see SecretRouter. Authenticator apps ignore it.
+const SecretVersionParam = "secret_version"
+
+// No secret is known for the version of a key.
+var ErrUnknownSecretVersion = errors.New("Unknown secret version")
+
+// WithSecretVersion sets the version of the secret of the key. An empty
+// version removes it.
+func WithSecretVersion(version string) KeyOpt {
+	return func(ks *keyState) {
+		if version == "" {
+			ks.params.extra.Del(SecretVersionParam)
+			return
+		}
+		if ks.params.extra == nil {
+			ks.params.extra = make(map[string][]string, 1)
+		}
+		ks.params.extra.Set(SecretVersionParam, version)
+	}
+}
+
+// SecretVersion returns the version of the secret of the key, empty when
+// it has none.
+func (k *Key) SecretVersion() string {
+	return k.load().params.extra.Get(SecretVersionParam)
+}
+
+// SecretResolver returns the secret of version for the key stored under
+// id, or an error matching ErrUnknownSecretVersion.
+type SecretResolver func(ctx context.Context, id, version string) (string, error)
+
+// SecretRouter is a KeyStore decorator for keys whose secret is held by
+// version outside the store, eg derived from a per-version master key, so
+// a large user base can be re-keyed without downtime: new enrollments get
+// the new version while existing keys keep validating against theirs.
+//
+// Keys with a secret version are stored without their secret, and Get
+// fills it in from the resolver. Keys without a version pass through, so
+// legacy keys keep working.
+type SecretRouter struct {
+	store   KeyStore
+	resolve SecretResolver
+}
+
+// NewSecretRouter returns a KeyStore keeping keys in store and resolving
+// their secret by version with resolve.
+func NewSecretRouter(store KeyStore, resolve SecretResolver) *SecretRouter {
+	return &SecretRouter{store: store, resolve: resolve}
+}
+
+// Get implements KeyStore.
+func (r *SecretRouter) Get(ctx context.Context, id string) (*Key, error) {
+	key, err := r.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	version := key.SecretVersion()
+	if version == "" {
+		return key, nil
+	}
+	secret, err := r.resolve(ctx, id, version)
+	if err != nil {
+		if errors.Is(err, ErrUnknownSecretVersion) {
+			return nil, err
+		}
+		return nil, WrapStoreError("Get", id, err)
+	}
+	return key.Clone(WithSecret(secret)), nil
+}
+
+// Put implements KeyStore.
+func (r *SecretRouter) Put(ctx context.Context, id string, key *Key) error {
+	if key.SecretVersion() != "" {
+		key = key.Clone(WithSecret(""))
+	}
+	return r.store.Put(ctx, id, key)
+}
+
+// Delete implements KeyStore.
+func (r *SecretRouter) Delete(ctx context.Context, id string) error {
+	return r.store.Delete(ctx, id)
+}
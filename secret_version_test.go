@@ -0,0 +1,73 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretVersion(t *testing.T) {
+	key, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.Equal(t, "", key.SecretVersion())
+
+	v2 := key.Clone(WithSecretVersion("2"))
+	require.Equal(t, "2", v2.SecretVersion())
+	require.Contains(t, v2.String(), "secret_version=2")
+
+	parsed, err := NewKeyFromURL(v2.String())
+	require.NoError(t, err)
+	require.Equal(t, "2", parsed.SecretVersion())
+
+	require.Equal(t, "", v2.Clone(WithSecretVersion("")).SecretVersion())
+	require.Equal(t, "", key.SecretVersion())
+}
+
+func TestSecretRouter(t *testing.T) {
+	ctx := context.Background()
+	secrets := map[string]string{"1": "JBSWY3DPEHPK3PXP", "2": "GEZDGNBVGY3TQOJQ"}
+	store := &MemoryKeyStore{}
+	r := NewSecretRouter(store, func(_ context.Context, id, version string) (string, error) {
+		if s, ok := secrets[version]; ok {
+			return s, nil
+		}
+		return "", ErrUnknownSecretVersion
+	})
+
+	legacy, err := NewKeyFromURL("otpauth://totp/Example:bob?secret=MFRGGZDFMZTWQ2LK&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, r.Put(ctx, "bob", legacy))
+
+	versioned := legacy.Clone(WithAccountName("alice"), WithSecret(secrets["2"]), WithSecretVersion("2"))
+	require.NoError(t, r.Put(ctx, "alice", versioned))
+
+	// The underlying store never sees the versioned secret.
+	stored, err := store.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "", stored.Secret())
+
+	got, err := r.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, secrets["2"], got.Secret())
+	now := time.Now()
+	code, err := versioned.GenerateCode(now)
+	require.NoError(t, err)
+	ok, err := got.Validate(code, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	got, err = r.Get(ctx, "bob")
+	require.NoError(t, err)
+	require.Equal(t, "MFRGGZDFMZTWQ2LK", got.Secret())
+
+	require.NoError(t, store.Put(ctx, "carol", legacy.Clone(WithSecretVersion("9"))))
+	_, err = r.Get(ctx, "carol")
+	require.True(t, errors.Is(err, ErrUnknownSecretVersion))
+
+	require.NoError(t, r.Delete(ctx, "alice"))
+	_, err = r.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+}
@@ -0,0 +1,175 @@
+// Package shard routes validations across a sharded fleet of validation
+// services by account, so each account's replay and attempt state lives
+// on a single shard.
+//
+// Accounts are placed on a consistent hash ring: adding or removing a
+// shard only moves the accounts of its neighbors. The transport is left
+// to the caller, through the Shard interface.
+package shard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+// ErrNoShards is returned by a Client without shards.
+var ErrNoShards = errors.New("No shards to route to")
+
+// Shard is a validation service, typically an RPC client for one instance.
+type Shard interface {
+	Validate(ctx context.Context, id, passcode string) (bool, error)
+}
+
+// DefaultReplicas is the number of points of each shard on the ring.
+const DefaultReplicas = 128
+
+// Ring is a consistent hash ring of shard names. It is immutable and safe
+// for concurrent use.
+type Ring struct {
+	points []point
+}
+
+type point struct {
+	hash uint64
+	name string
+}
+
+// NewRing places names on a ring with replicas points each.
+func NewRing(replicas int, names ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	r := &Ring{points: make([]point, 0, replicas*len(names))}
+	for _, name := range names {
+		for i := 0; i < replicas; i++ {
+			r.points = append(r.points, point{hash(name + "#" + strconv.Itoa(i)), name})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash == r.points[j].hash {
+			return r.points[i].name < r.points[j].name
+		}
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// Lookup returns up to n distinct shards for id, in order of preference:
+// the owner of id first, then the shards following it on the ring.
+func (r *Ring) Lookup(id string, n int) []string {
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+
+	h := hash(id)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+
+	var names []string
+	seen := make(map[string]bool, n)
+	for j := 0; j < len(r.points) && len(names) < n; j++ {
+		p := r.points[(i+j)%len(r.points)]
+		if !seen[p.name] {
+			seen[p.name] = true
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+// hash is the ring position of s: the first 8 bytes of its SHA-256, so
+// account ids are spread evenly whatever their format.
+func hash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Client routes validations to the shard owning each account.
+type Client struct {
+	ring      *Ring
+	shards    map[string]Shard
+	replicas  int
+	retries   int
+	retryable func(err error) bool
+}
+
+// ClientOpt configures a Client.
+type ClientOpt func(c *Client)
+
+// WithReplicas sets the number of points of each shard on the ring.
+// Defaults to DefaultReplicas.
+func WithReplicas(replicas int) ClientOpt {
+	return func(c *Client) {
+		c.replicas = replicas
+	}
+}
+
+// WithRetries sets how many following shards are tried when a shard
+// fails with an error retryable reports true for. Defaults to no retry:
+// a retried validation runs on a shard without the account's replay
+// state, so only enable it when that state is replicated or when
+// availability matters more than replay protection during an outage.
+func WithRetries(retries int, retryable func(err error) bool) ClientOpt {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryable = retryable
+	}
+}
+
+// NewClient creates a Client routing to shards by name. Every client of a
+// fleet must use the same names and replicas to agree on the owners.
+func NewClient(shards map[string]Shard, opts ...ClientOpt) *Client {
+	c := &Client{shards: shards, replicas: DefaultReplicas}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		names = append(names, name)
+	}
+	c.ring = NewRing(c.replicas, names...)
+	return c
+}
+
+// Owner returns the name of the shard owning id.
+func (c *Client) Owner(id string) string {
+	names := c.ring.Lookup(id, 1)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// Validate checks passcode for id on the shard owning id, then on the
+// following shards while the error is retryable and retries are left.
+// The context is checked between attempts.
+func (c *Client) Validate(ctx context.Context, id, passcode string) (bool, error) {
+	names := c.ring.Lookup(id, 1+c.retries)
+	if len(names) == 0 {
+		return false, ErrNoShards
+	}
+
+	var err error
+	for i, name := range names {
+		if i > 0 {
+			if c.retryable == nil || !c.retryable(err) {
+				break
+			}
+			if cerr := ctx.Err(); cerr != nil {
+				return false, cerr
+			}
+		}
+
+		var ok bool
+		ok, err = c.shards[name].Validate(ctx, id, passcode)
+		if err == nil {
+			return ok, nil
+		}
+	}
+	return false, err
+}
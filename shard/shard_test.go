@@ -0,0 +1,101 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeShard struct {
+	name  string
+	err   error
+	calls []string
+}
+
+func (s *fakeShard) Validate(_ context.Context, id, passcode string) (bool, error) {
+	s.calls = append(s.calls, id)
+	if s.err != nil {
+		return false, s.err
+	}
+	return passcode == "123456", nil
+}
+
+func TestRingBalanceAndStability(t *testing.T) {
+	r := NewRing(0, "a", "b", "c")
+
+	counts := map[string]int{}
+	owners := map[string]string{}
+	for i := 0; i < 3000; i++ {
+		id := fmt.Sprintf("user%d", i)
+		owner := r.Lookup(id, 1)[0]
+		counts[owner]++
+		owners[id] = owner
+	}
+	for _, n := range counts {
+		require.InDelta(t, 1000, n, 250)
+	}
+
+	// Adding a shard only moves accounts to the new shard.
+	r = NewRing(0, "a", "b", "c", "d")
+	moved := 0
+	for id, old := range owners {
+		if owner := r.Lookup(id, 1)[0]; owner != old {
+			require.Equal(t, "d", owner)
+			moved++
+		}
+	}
+	require.InDelta(t, 750, moved, 250)
+
+	require.Len(t, r.Lookup("user1", 10), 4)
+	require.Nil(t, NewRing(0).Lookup("user1", 1))
+}
+
+func TestClientValidate(t *testing.T) {
+	shards := map[string]*fakeShard{"a": {name: "a"}, "b": {name: "b"}, "c": {name: "c"}}
+	c := NewClient(map[string]Shard{"a": shards["a"], "b": shards["b"], "c": shards["c"]})
+
+	ok, err := c.Validate(context.Background(), "alice", "123456")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"alice"}, shards[c.Owner("alice")].calls)
+
+	// Without retries a failing owner fails the validation.
+	boom := errors.New("unavailable")
+	shards[c.Owner("alice")].err = boom
+	_, err = c.Validate(context.Background(), "alice", "123456")
+	require.Equal(t, boom, err)
+}
+
+func TestClientRetries(t *testing.T) {
+	boom := errors.New("unavailable")
+	shards := map[string]*fakeShard{"a": {name: "a"}, "b": {name: "b"}, "c": {name: "c"}}
+	c := NewClient(map[string]Shard{"a": shards["a"], "b": shards["b"], "c": shards["c"]},
+		WithRetries(1, func(err error) bool { return err == boom }))
+
+	order := c.ring.Lookup("alice", 3)
+	shards[order[0]].err = boom
+
+	ok, err := c.Validate(context.Background(), "alice", "123456")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, shards[order[1]].calls, 1)
+
+	// One retry only.
+	shards[order[1]].err = boom
+	_, err = c.Validate(context.Background(), "alice", "123456")
+	require.Equal(t, boom, err)
+	require.Empty(t, shards[order[2]].calls)
+
+	// Errors that are not retryable are returned at once.
+	other := errors.New("bad request")
+	shards[order[0]].err = other
+	_, err = c.Validate(context.Background(), "alice", "123456")
+	require.Equal(t, other, err)
+	require.Len(t, shards[order[1]].calls, 2)
+
+	_, err = NewClient(nil).Validate(context.Background(), "alice", "123456")
+	require.Equal(t, ErrNoShards, err)
+}
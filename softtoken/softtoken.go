@@ -0,0 +1,176 @@
+// Package softtoken simulates a user's authenticator app, for integration
+// tests of skew, resynchronization and replay handling.
+//
+// A Device has its own clock, offset from the server's by a fixed drift
+// and a random jitter on every reading, and a user who takes some time to
+// type the code shown. Randomness comes from a seeded source, so a failing
+// test can be reproduced.
+package softtoken
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+// Device simulates an authenticator app holding a single key.
+// A Device is safe for concurrent use.
+type Device struct {
+	key *otp.Key
+
+	drift    time.Duration
+	jitter   time.Duration
+	entryMin time.Duration
+	entryMax time.Duration
+	seed     int64
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	counter uint64
+}
+
+// DeviceOpt configures a Device.
+type DeviceOpt func(d *Device)
+
+// WithDrift sets the offset of the device clock from the server clock.
+// A positive drift makes the device run ahead of the server.
+func WithDrift(drift time.Duration) DeviceOpt {
+	return func(d *Device) {
+		d.drift = drift
+	}
+}
+
+// WithJitter adds a random offset of up to jitter either side to every
+// reading of the device clock.
+func WithJitter(jitter time.Duration) DeviceOpt {
+	return func(d *Device) {
+		d.jitter = jitter
+	}
+}
+
+// WithEntryDelay sets the range of time, between min and max, the user
+// takes from reading a code to submitting it.
+func WithEntryDelay(min, max time.Duration) DeviceOpt {
+	return func(d *Device) {
+		d.entryMin = min
+		d.entryMax = max
+	}
+}
+
+// WithSeed seeds the random source of jitter and entry delays.
+// Defaults to 1.
+func WithSeed(seed int64) DeviceOpt {
+	return func(d *Device) {
+		d.seed = seed
+	}
+}
+
+// New creates a Device holding key. The counter of a HOTP key starts at
+// the key's counter parameter.
+func New(key *otp.Key, deviceOpts ...DeviceOpt) *Device {
+	d := &Device{
+		key:  key,
+		seed: 1,
+	}
+	for _, opt := range deviceOpts {
+		opt(d)
+	}
+	if d.entryMax < d.entryMin {
+		d.entryMax = d.entryMin
+	}
+	d.rnd = rand.New(rand.NewSource(d.seed))
+	d.counter = key.Counter()
+
+	return d
+}
+
+// Entry is a code read from the Device and submitted by the user.
+type Entry struct {
+	// Code shown by the device.
+	Code string
+	// Clock is the device time the code was generated for.
+	Clock time.Time
+	// Shown is the server time the code was read at.
+	Shown time.Time
+	// Submitted is the server time the user submits the code at, to
+	// validate the code with.
+	Submitted time.Time
+}
+
+// Read returns the code the device shows at server time now. Reading a
+// HOTP key presses the button, so every Read consumes a counter.
+func (d *Device) Read(now time.Time) (Entry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e := Entry{
+		Clock: d.clock(now),
+		Shown: now,
+	}
+	e.Submitted = now.Add(d.entryDelay())
+
+	var err error
+	if strings.EqualFold(d.key.Type(), "hotp") {
+		e.Code, err = d.hotpCode(d.counter)
+		if err == nil {
+			d.counter++
+		}
+	} else {
+		e.Code, err = d.key.GenerateCode(e.Clock)
+	}
+
+	return e, err
+}
+
+// Press advances the counter of a HOTP key by n without submitting the
+// codes, like a user playing with the button, to desynchronize the device
+// from the server.
+func (d *Device) Press(n uint64) {
+	d.mu.Lock()
+	d.counter += n
+	d.mu.Unlock()
+}
+
+// Counter returns the counter the next Read of a HOTP key uses.
+func (d *Device) Counter() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.counter
+}
+
+// Clock returns the time the device clock shows at server time now.
+func (d *Device) Clock(now time.Time) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.clock(now)
+}
+
+// clock returns the device time at server time now, including a fresh
+// jitter.
+func (d *Device) clock(now time.Time) time.Time {
+	t := now.Add(d.drift)
+	if d.jitter > 0 {
+		t = t.Add(time.Duration(d.rnd.Int63n(int64(2*d.jitter)+1)) - d.jitter)
+	}
+	return t
+}
+
+// entryDelay returns a random delay between entryMin and entryMax.
+func (d *Device) entryDelay() time.Duration {
+	if d.entryMax == d.entryMin {
+		return d.entryMin
+	}
+	return d.entryMin + time.Duration(d.rnd.Int63n(int64(d.entryMax-d.entryMin)+1))
+}
+
+// hotpCode returns the passcode of a HOTP key for counter.
+func (d *Device) hotpCode(counter uint64) (string, error) {
+	return hotp.GenerateCodeCustom(d.key.Secret(), counter, hotp.ValidateOpts{
+		Digits:    d.key.Digits(),
+		Algorithm: d.key.Algorithm(),
+	})
+}
@@ -0,0 +1,74 @@
+package softtoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceTOTPDrift(t *testing.T) {
+	key, err := totp.GenerateWithOpts(totp.WithIssuer("SnakeOil"), totp.WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 10, 0, time.UTC)
+
+	d := New(key, WithDrift(30*time.Second), WithEntryDelay(2*time.Second, 5*time.Second))
+	e, err := d.Read(now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(30*time.Second), e.Clock)
+	require.True(t, e.Submitted.Sub(now) >= 2*time.Second && e.Submitted.Sub(now) <= 5*time.Second)
+
+	ok, err := key.Validate(e.Code, e.Submitted)
+	require.NoError(t, err)
+	require.True(t, ok, "one period of drift is within the default skew")
+
+	d = New(key, WithDrift(-90*time.Second))
+	e, err = d.Read(now)
+	require.NoError(t, err)
+	ok, err = key.Validate(e.Code, e.Submitted)
+	require.NoError(t, err)
+	require.False(t, ok, "three periods of drift are outside the default skew")
+}
+
+func TestDeviceJitterSeed(t *testing.T) {
+	key, err := totp.GenerateWithOpts(totp.WithIssuer("SnakeOil"), totp.WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	a := New(key, WithJitter(time.Minute), WithSeed(42))
+	b := New(key, WithJitter(time.Minute), WithSeed(42))
+
+	for i := 0; i < 10; i++ {
+		ta, tb := a.Clock(now), b.Clock(now)
+		require.Equal(t, ta, tb, "equal seeds must reproduce the same jitter")
+		require.True(t, ta.Sub(now) <= time.Minute && now.Sub(ta) <= time.Minute)
+	}
+}
+
+func TestDeviceHOTP(t *testing.T) {
+	key, err := hotp.Generate(hotp.GenerateOpts{Issuer: "SnakeOil", AccountName: "alice@example.com"})
+	require.NoError(t, err)
+
+	d := New(key)
+	e, err := d.Read(time.Now())
+	require.NoError(t, err)
+	require.True(t, hotp.Validate(e.Code, 0, key.Secret()))
+	require.Equal(t, uint64(1), d.Counter())
+
+	d.Press(5)
+	e, err = d.Read(time.Now())
+	require.NoError(t, err)
+	require.False(t, hotp.Validate(e.Code, 1, key.Secret()))
+	require.True(t, hotp.Validate(e.Code, 6, key.Secret()))
+}
+
+func TestDeviceInvalidKey(t *testing.T) {
+	key := otp.NewKey(otp.KeyOpts{Type: "totp", Issuer: "SnakeOil", AccountName: "alice", Secret: "1!"})
+
+	_, err := New(key).Read(time.Now())
+	require.Error(t, err)
+}
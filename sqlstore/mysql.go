@@ -0,0 +1,58 @@
+package sqlstore
+
+// MySQL is the Dialect of MySQL and MariaDB. Used passcodes are detected
+// from the affected rows of an upsert, so the connection must not report
+// found rows, ie the clientFoundRows option of the driver stays off.
+//
+// MySQL commits every DDL statement implicitly, so a migration interrupted
+// midway cannot be rolled back. Its statements are idempotent instead, and
+// Migrate completes it on the next call.
+var MySQL = &Dialect{
+	name: "mysql",
+	migrations: []migration{
+		{1, []string{
+			`CREATE TABLE IF NOT EXISTS otp_keys (
+				id VARCHAR(255) NOT NULL PRIMARY KEY,
+				url TEXT NOT NULL,
+				updated_at DATETIME(6) NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS otp_counters (
+				id VARCHAR(255) NOT NULL PRIMARY KEY,
+				next BIGINT UNSIGNED NOT NULL
+			)`,
+			`CREATE TABLE IF NOT EXISTS otp_replay (
+				id VARCHAR(255) NOT NULL,
+				counter BIGINT UNSIGNED NOT NULL,
+				used_at DATETIME(6) NOT NULL,
+				PRIMARY KEY (id, counter),
+				INDEX otp_replay_used_at (used_at)
+			)`,
+			`CREATE TABLE IF NOT EXISTS otp_attempts (
+				id VARCHAR(255) NOT NULL,
+				at DATETIME(6) NOT NULL,
+				INDEX otp_attempts_id_at (id, at)
+			)`,
+		}},
+	},
+
+	createMigrations: `CREATE TABLE IF NOT EXISTS otp_migrations (version INT NOT NULL PRIMARY KEY)`,
+	appliedVersions:  `SELECT version FROM otp_migrations`,
+	recordVersion:    `INSERT INTO otp_migrations (version) VALUES (?)`,
+
+	getKey: `SELECT url FROM otp_keys WHERE id = ?`,
+	putKey: `INSERT INTO otp_keys (id, url, updated_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE url = VALUES(url), updated_at = VALUES(updated_at)`,
+	deleteKey: `DELETE FROM otp_keys WHERE id = ?`,
+
+	insertCounter: `INSERT INTO otp_counters (id, next) VALUES (?, 0) ON DUPLICATE KEY UPDATE next = next`,
+	lockCounter:   `SELECT next FROM otp_counters WHERE id = ? FOR UPDATE`,
+	bumpCounter:   `UPDATE otp_counters SET next = next + 1 WHERE id = ?`,
+
+	useReplay:   `INSERT INTO otp_replay (id, counter, used_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE id = id`,
+	pruneReplay: `DELETE FROM otp_replay WHERE used_at < ?`,
+
+	pruneAttempts: `DELETE FROM otp_attempts WHERE id = ? AND at <= ?`,
+	addAttempt:    `INSERT INTO otp_attempts (id, at) VALUES (?, ?)`,
+	countAttempts: `SELECT COUNT(*) FROM otp_attempts WHERE id = ?`,
+	resetAttempts: `DELETE FROM otp_attempts WHERE id = ?`,
+}
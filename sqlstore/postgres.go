@@ -0,0 +1,52 @@
+package sqlstore
+
+// Postgres is the Dialect of PostgreSQL.
+var Postgres = &Dialect{
+	name: "postgres",
+	migrations: []migration{
+		{1, []string{
+			`CREATE TABLE otp_keys (
+				id TEXT PRIMARY KEY,
+				url TEXT NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE TABLE otp_counters (
+				id TEXT PRIMARY KEY,
+				next BIGINT NOT NULL
+			)`,
+			`CREATE TABLE otp_replay (
+				id TEXT NOT NULL,
+				counter BIGINT NOT NULL,
+				used_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (id, counter)
+			)`,
+			`CREATE INDEX otp_replay_used_at ON otp_replay (used_at)`,
+			`CREATE TABLE otp_attempts (
+				id TEXT NOT NULL,
+				at TIMESTAMPTZ NOT NULL
+			)`,
+			`CREATE INDEX otp_attempts_id_at ON otp_attempts (id, at)`,
+		}},
+	},
+
+	createMigrations: `CREATE TABLE IF NOT EXISTS otp_migrations (version INTEGER PRIMARY KEY)`,
+	appliedVersions:  `SELECT version FROM otp_migrations`,
+	recordVersion:    `INSERT INTO otp_migrations (version) VALUES ($1)`,
+
+	getKey: `SELECT url FROM otp_keys WHERE id = $1`,
+	putKey: `INSERT INTO otp_keys (id, url, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET url = EXCLUDED.url, updated_at = EXCLUDED.updated_at`,
+	deleteKey: `DELETE FROM otp_keys WHERE id = $1`,
+
+	insertCounter: `INSERT INTO otp_counters (id, next) VALUES ($1, 0) ON CONFLICT (id) DO NOTHING`,
+	lockCounter:   `SELECT next FROM otp_counters WHERE id = $1 FOR UPDATE`,
+	bumpCounter:   `UPDATE otp_counters SET next = next + 1 WHERE id = $1`,
+
+	useReplay:   `INSERT INTO otp_replay (id, counter, used_at) VALUES ($1, $2, $3) ON CONFLICT (id, counter) DO NOTHING`,
+	pruneReplay: `DELETE FROM otp_replay WHERE used_at < $1`,
+
+	pruneAttempts: `DELETE FROM otp_attempts WHERE id = $1 AND at <= $2`,
+	addAttempt:    `INSERT INTO otp_attempts (id, at) VALUES ($1, $2)`,
+	countAttempts: `SELECT COUNT(*) FROM otp_attempts WHERE id = $1`,
+	resetAttempts: `DELETE FROM otp_attempts WHERE id = $1`,
+}
@@ -0,0 +1,63 @@
+package sqlstore
+
+import "time"
+
+// SQLite is the Dialect of SQLite 3.24 or later, for single-binary services
+// that keep their keys and state in one file. It works with both the pure
+// Go modernc.org/sqlite driver and the cgo mattn/go-sqlite3 driver.
+//
+// SQLite has no row locks: the insert that starts Next takes the database
+// write lock, which serializes concurrent callers for the rest of the
+// transaction. Times are stored as Unix nanoseconds.
+var SQLite = &Dialect{
+	name: "sqlite",
+	migrations: []migration{
+		{1, []string{
+			`CREATE TABLE otp_keys (
+				id TEXT PRIMARY KEY,
+				url TEXT NOT NULL,
+				updated_at INTEGER NOT NULL
+			)`,
+			`CREATE TABLE otp_counters (
+				id TEXT PRIMARY KEY,
+				next INTEGER NOT NULL
+			)`,
+			`CREATE TABLE otp_replay (
+				id TEXT NOT NULL,
+				counter INTEGER NOT NULL,
+				used_at INTEGER NOT NULL,
+				PRIMARY KEY (id, counter)
+			)`,
+			`CREATE INDEX otp_replay_used_at ON otp_replay (used_at)`,
+			`CREATE TABLE otp_attempts (
+				id TEXT NOT NULL,
+				at INTEGER NOT NULL
+			)`,
+			`CREATE INDEX otp_attempts_id_at ON otp_attempts (id, at)`,
+		}},
+	},
+	timeValue: func(t time.Time) interface{} {
+		return t.UnixNano()
+	},
+
+	createMigrations: `CREATE TABLE IF NOT EXISTS otp_migrations (version INTEGER PRIMARY KEY)`,
+	appliedVersions:  `SELECT version FROM otp_migrations`,
+	recordVersion:    `INSERT INTO otp_migrations (version) VALUES (?)`,
+
+	getKey: `SELECT url FROM otp_keys WHERE id = ?`,
+	putKey: `INSERT INTO otp_keys (id, url, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET url = excluded.url, updated_at = excluded.updated_at`,
+	deleteKey: `DELETE FROM otp_keys WHERE id = ?`,
+
+	insertCounter: `INSERT INTO otp_counters (id, next) VALUES (?, 0) ON CONFLICT (id) DO NOTHING`,
+	lockCounter:   `SELECT next FROM otp_counters WHERE id = ?`,
+	bumpCounter:   `UPDATE otp_counters SET next = next + 1 WHERE id = ?`,
+
+	useReplay:   `INSERT INTO otp_replay (id, counter, used_at) VALUES (?, ?, ?) ON CONFLICT (id, counter) DO NOTHING`,
+	pruneReplay: `DELETE FROM otp_replay WHERE used_at < ?`,
+
+	pruneAttempts: `DELETE FROM otp_attempts WHERE id = ? AND at <= ?`,
+	addAttempt:    `INSERT INTO otp_attempts (id, at) VALUES (?, ?)`,
+	countAttempts: `SELECT COUNT(*) FROM otp_attempts WHERE id = ?`,
+	resetAttempts: `DELETE FROM otp_attempts WHERE id = ?`,
+}
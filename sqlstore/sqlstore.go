@@ -0,0 +1,248 @@
+// Package sqlstore implements the otp store interfaces on a SQL database:
+// keys, HOTP counters, used passcodes for replay protection and
+// validation attempts for rate limiting.
+//
+// The package uses database/sql and does not import a driver; open the
+// *sql.DB with the driver of your choice and pass the matching Dialect.
+// Migrate creates and upgrades the tables, which are prefixed with "otp_".
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// Dialect holds the SQL of a database flavor.
+type Dialect struct {
+	name       string
+	migrations []migration
+	// timeValue converts times to query arguments; nil passes them as is.
+	timeValue func(t time.Time) interface{}
+
+	createMigrations string
+	appliedVersions  string
+	recordVersion    string
+
+	getKey    string
+	putKey    string
+	deleteKey string
+
+	insertCounter string
+	lockCounter   string
+	bumpCounter   string
+
+	useReplay   string
+	pruneReplay string
+
+	pruneAttempts string
+	addAttempt    string
+	countAttempts string
+	resetAttempts string
+}
+
+// String returns the name of the database flavor.
+func (d *Dialect) String() string {
+	return d.name
+}
+
+// migration is a numbered schema change, applied in one transaction. On
+// databases that commit DDL implicitly, such as MySQL, the transaction
+// cannot undo a partly applied migration, so its statements must be safe
+// to run again.
+type migration struct {
+	version    int
+	statements []string
+}
+
+// Store implements otp.KeyStore, otp.CounterStore, otp.ReplayStore and
+// otp.AttemptStore on a SQL database.
+// A Store is safe for concurrent use.
+type Store struct {
+	db      *sql.DB
+	dialect *Dialect
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+// New creates a Store on db, using the SQL of dialect.
+func New(db *sql.DB, dialect *Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// Migrate applies the migrations the database lacks, each in its own
+// transaction, so it is safe to call on every start.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, s.dialect.createMigrations); err != nil {
+		return otp.WrapStoreError("Migrate", "", err)
+	}
+
+	applied := map[int]bool{}
+	rows, err := s.db.QueryContext(ctx, s.dialect.appliedVersions)
+	if err != nil {
+		return otp.WrapStoreError("Migrate", "", err)
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return otp.WrapStoreError("Migrate", "", err)
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return otp.WrapStoreError("Migrate", "", err)
+	}
+
+	for _, m := range s.dialect.migrations {
+		if applied[m.version] {
+			continue
+		}
+		err := s.tx(ctx, func(tx *sql.Tx) error {
+			for _, stmt := range m.statements {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, s.dialect.recordVersion, m.version)
+			return err
+		})
+		if err != nil {
+			return otp.WrapStoreError("Migrate", "", err)
+		}
+	}
+
+	return nil
+}
+
+// Get implements otp.KeyStore.
+func (s *Store) Get(ctx context.Context, id string) (*otp.Key, error) {
+	var u string
+	err := s.db.QueryRowContext(ctx, s.dialect.getKey, id).Scan(&u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, otp.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+
+	key, err := otp.NewKeyFromURL(u)
+	if err != nil {
+		return nil, otp.WrapStoreError("Get", id, err)
+	}
+	return key, nil
+}
+
+// Put implements otp.KeyStore. The key is stored as its URL, including
+// its metadata.
+func (s *Store) Put(ctx context.Context, id string, key *otp.Key) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.putKey, id, key.MetadataURL(), s.timeArg(s.clock()))
+	return otp.WrapStoreError("Put", id, err)
+}
+
+// Delete implements otp.KeyStore.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.deleteKey, id)
+	return otp.WrapStoreError("Delete", id, err)
+}
+
+// Next implements otp.CounterStore. The counter row is locked for the
+// duration of the transaction, so concurrent callers never consume the
+// same value.
+func (s *Store) Next(ctx context.Context, id string) (uint64, error) {
+	var c int64
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.insertCounter, id); err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, s.dialect.lockCounter, id).Scan(&c); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, s.dialect.bumpCounter, id)
+		return err
+	})
+	if err != nil {
+		return 0, otp.WrapStoreError("Next", id, err)
+	}
+	return uint64(c), nil
+}
+
+// Use implements otp.ReplayStore.
+func (s *Store) Use(ctx context.Context, id string, counter uint64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, s.dialect.useReplay, id, int64(counter), s.timeArg(s.clock()))
+	if err != nil {
+		return false, otp.WrapStoreError("Use", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, otp.WrapStoreError("Use", id, err)
+	}
+	return n == 1, nil
+}
+
+// PruneReplay forgets the passcodes used before t. Passcodes only need to
+// be remembered while they could still be accepted, so calling it
+// periodically with a time a few periods ago keeps the table small.
+func (s *Store) PruneReplay(ctx context.Context, t time.Time) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.pruneReplay, s.timeArg(t))
+	return otp.WrapStoreError("PruneReplay", "", err)
+}
+
+// Add implements otp.AttemptStore.
+func (s *Store) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	now := s.clock()
+
+	var n int
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.pruneAttempts, id, s.timeArg(now.Add(-window))); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.addAttempt, id, s.timeArg(now)); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, s.dialect.countAttempts, id).Scan(&n)
+	})
+	if err != nil {
+		return 0, otp.WrapStoreError("Add", id, err)
+	}
+	return n, nil
+}
+
+// Reset implements otp.AttemptStore.
+func (s *Store) Reset(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.resetAttempts, id)
+	return otp.WrapStoreError("Reset", id, err)
+}
+
+// tx runs fn in a transaction, committed when fn succeeds.
+func (s *Store) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// timeArg returns t as a query argument of the dialect.
+func (s *Store) timeArg(t time.Time) interface{} {
+	if s.dialect.timeValue != nil {
+		return s.dialect.timeValue(t)
+	}
+	return t
+}
+
+// clock returns the current time.
+func (s *Store) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
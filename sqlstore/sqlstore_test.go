@@ -0,0 +1,173 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// dialects are checked by every test.
+var dialects = []*Dialect{Postgres, MySQL, SQLite}
+
+func TestMigrate(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.String(), func(t *testing.T) {
+			db := &fakeDB{respond: func(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+				if query == d.appliedVersions {
+					return [][]driver.Value{{int64(1)}}, 0, nil
+				}
+				return nil, 0, nil
+			}}
+			require.NoError(t, New(db.open(), d).Migrate(context.Background()))
+			require.Len(t, db.statements(), 2, "applied migrations are skipped")
+
+			db = &fakeDB{}
+			require.NoError(t, New(db.open(), d).Migrate(context.Background()))
+			require.Len(t, db.statements(), 2+len(d.migrations[0].statements)+3)
+
+			for i, m := range d.migrations {
+				require.Equal(t, i+1, m.version, "versions are consecutive")
+			}
+		})
+	}
+}
+
+func TestMySQLMigrationsIdempotent(t *testing.T) {
+	// MySQL cannot roll back DDL, so a failed migration is rerun as is.
+	for _, m := range MySQL.migrations {
+		for _, stmt := range m.statements {
+			require.Contains(t, stmt, "IF NOT EXISTS", "migration %d", m.version)
+		}
+	}
+}
+
+func TestNextLocksCounter(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.String(), func(t *testing.T) {
+			db := &fakeDB{respond: func(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+				if strings.HasPrefix(query, "SELECT") {
+					return [][]driver.Value{{int64(41)}}, 0, nil
+				}
+				return nil, 1, nil
+			}}
+
+			c, err := New(db.open(), d).Next(context.Background(), "SnakeOil:alice")
+			require.NoError(t, err)
+			require.Equal(t, uint64(41), c)
+
+			log := db.statements()
+			require.Equal(t, "BEGIN", log[0])
+			require.Equal(t, fold(d.lockCounter), log[2])
+			require.Equal(t, "COMMIT", log[len(log)-1])
+		})
+	}
+}
+
+func TestUseReplay(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.String(), func(t *testing.T) {
+			var affected int64 = 1
+			db := &fakeDB{respond: func(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+				return nil, affected, nil
+			}}
+			s := New(db.open(), d)
+
+			ok, err := s.Use(context.Background(), "SnakeOil:alice", 7)
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			affected = 0
+			ok, err = s.Use(context.Background(), "SnakeOil:alice", 7)
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.String(), func(t *testing.T) {
+			stored := map[string]string{}
+			db := &fakeDB{respond: func(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+				switch {
+				case strings.HasPrefix(query, "INSERT INTO otp_keys"):
+					stored[args[0].(string)] = args[1].(string)
+				case strings.HasPrefix(query, "SELECT url FROM otp_keys"):
+					if u, ok := stored[args[0].(string)]; ok {
+						return [][]driver.Value{{u}}, 0, nil
+					}
+				}
+				return nil, 1, nil
+			}}
+			s := New(db.open(), d)
+			ctx := context.Background()
+
+			_, err := s.Get(ctx, "SnakeOil:alice")
+			require.True(t, errors.Is(err, otp.ErrKeyNotFound))
+
+			key, err := otp.NewKeyFromURL("otpauth://totp/SnakeOil:alice?secret=JBSWY3DPEHPK3PXP&issuer=SnakeOil&meta-team=core")
+			require.NoError(t, err)
+			require.NoError(t, s.Put(ctx, "SnakeOil:alice", key))
+
+			got, err := s.Get(ctx, "SnakeOil:alice")
+			require.NoError(t, err)
+			require.Equal(t, key.Secret(), got.Secret())
+			require.Equal(t, key.Metadata(), got.Metadata())
+		})
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	for _, d := range dialects {
+		t.Run(d.String(), func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			var cutoff time.Time
+			db := &fakeDB{respond: func(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+				switch {
+				case strings.HasPrefix(query, "SELECT COUNT"):
+					return [][]driver.Value{{int64(3)}}, 0, nil
+				case strings.HasPrefix(query, "DELETE") && len(args) == 2:
+					cutoff = toTime(args[1])
+				}
+				return nil, 1, nil
+			}}
+			s := New(db.open(), d)
+			s.now = func() time.Time { return now }
+
+			n, err := s.Add(context.Background(), "SnakeOil:alice", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, 3, n)
+			require.True(t, cutoff.Equal(now.Add(-time.Minute)))
+		})
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	db := &fakeDB{respond: func(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+		return nil, 0, errors.New("connection reset")
+	}}
+	s := New(db.open(), Postgres)
+
+	_, err := s.Next(context.Background(), "SnakeOil:alice")
+	require.True(t, errors.Is(err, otp.ErrStore))
+	require.Equal(t, "ROLLBACK", db.statements()[len(db.statements())-1])
+}
+
+// fold folds whitespace like the statement log of fakeDB.
+func fold(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// toTime converts a time argument of any dialect back to a time.
+func toTime(v driver.Value) time.Time {
+	if ns, ok := v.(int64); ok {
+		return time.Unix(0, ns)
+	}
+	return v.(time.Time)
+}
@@ -0,0 +1,244 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The stores below are the contract shared by the store adapters and the
+// subsystems using them. Every method takes a context, which
+// implementations must honor for cancellation and deadlines.
+//
+// Implementations report a missing entry with an error matching
+// ErrKeyNotFound, and a failure of the backend itself with a *StoreError.
+
+// No key is stored under the requested id.
+var ErrKeyNotFound = errors.New("Key not found")
+
+// A store operation failed. Every StoreError matches it with errors.Is.
+var ErrStore = errors.New("Store operation failed")
+
+// StoreError records a failed store operation and its cause.
+type StoreError struct {
+	// Op is the operation, eg "Get" or "Next".
+	Op string
+	// ID of the entry the operation was for.
+	ID string
+	// Err is the error reported by the backend.
+	Err error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("%v: %s %q: %v", ErrStore, e.Op, e.ID, e.Err)
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrStore.
+func (e *StoreError) Is(target error) bool {
+	return target == ErrStore
+}
+
+// WrapStoreError prepares an error returned by a store for the callers of
+// a store-backed operation, so they can tell a failing store from a
+// rejected passcode. Context errors, such as an exceeded deadline, and
+// errors matching ErrKeyNotFound or ErrStore are returned as is; any other
+// error is wrapped in a StoreError for op and id. A nil err returns nil.
+func WrapStoreError(op, id string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.Is(err, ErrKeyNotFound), errors.Is(err, ErrStore):
+		return err
+	}
+	return &StoreError{Op: op, ID: id, Err: err}
+}
+
+// KeyStore persists enrolled keys by id.
+type KeyStore interface {
+	// Get returns the key stored under id.
+	Get(ctx context.Context, id string) (*Key, error)
+	// Put stores key under id, replacing any previous key.
+	Put(ctx context.Context, id string, key *Key) error
+	// Delete removes the key stored under id. Deleting a missing key is
+	// not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// CounterStore persists the counters of HOTP keys, so each counter value is
+// consumed exactly once even across processes.
+type CounterStore interface {
+	// Next atomically consumes and returns the counter of the key with id,
+	// leaving the next value in the store. Unknown ids start at 0.
+	Next(ctx context.Context, id string) (uint64, error)
+}
+
+// ReplayStore records accepted passcodes, so none is accepted twice.
+type ReplayStore interface {
+	// Use marks counter as used for id and reports whether this is the
+	// first use.
+	Use(ctx context.Context, id string, counter uint64) (bool, error)
+}
+
+// AttemptStore counts validation attempts, for rate limiting.
+type AttemptStore interface {
+	// Add records an attempt for id and returns the number of attempts
+	// within the last window, including this one.
+	Add(ctx context.Context, id string, window time.Duration) (int, error)
+	// Reset forgets the attempts of id, eg after a successful validation.
+	Reset(ctx context.Context, id string) error
+}
+
+// KeyWrapper encrypts key secrets at rest, eg with a key held in a KMS,
+// so stores never hold them in plain text.
+//
+// The aad passed to both methods is authenticated but not encrypted,
+// usually the ID of the record holding the secret: a wrapped secret only
+// unwraps with the aad it was wrapped with, so it cannot be moved to
+// another record.
+type KeyWrapper interface {
+	// Wrap encrypts the raw secret of a key, binding it to aad.
+	Wrap(ctx context.Context, secret, aad []byte) ([]byte, error)
+	// Unwrap decrypts a secret encrypted by Wrap with the same aad.
+	Unwrap(ctx context.Context, wrapped, aad []byte) ([]byte, error)
+}
+
+// MemoryKeyStore is a KeyStore held in memory, suitable for tests.
+// The zero value is ready to use.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+// Get implements KeyStore. The returned Key is a copy.
+func (s *MemoryKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	u, ok := s.keys[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return NewKeyFromURL(u)
+}
+
+// Put implements KeyStore. The key is kept with its metadata, as by the
+// stores keeping MetadataURL.
+func (s *MemoryKeyStore) Put(ctx context.Context, id string, key *Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys == nil {
+		s.keys = map[string]string{}
+	}
+	s.keys[id] = key.MetadataURL()
+
+	return nil
+}
+
+// Delete implements KeyStore.
+func (s *MemoryKeyStore) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.keys, id)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// MemoryCounterStore is a CounterStore held in memory, suitable for tests
+// and single process soft tokens. The zero value is ready to use.
+type MemoryCounterStore struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// Next implements CounterStore.
+func (s *MemoryCounterStore) Next(ctx context.Context, id string) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counters == nil {
+		s.counters = map[string]uint64{}
+	}
+	c := s.counters[id]
+	s.counters[id] = c + 1
+
+	return c, nil
+}
+
+// MemoryAttemptStore is an AttemptStore held in memory, suitable for tests
+// and single process services. The zero value is ready to use.
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+}
+
+// Add implements AttemptStore.
+func (s *MemoryAttemptStore) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attempts == nil {
+		s.attempts = map[string][]time.Time{}
+	}
+
+	// Drop the attempts that left the window.
+	kept := s.attempts[id][:0]
+	for _, at := range s.attempts[id] {
+		if t.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	kept = append(kept, t)
+	s.attempts[id] = kept
+
+	return len(kept), nil
+}
+
+// Reset implements AttemptStore.
+func (s *MemoryAttemptStore) Reset(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.attempts, id)
+	s.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package otp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachingKeyStore is a read-through KeyStore decorator keeping recently
+// used keys in memory, so authentication paths looking up the same hot
+// keys do not reach the underlying store on every login.
+//
+// The cache holds at most size keys, evicting the least recently used
+// one, and forgets keys after ttl. Concurrent misses for the same id share
+// a single lookup. Put and Delete go through to the underlying store and
+// invalidate the cached key; changes made to the store by other processes
+// are seen after at most ttl.
+// A CachingKeyStore is safe for concurrent use.
+type CachingKeyStore struct {
+	store KeyStore
+	size  int
+	ttl   time.Duration
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inflight map[string]*cacheCall
+	// gen counts invalidations, so a lookup started before one does not
+	// cache a stale key.
+	gen uint64
+}
+
+type cacheEntry struct {
+	id      string
+	key     *Key
+	expires time.Time
+}
+
+// cacheCall is a lookup shared by concurrent misses.
+type cacheCall struct {
+	done chan struct{}
+	key  *Key
+	err  error
+}
+
+// NewCachingKeyStore returns a KeyStore caching up to size keys of store
+// for ttl.
+func NewCachingKeyStore(store KeyStore, size int, ttl time.Duration) *CachingKeyStore {
+	return &CachingKeyStore{
+		store:    store,
+		size:     size,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		inflight: map[string]*cacheCall{},
+	}
+}
+
+// Get implements KeyStore. The returned Key is a copy.
+func (s *CachingKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if e, ok := s.entries[id]; ok {
+		ce := e.Value.(*cacheEntry)
+		if s.clock().Before(ce.expires) {
+			s.lru.MoveToFront(e)
+			s.mu.Unlock()
+			return ce.key.Clone(), nil
+		}
+		s.remove(e)
+	}
+
+	if c, ok := s.inflight[id]; ok {
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// The lookup of another caller was canceled; make our own.
+		if isContextErr(c.err) {
+			return s.Get(ctx, id)
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		return c.key.Clone(), nil
+	}
+
+	c := &cacheCall{done: make(chan struct{})}
+	s.inflight[id] = c
+	gen := s.gen
+	s.mu.Unlock()
+
+	c.key, c.err = s.store.Get(ctx, id)
+
+	s.mu.Lock()
+	delete(s.inflight, id)
+	if c.err == nil && gen == s.gen {
+		s.add(id, c.key)
+	}
+	s.mu.Unlock()
+	close(c.done)
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.key.Clone(), nil
+}
+
+// Put implements KeyStore.
+func (s *CachingKeyStore) Put(ctx context.Context, id string, key *Key) error {
+	err := s.store.Put(ctx, id, key)
+	s.invalidate(id)
+	return err
+}
+
+// Delete implements KeyStore.
+func (s *CachingKeyStore) Delete(ctx context.Context, id string) error {
+	err := s.store.Delete(ctx, id)
+	s.invalidate(id)
+	return err
+}
+
+// invalidate forgets the cached key of id.
+func (s *CachingKeyStore) invalidate(id string) {
+	s.mu.Lock()
+	s.gen++
+	if e, ok := s.entries[id]; ok {
+		s.remove(e)
+	}
+	s.mu.Unlock()
+}
+
+// add caches key under id, evicting the least recently used keys beyond
+// size. The caller holds mu.
+func (s *CachingKeyStore) add(id string, key *Key) {
+	if s.size <= 0 {
+		return
+	}
+	if e, ok := s.entries[id]; ok {
+		s.remove(e)
+	}
+	s.entries[id] = s.lru.PushFront(&cacheEntry{id: id, key: key, expires: s.clock().Add(s.ttl)})
+	for s.lru.Len() > s.size {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove drops a cached key. The caller holds mu.
+func (s *CachingKeyStore) remove(e *list.Element) {
+	s.lru.Remove(e)
+	delete(s.entries, e.Value.(*cacheEntry).id)
+}
+
+// clock returns the current time.
+func (s *CachingKeyStore) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
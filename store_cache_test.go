@@ -0,0 +1,114 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingKeyStore counts the lookups reaching a KeyStore.
+type countingKeyStore struct {
+	MemoryKeyStore
+	gets  int32
+	block chan struct{}
+}
+
+func (s *countingKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	atomic.AddInt32(&s.gets, 1)
+	if s.block != nil {
+		<-s.block
+	}
+	return s.MemoryKeyStore.Get(ctx, id)
+}
+
+func TestCachingKeyStore(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingKeyStore{}
+	s := NewCachingKeyStore(backend, 2, time.Minute)
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	for _, id := range []string{"alice", "bob", "carol"} {
+		k, err := NewKeyFromURL("otpauth://totp/Example:" + id + "?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+		require.NoError(t, err)
+		require.NoError(t, backend.Put(ctx, id, k))
+	}
+
+	for i := 0; i < 3; i++ {
+		k, err := s.Get(ctx, "alice")
+		require.NoError(t, err)
+		require.Equal(t, "alice", k.AccountName())
+	}
+	require.Equal(t, int32(1), backend.gets)
+
+	// Callers cannot change the cached key.
+	k, _ := s.Get(ctx, "alice")
+	k.SetAccountName("mallory")
+	k, _ = s.Get(ctx, "alice")
+	require.Equal(t, "alice", k.AccountName())
+
+	// The least recently used key is evicted beyond the size.
+	s.Get(ctx, "bob")
+	s.Get(ctx, "carol")
+	s.Get(ctx, "alice")
+	require.Equal(t, int32(4), backend.gets)
+
+	// Keys expire after the ttl.
+	now = now.Add(2 * time.Minute)
+	s.Get(ctx, "alice")
+	require.Equal(t, int32(5), backend.gets)
+
+	// Put invalidates.
+	k, _ = s.Get(ctx, "alice")
+	k.SetIssuer("Other")
+	require.NoError(t, s.Put(ctx, "alice", k))
+	k, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "Other", k.Issuer())
+
+	require.NoError(t, s.Delete(ctx, "alice"))
+	_, err = s.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestCachingKeyStoreSingleflight(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingKeyStore{block: make(chan struct{})}
+	s := NewCachingKeyStore(backend, 10, time.Minute)
+
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, backend.Put(ctx, "alice", k))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k, err := s.Get(ctx, "alice")
+			require.NoError(t, err)
+			require.Equal(t, "alice", k.AccountName())
+		}()
+	}
+
+	// Let the callers pile up on the blocked lookup.
+	for {
+		s.mu.Lock()
+		_, ok := s.inflight["alice"]
+		s.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(backend.block)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&backend.gets))
+}
@@ -0,0 +1,60 @@
+package otp
+
+import (
+	"context"
+)
+
+// EncryptedKeyStore is a KeyStore decorator sealing keys with a KeyWrapper
+// before they reach the underlying store, so encryption at rest composes
+// with any backend. The stored keys are sealed by Key.Seal for the ID they
+// are stored under: they keep their other parameters in the clear, are
+// marked with SealedParam so they cannot be used as is, and their secret
+// cannot be moved to another ID.
+//
+// Every key of the underlying store must be written through the
+// decorator, as Get unseals all of them.
+type EncryptedKeyStore struct {
+	store   KeyStore
+	wrapper KeyWrapper
+}
+
+// NewEncryptedKeyStore returns a KeyStore keeping keys in store with their
+// secrets encrypted by wrapper.
+func NewEncryptedKeyStore(store KeyStore, wrapper KeyWrapper) *EncryptedKeyStore {
+	return &EncryptedKeyStore{store: store, wrapper: wrapper}
+}
+
+// Get implements KeyStore.
+func (s *EncryptedKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = key.Unseal(ctx, s.wrapper, id)
+	if err != nil {
+		return nil, WrapStoreError("Get", id, err)
+	}
+	return key, nil
+}
+
+// Put implements KeyStore.
+func (s *EncryptedKeyStore) Put(ctx context.Context, id string, key *Key) error {
+	if key.Sealed() {
+		return ErrKeySealed
+	}
+	if _, err := DecodeSecret(key.Secret()); err != nil {
+		return err
+	}
+	sealed, err := key.Seal(ctx, s.wrapper, id)
+	if err != nil {
+		return WrapStoreError("Put", id, err)
+	}
+
+	return s.store.Put(ctx, id, sealed)
+}
+
+// Delete implements KeyStore.
+func (s *EncryptedKeyStore) Delete(ctx context.Context, id string) error {
+	return s.store.Delete(ctx, id)
+}
@@ -0,0 +1,96 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// xorWrapper is a KeyWrapper for tests only.
+type xorWrapper struct {
+	err error
+}
+
+func (w xorWrapper) Wrap(ctx context.Context, secret, aad []byte) ([]byte, error) {
+	return w.xor(secret)
+}
+
+func (w xorWrapper) Unwrap(ctx context.Context, wrapped, aad []byte) ([]byte, error) {
+	return w.xor(wrapped)
+}
+
+func (w xorWrapper) xor(b []byte) ([]byte, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ 0x5a
+	}
+	return out, nil
+}
+
+func TestEncryptedKeyStore(t *testing.T) {
+	ctx := context.Background()
+	var backend MemoryKeyStore
+	s := NewEncryptedKeyStore(&backend, xorWrapper{})
+
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&digits=8")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "alice", k))
+
+	stored, err := backend.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.NotEqual(t, k.Secret(), stored.Secret())
+	require.Equal(t, DigitsEight, stored.Digits())
+	require.True(t, stored.Sealed())
+	_, err = stored.Validate("12345678", time.Now())
+	require.Equal(t, ErrKeySealed, err, "stored keys cannot validate against the wrapped secret")
+
+	got, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, k.Secret(), got.Secret())
+	require.Equal(t, k.AccountName(), got.AccountName())
+
+	_, err = s.Get(ctx, "bob")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+
+	s = NewEncryptedKeyStore(&backend, xorWrapper{err: errors.New("KMS unavailable")})
+	_, err = s.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrStore))
+
+	require.NoError(t, s.Delete(ctx, "alice"))
+	_, err = backend.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestEncryptedKeyStoreBindsID(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewAESWrapper(KEK{ID: "k1", Key: make([]byte, 32)})
+	require.NoError(t, err)
+	var backend MemoryKeyStore
+	s := NewEncryptedKeyStore(&backend, w)
+
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "alice", k))
+
+	// A sealed secret copied to another record does not unseal there.
+	stored, err := backend.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.NoError(t, backend.Put(ctx, "mallory", stored))
+	_, err = s.Get(ctx, "mallory")
+	require.True(t, errors.Is(err, ErrStore))
+
+	// Keys written around the decorator are refused.
+	require.NoError(t, backend.Put(ctx, "bob", k))
+	_, err = s.Get(ctx, "bob")
+	require.True(t, errors.Is(err, ErrKeyNotSealed))
+
+	sealed, err := k.Seal(ctx, w, "alice")
+	require.NoError(t, err)
+	require.Equal(t, ErrKeySealed, s.Put(ctx, "alice", sealed))
+}
@@ -0,0 +1,106 @@
+package otp
+
+import (
+	"context"
+	"time"
+)
+
+// StoreObserver receives the latency and outcome of every store operation,
+// eg to feed a histogram and an error counter per op, so operators can
+// tell whether slow validation comes from the store. op is the method,
+// eg "Get" or "Next". A missing key is reported with an err matching
+// ErrKeyNotFound, which observers usually do not count as a failure.
+type StoreObserver func(op string, elapsed time.Duration, err error)
+
+// ObserveKeyStore returns a KeyStore reporting the operations of store to
+// obs.
+func ObserveKeyStore(store KeyStore, obs StoreObserver) KeyStore {
+	return &observedKeyStore{store: store, obs: obs}
+}
+
+// ObserveCounterStore returns a CounterStore reporting the operations of
+// store to obs.
+func ObserveCounterStore(store CounterStore, obs StoreObserver) CounterStore {
+	return &observedCounterStore{store: store, obs: obs}
+}
+
+// ObserveReplayStore returns a ReplayStore reporting the operations of
+// store to obs.
+func ObserveReplayStore(store ReplayStore, obs StoreObserver) ReplayStore {
+	return &observedReplayStore{store: store, obs: obs}
+}
+
+// ObserveAttemptStore returns an AttemptStore reporting the operations of
+// store to obs.
+func ObserveAttemptStore(store AttemptStore, obs StoreObserver) AttemptStore {
+	return &observedAttemptStore{store: store, obs: obs}
+}
+
+type observedKeyStore struct {
+	store KeyStore
+	obs   StoreObserver
+}
+
+func (s *observedKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	start := time.Now()
+	key, err := s.store.Get(ctx, id)
+	s.obs("Get", time.Since(start), err)
+	return key, err
+}
+
+func (s *observedKeyStore) Put(ctx context.Context, id string, key *Key) error {
+	start := time.Now()
+	err := s.store.Put(ctx, id, key)
+	s.obs("Put", time.Since(start), err)
+	return err
+}
+
+func (s *observedKeyStore) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.store.Delete(ctx, id)
+	s.obs("Delete", time.Since(start), err)
+	return err
+}
+
+type observedCounterStore struct {
+	store CounterStore
+	obs   StoreObserver
+}
+
+func (s *observedCounterStore) Next(ctx context.Context, id string) (uint64, error) {
+	start := time.Now()
+	c, err := s.store.Next(ctx, id)
+	s.obs("Next", time.Since(start), err)
+	return c, err
+}
+
+type observedReplayStore struct {
+	store ReplayStore
+	obs   StoreObserver
+}
+
+func (s *observedReplayStore) Use(ctx context.Context, id string, counter uint64) (bool, error) {
+	start := time.Now()
+	ok, err := s.store.Use(ctx, id, counter)
+	s.obs("Use", time.Since(start), err)
+	return ok, err
+}
+
+type observedAttemptStore struct {
+	store AttemptStore
+	obs   StoreObserver
+}
+
+func (s *observedAttemptStore) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	start := time.Now()
+	n, err := s.store.Add(ctx, id, window)
+	s.obs("Add", time.Since(start), err)
+	return n, err
+}
+
+func (s *observedAttemptStore) Reset(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.store.Reset(ctx, id)
+	s.obs("Reset", time.Since(start), err)
+	return err
+}
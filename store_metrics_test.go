@@ -0,0 +1,52 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveStores(t *testing.T) {
+	ctx := context.Background()
+
+	type observation struct {
+		op  string
+		err error
+	}
+	var seen []observation
+	obs := func(op string, elapsed time.Duration, err error) {
+		require.True(t, elapsed >= 0)
+		seen = append(seen, observation{op, err})
+	}
+
+	keys := ObserveKeyStore(&MemoryKeyStore{}, obs)
+	_, err := keys.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, keys.Put(ctx, "alice", k))
+	require.NoError(t, keys.Delete(ctx, "alice"))
+
+	counters := ObserveCounterStore(&MemoryCounterStore{}, obs)
+	c, err := counters.Next(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), c)
+
+	attempts := ObserveAttemptStore(&MemoryAttemptStore{}, obs)
+	n, err := attempts.Add(ctx, "alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.NoError(t, attempts.Reset(ctx, "alice"))
+
+	require.Equal(t, []observation{
+		{"Get", ErrKeyNotFound},
+		{"Put", nil},
+		{"Delete", nil},
+		{"Next", nil},
+		{"Add", nil},
+		{"Reset", nil},
+	}, seen)
+}
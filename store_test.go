@@ -0,0 +1,91 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryKeyStore(t *testing.T) {
+	ctx := context.Background()
+	var s MemoryKeyStore
+
+	_, err := s.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "alice", k))
+
+	got, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, k.String(), got.String())
+
+	got.SetSecret("AAAAAAAA")
+	again, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", again.Secret(), "stored keys are copies")
+
+	require.NoError(t, s.Delete(ctx, "alice"))
+	require.NoError(t, s.Delete(ctx, "alice"))
+	_, err = s.Get(ctx, "alice")
+	require.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestMemoryKeyStoreMetadata(t *testing.T) {
+	ctx := context.Background()
+	var s MemoryKeyStore
+
+	k, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	k.SetMetadata("device", "phone")
+	require.NoError(t, s.Put(ctx, "alice", k))
+
+	got, err := s.Get(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"device": "phone"}, got.Metadata())
+	require.Equal(t, k.String(), got.String())
+}
+
+func TestMemoryAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	s := MemoryAttemptStore{now: func() time.Time { return now }}
+
+	for want := 1; want <= 3; want++ {
+		n, err := s.Add(ctx, "alice", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, want, n)
+	}
+
+	now = now.Add(time.Minute)
+	n, err := s.Add(ctx, "alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "older attempts left the window")
+
+	require.NoError(t, s.Reset(ctx, "alice"))
+	n, err = s.Add(ctx, "alice", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestStoreError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := error(&StoreError{Op: "Get", ID: "alice", Err: cause})
+
+	require.True(t, errors.Is(err, ErrStore))
+	require.True(t, errors.Is(err, cause))
+	require.Equal(t, `Store operation failed: Get "alice": connection refused`, err.Error())
+}
+
+func TestWrapStoreError(t *testing.T) {
+	require.NoError(t, WrapStoreError("Get", "alice", nil))
+	require.Equal(t, context.Canceled, WrapStoreError("Get", "alice", context.Canceled))
+	require.Equal(t, ErrKeyNotFound, WrapStoreError("Get", "alice", ErrKeyNotFound))
+
+	storeErr := &StoreError{Op: "Get", ID: "alice", Err: errors.New("timeout")}
+	require.Equal(t, error(storeErr), WrapStoreError("Put", "bob", storeErr), "not wrapped twice")
+}
@@ -0,0 +1,179 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyUsage is what a UsageKeyStore knows about the use of one key.
+type KeyUsage struct {
+	// Enrolled is when the key was last stored, zero if it was stored
+	// before the UsageKeyStore was created.
+	Enrolled time.Time
+	// LastUsed is the time of the last accepted passcode, zero if none
+	// was accepted yet.
+	LastUsed time.Time
+	// Successes and Failures count the accepted and rejected passcodes.
+	Successes uint64
+	Failures  uint64
+	// ConsecutiveFailures counts the passcodes rejected since the last
+	// accepted one.
+	ConsecutiveFailures uint64
+	// LastDrift is the drift of the last accepted passcode in periods,
+	// eg -1 when the device clock is one period behind. Always 0 for HOTP
+	// keys.
+	LastDrift int
+}
+
+// UsageKeyStore is a KeyStore decorator keeping usage statistics of each
+// key, so admins can spot stale enrollments and struggling users.
+// Passcodes must be checked with its Validate for the statistics to be
+// kept. Statistics are held in memory and are forgotten when the key is
+// deleted.
+// A UsageKeyStore is safe for concurrent use.
+type UsageKeyStore struct {
+	store KeyStore
+	// now returns the current time, time.Now when nil.
+	now func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]*KeyUsage
+}
+
+// NewUsageKeyStore returns a KeyStore keeping the usage statistics of the
+// keys of store.
+func NewUsageKeyStore(store KeyStore) *UsageKeyStore {
+	return &UsageKeyStore{store: store, usage: map[string]*KeyUsage{}}
+}
+
+// Get implements KeyStore.
+func (s *UsageKeyStore) Get(ctx context.Context, id string) (*Key, error) {
+	return s.store.Get(ctx, id)
+}
+
+// Put implements KeyStore. Storing a new key under an id already known
+// keeps its statistics but updates Enrolled.
+func (s *UsageKeyStore) Put(ctx context.Context, id string, key *Key) error {
+	if err := s.store.Put(ctx, id, key); err != nil {
+		return err
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+
+	s.mu.Lock()
+	s.entry(id).Enrolled = now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Delete implements KeyStore.
+func (s *UsageKeyStore) Delete(ctx context.Context, id string) error {
+	if err := s.store.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.usage, id)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Validate checks passcode at t against the key stored under id with
+// Key.Validate, and records the outcome. Store errors and expired or
+// invalid keys are returned without being counted; a passcode of the
+// wrong length counts as a failure.
+func (s *UsageKeyStore) Validate(ctx context.Context, id, passcode string, t time.Time) (bool, error) {
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	_, drift, ok, err := key.match(passcode, t)
+	if err != nil && !errors.Is(err, ErrValidateInputInvalidLength) {
+		return false, err
+	}
+
+	s.mu.Lock()
+	u := s.entry(id)
+	if ok {
+		u.LastUsed = t
+		u.Successes++
+		u.ConsecutiveFailures = 0
+		u.LastDrift = drift
+	} else {
+		u.Failures++
+		u.ConsecutiveFailures++
+	}
+	s.mu.Unlock()
+
+	return ok, err
+}
+
+// Usage returns the statistics of the key stored under id, and false if
+// the key was neither stored nor validated since the UsageKeyStore was
+// created.
+func (s *UsageKeyStore) Usage(id string) (KeyUsage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.usage[id]
+	if !ok {
+		return KeyUsage{}, false
+	}
+	return *u, true
+}
+
+// Stale returns the ids, sorted, of the keys without an accepted passcode
+// since before, including those never used.
+func (s *UsageKeyStore) Stale(before time.Time) []string {
+	return s.query(func(u *KeyUsage) bool {
+		return u.LastUsed.Before(before)
+	})
+}
+
+// Struggling returns the ids, sorted, of the keys with at least n
+// consecutive failures, or whose last accepted passcode drifted by at
+// least maxDrift periods. A maxDrift of 0 ignores drift.
+func (s *UsageKeyStore) Struggling(n uint64, maxDrift int) []string {
+	return s.query(func(u *KeyUsage) bool {
+		drift := u.LastDrift
+		if drift < 0 {
+			drift = -drift
+		}
+		return (n > 0 && u.ConsecutiveFailures >= n) || (maxDrift > 0 && drift >= maxDrift)
+	})
+}
+
+// query returns the sorted ids of the keys whose statistics match.
+func (s *UsageKeyStore) query(match func(u *KeyUsage) bool) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, u := range s.usage {
+		if match(u) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// entry returns the statistics of id, creating them if needed. s.mu must
+// be held.
+func (s *UsageKeyStore) entry(id string) *KeyUsage {
+	u, ok := s.usage[id]
+	if !ok {
+		u = &KeyUsage{}
+		s.usage[id] = u
+	}
+	return u
+}
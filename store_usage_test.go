@@ -0,0 +1,59 @@
+package otp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageKeyStore(t *testing.T) {
+	ctx := context.Background()
+	enrolled := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewUsageKeyStore(&MemoryKeyStore{})
+	s.now = func() time.Time { return enrolled }
+
+	key, err := NewKeyFromURL("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "alice", key))
+	require.NoError(t, s.Put(ctx, "bob", key))
+
+	u, ok := s.Usage("alice")
+	require.True(t, ok)
+	require.Equal(t, KeyUsage{Enrolled: enrolled}, u)
+
+	at := enrolled.Add(time.Hour)
+	code, err := key.GenerateCode(at.Add(-30 * time.Second))
+	require.NoError(t, err)
+	ok, err = s.Validate(ctx, "alice", code, at)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for i := 0; i < 3; i++ {
+		ok, err = s.Validate(ctx, "bob", "000000", at)
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+	_, err = s.Validate(ctx, "bob", "1", at)
+	require.Equal(t, ErrValidateInputInvalidLength, err)
+
+	u, _ = s.Usage("alice")
+	require.Equal(t, KeyUsage{Enrolled: enrolled, LastUsed: at, Successes: 1, LastDrift: -1}, u)
+	u, _ = s.Usage("bob")
+	require.Equal(t, KeyUsage{Enrolled: enrolled, Failures: 4, ConsecutiveFailures: 4}, u)
+
+	require.Equal(t, []string{"bob"}, s.Stale(at))
+	require.Equal(t, []string{"alice", "bob"}, s.Stale(at.Add(time.Second)))
+	require.Equal(t, []string{"bob"}, s.Struggling(3, 0))
+	require.Equal(t, []string{"alice", "bob"}, s.Struggling(3, 1))
+
+	_, err = s.Validate(ctx, "carol", code, at)
+	require.Equal(t, ErrKeyNotFound, err)
+	_, ok = s.Usage("carol")
+	require.False(t, ok)
+
+	require.NoError(t, s.Delete(ctx, "bob"))
+	_, ok = s.Usage("bob")
+	require.False(t, ok)
+}
@@ -0,0 +1,251 @@
+// Package testvectors exposes the test vectors of RFC 4226 and RFC 6238,
+// and a helper checking an implementation against them, so code wrapping
+// or reimplementing this library can verify it is still compatible.
+package testvectors
+
+import (
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// Secrets of the RFC vectors. RFC 6238 documents a single secret for every
+// algorithm, but per its errata each algorithm uses a secret as long as
+// its hash block output: https://www.rfc-editor.org/errata/eid2866
+var (
+	SecretSHA1   = []byte("12345678901234567890")
+	SecretSHA256 = []byte("12345678901234567890123456789012")
+	SecretSHA512 = []byte("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+// HOTP is a HOTP test vector.
+type HOTP struct {
+	Secret    []byte
+	Counter   uint64
+	Digits    otp.Digits
+	Algorithm otp.Algorithm
+	Code      string
+}
+
+// TOTP is a TOTP test vector.
+type TOTP struct {
+	Secret    []byte
+	Time      time.Time
+	Period    uint
+	Digits    otp.Digits
+	Algorithm otp.Algorithm
+	Code      string
+	// Epoch is the T0 periods are counted from, the Unix epoch when zero.
+	Epoch time.Time
+}
+
+// Counter returns the HOTP counter of the period of v.Time.
+func (v TOTP) Counter() uint64 {
+	return uint64(v.unix()) / uint64(v.Period)
+}
+
+// unix returns the seconds from the epoch of v to v.Time.
+func (v TOTP) unix() int64 {
+	if v.Epoch.IsZero() {
+		return v.Time.Unix()
+	}
+	return v.Time.Unix() - v.Epoch.Unix()
+}
+
+// Base32 returns the secret of v as used by the library, unpadded base32.
+func (v HOTP) Base32() string {
+	return encode(v.Secret)
+}
+
+// Base32 returns the secret of v as used by the library, unpadded base32.
+func (v TOTP) Base32() string {
+	return encode(v.Secret)
+}
+
+func (v HOTP) String() string {
+	return fmt.Sprintf("HOTP %s/%s counter %d", v.Algorithm, v.Digits, v.Counter)
+}
+
+func (v TOTP) String() string {
+	if !v.Epoch.IsZero() {
+		return fmt.Sprintf("TOTP %s/%s at %d t0 %d", v.Algorithm, v.Digits, v.Time.Unix(), v.Epoch.Unix())
+	}
+	return fmt.Sprintf("TOTP %s/%s at %d", v.Algorithm, v.Digits, v.Time.Unix())
+}
+
+func encode(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+// RFC4226 are the vectors of RFC 4226 appendix D.
+var RFC4226 = hotpVectors(SecretSHA1, otp.AlgorithmSHA1, otp.DigitsSix,
+	"755224", "287082", "359152", "969429", "338314",
+	"254676", "287922", "162583", "399871", "520489")
+
+// RFC6238 are the vectors of RFC 6238 appendix B, with 8 digits and a 30
+// second period, for SHA1, SHA256 and SHA512.
+var RFC6238 = []TOTP{
+	totpVector(59, otp.AlgorithmSHA1, "94287082"),
+	totpVector(59, otp.AlgorithmSHA256, "46119246"),
+	totpVector(59, otp.AlgorithmSHA512, "90693936"),
+	totpVector(1111111109, otp.AlgorithmSHA1, "07081804"),
+	totpVector(1111111109, otp.AlgorithmSHA256, "68084774"),
+	totpVector(1111111109, otp.AlgorithmSHA512, "25091201"),
+	totpVector(1111111111, otp.AlgorithmSHA1, "14050471"),
+	totpVector(1111111111, otp.AlgorithmSHA256, "67062674"),
+	totpVector(1111111111, otp.AlgorithmSHA512, "99943326"),
+	totpVector(1234567890, otp.AlgorithmSHA1, "89005924"),
+	totpVector(1234567890, otp.AlgorithmSHA256, "91819424"),
+	totpVector(1234567890, otp.AlgorithmSHA512, "93441116"),
+	totpVector(2000000000, otp.AlgorithmSHA1, "69279037"),
+	totpVector(2000000000, otp.AlgorithmSHA256, "90698825"),
+	totpVector(2000000000, otp.AlgorithmSHA512, "38618901"),
+	totpVector(20000000000, otp.AlgorithmSHA1, "65353130"),
+	totpVector(20000000000, otp.AlgorithmSHA256, "77737706"),
+	totpVector(20000000000, otp.AlgorithmSHA512, "47863826"),
+}
+
+// ExtendedTOTP are the RFC 6238 vectors with 6 digits, whose codes are
+// the last 6 digits of the 8 digit codes.
+var ExtendedTOTP = extendedTOTP()
+
+// ExtendedHOTP are the RFC 6238 vectors as HOTP vectors, covering 8 digit
+// codes and the SHA256 and SHA512 algorithms missing from RFC 4226.
+var ExtendedHOTP = extendedHOTP()
+
+func hotpVectors(secret []byte, algo otp.Algorithm, digits otp.Digits, codes ...string) []HOTP {
+	vs := make([]HOTP, len(codes))
+	for i, code := range codes {
+		vs[i] = HOTP{
+			Secret:    secret,
+			Counter:   uint64(i),
+			Digits:    digits,
+			Algorithm: algo,
+			Code:      code,
+		}
+	}
+	return vs
+}
+
+func totpVector(unix int64, algo otp.Algorithm, code string) TOTP {
+	secret := SecretSHA1
+	switch algo {
+	case otp.AlgorithmSHA256:
+		secret = SecretSHA256
+	case otp.AlgorithmSHA512:
+		secret = SecretSHA512
+	}
+	return TOTP{
+		Secret:    secret,
+		Time:      time.Unix(unix, 0).UTC(),
+		Period:    30,
+		Digits:    otp.DigitsEight,
+		Algorithm: algo,
+		Code:      code,
+	}
+}
+
+func extendedTOTP() []TOTP {
+	vs := make([]TOTP, len(RFC6238))
+	for i, v := range RFC6238 {
+		v.Digits = otp.DigitsSix
+		v.Code = v.Code[2:]
+		vs[i] = v
+	}
+	return vs
+}
+
+func extendedHOTP() []HOTP {
+	vs := make([]HOTP, len(RFC6238))
+	for i, v := range RFC6238 {
+		vs[i] = HOTP{
+			Secret:    v.Secret,
+			Counter:   v.Counter(),
+			Digits:    v.Digits,
+			Algorithm: v.Algorithm,
+			Code:      v.Code,
+		}
+	}
+	return vs
+}
+
+// HOTPFunc generates the HOTP code of a base32 secret.
+type HOTPFunc func(secret string, counter uint64, digits otp.Digits, algo otp.Algorithm) (string, error)
+
+// TOTPFunc generates the TOTP code of a base32 secret.
+type TOTPFunc func(secret string, t time.Time, period uint, digits otp.Digits, algo otp.Algorithm) (string, error)
+
+// Failure describes a vector an implementation got wrong.
+type Failure struct {
+	// Vector is the description of the vector.
+	Vector string
+	Want   string
+	Got    string
+	Err    error
+}
+
+func (f Failure) String() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s: %v", f.Vector, f.Err)
+	}
+	return fmt.Sprintf("%s: want %s, got %s", f.Vector, f.Want, f.Got)
+}
+
+// ConformanceError lists the vectors an implementation got wrong.
+type ConformanceError struct {
+	Failures []Failure
+}
+
+func (e *ConformanceError) Error() string {
+	lines := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		lines[i] = f.String()
+	}
+	return fmt.Sprintf("otp: %d test vectors failed: %s", len(e.Failures), strings.Join(lines, "; "))
+}
+
+// CheckHOTP runs fn on vectors, RFC4226 and ExtendedHOTP when none are
+// given, and returns a *ConformanceError listing the codes fn got wrong.
+func CheckHOTP(fn HOTPFunc, vectors ...HOTP) error {
+	if len(vectors) == 0 {
+		vectors = append(append([]HOTP{}, RFC4226...), ExtendedHOTP...)
+	}
+
+	var failures []Failure
+	for _, v := range vectors {
+		got, err := fn(v.Base32(), v.Counter, v.Digits, v.Algorithm)
+		if err != nil || got != v.Code {
+			failures = append(failures, Failure{Vector: v.String(), Want: v.Code, Got: got, Err: err})
+		}
+	}
+	return conformance(failures)
+}
+
+// CheckTOTP runs fn on vectors, RFC6238 and ExtendedTOTP when none are given,
+// and returns a *ConformanceError listing the codes fn got wrong. fn counts
+// periods from the Unix epoch: vectors with an Epoch are passed the time
+// elapsed since their T0, which falls in the same period.
+func CheckTOTP(fn TOTPFunc, vectors ...TOTP) error {
+	if len(vectors) == 0 {
+		vectors = append(append([]TOTP{}, RFC6238...), ExtendedTOTP...)
+	}
+
+	var failures []Failure
+	for _, v := range vectors {
+		got, err := fn(v.Base32(), time.Unix(v.unix(), 0).UTC(), v.Period, v.Digits, v.Algorithm)
+		if err != nil || got != v.Code {
+			failures = append(failures, Failure{Vector: v.String(), Want: v.Code, Got: got, Err: err})
+		}
+	}
+	return conformance(failures)
+}
+
+func conformance(failures []Failure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	return &ConformanceError{Failures: failures}
+}
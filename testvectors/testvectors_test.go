@@ -0,0 +1,59 @@
+package testvectors
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+func libHOTP(secret string, counter uint64, digits otp.Digits, algo otp.Algorithm) (string, error) {
+	return hotp.GenerateCodeCustom(secret, counter, hotp.ValidateOpts{Digits: digits, Algorithm: algo})
+}
+
+func libTOTP(secret string, t time.Time, period uint, digits otp.Digits, algo otp.Algorithm) (string, error) {
+	return totp.GenerateCodeCustom(secret, t, totp.ValidateOpts{Period: period, Digits: digits, Algorithm: algo})
+}
+
+func TestLibraryConforms(t *testing.T) {
+	require.NoError(t, CheckHOTP(libHOTP))
+	require.NoError(t, CheckTOTP(libTOTP))
+}
+
+func TestCheckReportsFailures(t *testing.T) {
+	// Ignoring the algorithm only gets the SHA1 vectors right.
+	sha1Only := func(secret string, t time.Time, period uint, digits otp.Digits, _ otp.Algorithm) (string, error) {
+		return libTOTP(secret, t, period, digits, otp.AlgorithmSHA1)
+	}
+	err := CheckTOTP(sha1Only, RFC6238...)
+	var cerr *ConformanceError
+	require.True(t, errors.As(err, &cerr))
+	require.Len(t, cerr.Failures, 12)
+	require.Equal(t, "TOTP SHA256/8 at 59", cerr.Failures[0].Vector)
+	require.Equal(t, "46119246", cerr.Failures[0].Want)
+
+	failing := func(string, uint64, otp.Digits, otp.Algorithm) (string, error) {
+		return "", errors.New("boom")
+	}
+	err = CheckHOTP(failing, RFC4226[0])
+	require.EqualError(t, err, "otp: 1 test vectors failed: HOTP SHA1/6 counter 0: boom")
+}
+
+func TestEpochVector(t *testing.T) {
+	v := RFC6238[0]
+	v.Epoch = time.Unix(15, 0)
+	v.Time = v.Time.Add(15 * time.Second)
+	require.Equal(t, uint64(1), v.Counter())
+	require.Equal(t, "TOTP SHA1/8 at 74 t0 15", v.String())
+	require.NoError(t, CheckTOTP(libTOTP, v))
+
+	key, err := otp.NewKeyFromURL("otpauth://totp/Example:alice?issuer=Example&digits=8&secret=" + v.Base32())
+	require.NoError(t, err)
+	code, err := key.Clone(otp.WithEpoch(v.Epoch)).GenerateCode(v.Time)
+	require.NoError(t, err)
+	require.Equal(t, v.Code, code)
+}
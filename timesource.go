@@ -0,0 +1,35 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeSource provides the current time from a reference trusted more than
+// the local clock, such as an NTP server.
+type TimeSource interface {
+	Now() (time.Time, error)
+}
+
+// The local clock diverges from the trusted TimeSource by more than the
+// allowed threshold.
+var ErrClockDrift = errors.New("Local clock diverges from trusted time")
+
+// DriftError records how far the local clock diverged from a trusted
+// TimeSource. It matches ErrClockDrift with errors.Is.
+type DriftError struct {
+	// Drift is the local time minus the trusted time.
+	Drift time.Duration
+	// Threshold is the largest drift that was allowed.
+	Threshold time.Duration
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%v: drift %v exceeds %v", ErrClockDrift, e.Drift, e.Threshold)
+}
+
+// Is reports whether target is ErrClockDrift.
+func (e *DriftError) Is(target error) bool {
+	return target == ErrClockDrift
+}
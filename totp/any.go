@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+// ValidateAny checks passcode against each of secrets at the current
+// time, eg the old and new secret of an account being migrated to a larger
+// secret, and returns the index of the first secret it matched, or -1.
+// Every counter of the skew window of every secret is compared and the
+// match is selected without branching, as by hotp.Generator.Match, so the
+// time taken does not reveal which secret matched.
+// All secrets are checked with the same options. Secrets that are not
+// valid base32 never match and, like with Validate, errors are discarded.
+func ValidateAny(passcode string, secrets []string, validateOpts ...ValidateOpt) (matchIndex int, ok bool) {
+	opts := newValidateOpts(validateOpts...)
+	matchIndex, ok, _ = opts.validateAny(passcode, secrets, opts.t)
+	return matchIndex, ok
+}
+
+// validateAny is validate for several secrets, using options that already
+// have their defaults.
+func (opts *ValidateOpts) validateAny(passcode string, secrets []string, t time.Time) (index int, ok bool, err error) {
+	if opts.recorder != nil {
+		defer func() {
+			for i, secret := range secrets {
+				opts.record(passcode, secret, t, ok && i == index, err)
+			}
+		}()
+	}
+
+	if err := opts.check(); err != nil {
+		return -1, false, err
+	}
+	if err := opts.clockGuard.check(); err != nil {
+		return -1, false, err
+	}
+
+	if err := opts.drift.check(opts.now); err != nil {
+		return -1, false, err
+	}
+
+	ctx := opts.storeContext()
+	for _, secret := range secrets {
+		if err := opts.rateLimit.attempt(ctx, secret); err != nil {
+			return -1, false, err
+		}
+	}
+
+	hotpOpts := opts.hotpOpts()
+	passcode = hotp.NormalizePasscode(passcode, hotpOpts)
+	if len(passcode) != opts.Digits.Length() {
+		return -1, false, otp.ErrValidateInputInvalidLength
+	}
+
+	compare := otp.CompareExact
+	if opts.Comparator != nil {
+		compare = opts.Comparator
+	}
+
+	code := []byte(passcode)
+	counters := opts.counters(nil, t)
+
+	var counter uint64
+	var found int
+	for i, secret := range secrets {
+		key, err := hotp.DecodeSecret(secret)
+		if err != nil {
+			continue
+		}
+
+		c, eq := hotp.NewGenerator(key, hotpOpts).ConstantTimeMatch(code, counters, compare)
+		// Keep only the first match, as hotp.Generator.Match does.
+		first := subtle.ConstantTimeSelect(found, 0, eq)
+		index = subtle.ConstantTimeSelect(first, i, index)
+		counter = counter&^-uint64(first) | c&-uint64(first)
+		found |= first
+	}
+	if found == 0 {
+		return -1, false, nil
+	}
+
+	if err := opts.use(ctx, secrets[index], counter); err != nil {
+		return -1, false, err
+	}
+	for _, secret := range secrets {
+		if err := opts.rateLimit.reset(ctx, secret); err != nil {
+			return -1, false, err
+		}
+	}
+	opts.observeMatch(counter, t)
+	return index, true, nil
+}
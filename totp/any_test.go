@@ -0,0 +1,51 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAny(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	old := "JBSWY3DPEHPK3PXP"
+	secrets := []string{old, secSha1}
+
+	code := MustGenerateCode(secSha1, WithTime(now))
+	i, ok := ValidateAny(code, secrets, WithTime(now))
+	require.True(t, ok)
+	require.Equal(t, 1, i)
+
+	code = MustGenerateCode(old, WithTime(now))
+	i, ok = ValidateAny(code, secrets, WithTime(now))
+	require.True(t, ok)
+	require.Equal(t, 0, i)
+
+	// Both secrets match: the first one is selected.
+	i, ok = ValidateAny(code, []string{"not base32!", old, old}, WithTime(now))
+	require.True(t, ok)
+	require.Equal(t, 1, i)
+
+	i, ok = ValidateAny(code, secrets, WithTime(now.Add(time.Hour)))
+	require.False(t, ok)
+	require.Equal(t, -1, i)
+
+	i, ok = ValidateAny(code, nil, WithTime(now))
+	require.False(t, ok)
+	require.Equal(t, -1, i)
+
+	i, ok = ValidateAny("12", secrets, WithTime(now))
+	require.False(t, ok)
+	require.Equal(t, -1, i)
+
+	var recs []ValidationRecord
+	_, ok = ValidateAny(code, secrets, WithTime(now),
+		WithRecorder(func(rec ValidationRecord) { recs = append(recs, rec) }))
+	require.True(t, ok)
+	require.Len(t, recs, 2)
+	require.True(t, recs[0].Valid)
+	require.False(t, recs[1].Valid)
+	require.Equal(t, otp.SecretFingerprint(secSha1), recs[1].Fingerprint)
+}
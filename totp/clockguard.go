@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// clockEpoch anchors the monotonic readings of readClock.
+var clockEpoch = time.Now()
+
+// readClock returns the wall clock and the monotonic time elapsed since
+// clockEpoch. It is replaced in tests.
+var readClock = func() (wall time.Time, mono time.Duration) {
+	t := time.Now()
+	return t.Round(0), t.Sub(clockEpoch)
+}
+
+// clockReading is a pair of readings taken by readClock.
+type clockReading struct {
+	wall time.Time
+	mono time.Duration
+}
+
+// clockGuardOpts configures wall clock jump detection.
+type clockGuardOpts struct {
+	threshold time.Duration
+	refuse    bool
+	hook      func(jump time.Duration)
+}
+
+// enabled reports whether any jump detection is configured.
+func (o clockGuardOpts) enabled() bool {
+	return o.refuse || o.hook != nil
+}
+
+// check fails when jump detection is configured, as it keeps state between
+// calls that only a Validator holds.
+func (o clockGuardOpts) check() error {
+	if !o.enabled() {
+		return nil
+	}
+	return &otp.OptionError{Name: "ClockJumpLimit", Value: o.threshold, Err: otp.ErrInvalidOption}
+}
+
+// clockGuard detects the wall clock stepping backwards between calls by
+// comparing the wall and monotonic time elapsed since the previous call.
+type clockGuard struct {
+	clockGuardOpts
+	// mu orders the readings, so every jump is measured between two
+	// consecutive calls and reported once.
+	mu   sync.Mutex
+	last clockReading
+	seen bool
+}
+
+// observe records the current time and reports a backwards jump of the
+// wall clock larger than the threshold since the previous call. It only
+// returns an error when the guard refuses validation on a jump.
+func (g *clockGuard) observe() error {
+	g.mu.Lock()
+	var r clockReading
+	r.wall, r.mono = readClock()
+	last, seen := g.last, g.seen
+	g.last, g.seen = r, true
+	g.mu.Unlock()
+
+	if !seen {
+		return nil
+	}
+
+	jump := (r.mono - last.mono) - r.wall.Sub(last.wall)
+	if jump <= g.threshold {
+		return nil
+	}
+
+	if g.hook != nil {
+		g.hook(jump)
+	}
+	if g.refuse {
+		return otp.ErrValidateClockJumped
+	}
+	return nil
+}
+
+// WithClockJumpLimit makes a Validator refuse to validate, returning
+// otp.ErrValidateClockJumped, when the wall clock stepped backwards by more
+// than threshold since its previous call. This protects replay assumptions
+// from clock manipulation. Detection keeps state between calls, so the
+// option is only accepted by NewValidator; the other validation functions
+// fail with otp.ErrInvalidOption.
+func WithClockJumpLimit(threshold time.Duration) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.clockGuard.threshold = threshold
+		opt.clockGuard.refuse = true
+	}
+}
+
+// WithClockJumpHook makes a Validator call fn with the size of the jump when
+// the wall clock stepped backwards by more than threshold since its
+// previous call. Validation proceeds unless WithClockJumpLimit is also set.
+// Like WithClockJumpLimit, it is only accepted by NewValidator.
+func WithClockJumpHook(threshold time.Duration, fn func(jump time.Duration)) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.clockGuard.threshold = threshold
+		opt.clockGuard.hook = fn
+	}
+}
@@ -0,0 +1,105 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClockJump(t *testing.T) {
+	orig := readClock
+	defer func() { readClock = orig }()
+
+	wall := time.Unix(1000, 0)
+	var mono time.Duration
+	readClock = func() (time.Time, time.Duration) {
+		return wall, mono
+	}
+	tick := func(monoStep, wallStep time.Duration) {
+		mono += monoStep
+		wall = wall.Add(wallStep)
+	}
+
+	var jumps []time.Duration
+	refusing := NewValidator(WithClockJumpLimit(5 * time.Second))
+	flagging := NewValidator(WithClockJumpHook(5*time.Second, func(jump time.Duration) {
+		jumps = append(jumps, jump)
+	}))
+
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(time.Unix(59, 0)))
+	require.NoError(t, err)
+
+	for _, v := range []*Validator{refusing, flagging} {
+		valid, err := v.Validate(code, secSha1, time.Unix(59, 0))
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+
+	// Small steps backwards are tolerated.
+	tick(time.Second, -3*time.Second)
+	for _, v := range []*Validator{refusing, flagging} {
+		_, err = v.Validate(code, secSha1, time.Unix(59, 0))
+		require.NoError(t, err)
+	}
+	require.Empty(t, jumps)
+
+	tick(time.Second, -time.Minute)
+	_, err = refusing.Validate(code, secSha1, time.Unix(59, 0))
+	require.Equal(t, otp.ErrValidateClockJumped, err)
+
+	valid, err := flagging.Validate(code, secSha1, time.Unix(59, 0))
+	require.NoError(t, err)
+	require.True(t, valid, "hook alone must not refuse validation")
+	require.Equal(t, []time.Duration{61 * time.Second}, jumps)
+}
+
+func TestClockJumpOnlyValidator(t *testing.T) {
+	for _, opt := range []ValidateOpt{
+		WithClockJumpLimit(time.Second),
+		WithClockJumpHook(time.Second, func(time.Duration) {}),
+	} {
+		_, err := ValidateWithOpts("123456", secSha1, opt)
+		require.True(t, errors.Is(err, otp.ErrInvalidOption))
+
+		_, err = ValidateErr("123456", secSha1, opt)
+		require.True(t, errors.Is(err, otp.ErrInvalidOption))
+
+		_, _, err = newValidateOpts(opt).validateAny("123456", []string{secSha1}, time.Now())
+		require.True(t, errors.Is(err, otp.ErrInvalidOption))
+	}
+}
+
+func TestClockJumpConcurrent(t *testing.T) {
+	orig := readClock
+	defer func() { readClock = orig }()
+
+	// Every reading steps the wall clock back by a minute.
+	var n time.Duration
+	readClock = func() (time.Time, time.Duration) {
+		n++
+		return time.Unix(1000, 0).Add(-n * time.Minute), n * time.Second
+	}
+
+	var mu sync.Mutex
+	var jumps int
+	v := NewValidator(WithClockJumpHook(5*time.Second, func(time.Duration) {
+		mu.Lock()
+		jumps++
+		mu.Unlock()
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.Validate("123456", secSha1, time.Unix(59, 0))
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 49, jumps, "every call but the first sees one jump")
+}
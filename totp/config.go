@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pquerna/otp"
+)
+
+// Config is the serialized form of an OTP policy. It decodes from JSON
+// with LoadConfig, and from YAML with any library honouring the yaml tags
+// and encoding.TextUnmarshaler, such as gopkg.in/yaml.v3:
+//
+//	{"period": 30, "digits": "8", "algorithm": "SHA256", "skew": 1}
+//
+// Zero fields fall back to the package defaults.
+type Config struct {
+	// Number of seconds a TOTP hash is valid for.
+	Period uint `json:"period,omitempty" yaml:"period,omitempty"`
+	// Digits of the passcode, eg "6" or 6.
+	Digits otp.Digits `json:"digits,omitempty" yaml:"digits,omitempty"`
+	// Algorithm to use for HMAC, eg "SHA1".
+	Algorithm otp.Algorithm `json:"algorithm" yaml:"algorithm"`
+	// Periods before or after the current time to allow.
+	Skew uint `json:"skew,omitempty" yaml:"skew,omitempty"`
+	// Largest Skew to accept.
+	MaxSkew uint `json:"max_skew,omitempty" yaml:"max_skew,omitempty"`
+}
+
+// LoadConfig decodes a JSON Config from r and validates it. Unknown fields
+// are rejected, so typos do not silently fall back to defaults.
+func LoadConfig(r io.Reader) (Config, error) {
+	var c Config
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return Config{}, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// Validate reports the first invalid field of the Config as an
+// *otp.OptionError.
+func (c Config) Validate() error {
+	if err := c.Algorithm.Check(); err != nil {
+		return err
+	}
+	if c.Digits != 0 {
+		if err := c.Digits.Check(); err != nil {
+			return err
+		}
+	}
+	if c.MaxSkew != 0 && c.Skew > c.MaxSkew {
+		return &otp.OptionError{Name: "Skew", Value: c.Skew, Err: otp.ErrValidateSkewTooLarge}
+	}
+	return nil
+}
+
+// Profile returns the validated Profile described by the Config.
+func (c Config) Profile() (Profile, error) {
+	if err := c.Validate(); err != nil {
+		return Profile{}, err
+	}
+	return Profile{
+		Period:    c.Period,
+		Digits:    c.Digits,
+		Algorithm: c.Algorithm,
+	}, nil
+}
+
+// ValidateOpts returns the validated options described by the Config.
+func (c Config) ValidateOpts() ([]ValidateOpt, error) {
+	p, err := c.Profile()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []ValidateOpt{p.ValidateOpt()}
+	if c.Skew != 0 {
+		opts = append(opts, WithSkew(c.Skew))
+	}
+	if c.MaxSkew != 0 {
+		opts = append(opts, WithMaxSkew(c.MaxSkew))
+	}
+	return opts, nil
+}
@@ -0,0 +1,53 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig(strings.NewReader(`{"period": 30, "digits": "8", "algorithm": "sha256", "skew": 1}`))
+	require.NoError(t, err)
+
+	p, err := c.Profile()
+	require.NoError(t, err)
+	require.Equal(t, Profile{Period: 30, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256}, p)
+
+	opts, err := c.ValidateOpts()
+	require.NoError(t, err)
+	valid, err := ValidateWithOpts("46119246", secSha256, append(opts, WithTime(time.Unix(59, 0).UTC()))...)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// Numeric digits are accepted as well.
+	c, err = LoadConfig(strings.NewReader(`{"digits": 6, "algorithm": "SHA1"}`))
+	require.NoError(t, err)
+	require.Equal(t, otp.DigitsSix, c.Digits)
+
+	out, err := json.Marshal(Config{Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA512})
+	require.NoError(t, err)
+	require.Equal(t, `{"digits":"8","algorithm":"SHA512"}`, string(out))
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	_, err := LoadConfig(strings.NewReader(`{"algorithm": "SHA3"}`))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedAlgorithm))
+
+	_, err = LoadConfig(strings.NewReader(`{"digits": "5", "algorithm": "SHA1"}`))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedDigits))
+
+	_, err = LoadConfig(strings.NewReader(`{"digits": 11}`))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedDigits))
+
+	_, err = LoadConfig(strings.NewReader(`{"skew": 5, "max_skew": 2}`))
+	require.True(t, errors.Is(err, otp.ErrValidateSkewTooLarge))
+
+	_, err = LoadConfig(strings.NewReader(`{"perod": 30}`))
+	require.Error(t, err)
+}
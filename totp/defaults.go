@@ -2,51 +2,235 @@ package totp
 
 import (
 	"crypto/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pquerna/otp"
 )
 
+// DefaultOpts holds the parameters used for any option a caller leaves unset.
+// The built-in values are compatible with Google-Authenticator.
+type DefaultOpts struct {
+	// Number of seconds a TOTP hash is valid for.
+	Period uint
+	// Periods before or after the current time to allow.
+	Skew uint
+	// Digits of generated and validated passcodes.
+	Digits otp.Digits
+	// Algorithm to use for HMAC.
+	Algorithm otp.Algorithm
+	// Largest Skew validation accepts; larger values make validation fail
+	// with otp.ErrValidateSkewTooLarge. Zero disables the check.
+	MaxSkew uint
+}
+
+var builtinDefaults = DefaultOpts{
+	Period:    otp.DefaultPeriod,
+	Skew:      otp.DefaultSkew,
+	Digits:    otp.DefaultDigits,
+	Algorithm: otp.DefaultAlgorithm,
+}
+
+var currentDefaults atomic.Value
+
+// defaultsMu serializes the writers of currentDefaults, so the
+// read-modify-write of SetDefaults loses no concurrent change. Readers
+// only load currentDefaults.
+var defaultsMu sync.Mutex
+
+func init() {
+	currentDefaults.Store(builtinDefaults)
+}
+
+// StoreDefaults atomically replaces the package defaults consulted by
+// Validate, GenerateCode and the option based functions, so long running
+// services can change their OTP policy without restarting. A zero Period or
+// Digits falls back to the built-in value.
+//
+// Keys and Validators that already exist keep the parameters they were
+// created with.
+func StoreDefaults(d DefaultOpts) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+
+	currentDefaults.Store(d.fill())
+}
+
+// SetDefaults atomically replaces the period, digits and algorithm of the
+// package defaults with those of p, so an application can set its house
+// defaults once instead of threading the same options through every call.
+// The skew settings are left as they are.
+func SetDefaults(p Profile) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+
+	d := currentDefaults.Load().(DefaultOpts)
+	d.Period = p.Period
+	d.Digits = p.Digits
+	d.Algorithm = p.Algorithm
+
+	currentDefaults.Store(d.fill())
+}
+
+// fill replaces a zero Period or Digits with the built-in value.
+func (d DefaultOpts) fill() DefaultOpts {
+	if d.Period == 0 {
+		d.Period = builtinDefaults.Period
+	}
+	if d.Digits == 0 {
+		d.Digits = builtinDefaults.Digits
+	}
+	return d
+}
+
+// LoadDefaults returns the current package defaults.
+func LoadDefaults() DefaultOpts {
+	return currentDefaults.Load().(DefaultOpts)
+}
+
+// defaults fills the unset options and checks the request, reporting
+// every invalid field in an *otp.GenerateError.
 func (opts *GenerateOpts) defaults() error {
-	if opts.Issuer == "" {
-		return otp.ErrGenerateMissingIssuer
+	var genErr otp.GenerateError
+
+	if opts.Issuer == "" && !opts.AllowMissingIssuer {
+		genErr.Add("Issuer", opts.Issuer, otp.ErrGenerateMissingIssuer)
 	}
 
 	if opts.AccountName == "" {
-		return otp.ErrGenerateMissingAccountName
+		genErr.Add("AccountName", opts.AccountName, otp.ErrGenerateMissingAccountName)
+	} else if opts.ValidateAccountName != nil {
+		if err := opts.ValidateAccountName(opts.AccountName); err != nil {
+			genErr.Add("AccountName", opts.AccountName, err)
+		}
 	}
 
+	d := LoadDefaults()
+
 	if opts.Period == 0 {
-		opts.Period = 30
+		opts.Period = d.Period
 	}
 
 	if opts.SecretSize == 0 {
-		opts.SecretSize = 20
+		opts.SecretSize = otp.DefaultTOTPSecretSize
+	}
+
+	if len(opts.Secret) == 0 && opts.SecretSize < otp.MinSecretSize {
+		genErr.Add("SecretSize", opts.SecretSize, otp.ErrGenerateSecretTooShort)
 	}
 
 	if opts.Digits == 0 {
-		opts.Digits = otp.DigitsSix
+		opts.Digits = d.Digits
+	}
+
+	if err := opts.Digits.Check(); err != nil {
+		genErr.Add("Digits", opts.Digits, err)
+	}
+
+	if opts.Algorithm == otp.AlgorithmSHA1 && !opts.algorithmSet {
+		opts.Algorithm = d.Algorithm
+	}
+
+	if err := opts.Algorithm.Check(); err != nil {
+		genErr.Add("Algorithm", opts.Algorithm, err)
+	}
+
+	if opts.Policy != nil {
+		if err := opts.Policy.CheckParams(opts.Algorithm, opts.Digits); err != nil {
+			optErr := err.(*otp.OptionError)
+			genErr.Add(optErr.Name, optErr.Value, optErr.Err)
+		}
 	}
 
 	if opts.Rand == nil {
 		opts.Rand = rand.Reader
 	}
 
-	return nil
+	return genErr.Err()
 }
 
 // defaultOpts sets default opts
 func (opts *ValidateOpts) defaultOpts() {
+	d := LoadDefaults()
+
 	if opts.Skew == 0 {
-		opts.Skew = 1
+		opts.Skew = d.Skew
 	}
 	if opts.Digits == 0 {
-		opts.Digits = otp.DigitsSix
+		opts.Digits = d.Digits
 	}
 	if opts.Period == 0 {
-		opts.Period = 30
+		opts.Period = d.Period
+	}
+	if opts.Algorithm == otp.AlgorithmSHA1 && !opts.algorithmSet {
+		opts.Algorithm = d.Algorithm
+		opts.algorithmSet = true
+	}
+	if opts.MaxSkew == 0 {
+		opts.MaxSkew = d.MaxSkew
 	}
 	if opts.t.IsZero() {
-		opts.t = time.Now()
+		opts.t = opts.now().UTC()
+	}
+}
+
+// newGenerateOpts returns GenerateOpts seeded from the package defaults,
+// with genOpts applied on top.
+func newGenerateOpts(genOpts ...GenerateOpt) *GenerateOpts {
+	d := LoadDefaults()
+
+	opts := &GenerateOpts{
+		Period:    d.Period,
+		Digits:    d.Digits,
+		Algorithm: d.Algorithm,
+
+		algorithmSet: true,
+	}
+	for _, opt := range genOpts {
+		opt(opts)
+	}
+
+	return opts
+}
+
+// newValidateOpts returns ValidateOpts seeded from the package defaults,
+// with validateOpts applied on top.
+func newValidateOpts(validateOpts ...ValidateOpt) *ValidateOpts {
+	d := LoadDefaults()
+
+	opts := &ValidateOpts{
+		Period:    d.Period,
+		Skew:      d.Skew,
+		Digits:    d.Digits,
+		Algorithm: d.Algorithm,
+		MaxSkew:   d.MaxSkew,
+
+		algorithmSet: true,
+	}
+	for _, opt := range validateOpts {
+		opt(opts)
+	}
+	opts.defaultOpts()
+
+	return opts
+}
+
+// check reports options that validation must refuse to run with.
+func (opts *ValidateOpts) check() error {
+	if opts.policy != nil {
+		if err := opts.policy.CheckParams(opts.Algorithm, opts.Digits); err != nil {
+			return err
+		}
+		if err := opts.policy.CheckValidate(opts.Skew, opts.replay != nil); err != nil {
+			return err
+		}
+	}
+	if opts.MaxSkew != 0 && opts.Skew > opts.MaxSkew {
+		return &otp.OptionError{Name: "Skew", Value: opts.Skew, Err: otp.ErrValidateSkewTooLarge}
+	}
+	if opts.BoundaryTolerance < 0 || opts.BoundaryTolerance >= time.Duration(opts.Period)*time.Second {
+		return &otp.OptionError{Name: "BoundaryTolerance", Value: opts.BoundaryTolerance, Err: otp.ErrValidateToleranceTooLarge}
 	}
+	return opts.Algorithm.Check()
 }
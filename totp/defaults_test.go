@@ -0,0 +1,98 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+
+	"testing"
+	"time"
+)
+
+func TestStoreDefaults(t *testing.T) {
+	orig := LoadDefaults()
+	defer StoreDefaults(orig)
+
+	StoreDefaults(DefaultOpts{
+		Skew:      1,
+		Digits:    otp.DigitsEight,
+		Algorithm: otp.AlgorithmSHA256,
+	})
+	d := LoadDefaults()
+	require.Equal(t, uint(30), d.Period, "zero period falls back to built-in")
+
+	passcode, err := GenerateCodeWithOpts(secSha256, WithTime(time.Unix(59, 0).UTC()))
+	require.NoError(t, err)
+	require.Equal(t, "46119246", passcode)
+
+	k, err := GenerateWithOpts(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+	require.Contains(t, k.URL(), "algorithm=SHA256")
+	require.Contains(t, k.URL(), "digits=8")
+
+	// Explicit options still win over the defaults.
+	passcode, err = GenerateCodeWithOpts(secSha1,
+		WithTime(time.Unix(59, 0).UTC()),
+		WithAlgorithm(otp.AlgorithmSHA1),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "94287082", passcode)
+}
+
+func TestSetDefaults(t *testing.T) {
+	orig := LoadDefaults()
+	defer StoreDefaults(orig)
+
+	SetDefaults(Profile{Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA512})
+
+	d := LoadDefaults()
+	require.Equal(t, orig.Skew, d.Skew, "skew is kept")
+	require.Equal(t, uint(30), d.Period)
+	require.Equal(t, otp.DigitsEight, d.Digits)
+	require.Equal(t, otp.AlgorithmSHA512, d.Algorithm)
+
+	valid, err := ValidateWithOpts("90693936", secSha512, WithTime(time.Unix(59, 0).UTC()))
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestDefaultAlgorithm(t *testing.T) {
+	orig := LoadDefaults()
+	defer StoreDefaults(orig)
+
+	SetDefaults(Profile{Period: 30, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256})
+
+	key, err := Generate(GenerateOpts{Issuer: "Example", AccountName: "alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, otp.AlgorithmSHA256, key.Algorithm())
+	require.Equal(t, otp.DigitsEight, key.Digits())
+
+	key, err = GenerateWithOpts(WithIssuer("Example"), WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+	require.Equal(t, otp.AlgorithmSHA256, key.Algorithm())
+
+	key, err = GenerateWithOpts(WithIssuer("Example"), WithAccountName("alice@example.com"),
+		WithGenAlgorithm(otp.AlgorithmSHA1))
+	require.NoError(t, err)
+	require.Equal(t, otp.AlgorithmSHA1, key.Algorithm())
+
+	at := time.Unix(59, 0).UTC()
+	code, err := GenerateCode(secSha256, at)
+	require.NoError(t, err)
+	require.Equal(t, "46119246", code)
+
+	valid, err := ValidateCustom(code, secSha256, at, ValidateOpts{})
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = ValidateWithOpts(code, secSha256, WithTime(at))
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = ValidateWithOpts(code, secSha256, ValidateOpts{}.Option(), WithTime(at))
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = ValidateWithOpts(code, secSha256, WithTime(at), WithAlgorithm(otp.AlgorithmSHA1))
+	require.NoError(t, err)
+	require.False(t, valid)
+}
@@ -0,0 +1,128 @@
+package totp
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+// Window describes one period validation tries.
+type Window struct {
+	// Counter of the period.
+	Counter uint64
+	// Offset of the period from the validation time, in periods.
+	Offset int
+	// Start of the period.
+	Start time.Time
+	// Code the secret produces in the period.
+	Code string
+	// Match is true when Code equals the passcode.
+	Match bool
+}
+
+// Diagnose reports every period validation of passcode would try, with the
+// code generated for each and whether it matches, to investigate codes that
+// unexpectedly fail. A match outside the window shows up as no match at
+// all, so callers may widen it with WithSkew.
+//
+// The result contains valid codes for secret; it is meant for support
+// tooling and must not be shown to the user who entered the passcode.
+func Diagnose(passcode, secret string, validateOpts ...ValidateOpt) ([]Window, error) {
+	opts := newValidateOpts(validateOpts...)
+
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+
+	key, err := hotp.DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hotpOpts := opts.hotpOpts()
+	passcode = hotp.NormalizePasscode(passcode, hotpOpts)
+	g := hotp.NewGenerator(key, hotpOpts)
+
+	compare := otp.CompareExact
+	if opts.Comparator != nil {
+		compare = opts.Comparator
+	}
+
+	counters := opts.counters(nil, opts.t)
+	sort.Slice(counters, func(i, j int) bool { return counters[i] < counters[j] })
+
+	current := opts.counterAt(opts.t)
+	windows := make([]Window, len(counters))
+	for i, counter := range counters {
+		code := string(g.AppendCode(nil, counter))
+		windows[i] = Window{
+			Counter: counter,
+			Offset:  int(int64(counter) - current),
+			Start:   opts.periodStart(int64(counter)),
+			Code:    code,
+			Match:   compare.Equal([]byte(code), []byte(passcode)),
+		}
+	}
+
+	return windows, nil
+}
+
+// MaxFindPeriods is the largest number of periods FindWindows scans, about
+// four years of 30 second periods.
+const MaxFindPeriods = 1 << 22
+
+// FindWindows reports every period between from and to in which passcode
+// would have been valid for secret, to reconstruct the timeline of an
+// incident. Offsets are relative to the time set with WithTime, which
+// defaults to now. Skew is ignored; each reported period is an exact match.
+// A range that is reversed or spans more than MaxFindPeriods fails with an
+// *otp.OptionError.
+//
+// Like Diagnose, the result is meant for security tooling only.
+func FindWindows(passcode, secret string, from, to time.Time, validateOpts ...ValidateOpt) ([]Window, error) {
+	opts := newValidateOpts(validateOpts...)
+
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+
+	first, last := opts.counterAt(from), opts.counterAt(to)
+	if first < 0 || last < first || last-first >= MaxFindPeriods {
+		return nil, &otp.OptionError{Name: "Range", Value: from.String() + " - " + to.String(), Err: otp.ErrInvalidOption}
+	}
+
+	key, err := hotp.DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hotpOpts := opts.hotpOpts()
+	passcode = hotp.NormalizePasscode(passcode, hotpOpts)
+	g := hotp.NewGenerator(key, hotpOpts)
+
+	compare := otp.CompareExact
+	if opts.Comparator != nil {
+		compare = opts.Comparator
+	}
+
+	current := opts.counterAt(opts.t)
+	var windows []Window
+	code := make([]byte, 0, opts.Digits.Length())
+	for counter := first; counter <= last; counter++ {
+		code = g.AppendCode(code[:0], uint64(counter))
+		if !compare.Equal(code, []byte(passcode)) {
+			continue
+		}
+		windows = append(windows, Window{
+			Counter: uint64(counter),
+			Offset:  int(counter - current),
+			Start:   opts.periodStart(counter),
+			Code:    string(code),
+			Match:   true,
+		})
+	}
+
+	return windows, nil
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnose(t *testing.T) {
+	// 07081804 is the code for t=1111111109; ask one period later.
+	windows, err := Diagnose("07081804", secSha1,
+		WithDigits(otp.DigitsEight),
+		WithSkew(2),
+		WithTime(time.Unix(1111111109+30, 0)),
+	)
+	require.NoError(t, err)
+	require.Len(t, windows, 5)
+
+	var matches []Window
+	for i, w := range windows {
+		require.Equal(t, i-2, w.Offset)
+		require.Len(t, w.Code, 8)
+		if w.Match {
+			matches = append(matches, w)
+		}
+	}
+	require.Len(t, matches, 1)
+	require.Equal(t, -1, matches[0].Offset)
+	require.Equal(t, uint64(1111111109/30), matches[0].Counter)
+	require.Equal(t, time.Unix(1111111080, 0).UTC(), matches[0].Start)
+
+	_, err = Diagnose("07081804", "not base32!")
+	require.Error(t, err)
+}
+
+func TestFindWindows(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	windows, err := FindWindows("07081804", secSha1, at.Add(-24*time.Hour), at.Add(24*time.Hour),
+		WithDigits(otp.DigitsEight),
+		WithTime(at.Add(time.Hour)),
+	)
+	require.NoError(t, err)
+	require.Len(t, windows, 1)
+	require.Equal(t, uint64(1111111109/30), windows[0].Counter)
+	require.Equal(t, -120, windows[0].Offset)
+	require.Equal(t, time.Unix(1111111109/30*30, 0).UTC(), windows[0].Start)
+	require.True(t, windows[0].Match)
+
+	// Six digit codes repeat about once every million periods.
+	windows, err = FindWindows("081804", secSha1, at.Add(-time.Hour), at.Add(time.Hour))
+	require.NoError(t, err)
+	require.NotEmpty(t, windows)
+
+	_, err = FindWindows("081804", secSha1, at, at.Add(-time.Hour))
+	require.True(t, errors.Is(err, otp.ErrInvalidOption))
+	_, err = FindWindows("081804", secSha1, at, at.Add(10*365*24*time.Hour))
+	require.True(t, errors.Is(err, otp.ErrInvalidOption))
+}
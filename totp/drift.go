@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// driftCheck compares the local clock with a trusted otp.TimeSource.
+type driftCheck struct {
+	src       otp.TimeSource
+	threshold time.Duration
+	refuse    bool
+	hook      func(drift time.Duration)
+}
+
+// check measures the drift of the local clock, read as now. It returns an
+// error if the source fails, or if the drift exceeds the threshold and the
+// check refuses.
+func (c *driftCheck) check(now func() time.Time) error {
+	if c.src == nil {
+		return nil
+	}
+
+	trusted, err := c.src.Now()
+	if err != nil {
+		return err
+	}
+
+	drift := now().Sub(trusted)
+	if drift <= c.threshold && drift >= -c.threshold {
+		return nil
+	}
+
+	if c.hook != nil {
+		c.hook(drift)
+	}
+	if c.refuse {
+		return &otp.DriftError{Drift: drift, Threshold: c.threshold}
+	}
+	return nil
+}
+
+// WithClockDriftLimit makes validation fail with an error matching
+// otp.ErrClockDrift when the local clock, set with WithClock, diverges from src by more than
+// threshold, or with the error of src when it cannot be queried.
+func WithClockDriftLimit(src otp.TimeSource, threshold time.Duration) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.drift.src = src
+		opt.drift.threshold = threshold
+		opt.drift.refuse = true
+	}
+}
+
+// WithClockDriftHook calls fn with the drift whenever the local clock
+// diverges from src by more than threshold. Validation proceeds unless
+// WithClockDriftLimit is also set.
+func WithClockDriftHook(src otp.TimeSource, threshold time.Duration, fn func(drift time.Duration)) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.drift.src = src
+		opt.drift.threshold = threshold
+		opt.drift.hook = fn
+	}
+}
+
+// WithObservedDriftHook calls fn after every successful validation with the
+// offset, in periods, of the matching code from the current period: 0 for
+// the current code, -1 for the previous one, 1 for the next one. Feeding
+// this into a metrics gauge shows the clock drift of a client population,
+// so skew policy can be tuned with data.
+func WithObservedDriftHook(fn func(offset int)) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.matchHook = fn
+	}
+}
+
+// observeMatch reports the offset of counter, matched at t, to the hook.
+func (opts *ValidateOpts) observeMatch(counter uint64, t time.Time) {
+	if opts.matchHook != nil {
+		opts.matchHook(int(int64(counter) - opts.counterAt(t)))
+	}
+}
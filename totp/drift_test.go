@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+
+	"errors"
+	"testing"
+	"time"
+)
+
+type offsetSource time.Duration
+
+func (o offsetSource) Now() (time.Time, error) {
+	return time.Now().Add(time.Duration(o)), nil
+}
+
+func TestClockDrift(t *testing.T) {
+	code, err := GenerateCodeWithOpts(secSha1)
+	require.NoError(t, err)
+
+	valid, err := ValidateWithOpts(code, secSha1,
+		WithClockDriftLimit(offsetSource(time.Second), 10*time.Second))
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = ValidateWithOpts(code, secSha1,
+		WithClockDriftLimit(offsetSource(time.Minute), 10*time.Second))
+	require.True(t, errors.Is(err, otp.ErrClockDrift))
+	require.False(t, valid)
+
+	var drift time.Duration
+	v := NewValidator(WithClockDriftHook(offsetSource(-time.Minute), 10*time.Second,
+		func(d time.Duration) { drift = d }))
+	valid, err = v.Validate(code, secSha1, time.Now())
+	require.NoError(t, err)
+	require.True(t, valid)
+	require.InDelta(t, float64(time.Minute), float64(drift), float64(time.Second))
+}
+
+func TestClockDriftClock(t *testing.T) {
+	ahead := otp.ClockFunc(func() time.Time { return time.Now().Add(time.Minute) })
+	code, err := GenerateCodeWithOpts(secSha1, WithClock(ahead))
+	require.NoError(t, err)
+
+	valid, err := ValidateWithOpts(code, secSha1, WithClock(ahead),
+		WithClockDriftLimit(offsetSource(0), 10*time.Second))
+	require.True(t, errors.Is(err, otp.ErrClockDrift), "the configured clock is checked")
+	require.False(t, valid)
+
+	v := NewValidator(WithClock(ahead), WithClockDriftLimit(offsetSource(time.Minute), 10*time.Second))
+	valid, err = v.Validate(code, secSha1, ahead.Now())
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestObservedDriftHook(t *testing.T) {
+	var offsets []int
+	hook := WithObservedDriftHook(func(offset int) { offsets = append(offsets, offset) })
+
+	// 94287082 is the code for counter 1 (t=30..59).
+	v := NewValidator(WithDigits(otp.DigitsEight), hook)
+	for _, ts := range []int64{29, 59, 61} {
+		valid, err := v.Validate("94287082", secSha1, time.Unix(ts, 0))
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+
+	valid, err := ValidateWithOpts("94287082", secSha1,
+		WithTime(time.Unix(45, 0)), WithDigits(otp.DigitsEight), hook)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	require.Equal(t, []int{1, 0, -1, 0}, offsets)
+}
@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pquerna/otp"
+)
+
+// OptsFromEnv reads validation options from the environment variables
+// <prefix>PERIOD, <prefix>DIGITS, <prefix>ALGORITHM and <prefix>SKEW, so
+// containerized services can configure their OTP policy without bespoke
+// parsing. With prefix "OTP_", OTP_PERIOD=60 yields WithPeriod(60).
+//
+// Unset or empty variables yield no option, leaving the package defaults
+// in effect. A variable that does not hold a valid value is reported as an
+// *otp.OptionError naming the variable.
+func OptsFromEnv(prefix string) ([]ValidateOpt, error) {
+	var opts []ValidateOpt
+
+	if s := os.Getenv(prefix + "PERIOD"); s != "" {
+		period, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || period == 0 {
+			return nil, envError(prefix+"PERIOD", s, err)
+		}
+		opts = append(opts, WithPeriod(uint(period)))
+	}
+
+	if s := os.Getenv(prefix + "DIGITS"); s != "" {
+		digits, err := otp.ParseDigits(s)
+		if err != nil {
+			return nil, envError(prefix+"DIGITS", s, err)
+		}
+		opts = append(opts, WithDigits(digits))
+	}
+
+	if s := os.Getenv(prefix + "ALGORITHM"); s != "" {
+		algorithm, err := otp.ParseAlgorithm(s)
+		if err != nil {
+			return nil, envError(prefix+"ALGORITHM", s, err)
+		}
+		opts = append(opts, WithAlgorithm(algorithm))
+	}
+
+	if s := os.Getenv(prefix + "SKEW"); s != "" {
+		skew, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, envError(prefix+"SKEW", s, err)
+		}
+		opts = append(opts, WithSkew(uint(skew)))
+	}
+
+	return opts, nil
+}
+
+// envError reports an environment variable holding an invalid value.
+func envError(name, value string, err error) error {
+	if optErr, ok := err.(*otp.OptionError); ok {
+		err = optErr.Err
+	}
+	if err == nil {
+		err = otp.ErrInvalidOption
+	}
+	return &otp.OptionError{Name: name, Value: value, Err: err}
+}
@@ -0,0 +1,64 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// setenv sets an environment variable for the duration of the test, like
+// testing.T.Setenv, which needs Go 1.17.
+func setenv(t *testing.T, key, value string) {
+	prev, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestOptsFromEnv(t *testing.T) {
+	setenv(t, "TEST_OTP_PERIOD", "30")
+	setenv(t, "TEST_OTP_DIGITS", "8")
+	setenv(t, "TEST_OTP_ALGORITHM", "sha256")
+	setenv(t, "TEST_OTP_SKEW", "1")
+
+	opts, err := OptsFromEnv("TEST_OTP_")
+	require.NoError(t, err)
+	require.Len(t, opts, 4)
+
+	valid, err := ValidateWithOpts("46119246", secSha256, append(opts, WithTime(time.Unix(59, 0).UTC()))...)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	opts, err = OptsFromEnv("TEST_UNSET_")
+	require.NoError(t, err)
+	require.Empty(t, opts)
+}
+
+func TestOptsFromEnvInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"TEST_BAD_PERIOD":    "0",
+		"TEST_BAD_DIGITS":    "5",
+		"TEST_BAD_ALGORITHM": "SHA3",
+		"TEST_BAD_SKEW":      "-1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			setenv(t, name, value)
+
+			_, err := OptsFromEnv("TEST_BAD_")
+			require.True(t, errors.Is(err, otp.ErrInvalidOption))
+
+			var optErr *otp.OptionError
+			require.True(t, errors.As(err, &optErr))
+			require.Equal(t, name, optErr.Name)
+		})
+	}
+}
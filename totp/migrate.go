@@ -0,0 +1,96 @@
+package totp
+
+// Option returns a ValidateOpt applying the non-zero fields of opts, so
+// code built around the ValidateOpts struct of earlier releases can move to
+// ValidateWithOpts and Validator one call site at a time, mixing it with
+// newer options. Zero fields keep the value of the options before it, or
+// the package defaults, as they do with ValidateCustom. A zero Algorithm
+// is SHA1 only when set with WithAlgorithm.
+func (opts ValidateOpts) Option() ValidateOpt {
+	return func(opt *ValidateOpts) {
+		if opts.Period != 0 {
+			opt.Period = opts.Period
+		}
+		if opts.Skew != 0 {
+			opt.Skew = opts.Skew
+		}
+		if opts.Digits != 0 {
+			opt.Digits = opts.Digits
+		}
+		if opts.Algorithm != 0 || opts.algorithmSet {
+			opt.Algorithm = opts.Algorithm
+			opt.algorithmSet = true
+		}
+		if !opts.Epoch.IsZero() {
+			opt.Epoch = opts.Epoch
+		}
+		if opts.Encoder != nil {
+			opt.Encoder = opts.Encoder
+		}
+		if opts.PadLeadingZeros {
+			opt.PadLeadingZeros = true
+		}
+		if opts.NormalizeInput {
+			opt.NormalizeInput = true
+		}
+		if opts.Comparator != nil {
+			opt.Comparator = opts.Comparator
+		}
+		if opts.BoundaryTolerance != 0 {
+			opt.BoundaryTolerance = opts.BoundaryTolerance
+		}
+		if opts.MaxSkew != 0 {
+			opt.MaxSkew = opts.MaxSkew
+		}
+	}
+}
+
+// Option returns a GenerateOpt applying the non-zero fields of opts, so
+// code built around the GenerateOpts struct of earlier releases can move to
+// GenerateWithOpts, mixing it with newer options. Zero fields keep the
+// value of the options before it, or the package defaults, as they do
+// with Generate.
+func (opts GenerateOpts) Option() GenerateOpt {
+	return func(opt *GenerateOpts) {
+		if opts.Issuer != "" {
+			opt.Issuer = opts.Issuer
+		}
+		if opts.AccountName != "" {
+			opt.AccountName = opts.AccountName
+		}
+		if opts.Period != 0 {
+			opt.Period = opts.Period
+		}
+		if opts.SecretSize != 0 {
+			opt.SecretSize = opts.SecretSize
+		}
+		if len(opts.Secret) != 0 {
+			opt.Secret = opts.Secret
+		}
+		if opts.Digits != 0 {
+			opt.Digits = opts.Digits
+		}
+		if opts.Algorithm != 0 || opts.algorithmSet {
+			opt.Algorithm = opts.Algorithm
+			opt.algorithmSet = true
+		}
+		if !opts.Epoch.IsZero() {
+			opt.Epoch = opts.Epoch
+		}
+		if opts.Rand != nil {
+			opt.Rand = opts.Rand
+		}
+		if opts.Label != nil {
+			opt.Label = opts.Label
+		}
+		if opts.AllowMissingIssuer {
+			opt.AllowMissingIssuer = true
+		}
+		if opts.ValidateAccountName != nil {
+			opt.ValidateAccountName = opts.ValidateAccountName
+		}
+		if opts.Policy != nil {
+			opt.Policy = opts.Policy
+		}
+	}
+}
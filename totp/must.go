@@ -0,0 +1,23 @@
+package totp
+
+import "github.com/pquerna/otp"
+
+// MustGenerate is like GenerateWithOpts but panics if the key cannot be
+// generated. It simplifies tests, examples and init-time fixtures.
+func MustGenerate(genOpts ...GenerateOpt) *otp.Key {
+	key, err := GenerateWithOpts(genOpts...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// MustGenerateCode is like GenerateCodeWithOpts but panics if the code
+// cannot be generated. It simplifies tests, examples and init-time fixtures.
+func MustGenerateCode(secret string, validateOpts ...ValidateOpt) string {
+	passcode, err := GenerateCodeWithOpts(secret, validateOpts...)
+	if err != nil {
+		panic(err)
+	}
+	return passcode
+}
@@ -1,10 +1,12 @@
 package totp
 
 import (
+	"context"
 	"io"
 	"time"
 
 	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
 )
 
 // GenerateOpts generate opts are the options to be used in
@@ -34,6 +36,14 @@ func WithSecret(secret []byte) GenerateOpt {
 	}
 }
 
+// WithGenEpoch counts the periods of the generated key from t0 instead of
+// the Unix epoch, and records it in the key's t0 parameter.
+func WithGenEpoch(t0 time.Time) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Epoch = t0
+	}
+}
+
 func WithGenDigits(digits otp.Digits) GenerateOpt {
 
 	return func(opts *GenerateOpts) {
@@ -44,6 +54,7 @@ func WithGenDigits(digits otp.Digits) GenerateOpt {
 func WithGenAlgorithm(algo otp.Algorithm) GenerateOpt {
 	return func(opts *GenerateOpts) {
 		opts.Algorithm = algo
+		opts.algorithmSet = true
 	}
 }
 
@@ -53,12 +64,49 @@ func WithRandomGenerator(r io.Reader) GenerateOpt {
 	}
 }
 
+// WithRand reads the generated secret from r instead of crypto/rand. It
+// is the same as WithRandomGenerator, named after the Rand field.
+func WithRand(r io.Reader) GenerateOpt {
+	return WithRandomGenerator(r)
+}
+
 func WithSecretSize(size uint) GenerateOpt {
 	return func(opts *GenerateOpts) {
 		opts.SecretSize = size
 	}
 }
 
+// WithoutIssuer allows generating a personal-use key without an issuer,
+// whose label is only the account name.
+func WithoutIssuer() GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.AllowMissingIssuer = true
+	}
+}
+
+// WithAccountNameValidator enforces a naming policy on the account name,
+// eg otp.ValidateEmail or otp.ValidateUsername.
+func WithAccountNameValidator(fn func(name string) error) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.ValidateAccountName = fn
+	}
+}
+
+// WithLabel builds the label shown by authenticator apps with fn instead
+// of the default "Issuer:AccountName". The label is escaped for the URL.
+func WithLabel(fn otp.LabelFunc) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Label = fn
+	}
+}
+
+// WithGenPolicy rejects digits and algorithms policy does not allow.
+func WithGenPolicy(policy *otp.Policy) GenerateOpt {
+	return func(opts *GenerateOpts) {
+		opts.Policy = policy
+	}
+}
+
 //
 type ValidateOpt func(opt *ValidateOpts)
 
@@ -73,6 +121,76 @@ func WithSkew(skew uint) ValidateOpt {
 	}
 }
 
+// WithMaxSkew sets the largest skew validation accepts, overriding the
+// package default.
+func WithMaxSkew(maxSkew uint) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.MaxSkew = maxSkew
+	}
+}
+
+// WithBoundaryTolerance accepts codes from the neighbouring period when
+// the validation time is within d of the edge of the skew window.
+func WithBoundaryTolerance(d time.Duration) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.BoundaryTolerance = d
+	}
+}
+
+// WithRecorder calls fn with a record of every validation, without the
+// secret or the passcode, so rejected codes can be reproduced later. See
+// ValidationRecord.
+func WithRecorder(fn func(rec ValidationRecord)) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.recorder = fn
+	}
+}
+
+// WithRecordedPasscodes makes the records of WithRecorder include the
+// submitted passcode, so passcodes that are not a generated code, eg of
+// the wrong length, can be reproduced too. Passcodes remain credentials
+// within their skew window: only set it where records are protected as such.
+func WithRecordedPasscodes() ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.recordPasscodes = true
+	}
+}
+
+// WithReplayProtection records accepted passcodes in store, so the same
+// secret and counter are never accepted twice: a replayed passcode fails
+// with otp.ErrValidateReplayed. Secrets are identified in the store by
+// otp.SecretFingerprint. See replay.Store for an in-memory store.
+func WithReplayProtection(store otp.ReplayStore) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.replay = store
+	}
+}
+
+// WithContext passes ctx to the stores consulted by validation, such as
+// the replay and rate limiting stores, so they honor its cancellation and deadline. An
+// error of ctx is returned as is. Defaults to context.Background.
+func WithContext(ctx context.Context) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.ctx = ctx
+	}
+}
+
+// WithPolicy fails validation with options policy does not allow, eg a
+// skew larger than its MaxSkew.
+func WithPolicy(policy *otp.Policy) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.policy = policy
+	}
+}
+
+// WithEpoch counts periods from t0 instead of the Unix epoch, for keys
+// provisioned with a non-zero T0.
+func WithEpoch(t0 time.Time) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.Epoch = t0
+	}
+}
+
 func WithDigits(digits otp.Digits) ValidateOpt {
 	return func(opt *ValidateOpts) {
 		opt.Digits = digits
@@ -82,6 +200,40 @@ func WithDigits(digits otp.Digits) ValidateOpt {
 func WithAlgorithm(algo otp.Algorithm) ValidateOpt {
 	return func(opt *ValidateOpts) {
 		opt.Algorithm = algo
+		opt.algorithmSet = true
+	}
+}
+
+// WithEncoder renders passcodes with e instead of decimal digits.
+func WithEncoder(e otp.Encoder) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.Encoder = e
+	}
+}
+
+// WithLeadingZeroPadding left-pads numeric input shorter than the
+// configured digits with zeros before comparison, so "12345" matches
+// "012345".
+func WithLeadingZeroPadding() ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.PadLeadingZeros = true
+	}
+}
+
+// WithInputNormalization removes spaces, dashes and invisible characters
+// from passcodes and converts non-ASCII digits before comparison, so codes
+// pasted as "123-456" or typed with fullwidth digits are accepted.
+func WithInputNormalization() ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.NormalizeInput = true
+	}
+}
+
+// WithComparator matches submitted passcodes with generated ones using c,
+// eg otp.CompareFold to accept alphanumeric codes in either case.
+func WithComparator(c otp.Comparator) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.Comparator = c
 	}
 }
 
@@ -90,3 +242,39 @@ func WithTime(t time.Time) ValidateOpt {
 		opt.t = t
 	}
 }
+
+// WithTimeFunc validates at the time returned by now, which is called
+// once per validation. It lets tests and callers with their own clock use
+// Validate and ValidateErr instead of passing a fixed time. It is
+// WithClock(otp.ClockFunc(now)), replacing any time set with WithTime.
+func WithTimeFunc(now func() time.Time) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.t = time.Time{}
+		opt.clock = otp.ClockFunc(now)
+	}
+}
+
+// WithClock validates and generates passcodes at the time read from c
+// when no time is set with WithTime, instead of otp.DefaultClock. It lets
+// tests fix the time and services use a clock adjusted by NTP.
+func WithClock(c otp.Clock) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.clock = c
+	}
+}
+
+// now returns the current time of the clock of the options.
+func (opts *ValidateOpts) now() time.Time {
+	if opts.clock != nil {
+		return opts.clock.Now()
+	}
+	return otp.DefaultClock().Now()
+}
+
+// WithSecretCache makes a Validator look up decoded secrets in c before
+// decoding them, so frequently validated secrets skip base32 decoding.
+func WithSecretCache(c *hotp.SecretCache) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.secretCache = c
+	}
+}
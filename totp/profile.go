@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// Profile captures the parameters a key is provisioned with and its codes
+// are validated with. Using one Profile for both keeps enrollment and
+// verification from drifting apart.
+type Profile struct {
+	// Number of seconds a TOTP hash is valid for. Zero uses the package default.
+	Period uint
+	// Digits of the passcode. Zero uses the package default.
+	Digits otp.Digits
+	// Algorithm to use for HMAC.
+	Algorithm otp.Algorithm
+}
+
+// GoogleAuthenticator is the profile supported by most authenticator apps:
+// SHA1, 6 digits and a 30 second period.
+var GoogleAuthenticator = Profile{
+	Period:    otp.DefaultPeriod,
+	Digits:    otp.DefaultDigits,
+	Algorithm: otp.DefaultAlgorithm,
+}
+
+// GenerateOpt returns an option applying the profile to key generation.
+func (p Profile) GenerateOpt() GenerateOpt {
+	return func(opts *GenerateOpts) {
+		if p.Period != 0 {
+			opts.Period = p.Period
+		}
+		if p.Digits != 0 {
+			opts.Digits = p.Digits
+		}
+		opts.Algorithm = p.Algorithm
+		opts.algorithmSet = true
+	}
+}
+
+// ValidateOpt returns an option applying the profile to code generation
+// and validation.
+func (p Profile) ValidateOpt() ValidateOpt {
+	return func(opt *ValidateOpts) {
+		if p.Period != 0 {
+			opt.Period = p.Period
+		}
+		if p.Digits != 0 {
+			opt.Digits = p.Digits
+		}
+		opt.Algorithm = p.Algorithm
+		opt.algorithmSet = true
+	}
+}
+
+// Generate creates a new TOTP Key with the profile's parameters.
+// genOpts are applied after the profile.
+func (p Profile) Generate(genOpts ...GenerateOpt) (*otp.Key, error) {
+	return GenerateWithOpts(append([]GenerateOpt{p.GenerateOpt()}, genOpts...)...)
+}
+
+// GenerateCode creates a passcode for secret at time t with the profile's
+// parameters.
+func (p Profile) GenerateCode(secret string, t time.Time) (string, error) {
+	return GenerateCodeWithOpts(secret, p.ValidateOpt(), WithTime(t))
+}
+
+// Validate checks passcode against secret with the profile's parameters.
+// validateOpts, such as WithSkew or WithTime, are applied after the profile.
+func (p Profile) Validate(passcode, secret string, validateOpts ...ValidateOpt) (bool, error) {
+	return ValidateWithOpts(passcode, secret, append([]ValidateOpt{p.ValidateOpt()}, validateOpts...)...)
+}
+
+// NewValidator creates a Validator with the profile's parameters.
+func (p Profile) NewValidator(validateOpts ...ValidateOpt) *Validator {
+	return NewValidator(append([]ValidateOpt{p.ValidateOpt()}, validateOpts...)...)
+}
@@ -0,0 +1,47 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+
+	"testing"
+	"time"
+)
+
+func TestProfile(t *testing.T) {
+	p := Profile{Period: 60, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256}
+
+	k, err := p.Generate(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(60), k.Period())
+	require.Contains(t, k.URL(), "algorithm=SHA256")
+	require.Contains(t, k.URL(), "digits=8")
+
+	now := time.Unix(1600000000, 0)
+	code, err := p.GenerateCode(k.Secret(), now)
+	require.NoError(t, err)
+	require.Len(t, code, 8)
+
+	valid, err := p.Validate(code, k.Secret(), WithTime(now.Add(59*time.Second)))
+	require.NoError(t, err)
+	require.True(t, valid, "code must be valid within the profile's period")
+
+	valid, err = p.NewValidator().Validate(code, k.Secret(), now)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// Validating with the package defaults does not match the profile.
+	_, err = ValidateWithOpts(code, k.Secret(), WithTime(now))
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err)
+}
+
+func TestGoogleAuthenticatorProfile(t *testing.T) {
+	d := LoadDefaults()
+	require.Equal(t, GoogleAuthenticator, Profile{Period: d.Period, Digits: d.Digits, Algorithm: d.Algorithm})
+	require.Equal(t, uint(otp.DefaultSkew), d.Skew)
+
+	key, err := GoogleAuthenticator.Generate(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(otp.DefaultPeriod), key.Period())
+	require.Equal(t, otp.DefaultDigits, key.Digits())
+}
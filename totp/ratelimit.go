@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"context"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// rateLimit configures the attempts accepted per secret.
+type rateLimit struct {
+	store  otp.AttemptStore
+	max    int
+	window time.Duration
+}
+
+// WithRateLimit accepts at most max validations of a secret within window,
+// counted in store: further validations fail with otp.ErrValidateRateLimited
+// without comparing the passcode, and a successful validation clears the
+// count. Secrets are identified in the store by otp.SecretFingerprint.
+// Store failures match otp.ErrStore, see otp.WrapStoreError.
+func WithRateLimit(store otp.AttemptStore, max int, window time.Duration) ValidateOpt {
+	return func(opt *ValidateOpts) {
+		opt.rateLimit = rateLimit{store: store, max: max, window: window}
+	}
+}
+
+// attempt records an attempt to validate a passcode for secret, failing
+// with otp.ErrValidateRateLimited when it exceeds the limit. It does
+// nothing without rate limiting.
+func (l rateLimit) attempt(ctx context.Context, secret string) error {
+	if l.store == nil {
+		return nil
+	}
+
+	id := otp.SecretFingerprint(secret)
+	n, err := l.store.Add(ctx, id, l.window)
+	if err != nil {
+		return otp.WrapStoreError("Add", id, err)
+	}
+	if n > l.max {
+		return otp.ErrValidateRateLimited
+	}
+	return nil
+}
+
+// reset clears the attempts of secret after a successful validation.
+func (l rateLimit) reset(ctx context.Context, secret string) error {
+	if l.store == nil {
+		return nil
+	}
+
+	id := otp.SecretFingerprint(secret)
+	return otp.WrapStoreError("Reset", id, l.store.Reset(ctx, id))
+}
@@ -0,0 +1,68 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+// failingAttemptStore is an otp.AttemptStore whose backend is down.
+type failingAttemptStore struct{}
+
+func (failingAttemptStore) Add(ctx context.Context, id string, window time.Duration) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingAttemptStore) Reset(ctx context.Context, id string) error {
+	return errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(at))
+	require.NoError(t, err)
+
+	var store otp.MemoryAttemptStore
+	limit := WithRateLimit(&store, 2, time.Minute)
+	for i := 0; i < 2; i++ {
+		valid, err := ValidateWithOpts("000000", secSha1, WithTime(at), limit)
+		require.NoError(t, err)
+		require.False(t, valid)
+	}
+	valid, err := ValidateWithOpts(code, secSha1, WithTime(at), limit)
+	require.Equal(t, otp.ErrValidateRateLimited, err)
+	require.False(t, valid, "the correct passcode is not compared once the limit is reached")
+
+	require.NoError(t, store.Reset(context.Background(), otp.SecretFingerprint(secSha1)))
+	v := NewValidator(limit)
+	valid, err = v.Validate("000000", secSha1, at)
+	require.NoError(t, err)
+	require.False(t, valid)
+	valid, err = v.Validate(code, secSha1, at)
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid, err = v.Validate(code, secSha1, at)
+	require.NoError(t, err)
+	require.True(t, valid, "a successful validation clears the count")
+}
+
+func TestRateLimitStoreErrors(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(at))
+	require.NoError(t, err)
+
+	_, err = ValidateWithOpts(code, secSha1, WithTime(at), WithRateLimit(failingAttemptStore{}, 5, time.Minute))
+	require.True(t, errors.Is(err, otp.ErrStore))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var store otp.MemoryAttemptStore
+	_, ok := ValidateAny(code, []string{secSha1}, WithTime(at), WithRateLimit(&store, 5, time.Minute), WithContext(ctx))
+	require.False(t, ok)
+	_, err = NewValidator(WithRateLimit(&store, 5, time.Minute)).ValidateContext(ctx, code, secSha1, at)
+	require.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pquerna/otp"
+)
+
+// ValidationRecord describes a validation for WithRecorder. It holds the
+// inputs needed to run the validation again except the secret, which is
+// only identified by its fingerprint, and the passcode, which is only
+// identified by its hash unless WithRecordedPasscodes is set.
+type ValidationRecord struct {
+	// Time the passcode was validated at.
+	Time      time.Time
+	Period    uint
+	Skew      uint
+	Digits    otp.Digits
+	Algorithm otp.Algorithm
+	Epoch     time.Time
+	// BoundaryTolerance, PadLeadingZeros and NormalizeInput as configured.
+	BoundaryTolerance time.Duration
+	PadLeadingZeros   bool
+	NormalizeInput    bool
+	// Fingerprint of the secret, see otp.SecretFingerprint.
+	Fingerprint string
+	// PasscodeHash identifies the passcode, after the trimming and
+	// normalization of validation, see PasscodeHash.
+	PasscodeHash string
+	// Passcode as validated, only set with WithRecordedPasscodes.
+	Passcode string
+	// Valid is the outcome of the validation.
+	Valid bool
+	// Err is the error of the validation, if any.
+	Err error
+}
+
+// ValidateOpts returns the options to run the recorded validation again.
+func (rec *ValidationRecord) ValidateOpts() ValidateOpts {
+	return ValidateOpts{
+		Period:    rec.Period,
+		Skew:      rec.Skew,
+		Digits:    rec.Digits,
+		Algorithm: rec.Algorithm,
+		Epoch:     rec.Epoch,
+		MaxSkew:   rec.Skew,
+
+		BoundaryTolerance: rec.BoundaryTolerance,
+		PadLeadingZeros:   rec.PadLeadingZeros,
+		NormalizeInput:    rec.NormalizeInput,
+
+		algorithmSet: true,
+	}
+}
+
+// record passes a ValidationRecord to the recorder.
+func (opts *ValidateOpts) record(passcode, secret string, t time.Time, ok bool, err error) {
+	var recordedPasscode string
+	if opts.recordPasscodes {
+		recordedPasscode = passcode
+	}
+	opts.recorder(ValidationRecord{
+		Time:      t,
+		Period:    opts.Period,
+		Skew:      opts.Skew,
+		Digits:    opts.Digits,
+		Algorithm: opts.Algorithm,
+		Epoch:     opts.Epoch,
+
+		BoundaryTolerance: opts.BoundaryTolerance,
+		PadLeadingZeros:   opts.PadLeadingZeros,
+		NormalizeInput:    opts.NormalizeInput,
+
+		Fingerprint:  otp.SecretFingerprint(secret),
+		PasscodeHash: PasscodeHash(secret, passcode),
+		Passcode:     recordedPasscode,
+		Valid:        ok,
+		Err:          err,
+	})
+}
+
+// PasscodeHash returns the HMAC-SHA256 of passcode keyed with the decoded
+// secret, in hex, as recorded in ValidationRecord. Only holders of the
+// secret can tell which passcode it was computed from. Secrets that fail
+// to decode are used as they are.
+func PasscodeHash(secret, passcode string) string {
+	key, err := otp.DecodeSecret(secret)
+	if err != nil {
+		key = []byte(secret)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(passcode))
+	return hex.EncodeToString(mac.Sum(nil))
+}
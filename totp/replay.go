@@ -0,0 +1,35 @@
+package totp
+
+import (
+	"context"
+
+	"github.com/pquerna/otp"
+)
+
+// storeContext returns the context of the store operations of a
+// validation, set with WithContext.
+func (opts *ValidateOpts) storeContext() context.Context {
+	if opts.ctx != nil {
+		return opts.ctx
+	}
+	return context.Background()
+}
+
+// use marks counter as used for secret in the replay store, failing with
+// otp.ErrValidateReplayed if it already was. It does nothing without
+// replay protection.
+func (opts *ValidateOpts) use(ctx context.Context, secret string, counter uint64) error {
+	if opts.replay == nil {
+		return nil
+	}
+
+	id := otp.SecretFingerprint(secret)
+	first, err := opts.replay.Use(ctx, id, counter)
+	if err != nil {
+		return otp.WrapStoreError("Use", id, err)
+	}
+	if !first {
+		return otp.ErrValidateReplayed
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/replay"
+	"github.com/stretchr/testify/require"
+
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplayProtection(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(at))
+	require.NoError(t, err)
+
+	store := replay.NewStore(30*time.Second, 1, 0)
+	valid, err := ValidateWithOpts(code, secSha1, WithTime(at), WithReplayProtection(store))
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = ValidateWithOpts(code, secSha1, WithTime(at.Add(30*time.Second)), WithReplayProtection(store))
+	require.Equal(t, otp.ErrValidateReplayed, err)
+	require.False(t, valid)
+
+	v := NewValidator(WithReplayProtection(store))
+	_, err = v.Validate(code, secSha1, at)
+	require.Equal(t, otp.ErrValidateReplayed, err)
+
+	next, err := GenerateCodeWithOpts(secSha1, WithTime(at.Add(30*time.Second)))
+	require.NoError(t, err)
+	valid, err = v.Validate(next, secSha1, at)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestReplayPolicy(t *testing.T) {
+	policy := &otp.Policy{RequireReplay: true}
+	at := time.Unix(1111111109, 0)
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(at))
+	require.NoError(t, err)
+
+	_, err = ValidateWithOpts(code, secSha1, WithTime(at), WithPolicy(policy))
+	require.True(t, errors.Is(err, otp.ErrPolicyViolation))
+
+	valid, err := ValidateWithOpts(code, secSha1, WithTime(at), WithPolicy(policy), WithReplayProtection(replay.NewStore(30*time.Second, 1, 0)))
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestReplayMixedPeriods(t *testing.T) {
+	store := replay.NewStore(60*time.Second, 1, 0)
+	at := time.Unix(1111111109, 0)
+
+	for i := 0; i < 10; i++ {
+		now := at.Add(time.Duration(i) * time.Minute)
+		for _, period := range []uint{30, 60} {
+			code, err := GenerateCodeWithOpts(secSha1, WithTime(now), WithPeriod(period))
+			require.NoError(t, err)
+			valid, err := ValidateWithOpts(code, secSha1, WithTime(now), WithPeriod(period), WithReplayProtection(store))
+			require.NoError(t, err, "period %d at %v", period, now)
+			require.True(t, valid)
+		}
+	}
+}
+
+func TestReplayContext(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(at))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store := replay.NewStore(30*time.Second, 1, 0)
+	valid, err := ValidateWithOpts(code, secSha1, WithTime(at), WithReplayProtection(store), WithContext(ctx))
+	require.Equal(t, context.Canceled, err)
+	require.False(t, valid)
+
+	v := NewValidator(WithReplayProtection(store))
+	valid, err = v.ValidateContext(ctx, code, secSha1, at)
+	require.Equal(t, context.Canceled, err)
+	require.False(t, valid)
+
+	valid, err = v.Validate(code, secSha1, at)
+	require.NoError(t, err)
+	require.True(t, valid, "the cancelled validations did not use the passcode")
+}
@@ -18,49 +18,52 @@
 package totp
 
 import (
+	"context"
 	"io"
 
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/hotp"
 
 	"encoding/base32"
-	"math"
-	"net/url"
-	"strconv"
 	"time"
 )
 
 // Deprecated
 // use ValidateWithOpts instead
 // Validate a TOTP using the current time.
-// A shortcut for ValidateCustom, Validate uses a configuration
-// that is compatible with Google-Authenticator and most clients.
-func Validate(passcode string, secret string) bool {
-	rv, _ := ValidateCustom(
-		passcode,
-		secret,
-		time.Now().UTC(),
-		ValidateOpts{
-			Period:    30,
-			Skew:      1,
-			Digits:    otp.DigitsSix,
-			Algorithm: otp.AlgorithmSHA1,
-		},
-	)
+// A shortcut for ValidateCustom, Validate uses the package defaults,
+// which are compatible with Google-Authenticator and most clients
+// unless changed with StoreDefaults.
+// Errors are discarded, so a malformed secret looks like a wrong passcode;
+// use ValidateErr to tell them apart. validateOpts are applied on top of
+// the package defaults, eg WithClock to control the time.
+func Validate(passcode string, secret string, validateOpts ...ValidateOpt) bool {
+	rv, _ := ValidateErr(passcode, secret, validateOpts...)
 	return rv
 }
 
+// ValidateErr validates a TOTP using the current time, like Validate, but
+// returns the error that prevented validation, such as a secret that is
+// not valid base32. validateOpts are applied on top of the package defaults.
+// The current time is read from the clock set with WithClock, or from
+// otp.DefaultClock.
+func ValidateErr(passcode string, secret string, validateOpts ...ValidateOpt) (bool, error) {
+	return ValidateWithOpts(passcode, secret, validateOpts...)
+}
+
 // Deprecated
 // use GenerateWithOpts instead
 // GenerateCode creates a TOTP token using the current time.
-// A shortcut for GenerateCodeCustom, GenerateCode uses a configuration
-// that is compatible with Google-Authenticator and most clients.
+// A shortcut for GenerateCodeCustom, GenerateCode uses the package defaults,
+// which are compatible with Google-Authenticator and most clients
+// unless changed with StoreDefaults.
 func GenerateCode(secret string, t time.Time) (string, error) {
+	d := LoadDefaults()
 	return GenerateCodeCustom(secret, t, ValidateOpts{
-		Period:    30,
-		Skew:      1,
-		Digits:    otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
+		Period:    d.Period,
+		Skew:      d.Skew,
+		Digits:    d.Digits,
+		Algorithm: d.Algorithm,
 	})
 }
 
@@ -74,12 +77,73 @@ type ValidateOpts struct {
 	Skew uint
 	// Digits as part of the input. Defaults to 6.
 	Digits otp.Digits
-	// Algorithm to use for HMAC. Defaults to SHA1.
+	// Algorithm to use for HMAC. Defaults to the package default, SHA1
+	// unless changed with SetDefaults or StoreDefaults. As SHA1 is the zero
+	// value, use WithAlgorithm to select SHA1 over another package default.
 	Algorithm otp.Algorithm
+	// Epoch is the T0 periods are counted from. Defaults to the Unix epoch.
+	Epoch time.Time
+	// Encoder rendering the passcode. Defaults to decimal digits.
+	Encoder otp.Encoder
+	// Left-pad numeric input shorter than Digits with zeros, for users who
+	// drop the leading zero of codes like 012345. Defaults to false.
+	PadLeadingZeros bool
+	// Remove spaces, dashes and invisible characters and convert
+	// non-ASCII digits, as injected by copy-paste and mobile autofill.
+	// See otp.NormalizeCode. Defaults to false.
+	NormalizeInput bool
+	// Comparator matching the submitted passcode with the generated one.
+	// Defaults to otp.CompareExact.
+	Comparator otp.Comparator
+	// Extra time accepted on either side of the skew window, to absorb
+	// leap-second smearing and NTP step corrections without allowing a
+	// whole extra period. Must be less than Period. Defaults to 0.
+	BoundaryTolerance time.Duration
+	// Largest Skew to accept. Validation fails with otp.ErrValidateSkewTooLarge
+	// when Skew is larger. Defaults to the package MaxSkew; zero disables the check.
+	MaxSkew uint
 	// the time in which we would like to validate our code
 	// in the normal usage, it is equal to current time : time.Now()
 	// but for testing puposes, it could be changed to a later/future time
 	t time.Time
+	// clock read for the time when none is set, nil for otp.DefaultClock.
+	clock otp.Clock
+	// cache of decoded secrets consulted by Validator. Nil disables caching.
+	secretCache *hotp.SecretCache
+	// wall clock jump detection used by Validator.
+	clockGuard clockGuardOpts
+	// comparison of the local clock with a trusted time source.
+	drift driftCheck
+	// called with the offset of every successful match.
+	matchHook func(offset int)
+	// policy the options must comply with, nil when unrestricted.
+	policy *otp.Policy
+	// called with the record of every validation.
+	recorder func(rec ValidationRecord)
+	// records include the submitted passcode.
+	recordPasscodes bool
+	// accepted passcodes, nil without replay protection.
+	replay otp.ReplayStore
+	// attempts accepted per secret, unlimited without a store.
+	rateLimit rateLimit
+	// context of the store operations, context.Background when nil.
+	ctx context.Context
+	// Algorithm was set by an option or seeded from the package defaults,
+	// so a zero Algorithm means SHA1 rather than unset.
+	algorithmSet bool
+}
+
+// hotpOpts returns the options for the underlying HOTP operations.
+func (opts *ValidateOpts) hotpOpts() hotp.ValidateOpts {
+	return hotp.ValidateOpts{
+		Digits:    opts.Digits,
+		Algorithm: opts.Algorithm,
+		Encoder:   opts.Encoder,
+
+		PadLeadingZeros: opts.PadLeadingZeros,
+		NormalizeInput:  opts.NormalizeInput,
+		Comparator:      opts.Comparator,
+	}
 }
 
 // Deprecated
@@ -87,14 +151,17 @@ type ValidateOpts struct {
 // secret and the provided opts. (Under the hood, this is making an adapted
 // call to hotp.GenerateCodeCustom)
 func GenerateCodeCustom(secret string, t time.Time, opts ValidateOpts) (passcode string, err error) {
+	return opts.GenerateCode(secret, t)
+}
+
+// GenerateCode produces the passcode for secret at time t. Unset options
+// take the package defaults.
+func (opts ValidateOpts) GenerateCode(secret string, t time.Time) (passcode string, err error) {
 
 	opts.defaultOpts()
 
-	counter := uint64(math.Floor(float64(t.Unix()) / float64(opts.Period)))
-	passcode, err = hotp.GenerateCodeCustom(secret, counter, hotp.ValidateOpts{
-		Digits:    opts.Digits,
-		Algorithm: opts.Algorithm,
-	})
+	counter := uint64(opts.counterAt(t))
+	passcode, err = hotp.GenerateCodeCustom(secret, counter, opts.hotpOpts())
 	if err != nil {
 		return "", err
 	}
@@ -106,35 +173,15 @@ func GenerateCodeCustom(secret string, t time.Time, opts ValidateOpts) (passcode
 // ValidateCustom validates a TOTP given a user specified time and custom options.
 // Most users should use Validate() to provide an interpolatable TOTP experience.
 func ValidateCustom(passcode string, secret string, t time.Time, opts ValidateOpts) (bool, error) {
+	return opts.Validate(passcode, secret, t)
+}
 
+// Validate checks passcode against secret at time t. Unset options take
+// the package defaults. A ValidateOpts can be built once and reused, which
+// avoids expanding functional options on every call.
+func (opts ValidateOpts) Validate(passcode string, secret string, t time.Time) (bool, error) {
 	opts.defaultOpts()
-
-	counters := []uint64{}
-	counter := int64(math.Floor(float64(t.Unix()) / float64(opts.Period)))
-
-	counters = append(counters, uint64(counter))
-	for i := 1; i <= int(opts.Skew); i++ {
-		counters = append(counters, uint64(counter+int64(i)))
-		counters = append(counters, uint64(counter-int64(i)))
-	}
-
-	for _, counter := range counters {
-
-		rv, err := hotp.ValidateCustom(passcode, counter, secret, hotp.ValidateOpts{
-			Digits:    opts.Digits,
-			Algorithm: opts.Algorithm,
-		})
-
-		if err != nil {
-			return false, err
-		}
-
-		if rv {
-			return true, nil
-		}
-	}
-
-	return false, nil
+	return opts.validate(passcode, secret, t)
 }
 
 // GenerateOpts provides options for Generate().  The default values
@@ -152,10 +199,31 @@ type GenerateOpts struct {
 	Secret []byte
 	// Digits to request. Defaults to 6.
 	Digits otp.Digits
-	// Algorithm to use for HMAC. Defaults to SHA1.
+	// Algorithm to use for HMAC. Defaults to the package default, SHA1
+	// unless changed with SetDefaults or StoreDefaults. As SHA1 is the zero
+	// value, use WithGenAlgorithm to select SHA1 over another package
+	// default.
 	Algorithm otp.Algorithm
+	// Epoch is the T0 periods are counted from, stored in the key's t0
+	// parameter. Defaults to the Unix epoch.
+	Epoch time.Time
 	// Reader to use for generating TOTP Key.
 	Rand io.Reader
+	// Label builds the label shown by authenticator apps.
+	// Defaults to "Issuer:AccountName".
+	Label otp.LabelFunc
+	// Generate personal-use keys without an Issuer, whose label is only
+	// the AccountName, instead of failing with otp.ErrGenerateMissingIssuer.
+	AllowMissingIssuer bool
+	// ValidateAccountName enforces a naming policy on AccountName, eg
+	// otp.ValidateEmail. Its error is reported for the AccountName field.
+	ValidateAccountName func(name string) error
+	// Policy restricting the digits and algorithm. Violations are reported
+	// for the Digits and Algorithm fields. Defaults to no restriction.
+	Policy *otp.Policy
+	// Algorithm was set by an option or seeded from the package defaults,
+	// so a zero Algorithm means SHA1 rather than unset.
+	algorithmSet bool
 }
 
 var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
@@ -171,29 +239,32 @@ func Generate(opts GenerateOpts) (*otp.Key, error) {
 
 	// otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example
 
-	v := url.Values{}
-	if len(opts.Secret) != 0 {
-		v.Set("secret", b32NoPadding.EncodeToString(opts.Secret))
-	} else {
-		secret := make([]byte, opts.SecretSize)
-		_, err := opts.Rand.Read(secret)
+	secret := opts.Secret
+	if len(secret) == 0 {
+		var err error
+		secret, err = otp.ReadSecret(opts.Rand, int(opts.SecretSize))
 		if err != nil {
 			return nil, err
 		}
-		v.Set("secret", b32NoPadding.EncodeToString(secret))
 	}
 
-	v.Set("issuer", opts.Issuer)
-	v.Set("period", strconv.FormatUint(uint64(opts.Period), 10))
-	v.Set("algorithm", opts.Algorithm.String())
-	v.Set("digits", opts.Digits.String())
-
-	u := url.URL{
-		Scheme:   "otpauth",
-		Host:     "totp",
-		Path:     "/" + opts.Issuer + ":" + opts.AccountName,
-		RawQuery: v.Encode(),
+	var label string
+	if opts.Label != nil {
+		label = opts.Label(opts.Issuer, opts.AccountName)
 	}
 
-	return otp.NewKeyFromURL(u.String())
+	key := otp.NewKey(otp.KeyOpts{
+		Type:        "totp",
+		Issuer:      opts.Issuer,
+		AccountName: opts.AccountName,
+		Secret:      b32NoPadding.EncodeToString(secret),
+		Period:      opts.Period,
+		Digits:      opts.Digits,
+		Algorithm:   opts.Algorithm,
+		Label:       label,
+	})
+	if !opts.Epoch.IsZero() {
+		key = key.Clone(otp.WithEpoch(opts.Epoch))
+	}
+	return key, nil
 }
@@ -2,8 +2,7 @@ package totp
 
 import (
 	"math"
-	"net/url"
-	"strconv"
+	"time"
 
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/hotp"
@@ -20,38 +19,65 @@ func ValidateWithOpts(passcode, secret string, validateOpts ...ValidateOpt) (boo
 // This replicates ValidateCustomOpt
 func validateCustomOpt(passcode, secret string, validateOpts ...ValidateOpt) (bool, error) {
 
-	opts := new(ValidateOpts)
+	opts := newValidateOpts(validateOpts...)
 
-	for _, opt := range validateOpts {
-		opt(opts)
+	return opts.validate(passcode, secret, opts.t)
+}
+
+// validate checks passcode against secret at time t, using options that
+// already have their defaults.
+func (opts *ValidateOpts) validate(passcode, secret string, t time.Time) (ok bool, err error) {
+	if opts.recorder != nil {
+		defer func() { opts.record(passcode, secret, t, ok, err) }()
 	}
-	opts.defaultOpts()
 
-	counters := []uint64{}
-	counter := int64(math.Floor(float64(opts.t.Unix()) / float64(opts.Period)))
+	if err := opts.check(); err != nil {
+		return false, err
+	}
+	if err := opts.clockGuard.check(); err != nil {
+		return false, err
+	}
 
-	counters = append(counters, uint64(counter))
-	for i := 1; i <= int(opts.Skew); i++ {
-		counters = append(counters, uint64(counter+int64(i)))
-		counters = append(counters, uint64(counter-int64(i)))
+	if err := opts.drift.check(opts.now); err != nil {
+		return false, err
 	}
 
-	for _, counter := range counters {
-		rv, err := hotp.ValidateCustom(passcode, counter, secret, hotp.ValidateOpts{
-			Digits:    opts.Digits,
-			Algorithm: opts.Algorithm,
-		})
+	ctx := opts.storeContext()
+	if err := opts.rateLimit.attempt(ctx, secret); err != nil {
+		return false, err
+	}
 
-		if err != nil {
-			return false, err
-		}
+	hotpOpts := opts.hotpOpts()
+	passcode = hotp.NormalizePasscode(passcode, hotpOpts)
+	if len(passcode) != opts.Digits.Length() {
+		return false, otp.ErrValidateInputInvalidLength
+	}
 
-		if rv {
-			return true, nil
-		}
+	key, err := hotp.DecodeSecret(secret)
+	if err != nil {
+		return false, err
 	}
 
-	return false, nil
+	compare := otp.CompareExact
+	if opts.Comparator != nil {
+		compare = opts.Comparator
+	}
+
+	// Every counter of the window is compared, see hotp.Generator.Match.
+	g := hotp.NewGenerator(key, hotpOpts)
+	counter, ok := g.Match([]byte(passcode), opts.counters(nil, t), compare)
+	if !ok {
+		return false, nil
+	}
+
+	if err := opts.use(ctx, secret, counter); err != nil {
+		return false, err
+	}
+	if err := opts.rateLimit.reset(ctx, secret); err != nil {
+		return false, err
+	}
+	opts.observeMatch(counter, t)
+	return true, nil
 }
 
 //
@@ -62,42 +88,41 @@ func validateCustomOpt(passcode, secret string, validateOpts ...ValidateOpt) (bo
 // GenerateWitOpts(WithAccountName("example account"))
 func GenerateWithOpts(genOpts ...GenerateOpt) (*otp.Key, error) {
 
-	opts := new(GenerateOpts)
-
-	for _, opt := range genOpts {
-		opt(opts)
-	}
+	opts := newGenerateOpts(genOpts...)
 
 	if err := opts.defaults(); err != nil {
 		return nil, err
 	}
 	// otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&issuer=Example
 
-	v := url.Values{}
-
-	if opts.Secret != nil {
-		v.Set("secret", b32NoPadding.EncodeToString(opts.Secret))
-	} else {
-		secret := make([]byte, opts.SecretSize)
-		_, err := opts.Rand.Read(secret)
+	secret := opts.Secret
+	if len(secret) == 0 {
+		var err error
+		secret, err = otp.ReadSecret(opts.Rand, int(opts.SecretSize))
 		if err != nil {
 			return nil, err
 		}
-		v.Set("secret", b32NoPadding.EncodeToString(secret))
 	}
 
-	v.Set("issuer", opts.Issuer)
-	v.Set("period", strconv.FormatUint(uint64(opts.Period), 10))
-	v.Set("algorithm", opts.Algorithm.String())
-	v.Set("digits", opts.Digits.String())
+	var label string
+	if opts.Label != nil {
+		label = opts.Label(opts.Issuer, opts.AccountName)
+	}
 
-	u := url.URL{
-		Scheme:   "otpauth",
-		Host:     "totp",
-		Path:     "/" + opts.Issuer + ":" + opts.AccountName,
-		RawQuery: v.Encode(),
+	key := otp.NewKey(otp.KeyOpts{
+		Type:        "totp",
+		Issuer:      opts.Issuer,
+		AccountName: opts.AccountName,
+		Secret:      b32NoPadding.EncodeToString(secret),
+		Period:      opts.Period,
+		Digits:      opts.Digits,
+		Algorithm:   opts.Algorithm,
+		Label:       label,
+	})
+	if !opts.Epoch.IsZero() {
+		key = key.Clone(otp.WithEpoch(opts.Epoch))
 	}
-	return otp.NewKeyFromURL(u.String())
+	return key, nil
 }
 
 // GenerateCodeWithOpts takes a timepoint and produces a passcode using a
@@ -105,20 +130,55 @@ func GenerateWithOpts(genOpts ...GenerateOpt) (*otp.Key, error) {
 // call to hotp.GenerateCodeCustom)
 func GenerateCodeWithOpts(secret string, validateOpts ...ValidateOpt) (passcode string, err error) {
 
-	opts := new(ValidateOpts)
-
-	for _, opt := range validateOpts {
-		opt(opts)
-	}
-	opts.defaultOpts()
+	opts := newValidateOpts(validateOpts...)
 
-	counter := uint64(math.Floor(float64(opts.t.Unix()) / float64(opts.Period)))
-	passcode, err = hotp.GenerateCodeCustom(secret, counter, hotp.ValidateOpts{
-		Digits:    opts.Digits,
-		Algorithm: opts.Algorithm,
-	})
+	counter := uint64(opts.counterAt(opts.t))
+	passcode, err = hotp.GenerateCodeCustom(secret, counter, opts.hotpOpts())
 	if err != nil {
 		return "", err
 	}
 	return passcode, nil
 }
+
+// counterAt returns the TOTP counter for t.
+func (opts *ValidateOpts) counterAt(t time.Time) int64 {
+	return int64(math.Floor(float64(t.Unix()-opts.epoch()) / float64(opts.Period)))
+}
+
+// periodStart returns the start of the period of counter.
+func (opts *ValidateOpts) periodStart(counter int64) time.Time {
+	return time.Unix(opts.epoch()+counter*int64(opts.Period), 0).UTC()
+}
+
+// epoch returns Epoch in Unix seconds, 0 when it is not set.
+func (opts *ValidateOpts) epoch() int64 {
+	if opts.Epoch.IsZero() {
+		return 0
+	}
+	return opts.Epoch.Unix()
+}
+
+// counters appends to dst the counters validation must try at t: the
+// current counter first, then the skew window alternating forward and
+// backward, then any extra counter reached within BoundaryTolerance.
+func (opts *ValidateOpts) counters(dst []uint64, t time.Time) []uint64 {
+	counter := opts.counterAt(t)
+
+	dst = append(dst, uint64(counter))
+	for i := 1; i <= int(opts.Skew); i++ {
+		dst = append(dst, uint64(counter+int64(i)))
+		dst = append(dst, uint64(counter-int64(i)))
+	}
+
+	if opts.BoundaryTolerance > 0 {
+		edge := time.Duration(opts.Skew*opts.Period)*time.Second + opts.BoundaryTolerance
+		if lo := opts.counterAt(t.Add(-edge)); lo < counter-int64(opts.Skew) {
+			dst = append(dst, uint64(lo))
+		}
+		if hi := opts.counterAt(t.Add(edge)); hi > counter+int64(opts.Skew) {
+			dst = append(dst, uint64(hi))
+		}
+	}
+
+	return dst
+}
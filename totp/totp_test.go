@@ -22,7 +22,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"bytes"
 	"encoding/base32"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -252,3 +255,408 @@ func TestGoogleLowerCaseSecret(t *testing.T) {
 	valid := Validate(code, w.Secret())
 	require.True(t, valid)
 }
+
+func TestValidateMaxSkew(t *testing.T) {
+	valid, err := ValidateWithOpts("94287082", secSha1,
+		WithTime(time.Unix(59, 0).UTC()),
+		WithDigits(otp.DigitsEight),
+		WithSkew(20),
+		WithMaxSkew(10),
+	)
+	require.True(t, errors.Is(err, otp.ErrValidateSkewTooLarge))
+	require.False(t, valid)
+
+	valid, err = ValidateCustom("94287082", secSha1, time.Unix(59, 0).UTC(),
+		ValidateOpts{
+			Digits:  otp.DigitsEight,
+			Skew:    20,
+			MaxSkew: 10,
+		})
+	require.True(t, errors.Is(err, otp.ErrValidateSkewTooLarge))
+	require.False(t, valid)
+
+	orig := LoadDefaults()
+	defer StoreDefaults(orig)
+	d := orig
+	d.MaxSkew = 2
+	StoreDefaults(d)
+
+	_, err = NewValidator(WithSkew(3)).Validate("123456", secSha1, time.Now())
+	require.True(t, errors.Is(err, otp.ErrValidateSkewTooLarge))
+
+	valid, err = ValidateWithOpts("94287082", secSha1,
+		WithTime(time.Unix(59, 0).UTC()),
+		WithDigits(otp.DigitsEight),
+		WithSkew(2),
+	)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestUnsupportedAlgorithm(t *testing.T) {
+	_, err := GenerateCodeWithOpts(secSha1, WithAlgorithm(otp.Algorithm(99)))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedAlgorithm))
+
+	_, err = ValidateWithOpts("123456", secSha1, WithAlgorithm(otp.Algorithm(99)))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedAlgorithm))
+	require.True(t, errors.Is(err, otp.ErrInvalidOption))
+
+	var optErr *otp.OptionError
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "Algorithm", optErr.Name)
+
+	_, err = GenerateWithOpts(
+		WithIssuer("SnakeOil"),
+		WithAccountName("alice@example.com"),
+		WithGenAlgorithm(otp.Algorithm(99)),
+	)
+	require.True(t, errors.Is(err, otp.ErrUnsupportedAlgorithm))
+}
+
+func TestValidateBoundaryTolerance(t *testing.T) {
+	// 94287082 is the code for counter 1 (t=30..59). With skew 1 it is
+	// rejected from t=90, but accepted up to t=92 with a 2s tolerance.
+	v := NewValidator(WithDigits(otp.DigitsEight), WithBoundaryTolerance(2*time.Second))
+	valid, err := v.Validate("94287082", secSha1, time.Unix(91, 0).UTC())
+	require.NoError(t, err)
+	require.True(t, valid, "code within tolerance of the skew window")
+
+	valid, err = v.Validate("94287082", secSha1, time.Unix(93, 0).UTC())
+	require.NoError(t, err)
+	require.False(t, valid, "code outside tolerance of the skew window")
+
+	_, err = ValidateWithOpts("94287082", secSha1, WithBoundaryTolerance(30*time.Second))
+	require.True(t, errors.Is(err, otp.ErrValidateToleranceTooLarge))
+}
+
+func TestValidateErr(t *testing.T) {
+	code, err := GenerateCode(secSha1, time.Now().UTC())
+	require.NoError(t, err)
+
+	valid, err := ValidateErr(code, secSha1)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = ValidateErr(code, "not base32!")
+	require.True(t, errors.Is(err, otp.ErrValidateSecretInvalidBase32))
+	require.False(t, valid)
+	require.False(t, Validate(code, "not base32!"))
+}
+
+func TestMust(t *testing.T) {
+	k := MustGenerate(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"))
+	require.Equal(t, "SnakeOil", k.Issuer())
+
+	require.Equal(t, "94287082", MustGenerateCode(secSha1,
+		WithTime(time.Unix(59, 0).UTC()), WithDigits(otp.DigitsEight)))
+
+	require.Panics(t, func() { MustGenerate(WithIssuer("SnakeOil")) })
+	require.Panics(t, func() { MustGenerateCode("not base32!") })
+}
+
+func TestEncoder(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(now), WithEncoder(otp.EncoderHex))
+	require.NoError(t, err)
+	require.Len(t, code, 6)
+
+	valid, err := NewValidator(WithEncoder(otp.EncoderHex)).Validate(code, secSha1, now)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	decimal, err := GenerateCodeWithOpts(secSha1, WithTime(now), WithEncoder(otp.EncoderDecimal))
+	require.NoError(t, err)
+	plain, err := GenerateCodeWithOpts(secSha1, WithTime(now))
+	require.NoError(t, err)
+	require.Equal(t, plain, decimal)
+}
+
+func TestLeadingZeroPadding(t *testing.T) {
+	// 07081804 is an eight digit RFC 6238 code with a leading zero.
+	ts := WithTime(time.Unix(1111111109, 0).UTC())
+
+	_, err := ValidateWithOpts("7081804", secSha1, ts, WithDigits(otp.DigitsEight))
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err, "padding is off by default")
+
+	valid, err := ValidateWithOpts("7081804", secSha1, ts, WithDigits(otp.DigitsEight), WithLeadingZeroPadding())
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, err = NewValidator(WithDigits(otp.DigitsEight), WithLeadingZeroPadding()).
+		Validate(" 7081804", secSha1, time.Unix(1111111109, 0).UTC())
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestGenerateLabel(t *testing.T) {
+	k, err := GenerateWithOpts(
+		WithIssuer("ACME"),
+		WithAccountName("jane"),
+		WithLabel(func(issuer, accountName string) string {
+			return issuer + "-PROD / " + accountName
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "ACME", k.Issuer())
+	require.Equal(t, "ACME-PROD / jane", k.AccountName())
+	require.Contains(t, k.String(), "otpauth://totp/ACME-PROD%20%2F%20jane?")
+}
+
+func TestGenerateError(t *testing.T) {
+	_, err := Generate(GenerateOpts{
+		SecretSize: 4,
+		Digits:     otp.Digits(5),
+	})
+
+	var genErr *otp.GenerateError
+	require.True(t, errors.As(err, &genErr))
+	require.Len(t, genErr.Fields, 4)
+	require.True(t, errors.Is(genErr.Field("Issuer"), otp.ErrGenerateMissingIssuer))
+	require.True(t, errors.Is(genErr.Field("AccountName"), otp.ErrGenerateMissingAccountName))
+	require.True(t, errors.Is(genErr.Field("SecretSize"), otp.ErrGenerateSecretTooShort))
+	require.True(t, errors.Is(genErr.Field("Digits"), otp.ErrUnsupportedDigits))
+	require.Nil(t, genErr.Field("Algorithm"))
+
+	require.True(t, errors.Is(err, otp.ErrGenerateMissingIssuer))
+	require.True(t, errors.Is(err, otp.ErrInvalidOption))
+
+	var optErr *otp.OptionError
+	require.True(t, errors.As(err, &optErr))
+	require.Equal(t, "Issuer", optErr.Name)
+}
+
+func TestValidateOptsMethods(t *testing.T) {
+	opts := ValidateOpts{Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA1}
+	for _, tx := range rfcMatrixTCs {
+		if tx.Mode != otp.AlgorithmSHA1 {
+			continue
+		}
+		ts := time.Unix(tx.TS, 0).UTC()
+
+		code, err := opts.GenerateCode(tx.Secret, ts)
+		require.NoError(t, err)
+		require.Equal(t, tx.TOTP, code)
+
+		valid, err := opts.Validate(tx.TOTP, tx.Secret, ts)
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+
+	require.Equal(t, otp.DigitsEight, opts.Digits, "opts are not modified")
+	require.Zero(t, opts.Period)
+}
+
+func TestValidateTimeFunc(t *testing.T) {
+	now := func() time.Time { return time.Unix(1111111109, 0) }
+
+	require.True(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight), WithTimeFunc(now)))
+	require.False(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight)))
+
+	calls := 0
+	counting := func() time.Time {
+		calls++
+		return now()
+	}
+	opt := WithTimeFunc(counting)
+	require.True(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight), opt))
+	require.True(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight), opt))
+	require.Equal(t, 2, calls, "the clock is read on every validation")
+
+	at := now()
+	v := NewValidator(WithDigits(otp.DigitsEight), WithTimeFunc(func() time.Time { return at }))
+	valid, err := v.Validate("07081804", secSha1, time.Time{})
+	require.NoError(t, err)
+	require.True(t, valid)
+	at = at.Add(time.Hour)
+	valid, err = v.Validate("07081804", secSha1, time.Time{})
+	require.NoError(t, err)
+	require.False(t, valid, "the Validator reads the time on every validation")
+
+	require.False(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight),
+		WithTime(now()), WithTimeFunc(func() time.Time { return at })), "the last option wins")
+}
+
+func TestValidateClock(t *testing.T) {
+	clock := otp.ClockFunc(func() time.Time { return time.Unix(1111111109, 0) })
+
+	require.True(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight), WithClock(clock)))
+	require.Equal(t, "07081804", MustGenerateCode(secSha1, WithDigits(otp.DigitsEight), WithClock(clock)))
+	require.False(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight),
+		WithClock(clock), WithTime(time.Unix(2000000000, 0))), "WithTime takes precedence")
+
+	otp.SetDefaultClock(clock)
+	defer otp.SetDefaultClock(nil)
+	require.True(t, Validate("07081804", secSha1, WithDigits(otp.DigitsEight)))
+	valid, err := ValidateErr("07081804", secSha1, WithDigits(otp.DigitsEight))
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestInputNormalization(t *testing.T) {
+	ts := time.Unix(1111111109, 0).UTC()
+	pasted := "0708‑1804 "
+
+	valid, err := ValidateWithOpts(pasted, secSha1, WithDigits(otp.DigitsEight), WithTime(ts))
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err)
+	require.False(t, valid)
+
+	valid, err = ValidateWithOpts(pasted, secSha1, WithDigits(otp.DigitsEight), WithTime(ts), WithInputNormalization())
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	v := NewValidator(WithDigits(otp.DigitsEight), WithInputNormalization())
+	valid, err = v.Validate("０７０８ １８０４", secSha1, ts)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestComparator(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(now), WithEncoder(otp.EncoderCrockford))
+	require.NoError(t, err)
+	lower := strings.ToLower(code)
+	require.NotEqual(t, code, lower, "code %s has letters", code)
+
+	valid, err := ValidateWithOpts(lower, secSha1, WithTime(now), WithEncoder(otp.EncoderCrockford))
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	valid, err = ValidateWithOpts(lower, secSha1, WithTime(now), WithEncoder(otp.EncoderCrockford), WithComparator(otp.CompareFold))
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	v := NewValidator(WithEncoder(otp.EncoderCrockford), WithComparator(otp.CompareFold))
+	valid, err = v.Validate(lower, secSha1, now)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestGenerateWithoutIssuer(t *testing.T) {
+	_, err := GenerateWithOpts(WithAccountName("alice@example.com"))
+	require.True(t, errors.Is(err, otp.ErrGenerateMissingIssuer))
+
+	k, err := GenerateWithOpts(WithAccountName("alice@example.com"), WithoutIssuer())
+	require.NoError(t, err)
+	require.Equal(t, "", k.Issuer())
+	require.Equal(t, "alice@example.com", k.AccountName())
+	require.True(t, strings.HasPrefix(k.String(), "otpauth://totp/alice@example.com?"))
+	require.NotContains(t, k.String(), "issuer=")
+}
+
+func TestGenerateAccountNameValidator(t *testing.T) {
+	_, err := GenerateWithOpts(WithIssuer("SnakeOil"), WithAccountName("alice"), WithAccountNameValidator(otp.ValidateEmail))
+	require.True(t, errors.Is(err, otp.ErrInvalidAccountName))
+
+	var genErr *otp.GenerateError
+	require.True(t, errors.As(err, &genErr))
+	require.Error(t, genErr.Field("AccountName"))
+
+	_, err = GenerateWithOpts(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"), WithAccountNameValidator(otp.ValidateEmail))
+	require.NoError(t, err)
+}
+
+func TestPolicy(t *testing.T) {
+	p := &otp.Policy{Algorithms: []otp.Algorithm{otp.AlgorithmSHA256}, MinDigits: otp.DigitsEight, MaxSkew: 1}
+
+	_, err := GenerateWithOpts(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"), WithGenPolicy(p))
+	require.True(t, errors.Is(err, otp.ErrPolicyViolation))
+
+	key, err := GenerateWithOpts(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"), WithGenPolicy(p),
+		WithGenAlgorithm(otp.AlgorithmSHA256), WithGenDigits(otp.DigitsEight))
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := key.GenerateCode(now)
+	require.NoError(t, err)
+
+	ok, err := ValidateWithOpts(code, key.Secret(), WithTime(now), WithPolicy(p),
+		WithAlgorithm(otp.AlgorithmSHA256), WithDigits(otp.DigitsEight))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = ValidateWithOpts(code, key.Secret(), WithTime(now), WithPolicy(p),
+		WithAlgorithm(otp.AlgorithmSHA256), WithDigits(otp.DigitsEight), WithSkew(3), WithMaxSkew(0))
+	require.True(t, errors.Is(err, otp.ErrPolicyViolation))
+
+	p.RequireReplay = true
+	_, err = ValidateWithOpts(code, key.Secret(), WithTime(now), WithPolicy(p),
+		WithAlgorithm(otp.AlgorithmSHA256), WithDigits(otp.DigitsEight))
+	require.True(t, errors.Is(err, otp.ErrPolicyViolation), "no replay protection is configured")
+}
+
+func TestStructOptions(t *testing.T) {
+	ts := time.Unix(1111111109, 0)
+	vopts := ValidateOpts{Digits: otp.DigitsEight, Period: 60, Skew: 1}
+	code, err := vopts.GenerateCode(secSha1, ts.Add(-time.Minute))
+	require.NoError(t, err)
+
+	valid, err := ValidateWithOpts(code, secSha1, vopts.Option(), WithTime(ts))
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid, err = ValidateWithOpts(code, secSha1, vopts.Option(), WithTime(ts.Add(time.Minute)))
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	key, err := GenerateWithOpts(GenerateOpts{Issuer: "Example", AccountName: "alice", Period: 60}.Option(), WithGenDigits(otp.DigitsEight))
+	require.NoError(t, err)
+	require.Equal(t, uint64(60), key.Period())
+	require.Equal(t, otp.DigitsEight, key.Digits())
+
+	// Options before Option are kept unless it sets the field.
+	valid, err = ValidateWithOpts(code, secSha1, WithSkew(2), ValidateOpts{Digits: otp.DigitsEight, Period: 60}.Option(), WithTime(ts.Add(time.Minute)))
+	require.NoError(t, err)
+	require.True(t, valid, "the skew of WithSkew applies")
+
+	key, err = GenerateWithOpts(WithGenDigits(otp.DigitsEight), GenerateOpts{Issuer: "Example", AccountName: "alice"}.Option())
+	require.NoError(t, err)
+	require.Equal(t, otp.DigitsEight, key.Digits())
+}
+
+func TestEpoch(t *testing.T) {
+	t0 := time.Unix(1000000015, 0)
+	at := time.Unix(1111111109, 0)
+	want, err := GenerateCodeWithOpts(secSha1, WithTime(at.Add(-1000000015*time.Second)))
+	require.NoError(t, err)
+
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(at), WithEpoch(t0))
+	require.NoError(t, err)
+	require.Equal(t, want, code)
+
+	valid, err := ValidateWithOpts(code, secSha1, WithTime(at), WithEpoch(t0))
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid, err = ValidateWithOpts(code, secSha1, WithTime(at))
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	windows, err := Diagnose(code, secSha1, WithTime(at), WithEpoch(t0))
+	require.NoError(t, err)
+	for _, w := range windows {
+		require.Equal(t, w.Offset == 0, w.Match)
+		if w.Match {
+			require.False(t, w.Start.After(at))
+			require.True(t, w.Start.Add(30*time.Second).After(at))
+		}
+	}
+
+	key, err := GenerateWithOpts(WithIssuer("Example"), WithAccountName("alice"), WithGenEpoch(t0))
+	require.NoError(t, err)
+	require.True(t, t0.Equal(key.Epoch()))
+
+	// Keys and options agree before T0 too.
+	before := t0.Add(-15 * time.Second)
+	want, err = key.GenerateCode(before)
+	require.NoError(t, err)
+	code, err = GenerateCodeWithOpts(key.Secret(), WithTime(before), WithEpoch(t0))
+	require.NoError(t, err)
+	require.Equal(t, want, code)
+}
+
+func TestGenerateWithRand(t *testing.T) {
+	k, err := GenerateWithOpts(WithIssuer("SnakeOil"), WithAccountName("alice@example.com"),
+		WithRand(bytes.NewReader([]byte("12345678901234567890"))))
+	require.NoError(t, err)
+	require.Equal(t, secSha1, k.Secret())
+}
@@ -0,0 +1,133 @@
+package totp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+)
+
+// Validator validates TOTP passcodes against a fixed set of options.
+// The options are expanded and defaulted once in NewValidator, so the
+// per-call cost of Validate is limited to decoding the secret and the
+// HMAC operations themselves.
+// A Validator is safe for concurrent use.
+type Validator struct {
+	opts     ValidateOpts
+	hotpOpts hotp.ValidateOpts
+	compare  otp.Comparator
+	bufs     sync.Pool
+	// err is returned by every call to Validate when the options are unusable.
+	err error
+	// guard detects wall clock jumps, nil when disabled.
+	guard *clockGuard
+}
+
+// validatorBufs are the scratch buffers reused between calls to Validate.
+type validatorBufs struct {
+	counters []uint64
+}
+
+// NewValidator creates a Validator using the provided options.
+// Any time set with WithTime is ignored; the time is passed to Validate.
+// The clock set with WithClock or WithTimeFunc is read when that time is
+// zero, and compared with the time source of WithClockDriftLimit.
+// Package defaults are captured when the Validator is created.
+func NewValidator(validateOpts ...ValidateOpt) *Validator {
+	opts := newValidateOpts(validateOpts...)
+
+	v := &Validator{
+		opts:     *opts,
+		hotpOpts: opts.hotpOpts(),
+		compare:  otp.CompareExact,
+		err:      opts.check(),
+	}
+	if opts.Comparator != nil {
+		v.compare = opts.Comparator
+	}
+	if opts.clockGuard.enabled() {
+		v.guard = &clockGuard{clockGuardOpts: opts.clockGuard}
+	}
+	v.bufs.New = func() interface{} {
+		return &validatorBufs{
+			counters: make([]uint64, 0, 2*opts.Skew+3),
+		}
+	}
+
+	return v
+}
+
+// Validate checks passcode against secret at time t, or at the time of
+// the clock of the Validator when t is zero. Stores are passed the context
+// set with WithContext.
+func (v *Validator) Validate(passcode string, secret string, t time.Time) (ok bool, err error) {
+	return v.ValidateContext(v.opts.storeContext(), passcode, secret, t)
+}
+
+// ValidateContext is Validate passing ctx to the stores consulted by
+// validation, such as the replay and rate limiting stores.
+func (v *Validator) ValidateContext(ctx context.Context, passcode string, secret string, t time.Time) (ok bool, err error) {
+	if t.IsZero() {
+		t = v.opts.now().UTC()
+	}
+
+	if v.opts.recorder != nil {
+		defer func() { v.opts.record(passcode, secret, t, ok, err) }()
+	}
+
+	if v.err != nil {
+		return false, v.err
+	}
+
+	if v.guard != nil {
+		if err := v.guard.observe(); err != nil {
+			return false, err
+		}
+	}
+
+	if err := v.opts.drift.check(v.opts.now); err != nil {
+		return false, err
+	}
+
+	if err := v.opts.rateLimit.attempt(ctx, secret); err != nil {
+		return false, err
+	}
+
+	passcode = hotp.NormalizePasscode(passcode, v.hotpOpts)
+
+	if len(passcode) != v.opts.Digits.Length() {
+		return false, otp.ErrValidateInputInvalidLength
+	}
+
+	key, err := v.opts.secretCache.Decode(secret)
+	if err != nil {
+		return false, err
+	}
+
+	counter, ok := v.validateKey(passcode, key, t)
+	if ok {
+		if err := v.opts.use(ctx, secret, counter); err != nil {
+			return false, err
+		}
+		if err := v.opts.rateLimit.reset(ctx, secret); err != nil {
+			return false, err
+		}
+		v.opts.observeMatch(counter, t)
+	}
+
+	return ok, nil
+}
+
+// validateKey checks an already trimmed passcode against decoded key material
+// and returns the matching counter.
+func (v *Validator) validateKey(passcode string, key []byte, t time.Time) (uint64, bool) {
+	g := hotp.NewGenerator(key, v.hotpOpts)
+
+	bufs := v.bufs.Get().(*validatorBufs)
+	defer v.bufs.Put(bufs)
+
+	bufs.counters = v.opts.counters(bufs.counters[:0], t)
+	return g.Match([]byte(passcode), bufs.counters, v.compare)
+}
@@ -0,0 +1,78 @@
+package totp
+
+import (
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/stretchr/testify/require"
+
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidatorRFCMatrix(t *testing.T) {
+	for _, mode := range []otp.Algorithm{otp.AlgorithmSHA1, otp.AlgorithmSHA256, otp.AlgorithmSHA512} {
+		v := NewValidator(WithDigits(otp.DigitsEight), WithAlgorithm(mode))
+		for _, tx := range rfcMatrixTCs {
+			if tx.Mode != mode {
+				continue
+			}
+			valid, err := v.Validate(tx.TOTP, tx.Secret, time.Unix(tx.TS, 0).UTC())
+			require.NoError(t, err,
+				"unexpected error totp=%s mode=%v ts=%v", tx.TOTP, tx.Mode, tx.TS)
+			require.True(t, valid,
+				"unexpected totp failure totp=%s mode=%v ts=%v", tx.TOTP, tx.Mode, tx.TS)
+		}
+	}
+}
+
+func TestValidatorSkew(t *testing.T) {
+	v := NewValidator(WithDigits(otp.DigitsEight))
+
+	for _, ts := range []int64{29, 59, 61} {
+		valid, err := v.Validate("94287082", secSha1, time.Unix(ts, 0).UTC())
+		require.NoError(t, err)
+		require.True(t, valid, "ts=%v", ts)
+	}
+
+	valid, err := v.Validate("94287082", secSha1, time.Unix(120, 0).UTC())
+	require.NoError(t, err)
+	require.False(t, valid)
+}
+
+func TestValidatorInvalid(t *testing.T) {
+	v := NewValidator()
+
+	valid, err := v.Validate("foo", secSha1, time.Now())
+	require.Equal(t, otp.ErrValidateInputInvalidLength, err)
+	require.False(t, valid)
+
+	valid, err = v.Validate("123456", "not base32!", time.Now())
+	require.True(t, errors.Is(err, otp.ErrValidateSecretInvalidBase32))
+	require.False(t, valid)
+}
+
+func BenchmarkValidator(b *testing.B) {
+	v := NewValidator()
+	now := time.Now()
+	code, err := GenerateCodeWithOpts(secSha1, WithTime(now))
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v.Validate(code, secSha1, now)
+	}
+}
+
+func TestValidatorSecretCache(t *testing.T) {
+	c := hotp.NewSecretCache(16)
+	v := NewValidator(WithDigits(otp.DigitsEight), WithSecretCache(c))
+
+	for i := 0; i < 2; i++ {
+		valid, err := v.Validate("94287082", secSha1, time.Unix(59, 0).UTC())
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+	require.Equal(t, 1, c.Len())
+}
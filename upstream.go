@@ -0,0 +1,76 @@
+package otp
+
+import "fmt"
+
+// upstreamAlgorithms lists the algorithms of upstream github.com/pquerna/otp,
+// by their value there.
+var upstreamAlgorithms = []Algorithm{
+	AlgorithmSHA1,
+	AlgorithmSHA256,
+	AlgorithmSHA512,
+	AlgorithmMD5,
+}
+
+// AlgorithmFromUpstream returns the Algorithm of a value of the Algorithm
+// type of upstream github.com/pquerna/otp, passed as an int.
+func AlgorithmFromUpstream(a int) (Algorithm, error) {
+	if a < 0 || a >= len(upstreamAlgorithms) {
+		return 0, &OptionError{Name: "Algorithm", Value: a, Err: ErrUnsupportedAlgorithm}
+	}
+	return upstreamAlgorithms[a], nil
+}
+
+// Upstream returns the value of a in the Algorithm type of upstream
+// github.com/pquerna/otp, which lacks the algorithms added since.
+func (a Algorithm) Upstream() (int, error) {
+	for i, v := range upstreamAlgorithms {
+		if a == v {
+			return i, nil
+		}
+	}
+	return 0, &OptionError{Name: "Algorithm", Value: a, Err: ErrUnsupportedAlgorithm}
+}
+
+// DigitsFromUpstream returns the Digits of a value of the Digits type of
+// upstream github.com/pquerna/otp, passed as an int.
+func DigitsFromUpstream(d int) (Digits, error) {
+	if err := Digits(d).Check(); err != nil {
+		return 0, err
+	}
+	return Digits(d), nil
+}
+
+// Upstream returns the value of d in the Digits type of upstream
+// github.com/pquerna/otp.
+func (d Digits) Upstream() (int, error) {
+	if err := d.Check(); err != nil {
+		return 0, err
+	}
+	return int(d), nil
+}
+
+// KeyFromUpstream returns the Key of an upstream github.com/pquerna/otp
+// Key, or of any value whose String method returns a Key URL.
+func KeyFromUpstream(k fmt.Stringer) (*Key, error) {
+	return NewKeyFromURL(k.String())
+}
+
+// UpstreamURL returns the URL of the key for NewKeyFromURL of upstream
+// github.com/pquerna/otp. Keys upstream would validate differently fail
+// with ErrInvalidOption: keys with an epoch or an expiry, and sealed keys.
+// Keys using an algorithm upstream lacks fail with ErrUnsupportedAlgorithm.
+func (k *Key) UpstreamURL() (string, error) {
+	if _, err := k.Algorithm().Upstream(); err != nil {
+		return "", err
+	}
+	if epoch := k.Epoch(); !epoch.IsZero() {
+		return "", &OptionError{Name: "Epoch", Value: epoch, Err: ErrInvalidOption}
+	}
+	if expiry, ok := k.Expiry(); ok {
+		return "", &OptionError{Name: "Expiry", Value: expiry, Err: ErrInvalidOption}
+	}
+	if k.Sealed() {
+		return "", ErrKeySealed
+	}
+	return k.String(), nil
+}
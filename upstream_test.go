@@ -0,0 +1,52 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// upstreamKey stands in for the Key of upstream github.com/pquerna/otp.
+type upstreamKey string
+
+func (k upstreamKey) String() string { return string(k) }
+
+func TestUpstream(t *testing.T) {
+	for i, want := range []Algorithm{AlgorithmSHA1, AlgorithmSHA256, AlgorithmSHA512, AlgorithmMD5} {
+		a, err := AlgorithmFromUpstream(i)
+		require.NoError(t, err)
+		require.Equal(t, want, a)
+
+		u, err := a.Upstream()
+		require.NoError(t, err)
+		require.Equal(t, i, u)
+	}
+	_, err := AlgorithmFromUpstream(4)
+	require.True(t, errors.Is(err, ErrUnsupportedAlgorithm))
+	_, err = AlgorithmSHA3_256.Upstream()
+	require.True(t, errors.Is(err, ErrUnsupportedAlgorithm))
+
+	d, err := DigitsFromUpstream(8)
+	require.NoError(t, err)
+	require.Equal(t, DigitsEight, d)
+	_, err = DigitsFromUpstream(4)
+	require.True(t, errors.Is(err, ErrUnsupportedDigits))
+
+	const url = "otpauth://totp/Example:alice?algorithm=SHA256&issuer=Example&secret=JBSWY3DPEHPK3PXP"
+	k, err := KeyFromUpstream(upstreamKey(url))
+	require.NoError(t, err)
+	require.Equal(t, AlgorithmSHA256, k.Algorithm())
+
+	u, err := k.UpstreamURL()
+	require.NoError(t, err)
+	require.Equal(t, url, u)
+
+	_, err = k.Clone(WithEpoch(time.Unix(1000, 0))).UpstreamURL()
+	require.True(t, errors.Is(err, ErrInvalidOption), "upstream ignores t0")
+
+	k.SetAlgorithm(AlgorithmSHA3_512)
+	_, err = k.UpstreamURL()
+	require.True(t, errors.Is(err, ErrUnsupportedAlgorithm))
+}
@@ -0,0 +1,23 @@
+/*
+Package otp is version 2 of github.com/pquerna/otp.
+
+Version 2 keeps the algorithms and the Key type of version 1 and redesigns
+the entry points around them:
+
+  - every generation and validation function takes a context.Context first;
+  - errors are always returned, never discarded;
+  - parameters are plain value structs, so an Options value can be built
+    once, copied freely and reused on hot paths;
+  - stores such as CounterStore and ReplayStore and hooks such as
+    Options.OnMatch are part of the options instead of being added through
+    separate functions.
+
+The types of version 1 are aliases here, so keys and errors can be passed
+between code using either version.
+
+TOTP functions are in the totp subpackage, HOTP functions in hotp.
+
+Version 2 lives in the same repository as version 1 and builds against it
+through a replace directive in its go.mod, so both are released together.
+*/
+package otp
@@ -0,0 +1,54 @@
+package otp
+
+import (
+	otp1 "github.com/pquerna/otp"
+)
+
+// The errors of version 1, shared so errors.Is matches across versions.
+var (
+	ErrValidateSecretInvalidBase32 = otp1.ErrValidateSecretInvalidBase32
+	ErrValidateInputInvalidLength  = otp1.ErrValidateInputInvalidLength
+	ErrValidateSkewTooLarge        = otp1.ErrValidateSkewTooLarge
+	ErrGenerateMissingIssuer       = otp1.ErrGenerateMissingIssuer
+	ErrGenerateMissingAccountName  = otp1.ErrGenerateMissingAccountName
+	ErrGenerateSecretTooShort      = otp1.ErrGenerateSecretTooShort
+	ErrInvalidURL                  = otp1.ErrInvalidURL
+	ErrInvalidOption               = otp1.ErrInvalidOption
+	ErrUnsupportedAlgorithm        = otp1.ErrUnsupportedAlgorithm
+	ErrUnsupportedDigits           = otp1.ErrUnsupportedDigits
+	ErrUnsupportedType             = otp1.ErrUnsupportedType
+	ErrKeyNotFound                 = otp1.ErrKeyNotFound
+	ErrStore                       = otp1.ErrStore
+	ErrIssuerMismatch              = otp1.ErrIssuerMismatch
+	ErrInvalidAccountName          = otp1.ErrInvalidAccountName
+	ErrRandFailure                 = otp1.ErrRandFailure
+	ErrRandStuck                   = otp1.ErrRandStuck
+	ErrPolicyViolation             = otp1.ErrPolicyViolation
+	ErrUnknownSecretVersion        = otp1.ErrUnknownSecretVersion
+	ErrKeyExpired                  = otp1.ErrKeyExpired
+	ErrValidateReplayed            = otp1.ErrValidateReplayed
+	ErrUnknownKEK                  = otp1.ErrUnknownKEK
+	ErrKeySealed                   = otp1.ErrKeySealed
+	ErrKeyNotSealed                = otp1.ErrKeyNotSealed
+)
+
+// OptionError records an option that failed validation.
+type OptionError = otp1.OptionError
+
+// GenerateError lists every invalid field of a request to generate a Key.
+type GenerateError = otp1.GenerateError
+
+// SecretError describes why a secret failed to decode as base32.
+type SecretError = otp1.SecretError
+
+// RandError reports a random source that failed to provide a secret.
+type RandError = otp1.RandError
+
+// StoreError records a failed store operation and its cause.
+type StoreError = otp1.StoreError
+
+// WrapStoreError prepares an error returned by a store for the callers of
+// a store-backed operation. See the version 1 function.
+func WrapStoreError(op, id string, err error) error {
+	return otp1.WrapStoreError(op, id, err)
+}
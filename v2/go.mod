@@ -0,0 +1,11 @@
+module github.com/pquerna/otp/v2
+
+go 1.12
+
+require (
+	github.com/pquerna/otp v1.3.0
+	github.com/stretchr/testify v1.3.0
+)
+
+// Build against the version 1 packages of this repository.
+replace github.com/pquerna/otp => ../
@@ -0,0 +1,121 @@
+// Package hotp implements the HMAC-based One-time Password Algorithm
+// (RFC 4226) with the context-first API of version 2.
+package hotp
+
+import (
+	"context"
+	"io"
+
+	"github.com/pquerna/otp/v2"
+
+	hotp1 "github.com/pquerna/otp/hotp"
+)
+
+// Options are the parameters of HOTP generation and validation. The zero
+// value uses 6 digits and SHA1.
+type Options struct {
+	// Digits of the passcode. Defaults to 6.
+	Digits otp.Digits
+	// Algorithm to use for HMAC. Defaults to SHA1.
+	Algorithm otp.Algorithm
+}
+
+// v1 returns the version 1 options for opts.
+func (opts Options) v1() hotp1.ValidateOpts {
+	if opts.Digits == 0 {
+		opts.Digits = otp.DefaultDigits
+	}
+	return hotp1.ValidateOpts{Digits: opts.Digits, Algorithm: opts.Algorithm}
+}
+
+// FromV1 returns the Options matching the version 1 options opts, so
+// callers can move to version 2 one call site at a time. The encoding and
+// normalization options of version 1 have no counterpart and are dropped.
+func FromV1(opts hotp1.ValidateOpts) Options {
+	return Options{Digits: opts.Digits, Algorithm: opts.Algorithm}
+}
+
+// ToV1 returns the version 1 options matching opts, for code still
+// calling version 1.
+func (opts Options) ToV1() hotp1.ValidateOpts {
+	return opts.v1()
+}
+
+// Validate checks passcode against secret at counter.
+func Validate(ctx context.Context, passcode string, counter uint64, secret string, opts Options) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	vopts := opts.v1()
+	if err := vopts.Digits.Check(); err != nil {
+		return false, err
+	}
+	if err := vopts.Algorithm.Check(); err != nil {
+		return false, err
+	}
+
+	return hotp1.ValidateCustom(passcode, counter, secret, vopts)
+}
+
+// GenerateCode returns the passcode for secret at counter.
+func GenerateCode(ctx context.Context, secret string, counter uint64, opts Options) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	vopts := opts.v1()
+	if err := vopts.Digits.Check(); err != nil {
+		return "", err
+	}
+
+	return hotp1.GenerateCodeCustom(secret, counter, vopts)
+}
+
+// Next consumes the next counter of a HOTP key from store and returns its
+// passcode. See otp.Key.NextCode.
+func Next(ctx context.Context, key *otp.Key, store otp.CounterStore) (string, error) {
+	return key.NextCode(ctx, store)
+}
+
+// GenerateRequest describes a Key to generate.
+type GenerateRequest struct {
+	// Name of the issuing Organization/Company.
+	Issuer string
+	// Name of the User's Account (eg, email address)
+	AccountName string
+	// Size in bytes of the generated Secret. Defaults to 10 bytes.
+	SecretSize uint
+	// Secret to store. Defaults to a randomly generated secret of SecretSize.
+	Secret []byte
+	// Reader to use for generating the secret. Defaults to crypto/rand.
+	Rand io.Reader
+	// Label builds the label shown by authenticator apps.
+	Label otp.LabelFunc
+	// Generate a personal-use key without an Issuer instead of failing.
+	AllowMissingIssuer bool
+	// Enforce a naming policy on AccountName, eg otp.ValidateEmail.
+	ValidateAccountName func(name string) error
+}
+
+// Generate creates a new HOTP Key using the digits and algorithm of opts.
+// Every invalid field is reported in an *otp.GenerateError.
+func Generate(ctx context.Context, req GenerateRequest, opts Options) (*otp.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return hotp1.Generate(hotp1.GenerateOpts{
+		Issuer:      req.Issuer,
+		AccountName: req.AccountName,
+		SecretSize:  req.SecretSize,
+		Secret:      req.Secret,
+		Digits:      opts.Digits,
+		Algorithm:   opts.Algorithm,
+		Rand:        req.Rand,
+		Label:       req.Label,
+
+		AllowMissingIssuer:  req.AllowMissingIssuer,
+		ValidateAccountName: req.ValidateAccountName,
+	})
+}
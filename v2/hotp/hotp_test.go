@@ -0,0 +1,54 @@
+package hotp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pquerna/otp/v2"
+	"github.com/stretchr/testify/require"
+
+	hotp1 "github.com/pquerna/otp/hotp"
+)
+
+// RFC 4226 Appendix D, secret "12345678901234567890".
+const secSha1 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+
+	code, err := GenerateCode(ctx, secSha1, 1, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "287082", code)
+
+	valid, err := Validate(ctx, "287082", 1, secSha1, Options{})
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	_, err = Validate(ctx, "287082", 1, secSha1, Options{Digits: 5})
+	require.Error(t, err)
+}
+
+func TestNext(t *testing.T) {
+	ctx := context.Background()
+
+	k, err := Generate(ctx, GenerateRequest{
+		Issuer:      "SnakeOil",
+		AccountName: "alice@example.com",
+		Secret:      []byte("12345678901234567890"),
+	}, Options{})
+	require.NoError(t, err)
+
+	var store otp.MemoryCounterStore
+	for _, want := range []string{"755224", "287082"} {
+		code, err := Next(ctx, k, &store)
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+}
+
+func TestV1Options(t *testing.T) {
+	opts := FromV1(hotp1.ValidateOpts{Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256, PadLeadingZeros: true})
+	require.Equal(t, Options{Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256}, opts)
+	require.Equal(t, hotp1.ValidateOpts{Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256}, opts.ToV1())
+	require.Equal(t, otp.DigitsSix, Options{}.ToV1().Digits)
+}
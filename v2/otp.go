@@ -0,0 +1,173 @@
+package otp
+
+import (
+	"time"
+
+	otp1 "github.com/pquerna/otp"
+)
+
+// Key represents an TOTP or HTOP key.
+type Key = otp1.Key
+
+// KeyOpts describes the components of a Key created with NewKey.
+type KeyOpts = otp1.KeyOpts
+
+// LabelFunc builds the label of a generated key.
+type LabelFunc = otp1.LabelFunc
+
+// Digits represents the number of digits present in the user's OTP passcode.
+type Digits = otp1.Digits
+
+// Algorithm represents the hashing function to use in the HMAC operation.
+type Algorithm = otp1.Algorithm
+
+// KeyStore persists enrolled keys by id.
+type KeyStore = otp1.KeyStore
+
+// CounterStore persists the counters of HOTP keys.
+type CounterStore = otp1.CounterStore
+
+// ReplayStore records accepted passcodes, so none is accepted twice.
+type ReplayStore = otp1.ReplayStore
+
+// AttemptStore counts validation attempts, for rate limiting.
+type AttemptStore = otp1.AttemptStore
+
+// KeyWrapper encrypts key secrets at rest.
+type KeyWrapper = otp1.KeyWrapper
+
+// EncryptedKeyStore is a KeyStore decorator encrypting secrets at rest.
+type EncryptedKeyStore = otp1.EncryptedKeyStore
+
+// NewEncryptedKeyStore returns a KeyStore keeping keys in store with their
+// secrets encrypted by wrapper.
+func NewEncryptedKeyStore(store KeyStore, wrapper KeyWrapper) *EncryptedKeyStore {
+	return otp1.NewEncryptedKeyStore(store, wrapper)
+}
+
+// KEK is a key encryption key of an AESWrapper.
+type KEK = otp1.KEK
+
+// AESWrapper is a KeyWrapper encrypting secrets with AES-GCM, supporting
+// KEK rotation.
+type AESWrapper = otp1.AESWrapper
+
+// NewAESWrapper returns an AESWrapper wrapping under current and also
+// unwrapping secrets wrapped under the previous KEKs.
+func NewAESWrapper(current KEK, previous ...KEK) (*AESWrapper, error) {
+	return otp1.NewAESWrapper(current, previous...)
+}
+
+// CachingKeyStore is a read-through KeyStore decorator caching hot keys.
+type CachingKeyStore = otp1.CachingKeyStore
+
+// NewCachingKeyStore returns a KeyStore caching up to size keys of store
+// for ttl.
+func NewCachingKeyStore(store KeyStore, size int, ttl time.Duration) *CachingKeyStore {
+	return otp1.NewCachingKeyStore(store, size, ttl)
+}
+
+// SecretResolver returns the secret of a version for a stored key.
+type SecretResolver = otp1.SecretResolver
+
+// SecretRouter is a KeyStore decorator resolving secrets by version.
+type SecretRouter = otp1.SecretRouter
+
+// NewSecretRouter returns a KeyStore keeping keys in store and resolving
+// their secret by version with resolve.
+func NewSecretRouter(store KeyStore, resolve SecretResolver) *SecretRouter {
+	return otp1.NewSecretRouter(store, resolve)
+}
+
+// MemoryKeyStore is a KeyStore held in memory.
+type MemoryKeyStore = otp1.MemoryKeyStore
+
+// MemoryCounterStore is a CounterStore held in memory.
+type MemoryCounterStore = otp1.MemoryCounterStore
+
+// MemoryAttemptStore is an AttemptStore held in memory.
+type MemoryAttemptStore = otp1.MemoryAttemptStore
+
+// TimeSource reports the current time from a trusted source.
+type TimeSource = otp1.TimeSource
+
+// Clock provides the current time.
+type Clock = otp1.Clock
+
+// ClockFunc adapts a function to a Clock.
+type ClockFunc = otp1.ClockFunc
+
+// SystemClock is the Clock reading the local wall clock.
+var SystemClock = otp1.SystemClock
+
+// SetDefaultClock replaces the Clock used when no time or Clock is given.
+// A nil c restores SystemClock.
+func SetDefaultClock(c Clock) {
+	otp1.SetDefaultClock(c)
+}
+
+// DefaultClock returns the Clock set with SetDefaultClock.
+func DefaultClock() Clock {
+	return otp1.DefaultClock()
+}
+
+const (
+	DigitsSix   = otp1.DigitsSix
+	DigitsSeven = otp1.DigitsSeven
+	DigitsEight = otp1.DigitsEight
+	DigitsNine  = otp1.DigitsNine
+	DigitsTen   = otp1.DigitsTen
+)
+
+const (
+	AlgorithmSHA1       = otp1.AlgorithmSHA1
+	AlgorithmSHA256     = otp1.AlgorithmSHA256
+	AlgorithmSHA512     = otp1.AlgorithmSHA512
+	AlgorithmMD5        = otp1.AlgorithmMD5
+	AlgorithmSHA224     = otp1.AlgorithmSHA224
+	AlgorithmSHA512_256 = otp1.AlgorithmSHA512_256
+	AlgorithmSHA3_256   = otp1.AlgorithmSHA3_256
+	AlgorithmSHA3_512   = otp1.AlgorithmSHA3_512
+)
+
+// The parameters of the Google-Authenticator compatible profile.
+const (
+	DefaultPeriod         = otp1.DefaultPeriod
+	DefaultSkew           = otp1.DefaultSkew
+	DefaultDigits         = otp1.DefaultDigits
+	DefaultAlgorithm      = otp1.DefaultAlgorithm
+	DefaultTOTPSecretSize = otp1.DefaultTOTPSecretSize
+	DefaultHOTPSecretSize = otp1.DefaultHOTPSecretSize
+)
+
+// Policy restricts the parameters of keys and validation.
+type Policy = otp1.Policy
+
+// NewKey creates a new Key from its components.
+func NewKey(opts KeyOpts) *Key {
+	return otp1.NewKey(opts)
+}
+
+// ParseOpt is an additional check NewKeyFromURL makes on a parsed Key.
+type ParseOpt = otp1.ParseOpt
+
+// StrictIssuer rejects URLs whose label and issuer parameter disagree.
+func StrictIssuer() ParseOpt {
+	return otp1.StrictIssuer()
+}
+
+// ValidateEmail accepts account names that are bare email addresses.
+func ValidateEmail(name string) error {
+	return otp1.ValidateEmail(name)
+}
+
+// ValidateUsername accepts account names of 1 to 64 ASCII letters, digits,
+// dots, underscores and hyphens.
+func ValidateUsername(name string) error {
+	return otp1.ValidateUsername(name)
+}
+
+// NewKeyFromURL creates a new Key from an TOTP or HOTP url.
+func NewKeyFromURL(orig string, parseOpts ...ParseOpt) (*Key, error) {
+	return otp1.NewKeyFromURL(orig, parseOpts...)
+}
@@ -0,0 +1,250 @@
+// Package totp implements the Time-based One-time Password Algorithm
+// (RFC 6238) with the context-first API of version 2.
+package totp
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp/v2"
+
+	otp1 "github.com/pquerna/otp"
+	hotp1 "github.com/pquerna/otp/hotp"
+	totp1 "github.com/pquerna/otp/totp"
+)
+
+// Options are the parameters of TOTP generation and validation. The zero
+// value uses 30 second periods, 6 digits and SHA1, and accepts only the
+// current period; DefaultOptions also accepts one period either side, as
+// Google Authenticator compatible servers do.
+type Options struct {
+	// Number of seconds a TOTP hash is valid for. Defaults to 30 seconds.
+	Period uint
+	// Periods before or after the current time to allow. Taken as is.
+	Skew uint
+	// Digits of the passcode. Defaults to 6.
+	Digits otp.Digits
+	// Algorithm to use for HMAC. Defaults to SHA1.
+	Algorithm otp.Algorithm
+	// Epoch is the T0 periods are counted from. Defaults to the Unix epoch.
+	Epoch time.Time
+	// Now returns the time to generate and validate at. Defaults to the
+	// Now method of otp.DefaultClock.
+	Now func() time.Time
+	// OnMatch is called with the offset, in periods, of every successful match.
+	OnMatch func(offset int)
+	// Replay records accepted passcodes by secret fingerprint. A passcode
+	// matched again fails with otp.ErrValidateReplayed. Nil disables
+	// replay protection.
+	Replay otp.ReplayStore
+}
+
+// DefaultOptions returns Options compatible with Google Authenticator.
+func DefaultOptions() Options {
+	return Options{
+		Period:    otp.DefaultPeriod,
+		Skew:      otp.DefaultSkew,
+		Digits:    otp.DefaultDigits,
+		Algorithm: otp.DefaultAlgorithm,
+	}
+}
+
+// filled returns opts with the zero Period and Digits replaced. Unlike
+// version 1, the package wide defaults of totp.StoreDefaults do not apply.
+func (opts Options) filled() Options {
+	if opts.Period == 0 {
+		opts.Period = otp.DefaultPeriod
+	}
+	if opts.Digits == 0 {
+		opts.Digits = otp.DefaultDigits
+	}
+	if opts.Now == nil {
+		opts.Now = otp.DefaultClock().Now
+	}
+	return opts
+}
+
+// FromV1 returns the Options matching the version 1 options opts, so
+// callers can move to version 2 one call site at a time. Version 1 applies
+// the package defaults to a zero Skew, which FromV1 resolves, and its
+// validation time is taken from Now.
+func FromV1(opts totp1.ValidateOpts) Options {
+	if opts.Skew == 0 {
+		opts.Skew = totp1.LoadDefaults().Skew
+	}
+	return Options{
+		Period:    opts.Period,
+		Skew:      opts.Skew,
+		Digits:    opts.Digits,
+		Algorithm: opts.Algorithm,
+		Epoch:     opts.Epoch,
+	}
+}
+
+// ToV1 returns the version 1 options matching opts, for code still
+// calling version 1. Now and OnMatch have no version 1 counterpart; pass
+// the time of Now to ValidateOpts.Validate instead. Version 1 cannot
+// express a zero Skew, which takes its package default.
+func (opts Options) ToV1() totp1.ValidateOpts {
+	opts = opts.filled()
+	return totp1.ValidateOpts{
+		Period:    opts.Period,
+		Skew:      opts.Skew,
+		Digits:    opts.Digits,
+		Algorithm: opts.Algorithm,
+		Epoch:     opts.Epoch,
+		MaxSkew:   opts.Skew,
+	}
+}
+
+// counter returns the counter of the period of Now.
+func (opts Options) counter() uint64 {
+	t := opts.Now().Unix()
+	if !opts.Epoch.IsZero() {
+		t -= opts.Epoch.Unix()
+	}
+	// Round down like version 1, also for times before the epoch.
+	period := int64(opts.Period)
+	c := t / period
+	if t%period < 0 {
+		c--
+	}
+	return uint64(c)
+}
+
+// generator returns the HOTP generator for secret and opts.
+func (opts Options) generator(secret string) (*hotp1.Generator, error) {
+	if err := opts.Algorithm.Check(); err != nil {
+		return nil, err
+	}
+	if err := opts.Digits.Check(); err != nil {
+		return nil, err
+	}
+
+	key, err := hotp1.DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return hotp1.NewGenerator(key, hotp1.ValidateOpts{
+		Digits:    opts.Digits,
+		Algorithm: opts.Algorithm,
+	}), nil
+}
+
+// Validate checks passcode against secret. The current period is tried
+// first, then the periods within Skew alternating forward and backward.
+func Validate(ctx context.Context, passcode, secret string, opts Options) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	opts = opts.filled()
+
+	passcode = strings.TrimSpace(passcode)
+	if len(passcode) != opts.Digits.Length() {
+		return false, otp.ErrValidateInputInvalidLength
+	}
+
+	g, err := opts.generator(secret)
+	if err != nil {
+		return false, err
+	}
+
+	// Every counter of the window is compared, see hotp.Generator.Match.
+	current := opts.counter()
+	counters := make([]uint64, 0, 2*opts.Skew+1)
+	counters = append(counters, current)
+	for i := uint64(1); i <= uint64(opts.Skew); i++ {
+		counters = append(counters, current+i, current-i)
+	}
+
+	counter, ok := g.Match([]byte(passcode), counters, otp1.CompareExact)
+	if !ok {
+		return false, nil
+	}
+
+	if opts.Replay != nil {
+		id := otp1.SecretFingerprint(secret)
+		first, err := opts.Replay.Use(ctx, id, counter)
+		if err != nil {
+			return false, otp.WrapStoreError("Use", id, err)
+		}
+		if !first {
+			return false, otp.ErrValidateReplayed
+		}
+	}
+	if opts.OnMatch != nil {
+		opts.OnMatch(int(int64(counter - current)))
+	}
+	return true, nil
+}
+
+// GenerateCode returns the passcode for secret.
+func GenerateCode(ctx context.Context, secret string, opts Options) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	opts = opts.filled()
+
+	g, err := opts.generator(secret)
+	if err != nil {
+		return "", err
+	}
+
+	counter := opts.counter()
+
+	return string(g.AppendCode(nil, counter)), nil
+}
+
+// GenerateRequest describes a Key to generate.
+type GenerateRequest struct {
+	// Name of the issuing Organization/Company.
+	Issuer string
+	// Name of the User's Account (eg, email address)
+	AccountName string
+	// Size in bytes of the generated Secret. Defaults to 20 bytes.
+	SecretSize uint
+	// Secret to store. Defaults to a randomly generated secret of SecretSize.
+	Secret []byte
+	// Reader to use for generating the secret. Defaults to crypto/rand.
+	Rand io.Reader
+	// Label builds the label shown by authenticator apps.
+	Label otp.LabelFunc
+	// Generate a personal-use key without an Issuer instead of failing.
+	AllowMissingIssuer bool
+	// Enforce a naming policy on AccountName, eg otp.ValidateEmail.
+	ValidateAccountName func(name string) error
+}
+
+// Generate creates a new TOTP Key using the period, digits and algorithm
+// of opts. Every invalid field is reported in an *otp.GenerateError.
+func Generate(ctx context.Context, req GenerateRequest, opts Options) (*otp.Key, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	opts = opts.filled()
+
+	gopts := []totp1.GenerateOpt{
+		totp1.WithIssuer(req.Issuer),
+		totp1.WithAccountName(req.AccountName),
+		totp1.WithGenPeriod(opts.Period),
+		totp1.WithGenDigits(opts.Digits),
+		totp1.WithGenAlgorithm(opts.Algorithm),
+		totp1.WithGenEpoch(opts.Epoch),
+		totp1.WithSecretSize(req.SecretSize),
+		totp1.WithSecret(req.Secret),
+		totp1.WithRandomGenerator(req.Rand),
+		totp1.WithLabel(req.Label),
+		totp1.WithAccountNameValidator(req.ValidateAccountName),
+	}
+	if req.AllowMissingIssuer {
+		gopts = append(gopts, totp1.WithoutIssuer())
+	}
+
+	return totp1.GenerateWithOpts(gopts...)
+}
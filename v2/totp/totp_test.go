@@ -0,0 +1,142 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/v2"
+	"github.com/stretchr/testify/require"
+
+	totp1 "github.com/pquerna/otp/totp"
+)
+
+// RFC 6238 Appendix B, SHA1 seed "12345678901234567890".
+const secSha1 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func at(ts int64) func() time.Time {
+	return func() time.Time { return time.Unix(ts, 0).UTC() }
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{Digits: otp.DigitsEight, Now: at(1111111109)}
+
+	code, err := GenerateCode(ctx, secSha1, opts)
+	require.NoError(t, err)
+	require.Equal(t, "07081804", code)
+
+	valid, err := Validate(ctx, "07081804", secSha1, opts)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	opts.Now = at(1111111109 + 30)
+	valid, err = Validate(ctx, "07081804", secSha1, opts)
+	require.NoError(t, err)
+	require.False(t, valid, "the zero Skew accepts only the current period")
+
+	var offset int
+	opts.Skew = 1
+	opts.OnMatch = func(o int) { offset = o }
+	valid, err = Validate(ctx, "07081804", secSha1, opts)
+	require.NoError(t, err)
+	require.True(t, valid)
+	require.Equal(t, -1, offset)
+}
+
+func TestErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Validate(ctx, "123456", secSha1, DefaultOptions())
+	require.Equal(t, context.Canceled, err)
+
+	_, err = Validate(context.Background(), "123456", "not base32!", DefaultOptions())
+	require.True(t, errors.Is(err, otp.ErrValidateSecretInvalidBase32))
+
+	_, err = Generate(context.Background(), GenerateRequest{}, DefaultOptions())
+	var genErr *otp.GenerateError
+	require.True(t, errors.As(err, &genErr))
+	require.Len(t, genErr.Fields, 2)
+}
+
+func TestGenerate(t *testing.T) {
+	k, err := Generate(context.Background(), GenerateRequest{
+		Issuer:      "SnakeOil",
+		AccountName: "alice@example.com",
+	}, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "SnakeOil", k.Issuer())
+	require.Equal(t, uint64(30), k.Period())
+	require.Equal(t, otp.DigitsSix, k.Digits())
+	require.Len(t, k.Secret(), 32)
+}
+
+func TestV1Options(t *testing.T) {
+	ctx := context.Background()
+	v1 := totp1.ValidateOpts{Period: 60, Skew: 2, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256}
+
+	opts := FromV1(v1)
+	require.Equal(t, Options{Period: 60, Skew: 2, Digits: otp.DigitsEight, Algorithm: otp.AlgorithmSHA256}, opts)
+
+	back := opts.ToV1()
+	require.Equal(t, uint(60), back.Period)
+	require.Equal(t, uint(2), back.Skew)
+
+	ts := time.Unix(1111111109, 0)
+	code, err := back.GenerateCode(secSha1, ts.Add(-120*time.Second))
+	require.NoError(t, err)
+	opts.Now = at(ts.Unix())
+	valid, err := Validate(ctx, code, secSha1, opts)
+	require.NoError(t, err)
+	require.True(t, valid)
+	valid, err = back.Validate(code, secSha1, ts)
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestEpoch(t *testing.T) {
+	ctx := context.Background()
+	code, err := GenerateCode(ctx, secSha1, Options{Digits: otp.DigitsEight, Now: at(1111111109 - 1000000015)})
+	require.NoError(t, err)
+
+	opts := Options{Digits: otp.DigitsEight, Epoch: time.Unix(1000000015, 0), Now: at(1111111109)}
+	valid, err := Validate(ctx, code, secSha1, opts)
+	require.NoError(t, err)
+	require.True(t, valid)
+	require.Equal(t, opts.Epoch, FromV1(opts.ToV1()).Epoch)
+
+	// Before the epoch, counters round down as in version 1.
+	opts.Now = at(1000000015 - 15)
+	code, err = GenerateCode(ctx, secSha1, opts)
+	require.NoError(t, err)
+	want, err := opts.ToV1().GenerateCode(secSha1, opts.Now())
+	require.NoError(t, err)
+	require.Equal(t, want, code)
+}
+
+// memReplay is a ReplayStore for tests only.
+type memReplay map[uint64]bool
+
+func (m memReplay) Use(ctx context.Context, id string, counter uint64) (bool, error) {
+	if m[counter] {
+		return false, nil
+	}
+	m[counter] = true
+	return true, nil
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{Digits: otp.DigitsEight, Skew: 1, Now: at(1111111109), Replay: memReplay{}}
+
+	valid, err := Validate(ctx, "07081804", secSha1, opts)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	opts.Now = at(1111111109 + 30)
+	valid, err = Validate(ctx, "07081804", secSha1, opts)
+	require.Equal(t, otp.ErrValidateReplayed, err)
+	require.False(t, valid)
+}
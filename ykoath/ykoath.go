@@ -0,0 +1,196 @@
+// Package ykoath converts keys into credentials of the YubiKey OATH applet
+// (YKOATH), so enrollment can target hardware authenticators: either as
+// the data of a PUT instruction sent to the applet, or as the arguments of
+// ykman.
+package ykoath
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strconv"
+
+	"github.com/pquerna/otp"
+)
+
+// ErrNameTooLong is returned for keys whose credential ID exceeds the 64
+// bytes the applet stores.
+var ErrNameTooLong = errors.New("Credential name longer than 64 bytes")
+
+// Credential types and algorithms, as encoded by the applet.
+const (
+	TypeHOTP byte = 0x10
+	TypeTOTP byte = 0x20
+
+	AlgorithmSHA1   byte = 0x01
+	AlgorithmSHA256 byte = 0x02
+	AlgorithmSHA512 byte = 0x03
+)
+
+// TLV tags of the PUT instruction.
+const (
+	tagName     = 0x71
+	tagKey      = 0x73
+	tagProperty = 0x78
+	tagIMF      = 0x7a
+
+	propRequireTouch = 0x02
+)
+
+const (
+	maxNameLength = 64
+	// minKeyLength is the shortest secret the applet accepts; shorter
+	// ones are padded with zeros, which leaves their HMAC unchanged.
+	minKeyLength = 14
+)
+
+// Credential is a key as stored by the applet.
+type Credential struct {
+	// ID is the name of the credential, see NewCredential.
+	ID        string
+	Type      byte
+	Algorithm byte
+	Digits    int
+	// Period of a TOTP credential, in seconds.
+	Period uint64
+	// Counter of a HOTP credential, its initial moving factor.
+	Counter uint32
+	// Secret as written to the applet, shortened and padded.
+	Secret []byte
+	// Touch requires touching the YubiKey to compute a code.
+	Touch bool
+}
+
+// Opt sets an option of NewCredential.
+type Opt func(c *Credential)
+
+// RequireTouch requires touching the YubiKey for every code.
+func RequireTouch() Opt {
+	return func(c *Credential) {
+		c.Touch = true
+	}
+}
+
+// NewCredential converts key into a Credential named as ykman does:
+// "issuer:account", prefixed with "period/" for TOTP keys whose period is
+// not 30 seconds. The applet supports 6 to 8 digits and SHA1, SHA256 and
+// SHA512; other keys fail with an *otp.OptionError.
+func NewCredential(key *otp.Key, opts ...Opt) (*Credential, error) {
+	c := &Credential{Digits: key.Digits().Length()}
+
+	if c.Digits < 6 || c.Digits > 8 {
+		return nil, &otp.OptionError{Name: "Digits", Value: key.Digits(), Err: otp.ErrUnsupportedDigits}
+	}
+
+	var newHash func() hash.Hash
+	switch key.Algorithm() {
+	case otp.AlgorithmSHA1:
+		c.Algorithm, newHash = AlgorithmSHA1, sha1.New
+	case otp.AlgorithmSHA256:
+		c.Algorithm, newHash = AlgorithmSHA256, sha256.New
+	case otp.AlgorithmSHA512:
+		c.Algorithm, newHash = AlgorithmSHA512, sha512.New
+	default:
+		return nil, &otp.OptionError{Name: "Algorithm", Value: key.Algorithm(), Err: otp.ErrUnsupportedAlgorithm}
+	}
+
+	switch key.Type() {
+	case "totp":
+		c.Type, c.Period = TypeTOTP, key.Period()
+	case "hotp":
+		if key.Counter() > 1<<32-1 {
+			return nil, &otp.OptionError{Name: "Counter", Value: key.Counter(), Err: otp.ErrInvalidOption}
+		}
+		c.Type, c.Counter = TypeHOTP, uint32(key.Counter())
+	default:
+		return nil, &otp.OptionError{Name: "Type", Value: key.Type(), Err: otp.ErrUnsupportedType}
+	}
+
+	c.ID = key.AccountName()
+	if key.Issuer() != "" {
+		c.ID = key.Issuer() + ":" + c.ID
+	}
+	if c.Type == TypeTOTP && c.Period != 30 {
+		c.ID = strconv.FormatUint(c.Period, 10) + "/" + c.ID
+	}
+	if len(c.ID) > maxNameLength {
+		return nil, &otp.OptionError{Name: "AccountName", Value: c.ID, Err: ErrNameTooLong}
+	}
+
+	secret, err := otp.DecodeSecret(key.Secret())
+	if err != nil {
+		return nil, err
+	}
+	c.Secret = shortenKey(secret, newHash)
+
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// shortenKey returns a secret with the same HMAC as secret that fits the
+// applet: secrets longer than the hash block are replaced by their hash,
+// as HMAC does, and short ones are padded with zeros.
+func shortenKey(secret []byte, newHash func() hash.Hash) []byte {
+	h := newHash()
+	if len(secret) > h.BlockSize() {
+		h.Write(secret)
+		secret = h.Sum(nil)
+	}
+	if len(secret) < minKeyLength {
+		secret = append(secret, make([]byte, minKeyLength-len(secret))...)
+	}
+	return append([]byte(nil), secret...)
+}
+
+// PutData returns the data of the PUT instruction storing c in the applet.
+func (c *Credential) PutData() []byte {
+	data := tlv(nil, tagName, []byte(c.ID))
+
+	key := append([]byte{c.Type | c.Algorithm, byte(c.Digits)}, c.Secret...)
+	data = tlv(data, tagKey, key)
+
+	if c.Touch {
+		data = append(data, tagProperty, propRequireTouch)
+	}
+	if c.Type == TypeHOTP && c.Counter != 0 {
+		var imf [4]byte
+		binary.BigEndian.PutUint32(imf[:], c.Counter)
+		data = tlv(data, tagIMF, imf[:])
+	}
+	return data
+}
+
+// tlv appends the tag, BER length and value to dst. Values are shorter
+// than 256 bytes.
+func tlv(dst []byte, tag byte, value []byte) []byte {
+	dst = append(dst, tag)
+	if len(value) >= 0x80 {
+		dst = append(dst, 0x81)
+	}
+	dst = append(dst, byte(len(value)))
+	return append(dst, value...)
+}
+
+// YkmanArgs returns the arguments of ykman adding the credential of key:
+//
+//	ykman oath accounts uri <url> [--touch]
+//
+// The URL holds the secret; avoid passing it on shared machines, where
+// command lines are visible to other users.
+func YkmanArgs(key *otp.Key, opts ...Opt) ([]string, error) {
+	c, err := NewCredential(key, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"oath", "accounts", "uri", key.URL()}
+	if c.Touch {
+		args = append(args, "--touch")
+	}
+	return args, nil
+}
@@ -0,0 +1,79 @@
+package ykoath
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pquerna/otp"
+	"github.com/stretchr/testify/require"
+)
+
+func key(t *testing.T, url string) *otp.Key {
+	k, err := otp.NewKeyFromURL(url)
+	require.NoError(t, err)
+	return k
+}
+
+func TestNewCredential(t *testing.T) {
+	c, err := NewCredential(key(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"))
+	require.NoError(t, err)
+	require.Equal(t, "Example:alice", c.ID)
+	require.Equal(t, TypeTOTP, c.Type)
+	require.Equal(t, AlgorithmSHA1, c.Algorithm)
+	require.Equal(t, 6, c.Digits)
+	// The 10 byte secret is padded to 14 bytes.
+	require.Equal(t, []byte("Hello!\xde\xad\xbe\xef\x00\x00\x00\x00"), c.Secret)
+	require.False(t, c.Touch)
+
+	c, err = NewCredential(key(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&period=60&algorithm=SHA256&digits=8"), RequireTouch())
+	require.NoError(t, err)
+	require.Equal(t, "60/Example:alice", c.ID)
+	require.Equal(t, AlgorithmSHA256, c.Algorithm)
+	require.True(t, c.Touch)
+}
+
+func TestNewCredentialUnsupported(t *testing.T) {
+	_, err := NewCredential(key(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&digits=10"))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedDigits))
+
+	_, err = NewCredential(key(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example&algorithm=MD5"))
+	require.True(t, errors.Is(err, otp.ErrUnsupportedAlgorithm))
+
+	long := strings.Repeat("a", 60)
+	_, err = NewCredential(key(t, "otpauth://totp/Example:"+long+"?secret=JBSWY3DPEHPK3PXP&issuer=Example"))
+	require.True(t, errors.Is(err, ErrNameTooLong))
+}
+
+func TestPutData(t *testing.T) {
+	c, err := NewCredential(key(t, "otpauth://hotp/Ex:al?secret=JBSWY3DPEHPK3PXP&issuer=Ex&counter=258"), RequireTouch())
+	require.NoError(t, err)
+
+	want := []byte{0x71, 5, 'E', 'x', ':', 'a', 'l', 0x73, 16, 0x11, 6}
+	want = append(want, c.Secret...)
+	want = append(want, 0x78, 0x02, 0x7a, 4, 0, 0, 1, 2)
+	require.Equal(t, want, c.PutData())
+}
+
+func TestLongSecret(t *testing.T) {
+	// A SHA512 secret of 130 bytes is hashed down to 64 bytes.
+	secret := strings.Repeat("A", 208)
+	c, err := NewCredential(key(t, "otpauth://totp/Ex:al?secret="+secret+"&issuer=Ex&algorithm=SHA512"))
+	require.NoError(t, err)
+	require.Len(t, c.Secret, 64)
+
+	// A 128 byte secret is kept and needs a two byte length.
+	secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(make([]byte, 128))
+	c, err = NewCredential(key(t, "otpauth://totp/Ex:al?secret="+secret+"&issuer=Ex&algorithm=SHA512"))
+	require.NoError(t, err)
+	require.Len(t, c.Secret, 128)
+	require.Equal(t, []byte{0x73, 0x81, 130}, c.PutData()[7:10])
+}
+
+func TestYkmanArgs(t *testing.T) {
+	k := key(t, "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+	args, err := YkmanArgs(k, RequireTouch())
+	require.NoError(t, err)
+	require.Equal(t, []string{"oath", "accounts", "uri", k.URL(), "--touch"}, args)
+}